@@ -4,7 +4,12 @@ go 1.23.0
 
 require (
 	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/mod v0.22.0
+	golang.org/x/tools v0.29.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
-require golang.org/x/sys v0.4.0 // indirect
+require (
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const activityTemplate = `// Code generated by next-gen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	{{.Info.SDKImport}}
+	{{range .Info.Imports}}{{.}}
+	{{end}}
+)
+
+// ServiceName is the registered name of the {{.Info.ServiceName}} service
+const ServiceName = "{{.Info.ServiceName}}"
+
+// TaskQueue is the task queue the activities of the {{.Info.ServiceName}} service are scheduled on,
+// empty for the default queue of the runtime. Set it at startup to route them to dedicated workers.
+var TaskQueue = ""
+
+// Caller schedules a service method as an activity of a workflow and decodes the result into output.
+// The workflow context of the runtime implements it, the activity is retried and replayed by the
+// workflow engine like any other.
+type Caller interface {
+	CallActivity(taskQueue string, service string, method string, input any, output any, policy polycode.Policy) error
+}
+
+// call schedules a method of the service through the workflow context
+func call(ctx polycode.WorkflowContext, method string, input any, output any, policy polycode.Policy) error {
+	caller, ok := ctx.(Caller)
+	if !ok {
+		return errors.New("workflow context does not support activity calls")
+	}
+	return caller.CallActivity(TaskQueue, ServiceName, method, input, output, policy)
+}
+{{range .Methods}}
+// {{.OriginalName}} calls the {{.OriginalName}} method of the {{$.Info.ServiceName}} service as an activity of a workflow
+{{- if or .Timeout .Retry}}, with the policy
+// declared by its //polycode:timeout and //polycode:retry directives{{end}}
+{{- if .Deprecated}}
+//
+// {{.Deprecated.Notice}}
+{{- end}}
+{{- if .HasOutput}}
+func {{.OriginalName}}(ctx polycode.WorkflowContext{{template "params" .}}) ({{if .IsOutputPointer}}*{{end}}{{.OutputType}}, error) {
+	var output {{.OutputType}}
+	if err := call(ctx, "{{.ExposedName}}", {{template "args" .}}, &output, {{template "policy" .}}); err != nil {
+		return {{if .IsOutputPointer}}nil{{else}}output{{end}}, err
+	}
+	return {{if .IsOutputPointer}}&{{end}}output, nil
+}
+{{- else}}
+func {{.OriginalName}}(ctx polycode.WorkflowContext{{template "params" .}}) error {
+	return call(ctx, "{{.ExposedName}}", {{template "args" .}}, nil, {{template "policy" .}})
+}
+{{- end}}
+{{end}}
+{{- define "params"}}{{if .IsMultiInput}}{{range .Params}}, {{.JSONName}} {{if .IsVariadic}}...{{slice .Type 2}}{{else}}{{.Type}}{{end}}{{end}}{{else if .HasInput}}, input {{if .IsInputPointer}}*{{end}}{{.InputType}}{{end}}{{end}}
+{{- define "args"}}{{if .IsMultiInput}}map[string]any{ {{- range $i, $p := .Params}}{{if $i}}, {{end}}"{{.JSONName}}": {{.JSONName}}{{end -}} }{{else if .HasInput}}input{{else}}nil{{end}}{{end}}
+{{- define "policy"}}polycode.Policy{ {{- with .Timeout}}Timeout: {{.Nanoseconds}}{{end}}{{if and .Timeout .Retry}}, {{end}}{{with .Retry}}Retry: &polycode.RetryPolicy{MaxAttempts: {{.MaxAttempts}}, Backoff: {{printf "%q" .Backoff}}{{with .Delay}}, InitialDelay: {{.Nanoseconds}}{{end}}}{{end -}} }{{end}}`
+
+// TargetActivities generates typed activity stubs for calling services from workflows
+const TargetActivities = "activities"
+
+// activityPackageName returns the Go package name of the activity stubs of a service
+func activityPackageName(serviceName string) string {
+	return strings.ToLower(strings.ReplaceAll(serviceFileName(serviceName), "-", "")) + "activity"
+}
+
+// activityTarget generates .polycode/activities/<service>activity packages. Only the unary service
+// methods are activities, workflows and streams are not called this way.
+type activityTarget struct{}
+
+func (activityTarget) Name() string {
+	return TargetActivities
+}
+
+func (activityTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	var methods []MethodInfo
+	for _, method := range info.Methods {
+		if method.IsService && !method.IsStreaming() {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+	// The stubs pass the policy of each method to the runtime
+	if !info.SDK.Policy {
+		return nil, fmt.Errorf("the %s target needs polycode.Policy, which the SDK version required by go.mod lacks, upgrade the SDK", TargetActivities)
+	}
+
+	tmpl, err := template.New("activity").Parse(stampVersion(activityTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	packageName := activityPackageName(info.ServiceName)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"PackageName": packageName,
+		"Info":        info,
+		"Methods":     methods,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate activities: %w", err)
+	}
+
+	return map[string][]byte{"activities/" + packageName + "/activity.go": buf.Bytes()}, nil
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// AnalyzerVet runs `go vet`, any other analyzer is run as a command that accepts package patterns
+const AnalyzerVet = "vet"
+
+// analysisPatterns returns the packages checked after generation, outputDirs are relative to the app root
+func analysisPatterns(outputDirs []string, opts Options) []string {
+	var patterns []string
+	for _, outputDir := range outputDirs {
+		patterns = append(patterns, "./"+filepath.ToSlash(filepath.Clean(outputDir)))
+	}
+	for _, root := range opts.ServicesDirs {
+		patterns = append(patterns, "./"+filepath.ToSlash(filepath.Clean(root))+"/...")
+	}
+	return patterns
+}
+
+// runAnalyzers runs the configured analyzers over the generated package and the services
+func runAnalyzers(appPath string, analyzers []string, patterns []string) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	for _, analyzer := range analyzers {
+		args := strings.Fields(analyzer)
+		if len(args) == 0 {
+			continue
+		}
+
+		var cmd *exec.Cmd
+		if args[0] == AnalyzerVet {
+			cmd = exec.Command("go", append(append([]string{"vet"}, args[1:]...), patterns...)...)
+		} else {
+			cmd = exec.Command(args[0], append(args[1:], patterns...)...)
+		}
+		cmd.Dir = appPath
+
+		output, err := cmd.CombinedOutput()
+		found := parseDiagnostics(string(output), args[0], appPath)
+		if err != nil && len(found) == 0 {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("failed to run analyzer %s: %w", args[0], err)
+			}
+			// The analyzer failed without reporting positions, keep its output as a single finding
+			found = append(found, Diagnostic{Source: args[0], Message: strings.TrimSpace(string(output))})
+		}
+		diags = append(diags, found...)
+	}
+	return diags, nil
+}
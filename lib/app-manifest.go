@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"time"
+)
+
+// appManifestName is the single manifest describing every service of the app
+const appManifestName = "app.yml"
+
+// AppManifest is the content of .polycode/app.yml
+type AppManifest struct {
+	Module           string            `yaml:"module"`
+	GeneratorVersion string            `yaml:"generatorVersion"`
+	GeneratedAt      string            `yaml:"generatedAt"`
+	Services         []ManifestService `yaml:"services"`
+}
+
+// ManifestService summarizes a service definition, Digest changes whenever the definition does
+type ManifestService struct {
+	Name       string           `yaml:"name"`
+	Definition string           `yaml:"definition"`
+	Digest     string           `yaml:"digest"`
+	Methods    []ManifestMethod `yaml:"methods"`
+}
+
+// ManifestMethod summarizes a method, SchemaDigest covers its input and output schemas
+type ManifestMethod struct {
+	Name         string `yaml:"name"`
+	IsWorkflow   bool   `yaml:"isWorkflow"`
+	InputType    string `yaml:"inputType,omitempty"`
+	OutputType   string `yaml:"outputType,omitempty"`
+	SchemaDigest string `yaml:"schemaDigest"`
+}
+
+// digest returns the sha256 of a value encoded as JSON
+func digest(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// writeAppManifest writes .polycode/app.yml from the service definitions in the output folder.
+// The file is left untouched when nothing but the timestamp would change.
+func writeAppManifest(output outputFS, outputPath string, moduleName string) error {
+	defs, err := loadServiceDefinitions(output, outputPath)
+	if err != nil {
+		return err
+	}
+
+	manifest := AppManifest{
+		Module:           moduleName,
+		GeneratorVersion: VersionString(),
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		Services:         []ManifestService{},
+	}
+	files, err := definitionFiles(output, outputPath)
+	if err != nil {
+		return err
+	}
+	for _, def := range defs {
+		service := ManifestService{
+			Name:       def.Name,
+			Definition: files[serviceFileName(def.Name)],
+			Methods:    []ManifestMethod{},
+		}
+		if service.Digest, err = digest(def); err != nil {
+			return err
+		}
+
+		for _, method := range def.Methods {
+			schemaDigest, err := digest([]any{method.InputType, method.InputSchema, method.OutputType, method.OutputSchema, def.Types})
+			if err != nil {
+				return err
+			}
+			service.Methods = append(service.Methods, ManifestMethod{
+				Name:         method.Name,
+				IsWorkflow:   method.IsWorkflow,
+				InputType:    method.InputType,
+				OutputType:   method.OutputType,
+				SchemaDigest: schemaDigest,
+			})
+		}
+		manifest.Services = append(manifest.Services, service)
+	}
+
+	manifestPath := filepath.Join(outputPath, appManifestName)
+	if data, err := output.ReadFile(manifestPath); err == nil {
+		var previous AppManifest
+		if yaml.Unmarshal(data, &previous) == nil {
+			unchanged := manifest
+			unchanged.GeneratedAt = previous.GeneratedAt
+			if current, err := yaml.Marshal(unchanged); err == nil && bytes.Equal(append([]byte(yamlHeader), current...), data) {
+				return nil
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", appManifestName, err)
+	}
+	return output.WriteFile(manifestPath, append([]byte(yamlHeader), data...), 0644)
+}
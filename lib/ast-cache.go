@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sync"
+	"time"
+)
+
+// astCache keeps the syntax trees parsed while loading the app, so later runs of the same process,
+// like watch mode regenerations, only parse the files that changed since. Trees are shared by every
+// load and never modified, type checking only reads them.
+var astCache = &parsedFiles{
+	fset:  token.NewFileSet(),
+	files: make(map[string]parsedFile),
+}
+
+// racyModTime is how recently a file may have been modified for its tree not to be cached. The loader
+// reads a file before it is parsed here, a write in between would leave a tree of the old content
+// under the new modification time, and coarse file system clocks hide writes within the same tick.
+const racyModTime = 2 * time.Second
+
+// maxFileSetBase is the size in bytes of the parsed source the file set of the cache may span
+const maxFileSetBase = 64 << 20
+
+// parsedFiles is a cache of syntax trees keyed by path, all positioned in the same file set
+type parsedFiles struct {
+	fset  *token.FileSet
+	mu    sync.Mutex
+	files map[string]parsedFile
+}
+
+// parsedFile is a cached syntax tree and the state of the file it was parsed from
+type parsedFile struct {
+	modTime time.Time
+	size    int64
+	file    *ast.File
+}
+
+// parse returns the syntax tree of a file, reusing the cached one while the modification time and
+// size of the file are unchanged. It has the signature of packages.Config.ParseFile and parses like
+// its default. Trees are only cached and reused for the current file set of the cache, a load started
+// before the file set was replaced parses every file.
+func (c *parsedFiles) parse(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	info, err := os.Stat(filename)
+	if err == nil {
+		c.mu.Lock()
+		cached, ok := c.files[filename]
+		current := fset == c.fset
+		c.mu.Unlock()
+		if current && ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() && int64(len(src)) == info.Size() {
+			return cached.file, nil
+		}
+	}
+
+	file, parseErr := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	// Files with syntax errors are parsed again, so their errors are reported on every run
+	if err == nil && parseErr == nil && time.Since(info.ModTime()) > racyModTime {
+		c.mu.Lock()
+		if fset == c.fset {
+			c.files[filename] = parsedFile{modTime: info.ModTime(), size: info.Size(), file: file}
+		}
+		c.mu.Unlock()
+	}
+	return file, parseErr
+}
+
+// fileSet returns the file set a load parses into. The trees of files that no longer exist are evicted,
+// and since the positions of replaced trees stay in the file set, it starts over empty along with the
+// cache once it spans more than maxFileSetBase.
+func (c *parsedFiles) fileSet() *token.FileSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fset.Base() > maxFileSetBase {
+		c.fset = token.NewFileSet()
+		clear(c.files)
+		return c.fset
+	}
+	for filename := range c.files {
+		if _, err := os.Stat(filename); err != nil {
+			delete(c.files, filename)
+		}
+	}
+	return c.fset
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+)
+
+// asyncAPIRef resolves struct types to the schemas under #/components/schemas
+func asyncAPIRef(schemas map[string]any) refFunc {
+	return func(name string) (string, bool) {
+		_, ok := schemas[name]
+		return "#/components/schemas/" + name, ok
+	}
+}
+
+// workflowDefinitions keeps the workflow methods of the definitions, dropping services without any
+func workflowDefinitions(defs []ServiceDefinition) []ServiceDefinition {
+	var workflows []ServiceDefinition
+	for _, def := range defs {
+		methods := []MethodDefinition{}
+		for _, method := range def.Methods {
+			if method.IsWorkflow {
+				methods = append(methods, method)
+			}
+		}
+		if len(methods) > 0 {
+			def.Methods = methods
+			workflows = append(workflows, def)
+		}
+	}
+	return workflows
+}
+
+// buildAsyncAPI builds an AsyncAPI 3.0 document describing each workflow as a trigger channel the
+// service receives its input on and a result channel it sends its output to
+func buildAsyncAPI(title string, defs []ServiceDefinition) yaml.MapSlice {
+	types := collectSchemaTypes(defs)
+	schemas := make(map[string]any)
+	for name := range types {
+		schemas[name] = nil
+	}
+	for name, fields := range types {
+		schemas[name] = structSchema(fields, asyncAPIRef(schemas), rootPointer("components", "schemas", name))
+	}
+
+	channels := make(map[string]any)
+	operations := make(map[string]any)
+	messages := make(map[string]any)
+	for _, def := range defs {
+		for _, method := range def.Methods {
+			prefix := def.Name + "." + method.Name
+			address := fmt.Sprintf("services/%s/workflows/%s", def.Name, method.Name)
+
+			add := func(kind string, action string, payloadType string, summary string) {
+				id := prefix + "." + kind
+				message := map[string]any{
+					"name":        id,
+					"title":       summary,
+					"contentType": "application/json",
+				}
+				if payloadType != "" {
+					message["payload"] = jsonSchema(payloadType, asyncAPIRef(schemas))
+				}
+				messages[id] = message
+
+				channels[id] = map[string]any{
+					"address":  address + "/" + kind,
+					"messages": map[string]any{id: map[string]any{"$ref": "#/components/messages/" + id}},
+				}
+
+				operation := map[string]any{
+					"action":   action,
+					"channel":  map[string]any{"$ref": "#/channels/" + id},
+					"summary":  summary,
+					"messages": []any{map[string]any{"$ref": "#/channels/" + id + "/messages/" + id}},
+				}
+				if method.Doc != "" {
+					operation["description"] = method.Doc
+				}
+				operations[id] = operation
+			}
+
+			description := method.Description
+			if description == "" {
+				description = method.Name
+			}
+			add("trigger", "receive", method.InputType, "Starts "+description)
+			add("result", "send", method.OutputType, "Result of "+description)
+		}
+	}
+
+	return yaml.MapSlice{
+		{Key: "asyncapi", Value: "3.0.0"},
+		{Key: "info", Value: map[string]any{"title": title, "version": "1.0.0"}},
+		{Key: "defaultContentType", Value: "application/json"},
+		{Key: "channels", Value: channels},
+		{Key: "operations", Value: operations},
+		{Key: "components", Value: map[string]any{"messages": messages, "schemas": schemas}},
+	}
+}
+
+// writeAsyncAPISpecs writes an AsyncAPI document per service with workflows and a merged one for the app
+func writeAsyncAPISpecs(output outputFS, outputPath string, moduleName string, defs []ServiceDefinition) error {
+	asyncAPIFolder := filepath.Join(outputPath, "asyncapi")
+	err := output.MkdirAll(asyncAPIFolder, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create asyncapi folder: %w", err)
+	}
+
+	write := func(name string, doc yaml.MapSlice) error {
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal asyncapi document: %w", err)
+		}
+		return output.WriteFile(filepath.Join(asyncAPIFolder, name), append([]byte(yamlHeader), data...), 0644)
+	}
+
+	workflows := workflowDefinitions(defs)
+	keep := map[string]bool{"asyncapi.yml": true}
+	for _, def := range workflows {
+		if err = write(serviceFileName(def.Name)+".yml", buildAsyncAPI(def.Name, []ServiceDefinition{def})); err != nil {
+			return err
+		}
+		keep[serviceFileName(def.Name)+".yml"] = true
+	}
+	if err = write("asyncapi.yml", buildAsyncAPI(moduleName, workflows)); err != nil {
+		return err
+	}
+	return removeUnlisted(output, asyncAPIFolder, "*.yml", keep)
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// authSupportName holds the authorization policy type shared by the wrappers of all services
+const authSupportName = "auth.go"
+
+const authSupport = `// Code generated by next-gen. DO NOT EDIT.
+package %s
+
+// AuthPolicy is the authorization a method declares with //polycode:auth, returned by GetAuthPolicy
+type AuthPolicy struct {
+	// Roles lists the roles allowed to call the method, any one of them is enough
+	Roles []string ` + "`json:\"roles\"`" + `
+}
+
+// Allows reports whether a caller holding roles may call the method, a nil policy allows everyone
+func (p *AuthPolicy) Allows(roles []string) bool {
+	if p == nil {
+		return true
+	}
+	for _, role := range roles {
+		for _, allowed := range p.Roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+`
+
+// AuthPolicy is the authorization declared by //polycode:auth roles=admin,billing
+type AuthPolicy struct {
+	Roles []string `yaml:"roles" json:"roles"`
+}
+
+// parseAuthPolicy reads the arguments of a //polycode:auth directive
+func parseAuthPolicy(args string) (*AuthPolicy, error) {
+	policy := &AuthPolicy{}
+	for key, value := range parseDirectiveArgs(args) {
+		if key != "roles" {
+			return nil, fmt.Errorf("//polycode:auth: unknown option %q, expected roles=<role>,...", key)
+		}
+		for _, role := range strings.Split(value, ",") {
+			if role = strings.TrimSpace(role); role != "" && role != "true" && !slices.Contains(policy.Roles, role) {
+				policy.Roles = append(policy.Roles, role)
+			}
+		}
+	}
+	if len(policy.Roles) == 0 {
+		return nil, fmt.Errorf("//polycode:auth requires at least one role, like roles=admin")
+	}
+	return policy, nil
+}
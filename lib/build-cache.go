@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// buildCacheName stores a hash of the inputs of each service, unchanged services are not regenerated
+const buildCacheName = "cache.json"
+
+// buildCache maps each service to the hash of the inputs its outputs were generated from
+type buildCache struct {
+	mu       sync.Mutex
+	Services map[string]string `json:"services"`
+}
+
+// loadBuildCache reads the cache of an output folder, a missing or unreadable cache is empty
+func loadBuildCache(output outputFS, outputPath string) *buildCache {
+	cache := &buildCache{Services: make(map[string]string)}
+
+	data, err := output.ReadFile(filepath.Join(outputPath, buildCacheName))
+	if err != nil || json.Unmarshal(data, cache) != nil || cache.Services == nil {
+		// The cache only saves work, start over instead of failing
+		cache.Services = make(map[string]string)
+	}
+	return cache
+}
+
+// unchanged reports whether a service was last generated from the same inputs
+func (c *buildCache) unchanged(serviceName string, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Services[serviceName] == hash
+}
+
+func (c *buildCache) set(serviceName string, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Services[serviceName] = hash
+}
+
+// save writes the cache into the output folder, dropping services that no longer exist
+func (c *buildCache) save(output outputFS, outputPath string, services map[string]bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.Services {
+		if !services[name] {
+			delete(c.Services, name)
+		}
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", buildCacheName, err)
+	}
+	return output.WriteFile(filepath.Join(outputPath, buildCacheName), append(data, '\n'), 0644)
+}
+
+var (
+	executableOnce sync.Once
+	executableID   string
+)
+
+// executableIdentity identifies the running next-gen build, so upgrading it invalidates the cache
+func executableIdentity() string {
+	executableOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+		if info, err := os.Stat(path); err == nil {
+			executableID = fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+		}
+	})
+	return executableID
+}
+
+// serviceHash hashes everything the outputs of a service are generated from: the parsed service,
+// its definition with the schemas of the shared types, the selected generators and the options
+func serviceHash(info ServiceInfo, def ServiceDefinition, opts Options) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintln(hash, executableIdentity())
+
+	encoder := json.NewEncoder(hash)
+	for _, value := range []any{info, def, opts.Targets, opts.GenTests, opts.DefinitionFormats, signingKeyID(opts.signingKey)} {
+		if err := encoder.Encode(value); err != nil {
+			return "", err
+		}
+	}
+
+	for _, name := range opts.Targets {
+		generator, err := resolveGenerator(name, opts)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%T %+v\n", generator, generator)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// outputsExist reports whether all files recorded for a service are still present
+func outputsExist(output outputFS, outputPath string, files []string) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if !output.Exists(filepath.Join(outputPath, file)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,335 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// CBOR major types, RFC 8949
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// marshalCBOR encodes a value the way encoding/json sees it, honouring the json tags, as deterministic
+// CBOR: map keys are sorted by their encoding, integers and floats use their shortest form
+func marshalCBOR(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err = decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = encodeCBOR(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalCBOR decodes CBOR written by marshalCBOR into value, through its json tags
+func unmarshalCBOR(data []byte, value any) error {
+	d := &cborDecoder{data: data}
+	generic, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return fmt.Errorf("cbor: %d trailing bytes", len(data)-d.pos)
+	}
+
+	data, err = json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func encodeCBOR(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | 22)
+	case bool:
+		if v {
+			buf.WriteByte(cborSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborSimple<<5 | 20)
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			if n >= 0 {
+				writeCBORHead(buf, cborUnsigned, uint64(n))
+			} else {
+				writeCBORHead(buf, cborNegative, uint64(-(n + 1)))
+			}
+			return nil
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			writeCBORHead(buf, cborUnsigned, n)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		writeCBORFloat(buf, f)
+	case []any:
+		writeCBORHead(buf, cborArray, uint64(len(v)))
+		for _, element := range v {
+			if err := encodeCBOR(buf, element); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		// Deterministic encoding orders the keys by their encoded bytes
+		type entry struct {
+			key     string
+			encoded []byte
+		}
+		entries := make([]entry, 0, len(v))
+		for key := range v {
+			var encoded bytes.Buffer
+			writeCBORHead(&encoded, cborText, uint64(len(key)))
+			encoded.WriteString(key)
+			entries = append(entries, entry{key, encoded.Bytes()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].encoded, entries[j].encoded) < 0
+		})
+
+		writeCBORHead(buf, cborMap, uint64(len(v)))
+		for _, e := range entries {
+			buf.Write(e.encoded)
+			if err := encodeCBOR(buf, v[e.key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %T", value)
+	}
+	return nil
+}
+
+// writeCBORHead writes the major type with its argument in the shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{major<<5 | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// writeCBORFloat writes a float in the shortest of the half, single and double precision forms holding it exactly
+func writeCBORFloat(buf *bytes.Buffer, f float64) {
+	f32 := float32(f)
+	if float64(f32) != f && !math.IsNaN(f) {
+		buf.WriteByte(cborSimple<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		return
+	}
+	if h, ok := floatToHalf(f32); ok {
+		buf.WriteByte(cborSimple<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, h)
+		return
+	}
+	buf.WriteByte(cborSimple<<5 | 26)
+	_ = binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+}
+
+// floatToHalf converts a single precision float to half precision when no precision is lost, NaN
+// becomes the quiet NaN 0x7e00
+func floatToHalf(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp, frac := int(bits>>23&0xff), bits&0x7fffff
+	switch {
+	case exp == 0xff && frac != 0:
+		return 0x7e00, true
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && frac == 0:
+		return sign, true
+	}
+
+	e := exp - 127
+	switch {
+	case exp == 0:
+		// Single precision subnormals are below the smallest half
+		return 0, false
+	case e >= -14 && e <= 15:
+		if frac&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(frac>>13), true
+	case e >= -24 && e < -14:
+		// Half precision subnormals are multiples of 2^-24
+		mant, shift := frac|0x800000, uint(-e-1)
+		if mant&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(mant>>shift), true
+	}
+	return 0, false
+}
+
+// cborDecoder decodes definite length CBOR into the values encoding/json produces
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the major type and argument of the next item
+func (d *cborDecoder) head() (major byte, n uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	size := 0
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+	if d.pos+size > len(d.data) {
+		return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+	}
+	for _, b := range d.data[d.pos : d.pos+size] {
+		n = n<<8 | uint64(b)
+	}
+	d.pos += size
+	return major, n, nil
+}
+
+func (d *cborDecoder) decode() (any, error) {
+	start := d.pos
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case cborNegative:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: negative integer out of range")
+		}
+		return json.Number(strconv.FormatInt(-int64(n)-1, 10)), nil
+	case cborBytes, cborText:
+		if uint64(len(d.data)-d.pos) < n {
+			return nil, fmt.Errorf("cbor: unexpected end of data")
+		}
+		value := d.data[d.pos : d.pos+int(n)]
+		d.pos += int(n)
+		if major == cborBytes {
+			return append([]byte(nil), value...), nil
+		}
+		return string(value), nil
+	case cborArray:
+		list := make([]any, 0, min(n, uint64(len(d.data))))
+		for i := uint64(0); i < n; i++ {
+			element, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, element)
+		}
+		return list, nil
+	case cborMap:
+		object := make(map[string]any)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %v is not a string", key)
+			}
+			if object[name], err = d.decode(); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case cborTag:
+		// Tags annotate the item that follows, the item is kept as is
+		return d.decode()
+	default:
+		info := d.data[start] & 0x1f
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			return json.Number(strconv.FormatFloat(float64(halfToFloat(uint16(n))), 'g', -1, 64)), nil
+		case 26:
+			// Formatted as the float64 it widens to, the shortest float32 text would parse to another float64
+			return json.Number(strconv.FormatFloat(float64(math.Float32frombits(uint32(n))), 'g', -1, 64)), nil
+		case 27:
+			return json.Number(strconv.FormatFloat(math.Float64frombits(n), 'g', -1, 64)), nil
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+}
+
+// halfToFloat converts an IEEE 754 half precision float
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		f := float32(frac) / 1024 * float32(math.Pow(2, -14))
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
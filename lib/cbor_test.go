@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// cborVectors are the examples of RFC 8949 Appendix A that JSON values can express, with their
+// preferred serialization
+var cborVectors = []struct {
+	value any
+	hex   string
+}{
+	{json.Number("0"), "00"},
+	{json.Number("1"), "01"},
+	{json.Number("10"), "0a"},
+	{json.Number("23"), "17"},
+	{json.Number("24"), "1818"},
+	{json.Number("25"), "1819"},
+	{json.Number("100"), "1864"},
+	{json.Number("1000"), "1903e8"},
+	{json.Number("1000000"), "1a000f4240"},
+	{json.Number("1000000000000"), "1b000000e8d4a51000"},
+	{json.Number("18446744073709551615"), "1bffffffffffffffff"},
+	{json.Number("-1"), "20"},
+	{json.Number("-10"), "29"},
+	{json.Number("-100"), "3863"},
+	{json.Number("-1000"), "3903e7"},
+	{json.Number("0.0"), "f90000"},
+	{json.Number("-0.0"), "f98000"},
+	{json.Number("1.0"), "f93c00"},
+	{json.Number("1.1"), "fb3ff199999999999a"},
+	{json.Number("1.5"), "f93e00"},
+	{json.Number("65504.0"), "f97bff"},
+	{json.Number("100000.0"), "fa47c35000"},
+	{json.Number("3.4028234663852886e+38"), "fa7f7fffff"},
+	{json.Number("1.0e+300"), "fb7e37e43c8800759c"},
+	{json.Number("5.960464477539063e-8"), "f90001"},
+	{json.Number("0.00006103515625"), "f90400"},
+	{json.Number("-4.0"), "f9c400"},
+	{json.Number("-4.1"), "fbc010666666666666"},
+	{false, "f4"},
+	{true, "f5"},
+	{nil, "f6"},
+	{"", "60"},
+	{"a", "6161"},
+	{"IETF", "6449455446"},
+	{"\"\\", "62225c"},
+	{"ü", "62c3bc"},
+	{"水", "63e6b0b4"},
+	{[]any{}, "80"},
+	{[]any{json.Number("1"), json.Number("2"), json.Number("3")}, "83010203"},
+	{[]any{json.Number("1"), []any{json.Number("2"), json.Number("3")}, []any{json.Number("4"), json.Number("5")}}, "8301820203820405"},
+	{map[string]any{}, "a0"},
+	{map[string]any{"a": json.Number("1"), "b": []any{json.Number("2"), json.Number("3")}}, "a26161016162820203"},
+	{[]any{"a", map[string]any{"b": "c"}}, "826161a161626163"},
+	{map[string]any{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, "a56161614161626142616361436164614461656145"},
+}
+
+func TestEncodeCBORVectors(t *testing.T) {
+	for _, tt := range cborVectors {
+		var buf bytes.Buffer
+		if err := encodeCBOR(&buf, tt.value); err != nil {
+			t.Errorf("%v: %v", tt.value, err)
+			continue
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != tt.hex {
+			t.Errorf("%v: got %s, want %s", tt.value, got, tt.hex)
+		}
+	}
+}
+
+func TestDecodeCBORVectors(t *testing.T) {
+	for _, tt := range cborVectors {
+		data, _ := hex.DecodeString(tt.hex)
+		d := &cborDecoder{data: data}
+		got, err := d.decode()
+		if err != nil {
+			t.Errorf("%s: %v", tt.hex, err)
+			continue
+		}
+		if d.pos != len(data) {
+			t.Errorf("%s: %d bytes left", tt.hex, len(data)-d.pos)
+		}
+		// Numbers are compared by value, 1.0 decodes as 1
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(tt.value)
+		if !bytes.Equal(normalizeJSON(t, gotJSON), normalizeJSON(t, wantJSON)) {
+			t.Errorf("%s: got %s, want %s", tt.hex, gotJSON, wantJSON)
+		}
+	}
+}
+
+// normalizeJSON decodes numbers as float64 so equal values compare equal whatever their notation
+func normalizeJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeCBORNumbers(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want string
+	}{
+		{hex: "f93c00", want: "1"},
+		{hex: "f9c400", want: "-4"},
+		{hex: "f90001", want: "5.960464477539063e-08"},
+		{hex: "fa47c35000", want: "100000"},
+		// Tags are skipped, 1(1363896240) is an epoch date
+		{hex: "c11a514b67b0", want: "1363896240"},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		got, err := (&cborDecoder{data: data}).decode()
+		if err != nil {
+			t.Errorf("%s: %v", tt.hex, err)
+			continue
+		}
+		if got != json.Number(tt.want) {
+			t.Errorf("%s: got %v, want %s", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalCBORErrors(t *testing.T) {
+	tests := []struct {
+		hex     string
+		wantErr string
+	}{
+		{hex: "", wantErr: "unexpected end of data"},
+		{hex: "1a0000", wantErr: "unexpected end of data"},
+		{hex: "6461", wantErr: "unexpected end of data"},
+		{hex: "0101", wantErr: "1 trailing bytes"},
+		{hex: "a10102", wantErr: "map key 1 is not a string"},
+		{hex: "9f01ff", wantErr: "unsupported additional information 31"},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		var value any
+		err := unmarshalCBOR(data, &value)
+		if err == nil || !bytes.Contains([]byte(err.Error()), []byte(tt.wantErr)) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.hex, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	def := ServiceDefinition{
+		Name:             "orders",
+		GeneratorVersion: "v1.2.3",
+		Methods: []MethodDefinition{{
+			Name:        "Create",
+			InputType:   "models.Order",
+			InputSchema: []Field{{Name: "Total", Type: "float64", Tag: `json:"total"`}, {Name: "Count", Type: "int", Optional: true}},
+			Timeout:     "1.5s",
+		}},
+		Types: map[string][]Field{"models.Order": {{Name: "Note", Type: "string", Doc: "Free text, ü and 水"}}},
+	}
+	data, err := marshalCBOR(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ServiceDefinition
+	if err = unmarshalCBOR(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, def) {
+		t.Errorf("got %+v, want %+v", got, def)
+	}
+
+	// The encoding is deterministic
+	again, err := marshalCBOR(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("two encodings of the same definition differ")
+	}
+}
+
+func TestCBORRoundTripNumbers(t *testing.T) {
+	values := []float64{0, 0.5, -2.75, 1.1, 65504, 100000, 3.4028234663852886e+38, 1e300, 5.960464477539063e-8, 1e-7}
+	for _, value := range values {
+		data, err := marshalCBOR(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got float64
+		if err = unmarshalCBOR(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != value {
+			t.Errorf("%v: got %v back from %x", value, got, data)
+		}
+	}
+}
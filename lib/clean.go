@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// generatedMarker starts the header of every file next-gen writes
+const generatedMarker = "Code generated by next-gen"
+
+// CleanOutput removes the files next-gen owns from the output folder: files carrying the generated
+// header and the files recorded in generated.yml. Anything else is kept and reported, so hand-written
+// files that slipped into the folder survive. With dryRun nothing is removed.
+func CleanOutput(outputPath string, dryRun bool) (removed []string, kept []string, err error) {
+	if _, err = os.Stat(outputPath); os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if !dryRun {
+		lock, err := lockOutput(context.Background(), diskFS{}, outputPath, DefaultLockTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer lock.unlock()
+	}
+
+	record, err := loadGeneratedFiles(diskFS{}, outputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := map[string]bool{generatedFilesName: true, buildCacheName: true, reportName: true}
+	for _, files := range record.Services {
+		for _, file := range files {
+			owned[file] = true
+		}
+	}
+
+	err = filepath.WalkDir(outputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		generated, err := hasGeneratedMarker(path)
+		if err != nil {
+			return err
+		}
+		if generated || owned[rel] {
+			removed = append(removed, rel)
+		} else {
+			kept = append(kept, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(removed)
+	sort.Strings(kept)
+
+	if dryRun {
+		return removed, kept, nil
+	}
+	for _, file := range removed {
+		if err = removeGeneratedFile(diskFS{}, outputPath, file); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Only succeeds when nothing was kept
+	_ = os.Remove(outputPath)
+	return removed, kept, nil
+}
+
+// hasGeneratedMarker reports whether the generated header appears at the start of a file
+func hasGeneratedMarker(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return bytes.Contains(head[:n], []byte(generatedMarker)), nil
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// generatedFilesName records which output files belong to which service so stale ones can be removed
+const generatedFilesName = "generated.yml"
+
+// generatedFiles maps each service to the files generated for it, relative to the output folder
+type generatedFiles struct {
+	Services map[string][]string `yaml:"services"`
+}
+
+// loadGeneratedFiles reads the generated file record of an output folder. Output folders written
+// before the record existed are seeded from their definitions and Go wrappers.
+func loadGeneratedFiles(output outputFS, outputPath string) (generatedFiles, error) {
+	record := generatedFiles{Services: make(map[string][]string)}
+
+	data, err := output.ReadFile(filepath.Join(outputPath, generatedFilesName))
+	if err == nil {
+		if err = yaml.Unmarshal(data, &record); err != nil {
+			return record, fmt.Errorf("failed to parse %s: %w", generatedFilesName, err)
+		}
+		if record.Services == nil {
+			record.Services = make(map[string][]string)
+		}
+		return record, nil
+	} else if !os.IsNotExist(err) {
+		return record, err
+	}
+
+	definitions, err := definitionFiles(output, outputPath)
+	if err != nil {
+		return record, err
+	}
+	for name, definition := range definitions {
+		record.Services[name] = []string{name + ".go", definition}
+	}
+	return record, nil
+}
+
+// save writes the record into the output folder
+func (g generatedFiles) save(output outputFS, outputPath string) error {
+	for _, files := range g.Services {
+		sort.Strings(files)
+	}
+
+	data, err := yaml.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", generatedFilesName, err)
+	}
+	return output.WriteFile(filepath.Join(outputPath, generatedFilesName), append([]byte(yamlHeader), data...), 0644)
+}
+
+// update records the files now generated for a service and removes the ones it no longer produces
+func (g generatedFiles) update(output outputFS, outputPath string, serviceName string, files []string) error {
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		keep[file] = true
+	}
+
+	for _, file := range g.Services[serviceName] {
+		if !keep[file] {
+			if err := removeGeneratedFile(output, outputPath, file); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		delete(g.Services, serviceName)
+	} else {
+		g.Services[serviceName] = files
+	}
+	return nil
+}
+
+// removeStale removes the files of recorded services that are not in services
+func (g generatedFiles) removeStale(output outputFS, outputPath string, services map[string]bool) error {
+	for serviceName := range g.Services {
+		if services[serviceName] {
+			continue
+		}
+
+		slog.Info("Removing generated files of deleted service", "service", serviceName)
+		if err := g.update(output, outputPath, serviceName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGeneratedFile deletes a generated file and any folders it leaves empty inside the output folder.
+// generated.yml may be edited by hand, files it lists outside of the output folder are left alone.
+func removeGeneratedFile(output outputFS, outputPath string, file string) error {
+	path := filepath.Join(outputPath, filepath.FromSlash(file))
+	if !filepath.IsLocal(filepath.FromSlash(file)) || path == filepath.Clean(outputPath) || !isInside(path, filepath.Clean(outputPath)) {
+		slog.Warn("Skipped removing a file outside of the output folder, check "+generatedFilesName, "file", file)
+		return nil
+	}
+	if err := output.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale file %s: %w", path, err)
+	}
+
+	for dir := filepath.Dir(path); dir != outputPath && isInside(dir, outputPath); dir = filepath.Dir(dir) {
+		// Remove fails on folders that still have content, which ends the walk up
+		if output.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// removeUnlisted deletes files matching pattern in dir whose base name is not in keep
+func removeUnlisted(output outputFS, dir string, pattern string, keep map[string]bool) error {
+	files, err := output.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !keep[filepath.Base(file)] {
+			if err = output.Remove(file); err != nil {
+				return fmt.Errorf("failed to remove stale file %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
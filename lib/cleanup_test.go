@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveGeneratedFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		removed bool // The file is removed, otherwise it is left alone
+	}{
+		{name: "wrapper", file: "orders.go", removed: true},
+		{name: "nested", file: "client/orders/client.go", removed: true},
+		{name: "parent folder", file: "../app.go"},
+		{name: "parent folder after cleaning", file: "client/../../app.go"},
+		{name: "output folder itself", file: "."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := t.TempDir()
+			outputPath := filepath.Join(app, ".polycode")
+			target := filepath.Join(outputPath, filepath.FromSlash(tt.file))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(outputPath, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.file != "." {
+				if err := os.WriteFile(target, []byte("package x\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := removeGeneratedFile(diskFS{}, outputPath, tt.file); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(target)
+			if removed := os.IsNotExist(err); removed != tt.removed {
+				t.Errorf("removed is %v, want %v", removed, tt.removed)
+			}
+			if _, err := os.Stat(outputPath); err != nil {
+				t.Errorf("the output folder was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestGeneratedFilesUpdate(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), ".polycode")
+	for _, file := range []string{"orders.go", "orders.yml", filepath.Join("client", "orders", "client.go")} {
+		path := filepath.Join(outputPath, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record := generatedFiles{Services: map[string][]string{"orders": {"orders.go", "orders.yml", "client/orders/client.go"}}}
+
+	if err := record.update(diskFS{}, outputPath, "orders", []string{"orders.go", "orders.yml"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, "client")); !os.IsNotExist(err) {
+		t.Errorf("the stale client and its empty folders were left: %v", err)
+	}
+	if err := record.removeStale(diskFS{}, outputPath, map[string]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.Services["orders"]; ok {
+		t.Error("the deleted service is still recorded")
+	}
+	for _, file := range []string{"orders.go", "orders.yml"} {
+		if _, err := os.Stat(filepath.Join(outputPath, file)); !os.IsNotExist(err) {
+			t.Errorf("%s of the deleted service was left: %v", file, err)
+		}
+	}
+}
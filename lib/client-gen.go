@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const clientTemplate = `// Code generated by next-gen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"errors"
+	{{.Info.SDKImport}}
+	{{range .Info.Imports}}{{.}}
+	{{end}}
+)
+
+// ServiceName is the registered name of the {{.Info.ServiceName}} service
+const ServiceName = "{{.Info.ServiceName}}"
+
+// Invoker performs a remote call of a service method and decodes the result into output
+type Invoker interface {
+	Invoke(service string, method string, input any, output any) error
+}
+
+// Client calls the {{.Info.ServiceName}} service with typed inputs and outputs
+type Client struct {
+	invoker Invoker
+}
+
+func New(invoker Invoker) *Client {
+	return &Client{invoker: invoker}
+}
+
+// FromServiceContext returns a client invoking through a service context
+func FromServiceContext(ctx polycode.ServiceContext) (*Client, error) {
+	return fromContext(ctx)
+}
+
+// FromWorkflowContext returns a client invoking through a workflow context
+func FromWorkflowContext(ctx polycode.WorkflowContext) (*Client, error) {
+	return fromContext(ctx)
+}
+
+func fromContext(ctx any) (*Client, error) {
+	invoker, ok := ctx.(Invoker)
+	if !ok {
+		return nil, errors.New("context does not support remote service invocation")
+	}
+	return New(invoker), nil
+}
+{{range .Info.Methods}}{{if not .IsStreaming}}
+// {{.OriginalName}} calls the {{.OriginalName}} {{if .IsWorkflow}}workflow{{else}}method{{end}} of the {{$.Info.ServiceName}} service
+{{- if .Deprecated}}
+//
+// {{.Deprecated.Notice}}
+{{- end}}
+{{- if .HasOutput}}
+func (c *Client) {{.OriginalName}}({{template "params" .}}) ({{if .IsOutputPointer}}*{{end}}{{.OutputType}}, error) {
+	var output {{.OutputType}}
+	if err := c.invoker.Invoke(ServiceName, "{{.ExposedName}}", {{template "args" .}}, &output); err != nil {
+		return {{if .IsOutputPointer}}nil{{else}}output{{end}}, err
+	}
+	return {{if .IsOutputPointer}}&{{end}}output, nil
+}
+{{- else}}
+func (c *Client) {{.OriginalName}}({{template "params" .}}) error {
+	return c.invoker.Invoke(ServiceName, "{{.ExposedName}}", {{template "args" .}}, nil)
+}
+{{- end}}
+{{end}}{{end}}
+{{- define "params"}}{{if .IsMultiInput}}{{range $i, $p := .Params}}{{if $i}}, {{end}}{{.JSONName}} {{if .IsVariadic}}...{{slice .Type 2}}{{else}}{{.Type}}{{end}}{{end}}{{else if .HasInput}}input {{if .IsInputPointer}}*{{end}}{{.InputType}}{{end}}{{end}}
+{{- define "args"}}{{if .IsMultiInput}}map[string]any{ {{- range $i, $p := .Params}}{{if $i}}, {{end}}"{{.JSONName}}": {{.JSONName}}{{end -}} }{{else if .HasInput}}input{{else}}nil{{end}}{{end}}`
+
+// TargetClients generates typed client packages for calling services from other services
+const TargetClients = "clients"
+
+// clientPackageName returns the Go package name of a service client
+func clientPackageName(serviceName string) string {
+	return strings.ToLower(strings.ReplaceAll(serviceFileName(serviceName), "-", "")) + "client"
+}
+
+// clientTarget generates .polycode/clients/<service>client packages
+type clientTarget struct{}
+
+func (clientTarget) Name() string {
+	return TargetClients
+}
+
+func (clientTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	tmpl, err := template.New("client").Parse(stampVersion(clientTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	packageName := clientPackageName(info.ServiceName)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"PackageName": packageName,
+		"Info":        info,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate client: %w", err)
+	}
+
+	return map[string][]byte{"clients/" + packageName + "/client.go": buf.Bytes()}, nil
+}
@@ -0,0 +1,389 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/go/packages"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journalFS passes the file operations to another outputFS and keeps the content every file had before
+// its first write or removal, so the changes of a run below root can be undone
+type journalFS struct {
+	outputFS
+	root     string
+	mu       sync.Mutex
+	previous map[string]journalEntry
+}
+
+// journalEntry is the content of a file before the run changed it
+type journalEntry struct {
+	data    []byte
+	existed bool
+}
+
+func newJournalFS(base outputFS, root string) *journalFS {
+	return &journalFS{outputFS: base, root: filepath.Clean(root), previous: make(map[string]journalEntry)}
+}
+
+func (j *journalFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	j.save(path)
+	return j.outputFS.WriteFile(path, data, perm)
+}
+
+func (j *journalFS) Remove(path string) error {
+	j.save(path)
+	return j.outputFS.Remove(path)
+}
+
+// save records the content of a file the first time the run changes it, folders are not recorded
+func (j *journalFS) save(path string) {
+	path = filepath.Clean(path)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.previous[path]; ok {
+		return
+	}
+	data, err := j.outputFS.ReadFile(path)
+	if err != nil && j.outputFS.Exists(path) {
+		return
+	}
+	j.previous[path] = journalEntry{data: data, existed: err == nil}
+}
+
+// changedGoFiles reports whether the run wrote or removed Go files
+func (j *journalFS) changedGoFiles() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for path := range j.previous {
+		if IsGoFile(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollback restores every file changed by the run, files it created are removed along with the
+// folders they leave empty
+func (j *journalFS) rollback() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	paths := make([]string, 0, len(j.previous))
+	for path := range j.previous {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		entry := j.previous[path]
+		if entry.existed {
+			if err := j.outputFS.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := j.outputFS.WriteFile(path, entry.data, 0644); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", path, err)
+			}
+			continue
+		}
+		if err := j.outputFS.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		for dir := filepath.Dir(path); dir != j.root && isInside(dir, j.root); dir = filepath.Dir(dir) {
+			if j.outputFS.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	j.previous = make(map[string]journalEntry)
+	return nil
+}
+
+// packagePosition splits the file:line:col position of a packages.Error
+var packagePosition = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
+
+// checkGenerated type-checks the Go packages of the output folder and returns their compile errors as
+// failures of the services whose wrappers they are in, located at the service function the failing
+// code calls when there is one. Errors of the app itself are left to the compiler, the generated code
+// cannot be blamed for them.
+func checkGenerated(ctx context.Context, output outputFS, module appModule, polycodeFolder string, entries []serviceEntry, reports []ServiceReport) ([]*ServiceError, error) {
+	files, err := output.Files(polycodeFolder)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, file := range files {
+		if !IsGoFile(file) || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		rel, err := filepath.Rel(module.Dir, filepath.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		// Folders starting with a dot are skipped by ./... patterns, the packages are listed one by one
+		pattern := "./" + filepath.ToSlash(rel)
+		if !slices.Contains(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	cfg := &packages.Config{
+		Context:   ctx,
+		Mode:      packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes,
+		Dir:       module.Dir,
+		Fset:      astCache.fileSet(),
+		ParseFile: astCache.parse,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to type-check generated code: %w", err)
+	}
+
+	rel, err := filepath.Rel(module.Dir, polycodeFolder)
+	if err != nil {
+		return nil, err
+	}
+	generatedPath := path.Join(module.Name, filepath.ToSlash(rel))
+	var appErrors int
+	var generated []packages.Error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		switch {
+		case provides(generatedPath, pkg.PkgPath):
+			generated = append(generated, pkg.Errors...)
+		case provides(module.Name, pkg.PkgPath):
+			appErrors += len(pkg.Errors)
+		}
+	})
+	if appErrors > 0 {
+		slog.Warn("The app does not compile, generated code is not type-checked", "errors", appErrors)
+		return nil, nil
+	}
+
+	// Wrappers of services that failed to generate keep their previous files, they are found by name
+	services := make(map[string]string)
+	for _, entry := range entries {
+		services[filepath.Join(polycodeFolder, serviceFileName(entry.Name)+".go")] = entry.Name
+	}
+	for _, report := range reports {
+		for _, file := range report.Files {
+			services[filepath.Join(polycodeFolder, filepath.FromSlash(file))] = report.Service
+		}
+	}
+	dirs := make(map[string]string)
+	for _, entry := range entries {
+		dirs[entry.Name] = filepath.Join(module.Dir, entry.Dir)
+	}
+
+	// Errors are grouped by service, the first one locates the failure
+	var order []string
+	causes := make(map[string][]error)
+	seen := make(map[string]bool)
+	for _, pkgErr := range generated {
+		var pos token.Position
+		if match := packagePosition.FindStringSubmatch(pkgErr.Pos); match != nil {
+			pos.Filename = match[1]
+			pos.Line, _ = strconv.Atoi(match[2])
+			pos.Column, _ = strconv.Atoi(match[3])
+		}
+		location := pkgErr.Pos
+		if rel, err := filepath.Rel(module.Dir, pos.Filename); err == nil && pos.Filename != "" {
+			location = filepath.ToSlash(rel) + strings.TrimPrefix(pkgErr.Pos, pos.Filename)
+		}
+		cause := fmt.Errorf("generated code does not compile, previous files restored: %s: %s", location, pkgErr.Msg)
+
+		service := services[pos.Filename]
+		failure := &positionError{pos: pos, err: cause}
+		if service != "" {
+			if function := calledFunction(output, pos); function != "" {
+				cause = fmt.Errorf("function %s: %w", function, cause)
+				failure = &positionError{pos: pos, err: cause}
+				if declared, ok := findFunction(dirs[service], function); ok {
+					failure.pos = declared
+				}
+			}
+		}
+		if key := service + "\n" + cause.Error(); !seen[key] {
+			seen[key] = true
+			if _, ok := causes[service]; !ok {
+				order = append(order, service)
+			}
+			causes[service] = append(causes[service], failure)
+		}
+	}
+
+	var failures []*ServiceError
+	for _, service := range order {
+		failures = append(failures, newServiceError(module.Dir, service, joinCauses(causes[service])))
+	}
+	return failures, nil
+}
+
+// joinCauses joins the errors of a service, a single error is kept as is
+func joinCauses(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// mergeFailures adds failures to a list, those of a service already in the list are joined to its failure
+// so every failed service is listed once
+func mergeFailures(failures []*ServiceError, more []*ServiceError) []*ServiceError {
+	for _, failure := range more {
+		i := slices.IndexFunc(failures, func(f *ServiceError) bool {
+			return f.Service == failure.Service
+		})
+		if i < 0 {
+			failures = append(failures, failure)
+			continue
+		}
+		failures[i].Err = errors.Join(failures[i].Err, failure.Err)
+	}
+	return failures
+}
+
+// calledFunction returns the service function called by the switch case of a wrapper holding a
+// position, like CreateOrder for the case "createorder" of ExecuteService. Cases without a call,
+// like those of GetInputType, are matched to the call of the case with the same name.
+func calledFunction(output outputFS, pos token.Position) string {
+	src, err := output.ReadFile(pos.Filename)
+	if err != nil {
+		return ""
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, pos.Filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return ""
+	}
+
+	// The innermost case is the last one containing the position, cases are visited outside in
+	var enclosing *ast.CaseClause
+	calls := make(map[string]string)
+	ast.Inspect(file, func(node ast.Node) bool {
+		clause, ok := node.(*ast.CaseClause)
+		if !ok {
+			return true
+		}
+		start, end := fset.Position(clause.Pos()), fset.Position(clause.End())
+		if start.Line <= pos.Line && pos.Line <= end.Line {
+			enclosing = clause
+		}
+		if function := serviceCall(clause); function != "" {
+			for _, name := range caseNames(clause) {
+				calls[name] = function
+			}
+		}
+		return true
+	})
+	if enclosing == nil {
+		return ""
+	}
+	if function := serviceCall(enclosing); function != "" {
+		return function
+	}
+	for _, name := range caseNames(enclosing) {
+		if function, ok := calls[name]; ok {
+			return function
+		}
+	}
+	return ""
+}
+
+// serviceCall returns the function called through service.Name or t.receiver.Name in a case
+func serviceCall(clause *ast.CaseClause) string {
+	var function string
+	for _, stmt := range clause.Body {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || function != "" {
+				return function == ""
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			switch x := selector.X.(type) {
+			case *ast.Ident:
+				if x.Name == "service" {
+					function = selector.Sel.Name
+				}
+			case *ast.SelectorExpr:
+				if x.Sel.Name == "receiver" {
+					function = selector.Sel.Name
+				}
+			}
+			return function == ""
+		})
+	}
+	return function
+}
+
+// caseNames returns the string literals a case matches
+func caseNames(clause *ast.CaseClause) []string {
+	var names []string
+	for _, expr := range clause.List {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if name, err := strconv.Unquote(lit.Value); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// findFunction returns the position of the declaration of a function or method of a service package
+func findFunction(dir string, name string) (token.Position, bool) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return token.Position{}, false
+	}
+	fset := token.NewFileSet()
+	for _, match := range matches {
+		if strings.HasSuffix(match, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, match, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == name {
+				return fset.Position(fn.Name.Pos()), true
+			}
+		}
+	}
+	return token.Position{}, false
+}
+
+// rollbackReports marks the services whose generated code does not compile as failed, the other
+// services generated by the run were restored along with them, nothing of theirs was written
+func rollbackReports(reports []ServiceReport, failures []*ServiceError) {
+	failed := make(map[string]bool)
+	for _, failure := range failures {
+		failed[failure.Service] = true
+	}
+	for i := range reports {
+		switch {
+		case failed[reports[i].Service]:
+			reports[i].Status, reports[i].Files = ServiceFailed, nil
+		case reports[i].Status == ServiceGenerated:
+			reports[i].Status, reports[i].Files = ServiceRestored, nil
+			reports[i].Warnings = append(reports[i].Warnings, "previous files restored, the generated code of the module does not compile")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalRollback(t *testing.T) {
+	root := filepath.Join(t.TempDir(), ".polycode")
+	write := func(path string, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modified := filepath.Join(root, "orders.go")
+	removed := filepath.Join(root, "billing.go")
+	created := filepath.Join(root, "client", "orders", "client.go")
+	write(modified, "old orders")
+	write(removed, "old billing")
+
+	journal := newJournalFS(diskFS{}, root)
+	steps := []error{
+		journal.WriteFile(modified, []byte("new orders"), 0644),
+		journal.WriteFile(modified, []byte("newer orders"), 0644),
+		journal.Remove(removed),
+		journal.MkdirAll(filepath.Dir(created), 0755),
+		journal.WriteFile(created, []byte("client"), 0644),
+	}
+	if err := errors.Join(steps...); err != nil {
+		t.Fatal(err)
+	}
+	if !journal.changedGoFiles() {
+		t.Error("changedGoFiles is false after writing Go files")
+	}
+
+	if err := journal.rollback(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{modified: "old orders", removed: "old billing"} {
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != want {
+			t.Errorf("%s holds %q (%v), want %q", path, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "client")); !os.IsNotExist(err) {
+		t.Errorf("folders of created files were left behind: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Errorf("the root folder was removed: %v", err)
+	}
+	if journal.changedGoFiles() {
+		t.Error("changedGoFiles is true after the rollback")
+	}
+}
+
+func TestRollbackReports(t *testing.T) {
+	reports := []ServiceReport{
+		{Service: "orders", Status: ServiceGenerated, Files: []string{"orders.go"}},
+		{Service: "billing", Status: ServiceGenerated, Files: []string{"billing.go"}},
+		{Service: "shipping", Status: ServiceUnchanged},
+	}
+	rollbackReports(reports, []*ServiceError{{Service: "billing", Err: errors.New("undefined: x")}})
+
+	want := []struct {
+		status   string
+		warnings int
+	}{
+		{status: ServiceRestored, warnings: 1},
+		{status: ServiceFailed},
+		{status: ServiceUnchanged},
+	}
+	for i, report := range reports {
+		if report.Status != want[i].status || len(report.Warnings) != want[i].warnings {
+			t.Errorf("%s: got status %s with warnings %q, want %s with %d", report.Service, report.Status, report.Warnings, want[i].status, want[i].warnings)
+		}
+		if report.Status != ServiceUnchanged && report.Files != nil {
+			t.Errorf("%s: got files %v, none were written", report.Service, report.Files)
+		}
+	}
+}
+
+func TestMergeFailures(t *testing.T) {
+	parse := errors.New("parse")
+	compile := errors.New("compile")
+	other := errors.New("other")
+	tests := []struct {
+		name     string
+		failures []*ServiceError
+		more     []*ServiceError
+		want     map[string][]error // errors each listed service must match, in order
+	}{
+		{name: "no compile failures", failures: []*ServiceError{{Service: "orders", Err: parse}}, want: map[string][]error{"orders": {parse}}},
+		{name: "new service", failures: []*ServiceError{{Service: "orders", Err: parse}}, more: []*ServiceError{{Service: "billing", Err: compile}}, want: map[string][]error{"orders": {parse}, "billing": {compile}}},
+		{name: "same service joined", failures: []*ServiceError{{Service: "orders", Err: parse}}, more: []*ServiceError{{Service: "orders", Err: compile}, {Service: "billing", Err: other}}, want: map[string][]error{"orders": {parse, compile}, "billing": {other}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeFailures(tt.failures, tt.more)
+			services := make([]string, 0, len(got))
+			for _, failure := range got {
+				services = append(services, failure.Service)
+				for _, err := range tt.want[failure.Service] {
+					if !errors.Is(failure.Err, err) {
+						t.Errorf("%s: %v does not wrap %v", failure.Service, failure.Err, err)
+					}
+				}
+			}
+			if len(services) != len(tt.want) {
+				t.Errorf("got services %v, want %d services listed once", services, len(tt.want))
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"gopkg.in/yaml.v2"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -157,17 +158,17 @@ func GenerateConfig(appPath string) error {
 	var yamlData interface{}
 	data, err := os.ReadFile(yamlFile)
 	if err != nil {
-		println("error reading yml file")
+		slog.Error("error reading yml file", "error", err)
 		return err
 	}
 
 	if os.IsNotExist(err) {
-		println("application.yml not found. generating empty config...")
+		slog.Info("application.yml not found. generating empty config...")
 		yamlData = make(map[string]interface{})
 	} else {
 		err = yaml.Unmarshal(data, &yamlData)
 		if err != nil {
-			println("error unmarshalling yml")
+			slog.Error("error unmarshalling yml", "error", err)
 			return err
 		}
 	}
@@ -177,19 +178,19 @@ func GenerateConfig(appPath string) error {
 
 	goCode, err := generateConfigCode(structs)
 	if err != nil {
-		println("error generating code")
+		slog.Error("error generating code", "error", err)
 		return err
 	}
 
 	err = os.MkdirAll(configFolder, 0755)
 	if err != nil {
-		println("error creating folder")
+		slog.Error("error creating folder", "error", err)
 		return err
 	}
 
 	err = os.WriteFile(configFile, []byte(goCode), 0644)
 	if err != nil {
-		println("error writing file")
+		slog.Error("error writing file", "error", err)
 		return err
 	}
 
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"go/ast"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"strconv"
+	"strings"
+)
+
+// contextTypes maps the types accepted as a polycode context, keyed by import path and name like
+// example.com/app/ctx.Ctx, to their kind, Service or Workflow. Besides the SDK types these are the
+// aliases and named types with an SDK context as their underlying type, like
+// type Ctx = polycode.ServiceContext. The wrappers pass the SDK context, never naming these types,
+// so unexported ones of the service package qualify too.
+type contextTypes map[string]string
+
+// findContextTypes collects the context types declared by the loaded packages
+func findContextTypes(pkgs []*packages.Package) contextTypes {
+	// The SDK packages are found by name, like the polycode qualifier is matched in the source
+	var sdkKinds []string
+	var sdkTypes []types.Type
+	visitTypes(pkgs, func(pkg *types.Package) {
+		if pkg.Name() != "polycode" {
+			return
+		}
+		for _, kind := range []string{"Service", "Workflow"} {
+			if obj, ok := pkg.Scope().Lookup(kind + "Context").(*types.TypeName); ok {
+				sdkKinds = append(sdkKinds, kind)
+				sdkTypes = append(sdkTypes, obj.Type())
+			}
+		}
+	})
+
+	contexts := make(contextTypes)
+	if len(sdkTypes) == 0 {
+		return contexts
+	}
+	// Aliases match their SDK type exactly, named types by the methods of their underlying type, a
+	// service context wins should both have the same methods
+	match := func(t types.Type) string {
+		t = types.Unalias(t)
+		for i, sdkType := range sdkTypes {
+			if types.Identical(t, sdkType) {
+				return sdkKinds[i]
+			}
+		}
+		for i, sdkType := range sdkTypes {
+			if types.Identical(t.Underlying(), sdkType.Underlying()) {
+				return sdkKinds[i]
+			}
+		}
+		return ""
+	}
+	visitTypes(pkgs, func(pkg *types.Package) {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if kind := match(typeName.Type()); kind != "" {
+				contexts[pkg.Path()+"."+name] = kind
+			}
+		}
+	})
+	return contexts
+}
+
+// contextResolver returns the context kind of a parameter type, empty when it is not a context
+type contextResolver func(expr ast.Expr) string
+
+// resolver returns the context resolver of a file of the service package. polycode.ServiceContext
+// and polycode.WorkflowContext are recognized without type information, other types are looked up
+// by the import path they resolve to.
+func (c contextTypes) resolver(file *ast.File, servicePackage string) contextResolver {
+	imports := importPaths(file)
+	return func(expr ast.Expr) string {
+		switch t := expr.(type) {
+		case *ast.Ident:
+			return c[servicePackage+"."+t.Name]
+		case *ast.SelectorExpr:
+			pkg, ok := t.X.(*ast.Ident)
+			if !ok {
+				return ""
+			}
+			if pkg.Name == "polycode" && (t.Sel.Name == "ServiceContext" || t.Sel.Name == "WorkflowContext") {
+				return strings.TrimSuffix(t.Sel.Name, "Context")
+			}
+			if importPath, ok := imports[pkg.Name]; ok {
+				return c[importPath+"."+t.Sel.Name]
+			}
+		}
+		return ""
+	}
+}
+
+// importPaths returns the import path of each package imported by a file, keyed by the name it is
+// referenced with
+func importPaths(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, imp := range file.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		name := importName(importPath)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+// sdkResolver returns the name of the SDK type an expression of a file refers to, see sdkTypeName
+type sdkResolver func(expr ast.Expr) string
+
+// newSDKResolver returns the resolver of the SDK types referred to by a file
+func newSDKResolver(file *ast.File, sdkImport string) sdkResolver {
+	imports := importPaths(file)
+	return func(expr ast.Expr) string {
+		return sdkTypeName(expr, imports, sdkImport)
+	}
+}
+
+// sdkTypeName returns the name of a type of the SDK package a selector refers to, like ServiceContext
+// for polycode.ServiceContext, empty when the selector refers to another package. The package is
+// resolved through the imports of the file, so an aliased import or a fork imported from sdkImport is
+// recognized.
+func sdkTypeName(expr ast.Expr, imports map[string]string, sdkImport string) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || imports[pkg.Name] != sdkImport {
+		return ""
+	}
+	return sel.Sel.Name
+}
@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Debouncer coalesces triggers per key and fires once the key has been quiet for the delay
+type Debouncer struct {
+	mu    sync.Mutex
+	run   sync.Mutex
+	delay time.Duration
+	calls map[string]*debounceCall
+	fire  func(paths []string)
+	// afterFunc schedules a call and returns the function stopping it, time.AfterFunc outside of tests
+	afterFunc func(delay time.Duration, f func()) (stop func() bool)
+}
+
+// debounceCall is the pending call of a key. A trigger replaces it with a new call rather than resetting
+// its timer, so a timer that fired meanwhile finds it replaced and does nothing.
+type debounceCall struct {
+	paths []string
+	stop  func() bool
+}
+
+// NewDebouncer returns a debouncer calling fire with the paths seen for a key, in the order they first changed
+func NewDebouncer(delay time.Duration, fire func(paths []string)) *Debouncer {
+	return &Debouncer{
+		delay: delay,
+		calls: make(map[string]*debounceCall),
+		fire:  fire,
+		afterFunc: func(delay time.Duration, f func()) func() bool {
+			return time.AfterFunc(delay, f).Stop
+		},
+	}
+}
+
+// Trigger schedules a call for the key, replacing any pending call for the same key and taking over its paths
+func (d *Debouncer) Trigger(key string, path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	call := &debounceCall{paths: []string{path}}
+	if previous, ok := d.calls[key]; ok {
+		previous.stop()
+		call.paths = appendPath(previous.paths, path)
+	}
+	d.calls[key] = call
+	// The callback takes the lock, it cannot run before stop is set
+	call.stop = d.afterFunc(d.delay, func() {
+		d.mu.Lock()
+		current := d.calls[key] == call
+		if current {
+			delete(d.calls, key)
+		}
+		d.mu.Unlock()
+		if !current {
+			return
+		}
+
+		// Calls for different keys must not run at the same time
+		d.run.Lock()
+		defer d.run.Unlock()
+		d.fire(call.paths)
+	})
+}
+
+// appendPath adds a path to a batch unless it is already in it
+func appendPath(paths []string, path string) []string {
+	if slices.Contains(paths, path) {
+		return paths
+	}
+	return append(slices.Clip(paths), path)
+}
@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTimers records the calls scheduled by a debouncer, the test runs them instead of a clock
+type fakeTimers struct {
+	mu     sync.Mutex
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	f       func()
+	stopped bool
+}
+
+func (c *fakeTimers) afterFunc(_ time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{f: f}
+	c.timers = append(c.timers, timer)
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		stopped := timer.stopped
+		timer.stopped = true
+		return !stopped
+	}
+}
+
+// expire runs the scheduled calls, stopped ones too when late is set, like timers that fired right
+// before they were stopped
+func (c *fakeTimers) expire(late bool) {
+	c.mu.Lock()
+	timers := c.timers
+	c.timers = nil
+	c.mu.Unlock()
+	for _, timer := range timers {
+		if late || !timer.stopped {
+			timer.f()
+		}
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	tests := []struct {
+		name     string
+		triggers [][2]string // key and path of each trigger
+		late     bool        // replaced calls fire too
+		want     []string    // batches fired, comma separated and sorted
+	}{
+		{name: "single trigger", triggers: [][2]string{{"orders", "a.go"}}, want: []string{"a.go"}},
+		{name: "same key coalesced into a batch", triggers: [][2]string{{"orders", "a.go"}, {"orders", "b.go"}, {"orders", "c.go"}}, want: []string{"a.go,b.go,c.go"}},
+		{name: "repeated paths listed once", triggers: [][2]string{{"orders", "a.go"}, {"orders", "b.go"}, {"orders", "a.go"}}, want: []string{"a.go,b.go"}},
+		{name: "keys fired separately", triggers: [][2]string{{"orders", "a.go"}, {"billing", "b.go"}, {"orders", "c.go"}}, want: []string{"a.go,c.go", "b.go"}},
+		{name: "replaced calls firing late do nothing", triggers: [][2]string{{"orders", "a.go"}, {"orders", "b.go"}}, late: true, want: []string{"a.go,b.go"}},
+		{name: "full run key", triggers: [][2]string{{"", "go.mod"}, {"", "go.sum"}}, late: true, want: []string{"go.mod,go.sum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fired []string
+			timers := &fakeTimers{}
+			d := NewDebouncer(time.Second, func(paths []string) { fired = append(fired, strings.Join(paths, ",")) })
+			d.afterFunc = timers.afterFunc
+
+			for _, trigger := range tt.triggers {
+				d.Trigger(trigger[0], trigger[1])
+			}
+			timers.expire(tt.late)
+			// Nothing is left pending once the calls fired
+			timers.expire(true)
+
+			slices.Sort(fired)
+			if !slices.Equal(fired, tt.want) {
+				t.Errorf("fired %v, want %v", fired, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebouncerTriggerAfterFire(t *testing.T) {
+	var fired []string
+	timers := &fakeTimers{}
+	d := NewDebouncer(time.Second, func(paths []string) { fired = append(fired, strings.Join(paths, ",")) })
+	d.afterFunc = timers.afterFunc
+
+	d.Trigger("orders", "a.go")
+	timers.expire(false)
+	d.Trigger("orders", "b.go")
+	timers.expire(false)
+
+	if want := []string{"a.go", "b.go"}; !slices.Equal(fired, want) {
+		t.Errorf("fired %v, want %v", fired, want)
+	}
+}
+
+func TestDebouncerFiresOneAtATime(t *testing.T) {
+	var mu sync.Mutex
+	running := 0
+	started := make(chan int)
+	proceed := make(chan struct{})
+	timers := &fakeTimers{}
+	d := NewDebouncer(time.Second, func([]string) {
+		mu.Lock()
+		running++
+		n := running
+		mu.Unlock()
+
+		started <- n
+		<-proceed
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	d.afterFunc = timers.afterFunc
+
+	keys := []string{"orders", "billing", "shipping"}
+	for _, key := range keys {
+		d.Trigger(key, key+".go")
+	}
+	timers.mu.Lock()
+	scheduled := timers.timers
+	timers.mu.Unlock()
+	// Every key fires from its own goroutine, like timers do
+	for _, timer := range scheduled {
+		go timer.f()
+	}
+
+	for range keys {
+		if n := <-started; n != 1 {
+			t.Errorf("%d calls ran at the same time, want 1", n)
+		}
+		proceed <- struct{}{}
+	}
+}
@@ -0,0 +1,1042 @@
+package lib
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v2"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field represents a single field of an input or output struct
+type Field struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+	Tag  string `yaml:"tag,omitempty" json:"tag,omitempty"` // Raw struct tag
+	Doc  string `yaml:"doc,omitempty" json:"doc,omitempty"` // Doc or line comment of the field
+	// JSONName is the wire name from the json tag, defaulting to Name
+	JSONName string `yaml:"jsonName,omitempty" json:"jsonName,omitempty"`
+	// YAMLName is the name from the yaml tag, when there is one
+	YAMLName string `yaml:"yamlName,omitempty" json:"yamlName,omitempty"`
+	// Optional is set for fields that may be absent on the wire, pointers and fields tagged omitempty
+	Optional bool `yaml:"optional,omitempty" json:"optional,omitempty"`
+	// Schema describes the structure of the type for consumers that do not parse Go type expressions
+	Schema *TypeSchema `yaml:"schema,omitempty" json:"schema,omitempty"`
+	kind   string      // Kind of the type, or of the pointed type, validate rules are checked against
+	ref    string      // Import path qualified name of the struct the type refers to, like example.com/app/models.Money
+}
+
+// Kinds of types in a TypeSchema
+const (
+	SchemaKindString  = "string"
+	SchemaKindInteger = "integer"
+	SchemaKindNumber  = "number"
+	SchemaKindBoolean = "boolean"
+	SchemaKindBytes   = "bytes" // []byte, encoded as base64
+	SchemaKindTime    = "time"  // time.Time, encoded as RFC 3339
+	SchemaKindArray   = "array"
+	SchemaKindMap     = "map"
+	SchemaKindStruct  = "struct"
+	SchemaKindAny     = "any"
+	// SchemaKindRef is a recursive use of the named type Type, like the elements of type Tree map[string]Tree.
+	// Its schema is that of the enclosing schema of the same Type.
+	SchemaKindRef = "ref"
+)
+
+// TypeSchema is the recursive structure of a field type. Named structs refer to their schema in
+// ServiceDefinition.Types by Type, anonymous structs list their Fields inline and other named types
+// are expanded, down to their recursive uses.
+type TypeSchema struct {
+	Kind     string      `yaml:"kind" json:"kind"`
+	Type     string      `yaml:"type,omitempty" json:"type,omitempty"`         // Name of named types, like models.Status
+	Optional bool        `yaml:"optional,omitempty" json:"optional,omitempty"` // Pointers, null on the wire
+	Key      *TypeSchema `yaml:"key,omitempty" json:"key,omitempty"`           // Key of maps
+	Elem     *TypeSchema `yaml:"elem,omitempty" json:"elem,omitempty"`         // Element of arrays and maps
+	Fields   []Field     `yaml:"fields,omitempty" json:"fields,omitempty"`
+	// Enum lists the values of the constants declared with a named string or number type
+	Enum []any `yaml:"enum,omitempty" json:"enum,omitempty"`
+}
+
+// MethodDefinition describes a single service method in the definition file
+type MethodDefinition struct {
+	Name         string  `yaml:"name" json:"name"`
+	Description  string  `yaml:"description,omitempty" json:"description,omitempty"`
+	Doc          string  `yaml:"doc,omitempty" json:"doc,omitempty"`
+	IsWorkflow   bool    `yaml:"isWorkflow" json:"isWorkflow"`
+	InputType    string  `yaml:"inputType,omitempty" json:"inputType,omitempty"`
+	InputSchema  []Field `yaml:"inputSchema,omitempty" json:"inputSchema,omitempty"`
+	OutputType   string  `yaml:"outputType,omitempty" json:"outputType,omitempty"`
+	OutputSchema []Field `yaml:"outputSchema,omitempty" json:"outputSchema,omitempty"`
+	InputStream  bool    `yaml:"inputStream,omitempty" json:"inputStream,omitempty"`
+	OutputStream bool    `yaml:"outputStream,omitempty" json:"outputStream,omitempty"`
+	Concurrency  int     `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// InputTypeSchema describes inputs that are collections rather than structs, like []T or
+	// map[string]T, the structs of their elements are listed in the Types of the service
+	InputTypeSchema *TypeSchema `yaml:"inputTypeSchema,omitempty" json:"inputTypeSchema,omitempty"`
+	// OutputTypeSchema describes outputs that are collections rather than structs, like []T or
+	// map[string]T, the structs of their elements are listed in the Types of the service
+	OutputTypeSchema *TypeSchema `yaml:"outputTypeSchema,omitempty" json:"outputTypeSchema,omitempty"`
+	// Auth holds the roles declared with //polycode:auth, absent when every caller is allowed
+	Auth *AuthPolicy `yaml:"auth,omitempty" json:"auth,omitempty"`
+	// Timeout and Retry are declared with //polycode:timeout and //polycode:retry
+	Timeout string           `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retry   *RetryDefinition `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Deprecated is set for methods marked deprecated
+	Deprecated *Deprecation `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+	// Options holds the //polycode:method options other than name
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+// ServiceDefinition is the content of .polycode/definition/<service>.yml, or .json and .cbor
+type ServiceDefinition struct {
+	// Name is the registered name, prefixed with the namespace and suffixed with the version when set
+	Name string `yaml:"name" json:"name"`
+	// Namespace and Version are declared with //polycode:service
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Version   string `yaml:"version,omitempty" json:"version,omitempty"`
+	// GeneratorVersion is the version of next-gen that wrote the definition
+	GeneratorVersion string             `yaml:"generatorVersion" json:"generatorVersion"`
+	Methods          []MethodDefinition `yaml:"methods" json:"methods"`
+	// Signals and Queries are the handlers running workflows are sent signals and queries through
+	Signals []MethodDefinition `yaml:"signals,omitempty" json:"signals,omitempty"`
+	Queries []MethodDefinition `yaml:"queries,omitempty" json:"queries,omitempty"`
+	// HealthCheck is set when the service declares a HealthCheck function probing its health
+	HealthCheck *HealthCheckDefinition `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
+	// Routes are the HTTP routes of API gateways to the methods, declared with //polycode:http
+	Routes []RouteDefinition `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// Types holds the schemas of struct types referenced by fields of the method schemas
+	Types map[string][]Field `yaml:"types,omitempty" json:"types,omitempty"`
+	// SharedTypes maps the struct types also used by other services to their schema under
+	// .polycode/schema/common, relative to the output folder. LoadServiceDefinitions moves them
+	// back into Types and the method schemas.
+	SharedTypes map[string]string `yaml:"sharedTypes,omitempty" json:"sharedTypes,omitempty"`
+	// Errors is the catalog of sentinel errors and error types declared by the service package
+	Errors []ErrorDefinition `yaml:"errors,omitempty" json:"errors,omitempty"`
+	// Events are the events the service publishes and subscribes to, declared with //polycode:event
+	Events *ServiceEvents `yaml:"events,omitempty" json:"events,omitempty"`
+	// Integrity is the hash and signature of the definition, set when it is signed with Options.SignKey
+	Integrity *DefinitionIntegrity `yaml:"integrity,omitempty" json:"integrity,omitempty"`
+}
+
+// loadAppPackages type-checks the app with its dependencies from source. The x/tools release the module
+// requires cannot read the export data of newer Go toolchains, so dependencies are not loaded from it.
+func loadAppPackages(ctx context.Context, appPath string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax,
+		Dir:     appPath,
+		Tests:   false,
+		// Unchanged files of the app and its dependencies are not parsed again on later runs
+		Fset:      astCache.fileSet(),
+		ParseFile: astCache.parse,
+	}
+
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+	return pkgs, nil
+}
+
+// extractStructs returns the fields of each struct of the loaded packages and their dependencies, along
+// with the named interface types, keyed by import path and type name like example.com/app/models.Money.
+// Packages of the same name are told apart, services look their types up through localTypes.
+func extractStructs(pkgs []*packages.Package) (map[string][]Field, map[string]bool, map[string][]string) {
+	// Docs are collected first, fields promoted from embedded structs may come from any package
+	docs := make(map[string]map[string]string)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types == nil {
+			return
+		}
+		for name, fields := range fieldDocs(pkg.Syntax) {
+			docs[pkg.Types.Path()+"."+name] = fields
+		}
+	})
+	builder := &schemaBuilder{docs: docs, expanding: make(map[string]bool)}
+
+	structs := make(map[string][]Field)
+	interfaces := make(map[string]bool)
+	typeParams := make(map[string][]string)
+	visitTypes(pkgs, func(pkg *types.Package) {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !typeName.Exported() {
+				continue
+			}
+			if _, ok := typeName.Type().Underlying().(*types.Interface); ok {
+				interfaces[pkg.Path()+"."+name] = true
+				continue
+			}
+			structType, ok := typeName.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			structs[pkg.Path()+"."+name] = builder.structFields(structType, builder.typeDocs(typeName.Type()), nil)
+			if named, ok := typeName.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				for i := range named.TypeParams().Len() {
+					typeParams[pkg.Path()+"."+name] = append(typeParams[pkg.Path()+"."+name], named.TypeParams().At(i).Obj().Name())
+				}
+			}
+		}
+	})
+
+	return structs, interfaces, typeParams
+}
+
+// instantiateStructs adds the fields of the generic structs instantiated by the types of the methods to
+// structs, keyed by the instantiated type like models.Request[models.Money]. Fields typed with a type
+// parameter take its type argument and a schema built from it. typeParams lists the type parameters of
+// the generic structs.
+func instantiateStructs(methods []MethodInfo, structs map[string][]Field, typeParams map[string][]string) error {
+	for _, method := range methods {
+		typeExprs := []string{method.InputType, method.OutputType}
+		for _, param := range method.Params {
+			typeExprs = append(typeExprs, param.Type)
+		}
+		for _, typeExpr := range typeExprs {
+			if err := instantiateTypes(typeExpr, structs, typeParams); err != nil {
+				return fmt.Errorf("function %s: %w", method.OriginalName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// instantiateTypes adds the instantiated generic structs of a type expression to structs, along with
+// those their fields instantiate
+func instantiateTypes(typeExpr string, structs map[string][]Field, typeParams map[string][]string) error {
+	if !strings.Contains(typeExpr, "[") {
+		return nil
+	}
+	expr, err := parser.ParseExpr(typeExpr)
+	if err != nil {
+		return nil
+	}
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		var generic ast.Expr
+		var args []ast.Expr
+		switch index := node.(type) {
+		case *ast.IndexExpr:
+			generic, args = index.X, []ast.Expr{index.Index}
+		case *ast.IndexListExpr:
+			generic, args = index.X, index.Indices
+		default:
+			return true
+		}
+
+		name := types.ExprString(node.(ast.Expr))
+		if _, ok := structs[name]; ok {
+			return false
+		}
+		params, ok := typeParams[types.ExprString(generic)]
+		if !ok {
+			err = fmt.Errorf("the schema of %s is unknown, only generic structs can be instantiated", name)
+			return false
+		}
+		if len(params) != len(args) {
+			err = fmt.Errorf("%s needs %d type arguments", types.ExprString(generic), len(params))
+			return false
+		}
+		subst := make(map[string]string, len(params))
+		for i, param := range params {
+			subst[param] = types.ExprString(args[i])
+		}
+
+		fields := slices.Clone(structs[types.ExprString(generic)])
+		// Registered before the fields are instantiated, a recursive generic struct refers to itself
+		structs[name] = fields
+		for i, field := range fields {
+			fieldExpr, parseErr := parser.ParseExpr(field.Type)
+			if parseErr != nil {
+				continue
+			}
+			substituted, substErr := substituteTypeParams([]ast.Expr{fieldExpr}, subst)
+			if substErr != nil {
+				err = substErr
+				return false
+			}
+			fieldType := types.ExprString(substituted[0])
+			if fieldType == field.Type {
+				continue
+			}
+			if err = instantiateTypes(fieldType, structs, typeParams); err != nil {
+				return false
+			}
+			fields[i].Type, fields[i].Schema, fields[i].ref = fieldType, goTypeSchema(fieldType, structs), ""
+		}
+		return true
+	})
+	return err
+}
+
+// visitTypes calls visit once for the type-checked packages of the app and each package they import,
+// directly or not, dependencies included
+func visitTypes(pkgs []*packages.Package, visit func(pkg *types.Package)) {
+	seen := make(map[*types.Package]bool)
+	var walk func(pkg *types.Package)
+	walk = func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		visit(pkg)
+		for _, imported := range pkg.Imports() {
+			walk(imported)
+		}
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types != nil {
+			walk(pkg.Types)
+		}
+	})
+}
+
+// typeKey returns the import path qualified name of a named type, like example.com/app/models.Money,
+// empty for other types
+func typeKey(t types.Type) string {
+	if named, ok := types.Unalias(t).(*types.Named); ok && named.Obj().Pkg() != nil {
+		return named.Obj().Pkg().Path() + "." + named.Obj().Name()
+	}
+	return ""
+}
+
+// structRef returns the key of the named struct a field type refers to once stripped of pointers,
+// slices, arrays and map wrappers, like baseTypeName does for type expressions
+func structRef(t types.Type) string {
+	for {
+		switch u := types.Unalias(t).(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		case *types.Map:
+			t = u.Elem()
+		case *types.Named:
+			if _, ok := u.Underlying().(*types.Struct); ok {
+				return typeKey(u)
+			}
+			return ""
+		default:
+			return ""
+		}
+	}
+}
+
+// importNames maps the import paths of a service to the names its source refers to them by, from the
+// import specs collected by parseDir, like models for "example.com/app/models"
+func importNames(imports []string) map[string][]string {
+	names := make(map[string][]string)
+	for _, spec := range imports {
+		name, quoted, aliased := strings.Cut(spec, " ")
+		if !aliased {
+			quoted = spec
+		}
+		importPath, err := strconv.Unquote(quoted)
+		if err != nil {
+			continue
+		}
+		if !aliased {
+			name = path.Base(importPath)
+		}
+		names[importPath] = append(names[importPath], name)
+	}
+	return names
+}
+
+// localTypes returns the values of types keyed by import path, like the structs of extractStructs, also
+// keyed by the names a service writes them with, like xm.Money for "example.com/app/ext/models" imported
+// as xm. Types of packages with the same name are only reachable through the import of the service.
+func localTypes[V any](keyed map[string]V, imports []string) map[string]V {
+	names := importNames(imports)
+	local := make(map[string]V, len(keyed))
+	for key, value := range keyed {
+		local[key] = value
+	}
+	for key, value := range keyed {
+		i := strings.LastIndex(key, ".")
+		for _, name := range names[key[:i]] {
+			local[name+"."+key[i+1:]] = value
+		}
+	}
+	return local
+}
+
+// typeString formats a type qualified by package name, the way it is written in source
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+// fieldDocs collects the comments of struct fields keyed by type and field name,
+// a doc comment above the field takes precedence over a trailing line comment
+func fieldDocs(files []*ast.File) map[string]map[string]string {
+	docs := make(map[string]map[string]string)
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+
+			fields := make(map[string]string)
+			for _, field := range structType.Fields.List {
+				comment := field.Doc
+				if comment == nil {
+					comment = field.Comment
+				}
+				if comment == nil {
+					continue
+				}
+				for _, name := range field.Names {
+					fields[name.Name] = strings.TrimSpace(comment.Text())
+				}
+			}
+			docs[spec.Name.Name] = fields
+			return false
+		})
+	}
+	return docs
+}
+
+// schemaBuilder describes the struct fields and field types of the loaded packages
+type schemaBuilder struct {
+	docs map[string]map[string]string // Field docs keyed by import path qualified type and field name
+	// expanding holds the named types whose schema is being built, a type met again within its own
+	// schema is a recursive use and is referenced rather than expanded forever
+	expanding map[string]bool
+}
+
+// typeDocs returns the field docs of a named struct type
+func (b *schemaBuilder) typeDocs(t types.Type) map[string]string {
+	return b.docs[typeKey(t)]
+}
+
+// structFields converts the fields of a struct type into a flat schema. Like encoding/json, the fields of
+// embedded structs without a json name are promoted, fields declared on the struct shadow promoted ones.
+// embedding lists the types the struct was embedded through, a type embedding one of them again adds
+// no fields.
+func (b *schemaBuilder) structFields(structType *types.Struct, docs map[string]string, embedding []string) []Field {
+	fields := []Field{}
+	var promoted []Field
+	for i := 0; i < structType.NumFields(); i++ {
+		field := structType.Field(i)
+		tag := structType.Tag(i)
+		jsonTag := reflect.StructTag(tag).Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(jsonTag, ",")
+		if field.Embedded() && jsonName == "" {
+			embedded := types.Unalias(field.Type())
+			if pointer, ok := embedded.(*types.Pointer); ok {
+				embedded = types.Unalias(pointer.Elem())
+			}
+			if embeddedStruct, ok := embedded.Underlying().(*types.Struct); ok {
+				if name := typeString(embedded); !slices.Contains(embedding, name) {
+					promoted = append(promoted, b.structFields(embeddedStruct, b.typeDocs(embedded), append(slices.Clip(embedding), name))...)
+				}
+				continue
+			}
+		}
+		if !field.Exported() {
+			continue
+		}
+		fields = append(fields, b.newField(field.Name(), field.Type(), tag, docs[field.Name()]))
+	}
+
+	declared := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		name, _, _ := jsonFieldName(field)
+		declared[name] = true
+	}
+	for _, field := range promoted {
+		if name, _, _ := jsonFieldName(field); !declared[name] {
+			declared[name] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// newField describes a struct field with its wire names
+func (b *schemaBuilder) newField(name string, t types.Type, tag string, doc string) Field {
+	field := Field{
+		Name:   name,
+		Type:   typeString(t),
+		Tag:    tag,
+		Doc:    doc,
+		Schema: b.typeSchema(t),
+		kind:   fieldKind(t),
+		ref:    structRef(t),
+	}
+	var omitempty bool
+	field.JSONName, omitempty, _ = jsonFieldName(field)
+	_, pointer := types.Unalias(t).(*types.Pointer)
+	field.Optional = omitempty || pointer
+	if yamlName, _, _ := strings.Cut(reflect.StructTag(tag).Get("yaml"), ","); yamlName != "" && yamlName != "-" {
+		field.YAMLName = yamlName
+	}
+	return field
+}
+
+// typeSchema describes the structure of a type, named structs are referenced by name rather than expanded
+// and other named types are referenced where they recur within their own schema
+func (b *schemaBuilder) typeSchema(t types.Type) *TypeSchema {
+	t = types.Unalias(t)
+	if pointer, ok := t.(*types.Pointer); ok {
+		schema := b.typeSchema(pointer.Elem())
+		schema.Optional = true
+		return schema
+	}
+
+	schema := &TypeSchema{Kind: SchemaKindAny}
+	if _, ok := t.(*types.Named); ok {
+		schema.Type = typeString(t)
+		if wellKnownTypes[schema.Type] {
+			schema.Kind = SchemaKindTime
+			return schema
+		}
+		if _, ok := t.Underlying().(*types.Struct); !ok {
+			if b.expanding[schema.Type] {
+				schema.Kind = SchemaKindRef
+				return schema
+			}
+			b.expanding[schema.Type] = true
+			defer delete(b.expanding, schema.Type)
+		}
+	}
+
+	switch underlying := t.Underlying().(type) {
+	case *types.Basic:
+		info := underlying.Info()
+		switch {
+		case info&types.IsString != 0:
+			schema.Kind = SchemaKindString
+		case info&types.IsBoolean != 0:
+			schema.Kind = SchemaKindBoolean
+		case info&types.IsInteger != 0:
+			schema.Kind = SchemaKindInteger
+		case info&types.IsFloat != 0:
+			schema.Kind = SchemaKindNumber
+		}
+		if named, ok := t.(*types.Named); ok && schema.Kind != SchemaKindBoolean {
+			schema.Enum = enumValues(named)
+		}
+	case *types.Slice:
+		if elem, ok := underlying.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			schema.Kind = SchemaKindBytes
+			break
+		}
+		schema.Kind = SchemaKindArray
+		schema.Elem = b.typeSchema(underlying.Elem())
+	case *types.Array:
+		schema.Kind = SchemaKindArray
+		schema.Elem = b.typeSchema(underlying.Elem())
+	case *types.Map:
+		schema.Kind = SchemaKindMap
+		schema.Key = b.typeSchema(underlying.Key())
+		schema.Elem = b.typeSchema(underlying.Elem())
+	case *types.Struct:
+		schema.Kind = SchemaKindStruct
+		if schema.Type == "" {
+			schema.Fields = b.structFields(underlying, nil, nil)
+		}
+	}
+	return schema
+}
+
+// enumValues returns the values of the exported constants of a named type declared in its package, in
+// declaration order. Types marshaled by their own MarshalJSON or MarshalText have no enum, their
+// constants are not what goes on the wire.
+func enumValues(named *types.Named) []any {
+	pkg := named.Obj().Pkg()
+	if pkg == nil {
+		return nil
+	}
+	for _, method := range []string{"MarshalJSON", "MarshalText"} {
+		if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, pkg, method); obj != nil {
+			return nil
+		}
+	}
+
+	var consts []*types.Const
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && c.Exported() && types.Identical(c.Type(), named) {
+			consts = append(consts, c)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+
+	var values []any
+	seen := make(map[string]bool)
+	for _, c := range consts {
+		var value any
+		switch c.Val().Kind() {
+		case constant.String:
+			value = constant.StringVal(c.Val())
+		case constant.Int:
+			v, ok := constant.Int64Val(c.Val())
+			if !ok {
+				continue
+			}
+			value = v
+		case constant.Float:
+			value, _ = constant.Float64Val(c.Val())
+		default:
+			continue
+		}
+		// Aliases like StatusDefault = StatusActive list the value once
+		if key := c.Val().ExactString(); !seen[key] {
+			seen[key] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// fieldKind classifies a field type for the generated validation code, empty for types without validate support
+func fieldKind(t types.Type) string {
+	if pointer, ok := t.Underlying().(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+	switch underlying := t.Underlying().(type) {
+	case *types.Basic:
+		info := underlying.Info()
+		switch {
+		case info&types.IsString != 0:
+			return kindString
+		case info&types.IsBoolean != 0:
+			return kindBool
+		case info&types.IsUnsigned != 0:
+			return kindUint
+		case info&types.IsInteger != 0:
+			return kindInt
+		case info&types.IsFloat != 0:
+			return kindFloat
+		}
+	case *types.Slice, *types.Array, *types.Map:
+		return kindCollection
+	}
+	return ""
+}
+
+// buildServiceDefinition combines the parsed methods, signals and queries with the struct schemas
+func buildServiceDefinition(serviceName string, info ServiceInfo, structs map[string][]Field) ServiceDefinition {
+	def := ServiceDefinition{
+		Name:             serviceName,
+		GeneratorVersion: VersionString(),
+		Methods:          methodDefinitions(info.Methods, structs),
+		Signals:          methodDefinitions(info.Signals, structs),
+		Queries:          methodDefinitions(info.Queries, structs),
+		HealthCheck:      healthCheckDefinition(info.HealthCheck, structs),
+		Types:            map[string][]Field{},
+	}
+	if def.HealthCheck != nil {
+		collectNestedTypes(def.HealthCheck.StatusSchema, structs, def.Types)
+	}
+
+	for _, list := range [][]MethodDefinition{def.Methods, def.Signals, def.Queries} {
+		for _, method := range list {
+			collectNestedTypes(method.InputSchema, structs, def.Types)
+			collectNestedTypes(method.OutputSchema, structs, def.Types)
+			// The element structs of collection inputs and outputs, stripped of their wrappers by collectNestedTypes
+			if method.InputTypeSchema != nil {
+				collectNestedTypes([]Field{{Type: method.InputType}}, structs, def.Types)
+			}
+			if method.OutputTypeSchema != nil {
+				collectNestedTypes([]Field{{Type: method.OutputType}}, structs, def.Types)
+			}
+		}
+	}
+	return def
+}
+
+// definitionInfo returns the service as its definition describes it. The wrapper refers to the types of
+// the service package through its service import, definitions name them by package name like the
+// fields of structs do.
+func definitionInfo(info ServiceInfo, packageName string) ServiceInfo {
+	qualify := func(typeExpr string) string {
+		if !strings.Contains(typeExpr, "service.") {
+			return typeExpr
+		}
+		expr, err := parser.ParseExpr(typeExpr)
+		if err != nil {
+			return typeExpr
+		}
+		qualified, err := substituteTypeParams([]ast.Expr{expr}, map[string]string{"service": packageName})
+		if err != nil {
+			return typeExpr
+		}
+		return types.ExprString(qualified[0])
+	}
+	qualifyMethods := func(methods []MethodInfo) []MethodInfo {
+		methods = slices.Clone(methods)
+		for i := range methods {
+			method := &methods[i]
+			method.InputType, method.InputKeyType, method.InputElemType = qualify(method.InputType), qualify(method.InputKeyType), qualify(method.InputElemType)
+			method.OutputType = qualify(method.OutputType)
+			method.Params = slices.Clone(method.Params)
+			for j := range method.Params {
+				method.Params[j].Type = qualify(method.Params[j].Type)
+			}
+		}
+		return methods
+	}
+
+	info.Methods, info.Signals, info.Queries = qualifyMethods(info.Methods), qualifyMethods(info.Signals), qualifyMethods(info.Queries)
+	if info.HealthCheck != nil {
+		check := *info.HealthCheck
+		check.StatusType = qualify(check.StatusType)
+		info.HealthCheck = &check
+	}
+	return info
+}
+
+// methodDefinitions describes methods sorted by name
+func methodDefinitions(methods []MethodInfo, structs map[string][]Field) []MethodDefinition {
+	defs := []MethodDefinition{}
+	for _, method := range methods {
+		inputType, inputSchema := method.InputType, structs[method.InputType]
+		if method.IsMultiInput {
+			// The input struct generated in the wrapper is not for callers, the input is named after the
+			// method and its schema lists the parameters. Variadic parameters may be left out.
+			inputType = method.ExposedName + "Input"
+			inputSchema = []Field{}
+			for _, param := range method.Params {
+				inputSchema = append(inputSchema, Field{
+					Name:     param.Name,
+					Type:     param.Type,
+					Tag:      `json:"` + param.JSONName + `"`,
+					JSONName: param.JSONName,
+					Optional: strings.HasPrefix(param.Type, "*") || param.IsVariadic,
+					Schema:   goTypeSchema(param.Type, structs),
+				})
+			}
+		}
+
+		var inputTypeSchema, outputTypeSchema *TypeSchema
+		if method.InputCollection != "" {
+			inputTypeSchema = goTypeSchema(method.InputType, structs)
+			inputTypeSchema.Optional = method.IsInputPointer
+		}
+		if isCollectionType(method.OutputType) {
+			outputTypeSchema = goTypeSchema(method.OutputType, structs)
+		}
+
+		defs = append(defs, MethodDefinition{
+			Name:             method.ExposedName,
+			Description:      method.Description,
+			Doc:              method.Doc,
+			IsWorkflow:       method.IsWorkflow,
+			InputType:        inputType,
+			InputSchema:      inputSchema,
+			InputTypeSchema:  inputTypeSchema,
+			OutputType:       method.OutputType,
+			OutputSchema:     structs[method.OutputType],
+			InputStream:      method.IsInputStream,
+			OutputStream:     method.IsOutputStream,
+			Concurrency:      method.ConcurrencyLimit,
+			OutputTypeSchema: outputTypeSchema,
+			Auth:             method.Auth,
+			Timeout:          timeoutDefinition(method.Timeout),
+			Retry:            retryDefinition(method.Retry),
+			Deprecated:       method.Deprecated,
+			Options:          method.Options,
+		})
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
+
+// wellKnownTypes are structs with a dedicated schema mapping that are not expanded into fields
+var wellKnownTypes = map[string]bool{
+	"time.Time": true,
+}
+
+// isCollectionType reports whether a type expression is a slice, array or map, or a pointer to one
+func isCollectionType(typeStr string) bool {
+	typeStr = strings.TrimPrefix(typeStr, "*")
+	return strings.HasPrefix(typeStr, "[") || strings.HasPrefix(typeStr, "map[")
+}
+
+// goTypeSchema describes a type expression as written in a definition, structs are resolved by name
+// from structs and types it cannot resolve are of kind any with their name
+func goTypeSchema(typeStr string, structs map[string][]Field) *TypeSchema {
+	expr, err := parser.ParseExpr(typeStr)
+	if err != nil {
+		return &TypeSchema{Kind: SchemaKindAny, Type: typeStr}
+	}
+	return exprSchema(expr, structs)
+}
+
+func exprSchema(expr ast.Expr, structs map[string][]Field) *TypeSchema {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		schema := exprSchema(t.X, structs)
+		schema.Optional = true
+		return schema
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") && t.Len == nil {
+			return &TypeSchema{Kind: SchemaKindBytes}
+		}
+		return &TypeSchema{Kind: SchemaKindArray, Elem: exprSchema(t.Elt, structs)}
+	case *ast.MapType:
+		return &TypeSchema{Kind: SchemaKindMap, Key: exprSchema(t.Key, structs), Elem: exprSchema(t.Value, structs)}
+	case *ast.InterfaceType:
+		return &TypeSchema{Kind: SchemaKindAny}
+	}
+
+	name := types.ExprString(expr)
+	if basic, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return (&schemaBuilder{expanding: make(map[string]bool)}).typeSchema(basic.Type())
+	}
+	if wellKnownTypes[name] {
+		return &TypeSchema{Kind: SchemaKindTime, Type: name}
+	}
+	if _, ok := structs[name]; ok {
+		return &TypeSchema{Kind: SchemaKindStruct, Type: name}
+	}
+	return &TypeSchema{Kind: SchemaKindAny, Type: name}
+}
+
+// elemTypeName returns the element type of a slice or array type expression, like T of []T or [4]T
+func elemTypeName(typeStr string) (string, bool) {
+	if !strings.HasPrefix(typeStr, "[") {
+		return "", false
+	}
+	_, elem, ok := strings.Cut(typeStr, "]")
+	return elem, ok
+}
+
+// baseTypeName strips pointer, slice, array and map wrappers from a type expression
+func baseTypeName(typeStr string) string {
+	for {
+		elem, isElem := elemTypeName(typeStr)
+		switch {
+		case strings.HasPrefix(typeStr, "*"):
+			typeStr = typeStr[1:]
+		case isElem:
+			typeStr = elem
+		case strings.HasPrefix(typeStr, "map["):
+			_, typeStr, _ = strings.Cut(typeStr, "]")
+		default:
+			return typeStr
+		}
+	}
+}
+
+// collectNestedTypes adds the schemas of struct types referenced by the fields, recursively. Fields of
+// struct schemas are looked up by the struct they refer to, others by their type as the service writes it.
+func collectNestedTypes(fields []Field, structs map[string][]Field, types map[string][]Field) {
+	for _, field := range fields {
+		collectNestedTypes(inlineFields(field.Schema), structs, types)
+		name := baseTypeName(field.Type)
+		key := field.ref
+		if key == "" {
+			key = name
+		}
+		nested, ok := structs[key]
+		if !ok || wellKnownTypes[name] {
+			continue
+		}
+		if _, seen := types[name]; seen {
+			continue
+		}
+		types[name] = nested
+		collectNestedTypes(nested, structs, types)
+	}
+}
+
+// inlineFields returns the fields of the anonymous structs of a schema, including those of elements
+func inlineFields(schema *TypeSchema) []Field {
+	var fields []Field
+	for ; schema != nil; schema = schema.Elem {
+		fields = append(fields, schema.Fields...)
+	}
+	return fields
+}
+
+// Formats of the service definitions, selected with Options.DefinitionFormats
+const (
+	DefinitionYAML = "yaml"
+	DefinitionJSON = "json"
+	DefinitionCBOR = "cbor"
+)
+
+// definitionFormats lists the definition formats with their file extension, in the order they are read
+var definitionFormats = []struct {
+	name      string
+	extension string
+}{
+	{DefinitionYAML, ".yml"},
+	{DefinitionJSON, ".json"},
+	{DefinitionCBOR, ".cbor"},
+}
+
+// definitionExtension returns the file extension of a definition format, empty for unknown formats
+func definitionExtension(format string) string {
+	for _, f := range definitionFormats {
+		if f.name == format {
+			return f.extension
+		}
+	}
+	return ""
+}
+
+// definitionFormat returns the format of a definition file from its extension, YAML when unknown
+func definitionFormat(file string) string {
+	for _, f := range definitionFormats {
+		if strings.HasSuffix(file, f.extension) {
+			return f.name
+		}
+	}
+	return DefinitionYAML
+}
+
+// encodeDefinition renders a definition, or a file it refers to, in a format. JSON and CBOR are encoded
+// from the struct, so values keep their types instead of going through a YAML conversion.
+func encodeDefinition(def any, format string) ([]byte, error) {
+	switch format {
+	case DefinitionYAML:
+		data, err := yaml.Marshal(def)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(yamlHeader), data...), nil
+	case DefinitionJSON:
+		data, err := json.MarshalIndent(def, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case DefinitionCBOR:
+		return marshalCBOR(def)
+	}
+	return nil, fmt.Errorf("unknown definition format %q", format)
+}
+
+// decodeDefinition parses a definition, or a file it refers to, written by encodeDefinition
+func decodeDefinition(data []byte, format string, def any) error {
+	switch format {
+	case DefinitionYAML:
+		return yaml.Unmarshal(data, def)
+	case DefinitionJSON:
+		return json.Unmarshal(data, def)
+	case DefinitionCBOR:
+		return unmarshalCBOR(data, def)
+	}
+	return fmt.Errorf("unknown definition format %q", format)
+}
+
+// writeServiceDefinition writes the definition of a service in each format into the output folder, signed
+// with key when it is set, and returns the written paths relative to it
+func writeServiceDefinition(output outputFS, outputPath string, def ServiceDefinition, formats []string, key ed25519.PrivateKey) ([]string, error) {
+	definitionFolder := filepath.Join(outputPath, "definition")
+	err := output.MkdirAll(definitionFolder, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create definition folder: %w", err)
+	}
+
+	var written []string
+	for _, format := range formats {
+		data, err := encodeServiceDefinition(def, format, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal definition: %w", err)
+		}
+
+		name := "definition/" + serviceFileName(def.Name) + definitionExtension(format)
+		if err = output.WriteFile(filepath.Join(outputPath, name), data, 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+// definitionFiles returns the definition file of each service relative to the output folder, a service
+// written in several formats is read from the first format of definitionFormats
+func definitionFiles(output outputFS, outputPath string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, format := range definitionFormats {
+		matches, err := output.Glob(filepath.Join(outputPath, "definition", "*"+format.extension))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			name := strings.TrimSuffix(filepath.Base(match), format.extension)
+			if _, ok := files[name]; !ok {
+				files[name] = "definition/" + filepath.Base(match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// LoadServiceDefinitions reads all generated service definitions from an output folder, whatever their format
+func LoadServiceDefinitions(outputPath string) ([]ServiceDefinition, error) {
+	return loadServiceDefinitions(diskFS{}, outputPath)
+}
+
+// loadServiceDefinitions reads the service definitions through the file system of a run, which holds
+// the definitions written by a dry run
+func loadServiceDefinitions(output outputFS, outputPath string) ([]ServiceDefinition, error) {
+	files, err := definitionFiles(output, outputPath)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var defs []ServiceDefinition
+	shared := make(map[string][]Field)
+	for _, name := range names {
+		file := filepath.Join(outputPath, files[name])
+		data, err := output.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var def ServiceDefinition
+		if err = decodeDefinition(data, definitionFormat(file), &def); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if err = resolveSharedTypes(output, outputPath, &def, shared); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
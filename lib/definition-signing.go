@@ -0,0 +1,307 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// hashPrefix names the algorithm of the content hashes of definitions
+const hashPrefix = "sha256:"
+
+// integrityKey is the key of the integrity block in the encoding of a signed definition
+const integrityKey = "integrity"
+
+// DefinitionIntegrity lets the control plane check that a definition, or a shared schema it refers to,
+// was written by a generator holding the signing key and not edited since. It is embedded in the file
+// under integrity. Hash is the SHA-256 of the canonical encoding of the file content without its
+// integrity, Signature the Ed25519 signature of that hash.
+type DefinitionIntegrity struct {
+	Hash      string `yaml:"hash" json:"hash"`           // sha256:<hex>
+	Signature string `yaml:"signature" json:"signature"` // Base64
+	KeyID     string `yaml:"keyId" json:"keyId"`         // Fingerprint of the public key, sha256:<hex prefix>
+}
+
+// loadSigningKey reads the Ed25519 private key definitions are signed with, a PKCS #8 PEM file like the
+// one written by openssl genpkey -algorithm ed25519
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("signing key %s must be a PKCS #8 PEM private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+	// Ed25519 signatures are deterministic, unchanged definitions are written with the same signature
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s must be an Ed25519 key, got %T", path, key)
+	}
+	return private, nil
+}
+
+// ParseVerifyKey parses the Ed25519 public key signed definitions are checked with, a PKIX PEM file like
+// the one written by openssl pkey -pubout
+func ParseVerifyKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("verify key must be a PKIX PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse verify key: %w", err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("verify key must be an Ed25519 key, got %T", key)
+	}
+	return public, nil
+}
+
+// keyID returns the fingerprint of a public key, empty without a key
+func keyID(key ed25519.PublicKey) string {
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return hashPrefix + hex.EncodeToString(sum[:8])
+}
+
+// signingKeyID returns the fingerprint of the public half of a signing key, empty without a key
+func signingKeyID(key ed25519.PrivateKey) string {
+	if key == nil {
+		return ""
+	}
+	return keyID(key.Public().(ed25519.PublicKey))
+}
+
+// decodeGeneric parses a definition file of a format into maps, lists and scalars, numbers are json.Number
+func decodeGeneric(data []byte, format string) (any, error) {
+	var value any
+	switch format {
+	case DefinitionYAML:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	case DefinitionJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	case DefinitionCBOR:
+		d := &cborDecoder{data: data}
+		var err error
+		if value, err = d.decode(); err != nil {
+			return nil, err
+		}
+		if d.pos != len(data) {
+			return nil, fmt.Errorf("cbor: %d trailing bytes", len(data)-d.pos)
+		}
+	default:
+		return nil, fmt.Errorf("unknown definition format %q", format)
+	}
+	return canonicalValue(value)
+}
+
+// canonicalValue converts a decoded value so that the same content decoded from any format is equal:
+// map keys are strings and numbers are json.Number in their shortest form
+func canonicalValue(value any) (any, error) {
+	var err error
+	switch v := value.(type) {
+	case map[string]any:
+		for key, element := range v {
+			if v[key], err = canonicalValue(element); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[any]any:
+		object := make(map[string]any, len(v))
+		for key, element := range v {
+			if object[fmt.Sprint(key)], err = canonicalValue(element); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case []any:
+		for i, element := range v {
+			if v[i], err = canonicalValue(element); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case json.Number:
+		return canonicalNumber(string(v))
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return canonicalNumber(strconv.FormatFloat(v, 'g', -1, 64))
+	case string, bool, []byte, nil:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected %T value in definition", value)
+}
+
+// canonicalNumber renders integers in decimal and other numbers as the shortest text of their float64
+func canonicalNumber(text string) (json.Number, error) {
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}
+	if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid number %q in definition", text)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return json.Number(strconv.FormatInt(int64(f), 10)), nil
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// readIntegrity decodes a definition file and returns the SHA-256 of its canonical encoding without its
+// integrity, and the integrity it embeds, nil when it is not signed
+func readIntegrity(data []byte, format string) ([]byte, *DefinitionIntegrity, error) {
+	value, err := decodeGeneric(data, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected an object, got %T", value)
+	}
+
+	var integrity *DefinitionIntegrity
+	if block, ok := object[integrityKey]; ok {
+		delete(object, integrityKey)
+		encoded, err := json.Marshal(block)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = json.Unmarshal(encoded, &integrity); err != nil {
+			return nil, nil, fmt.Errorf("invalid integrity: %w", err)
+		}
+	}
+	// encoding/json sorts map keys, the encoding is the same whichever format and layout it was read from
+	canonical, err := json.Marshal(object)
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	return sum[:], integrity, nil
+}
+
+// sealEncoded returns the integrity of a definition file encoded without one
+func sealEncoded(data []byte, format string, key ed25519.PrivateKey) (*DefinitionIntegrity, error) {
+	sum, _, err := readIntegrity(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return &DefinitionIntegrity{
+		Hash:      hashPrefix + hex.EncodeToString(sum),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, sum)),
+		KeyID:     signingKeyID(key),
+	}, nil
+}
+
+// encodeServiceDefinition encodes a definition in a format with its integrity when key is set, without
+// a key the integrity it was read with is dropped
+func encodeServiceDefinition(def ServiceDefinition, format string, key ed25519.PrivateKey) ([]byte, error) {
+	def.Integrity = nil
+	data, err := encodeDefinition(def, format)
+	if err != nil || key == nil {
+		return data, err
+	}
+	if def.Integrity, err = sealEncoded(data, format, key); err != nil {
+		return nil, fmt.Errorf("failed to sign definition of %s: %w", def.Name, err)
+	}
+	return encodeDefinition(def, format)
+}
+
+// encodeSharedType encodes a shared schema in a format with its integrity when key is set
+func encodeSharedType(shared SharedType, format string, key ed25519.PrivateKey) ([]byte, error) {
+	shared.Integrity = nil
+	data, err := encodeDefinition(shared, format)
+	if err != nil || key == nil {
+		return data, err
+	}
+	if shared.Integrity, err = sealEncoded(data, format, key); err != nil {
+		return nil, fmt.Errorf("failed to sign shared type %s: %w", shared.Name, err)
+	}
+	return encodeDefinition(shared, format)
+}
+
+// verifyFile checks a definition or shared schema file against the integrity it embeds
+func verifyFile(data []byte, format string, key ed25519.PublicKey) error {
+	sum, integrity, err := readIntegrity(data, format)
+	if err != nil {
+		return err
+	}
+	if integrity == nil {
+		return errors.New("not signed")
+	}
+	if integrity.Hash != hashPrefix+hex.EncodeToString(sum) {
+		return errors.New("content does not match its hash, it was edited after generation")
+	}
+	signature, err := base64.StdEncoding.DecodeString(integrity.Signature)
+	if err != nil || !ed25519.Verify(key, sum, signature) {
+		if integrity.KeyID != keyID(key) {
+			return fmt.Errorf("signed with key %s, not the verify key %s", integrity.KeyID, keyID(key))
+		}
+		return errors.New("signature does not match its hash")
+	}
+	return nil
+}
+
+// VerifyDefinitions checks every definition and shared schema of an output folder, in every format
+// they are written in, and returns the files failing the check
+func VerifyDefinitions(outputPath string, key ed25519.PublicKey) error {
+	output := diskFS{}
+	var failures []string
+	for _, folder := range []string{"definition", sharedSchemaFolder} {
+		for _, format := range definitionFormats {
+			files, err := output.Glob(filepath.Join(outputPath, filepath.FromSlash(folder), "*"+format.extension))
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				data, err := output.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				if err = verifyFile(data, format.name, key); err != nil {
+					failures = append(failures, folder+"/"+filepath.Base(file)+": "+err.Error())
+				}
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d definition file(s) failed verification:\n  %s", len(failures), strings.Join(failures, "\n  "))
+	}
+	return nil
+}
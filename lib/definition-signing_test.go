@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeSignedDefinition writes a definition in every format signed with key and returns the output folder
+func writeSignedDefinition(t *testing.T, key ed25519.PrivateKey) (string, []string) {
+	t.Helper()
+	outputPath := t.TempDir()
+	def := ServiceDefinition{Name: "orders", Types: map[string][]Field{"Order": {{Name: "id", Type: "string"}}}}
+	written, err := writeServiceDefinition(diskFS{}, outputPath, def, []string{DefinitionYAML, DefinitionJSON, DefinitionCBOR}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outputPath, written
+}
+
+func TestVerifyDefinitions(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(t *testing.T, outputPath string)
+		key     ed25519.PublicKey
+		wantErr string
+	}{
+		{name: "unedited", key: public},
+		{name: "wrong key", key: otherPublic, wantErr: "not the verify key"},
+		{
+			name: "field added by hand",
+			edit: func(t *testing.T, outputPath string) {
+				appendFile(t, filepath.Join(outputPath, "definition", "orders.yml"), "owner: someone\n")
+			},
+			key:     public,
+			wantErr: "definition/orders.yml: content does not match its hash",
+		},
+		{
+			name: "value edited",
+			edit: func(t *testing.T, outputPath string) {
+				file := filepath.Join(outputPath, "definition", "orders.json")
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data = []byte(strings.Replace(string(data), `"string"`, `"int"`, 1))
+				if err = os.WriteFile(file, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			key:     public,
+			wantErr: "definition/orders.json: content does not match its hash",
+		},
+		{
+			name: "reformatted",
+			edit: func(t *testing.T, outputPath string) {
+				appendFile(t, filepath.Join(outputPath, "definition", "orders.json"), "\n")
+			},
+			key: public,
+		},
+		{
+			name: "integrity removed",
+			edit: func(t *testing.T, outputPath string) {
+				def := ServiceDefinition{Name: "orders", Types: map[string][]Field{"Order": {{Name: "id", Type: "string"}}}}
+				if _, err := writeServiceDefinition(diskFS{}, outputPath, def, []string{DefinitionCBOR}, nil); err != nil {
+					t.Fatal(err)
+				}
+			},
+			key:     public,
+			wantErr: "definition/orders.cbor: not signed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath, _ := writeSignedDefinition(t, private)
+			if tt.edit != nil {
+				tt.edit(t, outputPath)
+			}
+			err := VerifyDefinitions(outputPath, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteServiceDefinitionIntegrity(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputPath, written := writeSignedDefinition(t, private)
+	if want := []string{"definition/orders.yml", "definition/orders.json", "definition/orders.cbor"}; !slices.Equal(written, want) {
+		t.Errorf("got %v, want %v", written, want)
+	}
+	for _, name := range written {
+		def := readDefinition(t, filepath.Join(outputPath, name))
+		if def.Integrity == nil || def.Integrity.KeyID != signingKeyID(private) {
+			t.Errorf("%s: got integrity %+v, want one with the key %s", name, def.Integrity, signingKeyID(private))
+		}
+	}
+
+	// Without a key the integrity of the previous run is dropped
+	def := readDefinition(t, filepath.Join(outputPath, "definition", "orders.yml"))
+	if _, err = writeServiceDefinition(diskFS{}, outputPath, def, []string{DefinitionYAML}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if def = readDefinition(t, filepath.Join(outputPath, "definition", "orders.yml")); def.Integrity != nil {
+		t.Errorf("got integrity %+v, want none", def.Integrity)
+	}
+}
+
+// readDefinition decodes a definition file as is
+func readDefinition(t *testing.T, file string) ServiceDefinition {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def ServiceDefinition
+	if err = decodeDefinition(data, definitionFormat(file), &def); err != nil {
+		t.Fatal(err)
+	}
+	return def
+}
+
+func appendFile(t *testing.T, path string, text string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
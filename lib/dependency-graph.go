@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	dependenciesFileName = "dependencies.yml"
+	dependenciesDOTName  = "dependencies.dot"
+)
+
+// invokeMethods are the SDK and generated client calls taking the target service name as first argument
+var invokeMethods = map[string]bool{"Invoke": true, "Service": true, "GetService": true}
+
+// DependencyGraph is the content of .polycode/dependencies.yml, the services each service calls
+type DependencyGraph struct {
+	Services map[string][]string `yaml:"services"`
+}
+
+// buildDependencyGraph finds the calls between services. A service depends on another when it imports
+// the generated client of that service or invokes it by name through the SDK.
+func buildDependencyGraph(appPath string, moduleName string, entries []serviceEntry, opts Options) (DependencyGraph, error) {
+	graph := DependencyGraph{Services: make(map[string][]string)}
+
+	known := make(map[string]bool, len(entries))
+	clients := make(map[string]string, len(entries))
+	clientsPath := moduleName + "/" + filepath.ToSlash(filepath.Clean(opts.OutputDir)) + "/clients/"
+	for _, entry := range entries {
+		known[entry.Name] = true
+		clients[clientsPath+clientPackageName(entry.Name)] = entry.Name
+	}
+
+	for _, entry := range entries {
+		calls := make(map[string]bool)
+		files, err := filepath.Glob(filepath.Join(appPath, entry.Dir, "*.go"))
+		if err != nil {
+			return graph, err
+		}
+
+		for _, file := range files {
+			rel, _ := filepath.Rel(filepath.Join(appPath, entry.Dir), file)
+			if strings.HasSuffix(file, "_test.go") || isExcluded(rel, opts.Exclude) {
+				continue
+			}
+
+			node, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+			if err != nil {
+				return graph, err
+			}
+
+			for _, imp := range node.Imports {
+				importPath, _ := strconv.Unquote(imp.Path.Value)
+				if target, ok := clients[importPath]; ok {
+					calls[target] = true
+				}
+			}
+
+			ast.Inspect(node, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || !invokeMethods[sel.Sel.Name] {
+					return true
+				}
+				if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					if target, err := strconv.Unquote(lit.Value); err == nil && known[target] {
+						calls[target] = true
+					}
+				}
+				return true
+			})
+		}
+
+		// A service calling itself is not a dependency
+		delete(calls, entry.Name)
+		targets := []string{}
+		for target := range calls {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		graph.Services[entry.Name] = targets
+	}
+
+	return graph, nil
+}
+
+// dot renders the graph in Graphviz DOT format, an edge points from the caller to the called service
+func (g DependencyGraph) dot() []byte {
+	names := make([]string, 0, len(g.Services))
+	for name := range g.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("// " + generatedHeader() + ". DO NOT EDIT.\ndigraph services {\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q;\n", name)
+	}
+	for _, name := range names {
+		for _, target := range g.Services[name] {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", name, target)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeDependencyGraph writes .polycode/dependencies.yml and .polycode/dependencies.dot
+func writeDependencyGraph(output outputFS, outputPath string, graph DependencyGraph) error {
+	if err := output.MkdirAll(outputPath, 0755); err != nil {
+		return fmt.Errorf("failed to create output folder: %w", err)
+	}
+
+	data, err := yaml.Marshal(graph)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dependency graph: %w", err)
+	}
+	if err = output.WriteFile(filepath.Join(outputPath, dependenciesFileName), append([]byte(yamlHeader), data...), 0644); err != nil {
+		return err
+	}
+	return output.WriteFile(filepath.Join(outputPath, dependenciesDOTName), graph.dot(), 0644)
+}
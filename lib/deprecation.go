@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// deprecationSupportName holds the deprecation reporting shared by the wrappers of all services
+const deprecationSupportName = "deprecation.go"
+
+const deprecationSupport = `// Code generated by next-gen. DO NOT EDIT.
+package %s
+
+import (
+	"log/slog"
+)
+
+// DeprecatedCallHook is called on every call of a deprecated method after the warning is logged,
+// set it to count the calls in a metric
+var DeprecatedCallHook func(service string, method string)
+
+// reportDeprecatedCall logs the call of a method marked deprecated and notifies DeprecatedCallHook
+func reportDeprecatedCall(service string, method string, since string, use string) {
+	attrs := []any{"service", service, "method", method}
+	if since != "" {
+		attrs = append(attrs, "since", since)
+	}
+	if use != "" {
+		attrs = append(attrs, "use", use)
+	}
+	slog.Warn("Deprecated method called", attrs...)
+	if DeprecatedCallHook != nil {
+		DeprecatedCallHook(service, method)
+	}
+}
+`
+
+// Deprecation marks a method deprecated, by a "Deprecated:" paragraph of its doc comment or a
+// //polycode:deprecated since=v2 use=CreateOrderV2 directive
+type Deprecation struct {
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	Since   string `yaml:"since,omitempty" json:"since,omitempty"`
+	Use     string `yaml:"use,omitempty" json:"use,omitempty"` // Method replacing the deprecated one
+}
+
+// parseDeprecation returns the deprecation declared in the doc comment of a function, nil when there is none
+func parseDeprecation(doc *ast.CommentGroup) (*Deprecation, error) {
+	if doc == nil {
+		return nil, nil
+	}
+
+	var deprecation *Deprecation
+	// Like go doc, a paragraph starting with "Deprecated: " holds the deprecation notice
+	for _, paragraph := range strings.Split(doc.Text(), "\n\n") {
+		if message, ok := strings.CutPrefix(paragraph, "Deprecated: "); ok {
+			deprecation = &Deprecation{Message: strings.Join(strings.Fields(message), " ")}
+			break
+		}
+	}
+
+	if args, ok := parseDirectives(doc)["deprecated"]; ok {
+		if deprecation == nil {
+			deprecation = &Deprecation{}
+		}
+		for key, value := range parseDirectiveArgs(args) {
+			switch key {
+			case "since":
+				deprecation.Since = value
+			case "use":
+				deprecation.Use = value
+			default:
+				return nil, fmt.Errorf("//polycode:deprecated: unknown option %q, expected since=<version> or use=<method>", key)
+			}
+		}
+	}
+	return deprecation, nil
+}
+
+// Notice returns the deprecation notice as a Go "Deprecated:" paragraph, like
+// "Deprecated: Since v2. Use CreateOrderV2 instead."
+func (d *Deprecation) Notice() string {
+	var parts []string
+	if d.Since != "" {
+		parts = append(parts, "Since "+d.Since+".")
+	}
+	if d.Message != "" {
+		parts = append(parts, d.Message)
+	} else if d.Use != "" {
+		parts = append(parts, "Use "+d.Use+" instead.")
+	}
+	if len(parts) == 0 {
+		return "Deprecated: Do not use."
+	}
+	return "Deprecated: " + strings.Join(parts, " ")
+}
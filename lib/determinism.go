@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// nondeterministicCall lists the functions of a package that break workflow determinism, every
+// function of the package when funcs is empty
+type nondeterministicCall struct {
+	funcs  []string
+	reason string
+}
+
+const (
+	reasonClock   = "reads the clock or starts a timer, which differs when the workflow is replayed"
+	reasonRandom  = "returns random values, which differ when the workflow is replayed"
+	reasonIO      = "performs network or file IO, move it into a service method called from the workflow"
+	reasonProcess = "runs a process, move it into a service method called from the workflow"
+)
+
+// nondeterministicCalls are the calls flagged in workflow code by import path
+var nondeterministicCalls = map[string]nondeterministicCall{
+	"time":         {funcs: []string{"Now", "Since", "Until", "Sleep", "After", "AfterFunc", "Tick", "NewTimer", "NewTicker"}, reason: reasonClock},
+	"math/rand":    {reason: reasonRandom},
+	"math/rand/v2": {reason: reasonRandom},
+	"crypto/rand":  {reason: reasonRandom},
+	"os": {funcs: []string{"Open", "OpenFile", "Create", "CreateTemp", "ReadFile", "WriteFile", "ReadDir", "Stat", "Lstat",
+		"Remove", "RemoveAll", "Rename", "Mkdir", "MkdirAll", "MkdirTemp", "Truncate", "Chmod", "Chdir", "Getwd", "Hostname"}, reason: reasonIO},
+	"io/ioutil": {reason: reasonIO},
+	"net": {funcs: []string{"Dial", "DialTimeout", "DialTCP", "DialUDP", "DialIP", "DialUnix", "Listen", "ListenPacket", "ListenTCP", "ListenUDP",
+		"LookupHost", "LookupIP", "LookupAddr", "LookupCNAME", "LookupMX", "LookupNS", "LookupPort", "LookupSRV", "LookupTXT"}, reason: reasonIO},
+	"net/http": {funcs: []string{"Get", "Head", "Post", "PostForm", "ListenAndServe", "ListenAndServeTLS", "Serve", "ServeTLS"}, reason: reasonIO},
+	"net/smtp": {funcs: []string{"Dial", "SendMail"}, reason: reasonIO},
+	"os/exec":  {reason: reasonProcess},
+}
+
+// DeterminismIssue is a construct in workflow code that breaks determinism, workflows are replayed
+// from their history and must take the same path every time
+type DeterminismIssue struct {
+	Position  token.Position // File relative to the app root
+	Function  string         // Workflow function the construct is in
+	Construct string         // Like time.Now or "go statement"
+	Reason    string
+}
+
+func (i DeterminismIssue) String() string {
+	return fmt.Sprintf("%s: workflow %s: %s %s", i.Position, i.Function, i.Construct, i.Reason)
+}
+
+// checkDeterminism reports calls to the clock, random numbers, network and file IO and goroutines
+// started in the functions of a service taking a polycode.WorkflowContext, unexported helpers
+// included. Calls are matched syntactically against the imports of each file, functions called
+// through variables or methods of other types are not followed.
+func checkDeterminism(appPath string, serviceFolder string, servicePackage string, exclude []string, contexts contextTypes) ([]DeterminismIssue, error) {
+	files, err := filepath.Glob(filepath.Join(serviceFolder, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var issues []DeterminismIssue
+	for _, file := range files {
+		rel, _ := filepath.Rel(serviceFolder, file)
+		if strings.HasSuffix(file, "_test.go") || isExcluded(rel, exclude) {
+			continue
+		}
+
+		node, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Import paths keyed by the name they are referenced with in this file
+		imports := make(map[string]string)
+		for _, imp := range node.Imports {
+			importPath, _ := strconv.Unquote(imp.Path.Value)
+			name := importName(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports[name] = importPath
+		}
+		resolve := contexts.resolver(node, servicePackage)
+
+		for _, decl := range node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || !takesWorkflowContext(fn, resolve) {
+				continue
+			}
+			report := func(pos token.Pos, construct string, reason string) {
+				position := fset.Position(pos)
+				if relFile, err := filepath.Rel(appPath, position.Filename); err == nil {
+					position.Filename = filepath.ToSlash(relFile)
+				}
+				issues = append(issues, DeterminismIssue{Position: position, Function: fn.Name.Name, Construct: construct, Reason: reason})
+			}
+
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.GoStmt:
+					report(n.Pos(), "go statement", "starts a goroutine, which is not scheduled deterministically")
+				case *ast.CallExpr:
+					sel, ok := n.Fun.(*ast.SelectorExpr)
+					if !ok {
+						return true
+					}
+					// Identifiers resolved to a local declaration shadow the package name
+					pkg, ok := sel.X.(*ast.Ident)
+					if !ok || pkg.Obj != nil {
+						return true
+					}
+					call, ok := nondeterministicCalls[imports[pkg.Name]]
+					if ok && (len(call.funcs) == 0 || slices.Contains(call.funcs, sel.Sel.Name)) {
+						report(n.Pos(), pkg.Name+"."+sel.Sel.Name, call.reason)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return issues, nil
+}
+
+// takesWorkflowContext reports whether a function has a polycode.WorkflowContext parameter, or an alias of it
+func takesWorkflowContext(fn *ast.FuncDecl, resolve contextResolver) bool {
+	for _, param := range flattenFields(fn.Type.Params) {
+		if resolve(param) == "Workflow" {
+			return true
+		}
+	}
+	return false
+}
+
+// importName returns the name a package is referenced with when imported without a name, the
+// element before a major version suffix like math/rand/v2
+func importName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
+
+// determinismError is the error of a service whose workflows have determinism issues in strict mode,
+// located at the first issue
+func determinismError(issues []DeterminismIssue) error {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.String()
+	}
+	err := fmt.Errorf("%d non-deterministic construct(s) in workflows:\n%s", len(issues), strings.Join(lines, "\n"))
+	return &positionError{pos: issues[0].Position, err: err}
+}
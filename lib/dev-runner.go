@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// appStopTimeout is how long the app gets to exit after an interrupt before it is killed
+const appStopTimeout = 5 * time.Second
+
+// DefaultDevCommands returns the build and run commands used when none are configured,
+// the app is built into a temporary folder so the app tree stays clean
+func DefaultDevCommands(appPath string) (string, string) {
+	binary := filepath.Join(os.TempDir(), "next-gen-"+filepath.Base(filepath.Clean(appPath)), "app")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	return "go build -o " + binary + " .", binary
+}
+
+// AppRunner rebuilds and restarts the user's application for the dev loop, or reruns the command given
+// with -run in watch mode. The process's stdout and stderr are streamed to ours.
+type AppRunner struct {
+	dir   string
+	build []string
+	run   []string
+	name  string // What runs, app or command, in log messages
+	// group runs the process in its own process group and signals the whole group, so stopping a
+	// command also stops what it started, like the test binaries of go test
+	group bool
+
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// NewAppRunner returns a runner executing the build and run commands in dir, an empty build command skips the build step
+func NewAppRunner(dir string, buildCommand string, runCommand string) (*AppRunner, error) {
+	run := strings.Fields(runCommand)
+	if len(run) == 0 {
+		return nil, fmt.Errorf("no run command configured")
+	}
+	return &AppRunner{dir: dir, build: strings.Fields(buildCommand), run: run, name: "app"}, nil
+}
+
+// NewCommandRunner returns a runner executing a command line through the platform shell in dir,
+// restarting it kills the previous invocation
+func NewCommandRunner(dir string, command string) (*AppRunner, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("no command to run")
+	}
+	return &AppRunner{dir: dir, run: shellArgs(command), name: "command", group: true}, nil
+}
+
+// Restart builds the application and replaces the running instance. When the build fails
+// the previous instance keeps running and the build error is returned.
+func (r *AppRunner) Restart() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.build) > 0 {
+		slog.Info("Building app", "command", strings.Join(r.build, " "))
+		cmd := exec.Command(r.build[0], r.build[1:]...)
+		cmd.Dir = r.dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("build failed: %s", strings.TrimSpace(string(output)))
+		}
+	}
+
+	r.stop()
+
+	slog.Info("Starting "+r.name, "command", strings.Join(r.run, " "))
+	cmd := exec.Command(r.run[0], r.run[1:]...)
+	cmd.Dir = r.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if r.group {
+		startProcessGroup(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", r.name, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("Process exited", "process", r.name, "error", err)
+		} else {
+			slog.Info("Process exited", "process", r.name)
+		}
+	}()
+
+	r.cmd = cmd
+	r.done = done
+	return nil
+}
+
+// Stop terminates the running process, if any
+func (r *AppRunner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stop()
+}
+
+func (r *AppRunner) stop() {
+	if r.cmd == nil {
+		return
+	}
+
+	select {
+	case <-r.done:
+		// Already exited on its own
+	default:
+		slog.Info("Stopping " + r.name)
+		r.signal(syscall.SIGTERM)
+		select {
+		case <-r.done:
+		case <-time.After(appStopTimeout):
+			slog.Warn("Process did not stop in time, killing it", "process", r.name)
+			r.signal(syscall.SIGKILL)
+			<-r.done
+		}
+	}
+	r.cmd = nil
+	r.done = nil
+}
+
+// signal sends a signal to the running process, or to its process group
+func (r *AppRunner) signal(sig syscall.Signal) {
+	if r.group {
+		_ = signalProcessGroup(r.cmd, sig)
+	} else if sig == syscall.SIGKILL {
+		_ = r.cmd.Process.Kill()
+	} else {
+		_ = r.cmd.Process.Signal(sig)
+	}
+}
@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is a finding attached to a source position
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Source  string // Tool or stage that produced the finding
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			pos += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	if pos == "" {
+		return fmt.Sprintf("[%s] %s", d.Source, d.Message)
+	}
+	return fmt.Sprintf("%s: [%s] %s", pos, d.Source, d.Message)
+}
+
+// DiagnosticsError reports a list of diagnostics as a single error
+type DiagnosticsError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics)+1)
+	lines = append(lines, fmt.Sprintf("%d problem(s) found:", len(e.Diagnostics)))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, "  "+d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var diagnosticLine = regexp.MustCompile(`^(?:vet: )?(.+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseDiagnostics extracts file:line:col: message findings from tool output, paths are made relative to baseDir
+func parseDiagnostics(output string, source string, baseDir string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		match := diagnosticLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		file := match[1]
+		if rel, err := filepath.Rel(baseDir, file); err == nil && filepath.IsAbs(file) {
+			file = rel
+		}
+		lineNo, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+
+		diags = append(diags, Diagnostic{
+			File:    file,
+			Line:    lineNo,
+			Column:  column,
+			Source:  source,
+			Message: match[4],
+		})
+	}
+	return diags
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// maxDiffCells bounds the size of the line matching table, larger changes are shown as a full replacement
+const maxDiffCells = 4_000_000
+
+// diffLine is a line of an edit script: ' ' kept, '-' removed or '+' added
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff turning before into after, empty when they are equal
+func unifiedDiff(beforeName string, afterName string, before []byte, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+
+	script := editScript(splitLines(before), splitLines(after))
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", beforeName, afterName)
+
+	// Walk the script keeping the line numbers of both sides, a hunk spans changes less than
+	// two contexts apart
+	beforeLine, afterLine := 1, 1
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			beforeLine, afterLine = beforeLine+1, afterLine+1
+			i++
+			continue
+		}
+
+		start := max(i-diffContext, 0)
+		end := i
+		for gap := 0; end < len(script) && gap <= 2*diffContext; end++ {
+			if script[end].op == ' ' {
+				gap++
+			} else {
+				gap = 0
+			}
+		}
+		// Trim the trailing context down to diffContext lines
+		for end > i && script[end-1].op == ' ' && countTrailingKept(script[:end]) > diffContext {
+			end--
+		}
+
+		hunkBefore, hunkAfter := beforeLine-(i-start), afterLine-(i-start)
+		var beforeCount, afterCount int
+		var body strings.Builder
+		for _, line := range script[start:end] {
+			body.WriteString(string(line.op) + line.text + "\n")
+			if line.op != '+' {
+				beforeCount++
+			}
+			if line.op != '-' {
+				afterCount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n%s", hunkRange(hunkBefore, beforeCount), hunkRange(hunkAfter, afterCount), body.String())
+
+		for _, line := range script[i:end] {
+			if line.op != '+' {
+				beforeLine++
+			}
+			if line.op != '-' {
+				afterLine++
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+// hunkRange formats the start and length of a hunk side, an empty side starts at the line before it
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func countTrailingKept(script []diffLine) int {
+	n := 0
+	for i := len(script) - 1; i >= 0 && script[i].op == ' '; i-- {
+		n++
+	}
+	return n
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// editScript matches the lines of both sides by their longest common subsequence, after skipping
+// the common prefix and suffix
+func editScript(before []string, after []string) []diffLine {
+	var prefix, suffix int
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	var script []diffLine
+	for _, line := range before[:prefix] {
+		script = append(script, diffLine{' ', line})
+	}
+
+	a, b := before[prefix:len(before)-suffix], after[prefix:len(after)-suffix]
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			script = append(script, diffLine{'-', line})
+		}
+		for _, line := range b {
+			script = append(script, diffLine{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				script = append(script, diffLine{' ', a[i]})
+				i, j = i+1, j+1
+			case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+				script = append(script, diffLine{'+', b[j]})
+				j++
+			default:
+				script = append(script, diffLine{'-', a[i]})
+				i++
+			}
+		}
+	}
+
+	for _, line := range before[len(before)-suffix:] {
+		script = append(script, diffLine{' ', line})
+	}
+	return script
+}
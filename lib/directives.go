@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"go/ast"
+	"strings"
+)
+
+const directivePrefix = "polycode:"
+
+// parseDirectives collects the //polycode:<name> <args> comments attached to a declaration
+func parseDirectives(doc *ast.CommentGroup) map[string]string {
+	directives := make(map[string]string)
+	if doc == nil {
+		return directives
+	}
+
+	for _, c := range doc.List {
+		// Directives must be line comments without a space, like //go: directives
+		if !strings.HasPrefix(c.Text, "//"+directivePrefix) {
+			continue
+		}
+
+		line := strings.TrimPrefix(c.Text, "//"+directivePrefix)
+		name, args, _ := strings.Cut(line, " ")
+		directives[strings.TrimSpace(name)] = strings.TrimSpace(args)
+	}
+	return directives
+}
+
+// parseDirectiveList returns the arguments of every occurrence of a directive that may be repeated
+func parseDirectiveList(doc *ast.CommentGroup, name string) []string {
+	var list []string
+	if doc == nil {
+		return list
+	}
+
+	for _, c := range doc.List {
+		line, ok := strings.CutPrefix(c.Text, "//"+directivePrefix+name)
+		if ok && (line == "" || line[0] == ' ') {
+			list = append(list, strings.TrimSpace(line))
+		}
+	}
+	return list
+}
+
+// parseDirectiveArgs splits directive arguments like "timeout=30s retries=3 idempotent" into
+// key/value pairs, bare keys are flags set to "true"
+func parseDirectiveArgs(args string) map[string]string {
+	values := make(map[string]string)
+	for _, arg := range strings.Fields(args) {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			value = "true"
+		}
+		values[key] = value
+	}
+	return values
+}
@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"go/ast"
+	"reflect"
+	"testing"
+)
+
+// commentGroup returns the doc comment made of the given comment lines
+func commentGroup(lines ...string) *ast.CommentGroup {
+	doc := &ast.CommentGroup{}
+	for _, line := range lines {
+		doc.List = append(doc.List, &ast.Comment{Text: line})
+	}
+	return doc
+}
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  map[string]string
+	}{
+		{name: "no doc", want: map[string]string{}},
+		{
+			name:  "directives among comments",
+			lines: []string{"// Create places an order", "//polycode:timeout 30s", "//polycode:method name=place idempotent"},
+			want:  map[string]string{"timeout": "30s", "method": "name=place idempotent"},
+		},
+		{
+			name:  "spaced comments are not directives",
+			lines: []string{"// polycode:timeout 30s", "/* polycode:timeout 30s */"},
+			want:  map[string]string{},
+		},
+		{
+			name:  "flag without arguments",
+			lines: []string{"//polycode:deprecated"},
+			want:  map[string]string{"deprecated": ""},
+		},
+		{
+			name:  "last occurrence wins",
+			lines: []string{"//polycode:timeout 10s", "//polycode:timeout  20s "},
+			want:  map[string]string{"timeout": "20s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc *ast.CommentGroup
+			if tt.lines != nil {
+				doc = commentGroup(tt.lines...)
+			}
+			if got := parseDirectives(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirectiveList(t *testing.T) {
+	doc := commentGroup(
+		"//polycode:http GET /orders/{id}",
+		"//polycode:httpx ignored",
+		"//polycode:http",
+		"// polycode:http POST /spaced",
+		"//polycode:http POST /orders",
+	)
+	want := []string{"GET /orders/{id}", "", "POST /orders"}
+	if got := parseDirectiveList(doc, "http"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := parseDirectiveList(nil, "http"); len(got) != 0 {
+		t.Errorf("got %q for no doc, want none", got)
+	}
+}
+
+func TestParseDirectiveArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want map[string]string
+	}{
+		{args: "", want: map[string]string{}},
+		{args: "timeout=30s retries=3", want: map[string]string{"timeout": "30s", "retries": "3"}},
+		{args: "idempotent", want: map[string]string{"idempotent": "true"}},
+		{args: "  name=place   idempotent ", want: map[string]string{"name": "place", "idempotent": "true"}},
+		{args: "filter=a=b empty=", want: map[string]string{"filter": "a=b", "empty": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			if got := parseDirectiveArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
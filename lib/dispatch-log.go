@@ -0,0 +1,28 @@
+package lib
+
+// dispatchLogSupportName holds the debug logging of the calls dispatched by the wrappers
+const dispatchLogSupportName = "dispatch-log.go"
+
+const dispatchLogSupport = `// Code generated by next-gen. DO NOT EDIT.
+package %s
+
+import (
+	"log/slog"
+	"time"
+)
+
+// logDispatch logs a call dispatched by a wrapper at debug level, the returned function logs its
+// outcome with the error the method returned
+func logDispatch(service string, method string, kind string) func(err *error) {
+	slog.Debug("Dispatching call", "service", service, "method", method, "kind", kind)
+	start := time.Now()
+
+	return func(err *error) {
+		if *err != nil {
+			slog.Debug("Call failed", "service", service, "method", method, "kind", kind, "duration", time.Since(start), "error", *err)
+			return
+		}
+		slog.Debug("Call finished", "service", service, "method", method, "kind", kind, "duration", time.Since(start))
+	}
+}
+`
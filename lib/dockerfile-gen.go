@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DockerfileName is the file next-gen package writes into the app
+const DockerfileName = "Dockerfile"
+
+// dockerfileMarker is in the files written by next-gen package, only those are overwritten without -force
+const dockerfileMarker = "# Generated by next-gen package"
+
+// DefaultBaseImage is the image the app binary runs in, the binary is built without cgo
+const DefaultBaseImage = "gcr.io/distroless/static-debian12:nonroot"
+
+const dockerfileTemplate = `# syntax=docker/dockerfile:1
+{{.Marker}} {{.Version}}, run it again after changing the app layout.
+# The wrappers and definitions are generated inside the build, the image always carries the
+# definitions of the services it was built from.
+
+FROM {{.GoImage}} AS build
+WORKDIR /src
+
+COPY go.mod go.sum* ./
+RUN --mount=type=cache,target=/go/pkg/mod go mod download
+
+COPY . .
+RUN --mount=type=cache,target=/go/pkg/mod --mount=type=cache,target=/root/.cache/go-build \
+    go run {{.Generator}} -quiet \
+    && CGO_ENABLED=0 go build -trimpath -o /out/app {{.Main}}
+
+FROM {{.BaseImage}}
+WORKDIR /app
+COPY --from=build /out/app /app/app
+COPY --from=build /src/{{.OutputDir}}/definition /app/{{.OutputDir}}/definition
+{{- if .SharedSchemas}}
+COPY --from=build /src/{{.OutputDir}}/schema/common /app/{{.OutputDir}}/schema/common
+{{- end}}
+ENTRYPOINT ["/app/app"]
+`
+
+// dockerignoreTemplate keeps local outputs out of the build context, they are generated in the build
+const dockerignoreTemplate = `{{.Marker}}
+.git
+{{.OutputDir}}
+{{.OutputDir}}.lock
+`
+
+// PackageOptions configures the Dockerfile written by next-gen package
+type PackageOptions struct {
+	// Main is the package of the app binary relative to the app, like ./cmd/app. When empty it is
+	// the app folder or its single cmd/<name> folder declaring package main.
+	Main string
+	// GoImage is the image of the build stage, golang:<go version of go.mod> when empty
+	GoImage string
+	// BaseImage is the image of the final stage, DefaultBaseImage when empty
+	BaseImage string
+	// Force overwrites a Dockerfile and .dockerignore not written by next-gen package
+	Force bool
+}
+
+// PackageResult lists the files written by WritePackage
+type PackageResult struct {
+	Files []string
+	Main  string
+}
+
+// WritePackage writes a multi-stage Dockerfile building the app, and a .dockerignore when there is
+// none, into the app folder. The build stage generates the wrappers with the next-gen version
+// running now, builds the binary and the final stage copies it with the service definitions.
+func WritePackage(appPath string, opts Options, pkg PackageOptions) (*PackageResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	goModPath := filepath.Join(appPath, "go.mod")
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open go.mod file: %w", err)
+	}
+	mod, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading go.mod file: %w", err)
+	}
+	for _, replace := range mod.Replace {
+		if replace.New.Version == "" && isOutside(filepath.Clean(filepath.FromSlash(replace.New.Path))) {
+			slog.Warn("Replaced module is outside the build context, the image cannot be built until it is published or vendored",
+				"module", replace.Old.Path, "path", replace.New.Path)
+		}
+	}
+
+	if pkg.Main == "" {
+		if pkg.Main, err = findMainPackage(appPath); err != nil {
+			return nil, err
+		}
+	}
+	if pkg.GoImage == "" {
+		if mod.Go == nil {
+			return nil, fmt.Errorf("go.mod declares no go version, set the build image with -go-image")
+		}
+		pkg.GoImage = "golang:" + mod.Go.Version
+	}
+	if pkg.BaseImage == "" {
+		pkg.BaseImage = DefaultBaseImage
+	}
+
+	// Builds from a checkout have no release to install, they generate with the latest one
+	generator := "github.com/cloudimpl/next-gen@latest"
+	if version := Version(); semver.IsValid(version) && semver.Build(version) == "" && !module.IsPseudoVersion(version) {
+		generator = "github.com/cloudimpl/next-gen@" + version
+	}
+	values := map[string]any{
+		"Marker":    dockerfileMarker,
+		"Version":   Version(),
+		"GoImage":   pkg.GoImage,
+		"BaseImage": pkg.BaseImage,
+		"Generator": generator,
+		"Main":      pkg.Main,
+		"OutputDir": path.Clean(filepath.ToSlash(opts.OutputDir)),
+		// Definitions refer to the shared schemas, the image carries both
+		"SharedSchemas": opts.SharedSchemas,
+	}
+
+	result := &PackageResult{Main: pkg.Main}
+	for _, file := range []struct {
+		name     string
+		template string
+		// keep leaves an existing file alone, a hand written .dockerignore is the user's to maintain
+		keep bool
+	}{
+		{DockerfileName, dockerfileTemplate, false},
+		{".dockerignore", dockerignoreTemplate, true},
+	} {
+		target := filepath.Join(appPath, file.name)
+		existing, err := os.ReadFile(target)
+		if err == nil && !bytes.Contains(existing, []byte(dockerfileMarker)) && !pkg.Force {
+			if file.keep {
+				continue
+			}
+			return nil, fmt.Errorf("%s was not written by next-gen package, use -force to overwrite it", file.name)
+		}
+
+		tmpl, err := template.New(file.name).Parse(file.template)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, values); err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %w", file.name, err)
+		}
+		if bytes.Equal(existing, buf.Bytes()) {
+			continue
+		}
+		if err = os.WriteFile(target, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, file.name)
+	}
+	return result, nil
+}
+
+// findMainPackage returns the package of the app binary, the app folder when it declares package
+// main, otherwise its single cmd/<name> folder doing so
+func findMainPackage(appPath string) (string, error) {
+	if name, err := servicePackageName(appPath); err == nil && name == "main" {
+		return ".", nil
+	}
+
+	folders, err := filepath.Glob(filepath.Join(appPath, "cmd", "*"))
+	if err != nil {
+		return "", err
+	}
+	var mains []string
+	for _, folder := range folders {
+		if name, err := servicePackageName(folder); err == nil && name == "main" {
+			mains = append(mains, "./cmd/"+filepath.Base(folder))
+		}
+	}
+	switch len(mains) {
+	case 0:
+		return "", fmt.Errorf("no main package in the app folder or cmd/, set it with -main")
+	case 1:
+		return mains[0], nil
+	}
+	return "", fmt.Errorf("several main packages (%s), choose one with -main", strings.Join(mains, ", "))
+}
@@ -0,0 +1,213 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const docsTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Service}}{{.Service.Name}} - {{end}}Service docs</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 960px; color: #222; }
+table { border-collapse: collapse; margin: .5em 0 1em; }
+td, th { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+pre, textarea { background: #f6f8fa; padding: 8px; font-family: monospace; }
+textarea { width: 100%; height: 8em; }
+.method { border-top: 1px solid #ddd; padding-top: 1em; }
+.tag { background: #eef; border-radius: 3px; font-size: .8em; padding: 2px 6px; }
+</style>
+</head>
+<body>
+<p><a href="/">All services</a></p>
+{{if .Service}}{{$service := .Service.Name}}
+<h1>{{.Service.Name}}</h1>
+{{range .Service.Methods}}
+<div class="method" id="{{.Name}}">
+<h2>{{.Name}} <span class="tag">{{if .IsWorkflow}}workflow{{else}}service{{end}}</span></h2>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Doc}}<pre>{{.Doc}}</pre>{{end}}
+<h3>Input: <code>{{.InputType}}</code></h3>
+{{template "schema" .InputSchema}}
+<h3>Output: <code>{{.OutputType}}</code></h3>
+{{template "schema" .OutputSchema}}
+<h3>Try it</h3>
+<textarea id="input-{{.Name}}">{{example .InputSchema}}</textarea>
+<button onclick="invoke('{{$service}}', '{{.Name}}')">Invoke</button>
+<pre id="output-{{.Name}}"></pre>
+</div>
+{{end}}
+<script>
+function invoke(service, method) {
+	var out = document.getElementById('output-' + method);
+	out.textContent = 'Invoking...';
+	fetch('/invoke/' + service + '/' + method, {
+		method: 'POST',
+		headers: {'Content-Type': 'application/json'},
+		body: document.getElementById('input-' + method).value
+	}).then(function (res) {
+		return res.text().then(function (text) { out.textContent = res.status + '\n' + text; });
+	}).catch(function (err) { out.textContent = err; });
+}
+</script>
+{{else}}
+<h1>Services</h1>
+<ul>
+{{range .Services}}<li><a href="/services/{{.Name}}">{{.Name}}</a> ({{len .Methods}} methods)</li>
+{{else}}<li>No service definitions found. Run the generator first.</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+{{define "schema"}}{{if .}}<table>
+<tr><th>Field</th><th>Type</th><th>Description</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td><code>{{.Type}}</code></td><td>{{.Doc}}</td></tr>
+{{end}}</table>{{else}}<p><em>No schema available</em></p>{{end}}{{end}}
+`
+
+type docsPage struct {
+	Services []ServiceDefinition
+	Service  *ServiceDefinition
+}
+
+// exampleValue returns a placeholder JSON value for a Go type
+func exampleValue(typeStr string) any {
+	typeStr = strings.TrimPrefix(typeStr, "*")
+	switch {
+	case strings.HasPrefix(typeStr, "["):
+		return []any{}
+	case strings.HasPrefix(typeStr, "map["):
+		return map[string]any{}
+	case typeStr == "string":
+		return ""
+	case typeStr == "bool":
+		return false
+	case integerTypes[typeStr], floatTypes[typeStr]:
+		return 0
+	default:
+		return map[string]any{}
+	}
+}
+
+// exampleJSON builds an example JSON payload from a schema
+func exampleJSON(fields []Field) string {
+	example := make(map[string]any)
+	for _, field := range fields {
+		example[field.Name] = exampleValue(field.Type)
+	}
+
+	data, err := json.MarshalIndent(example, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ServeDocs serves the service definitions generated into the output folders as a browsable HTML site,
+// one folder per module of a go.work app as returned by OutputFolders. Try-it requests are forwarded to
+// invokeURL/services/{service}/{method} when invokeURL is set.
+func ServeDocs(outputPaths []string, addr string, invokeURL string) error {
+	tmpl, err := template.New("docs").Funcs(template.FuncMap{"example": exampleJSON}).Parse(docsTemplate)
+	if err != nil {
+		return err
+	}
+
+	render := func(w http.ResponseWriter, page docsPage) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		defs, err := loadDocsDefinitions(outputPaths)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		render(w, docsPage{Services: defs})
+	})
+
+	// Names of namespaced and versioned services contain slashes, like billing/orders/v2
+	mux.HandleFunc("GET /services/{service...}", func(w http.ResponseWriter, r *http.Request) {
+		defs, err := loadDocsDefinitions(outputPaths)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range defs {
+			if defs[i].Name == r.PathValue("service") {
+				render(w, docsPage{Service: &defs[i]})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	mux.HandleFunc("POST /invoke/{call...}", func(w http.ResponseWriter, r *http.Request) {
+		if invokeURL == "" {
+			http.Error(w, "no invoke URL configured, start the docs server with -invoke-url", http.StatusServiceUnavailable)
+			return
+		}
+		// The method is the last segment, the service the rest
+		service, method, ok := cutLast(r.PathValue("call"), "/")
+		if !ok || service == "" || method == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		target := fmt.Sprintf("%s/services/%s/%s", strings.TrimSuffix(invokeURL, "/"), service, method)
+		res, err := client.Post(target, "application/json", r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+
+		w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	})
+
+	slog.Info("Serving docs", "url", "http://"+addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// loadDocsDefinitions reads the service definitions of every output folder, sorted by service name
+func loadDocsDefinitions(outputPaths []string) ([]ServiceDefinition, error) {
+	var defs []ServiceDefinition
+	for _, outputPath := range outputPaths {
+		folderDefs, err := LoadServiceDefinitions(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, folderDefs...)
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs, nil
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
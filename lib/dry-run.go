@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DryRun runs the generation with the writes kept in memory and returns the unified diff between the
+// files on disk and what would be generated. Hooks, analyzers and the compile check are skipped, they
+// act on the disk. The overlay belongs to this run only, generations running alongside it keep
+// writing to the disk.
+// The diff is returned along with the error when some services failed.
+func DryRun(appPath string, opts Options) (string, error) {
+	appPath, err := NormalizeAppPath(appPath)
+	if err != nil {
+		return "", err
+	}
+	opts.Hooks = Hooks{}
+	opts.Analyzers = nil
+	opts.NoCompileCheck = true
+
+	overlay := newOverlayFS()
+	_, genErr := generateServices(context.Background(), overlay, appPath, nil, opts)
+
+	var diff strings.Builder
+	for _, path := range overlay.changes() {
+		name := path
+		if rel, err := filepath.Rel(appPath, path); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+
+		before, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		beforeName := "a/" + name
+		if err != nil {
+			beforeName = "/dev/null"
+		}
+		after, err := overlay.ReadFile(path)
+		afterName := "b/" + name
+		if err != nil {
+			after, afterName = nil, "/dev/null"
+		}
+		diff.WriteString(unifiedDiff(beforeName, afterName, before, after))
+	}
+	return diff.String(), genErr
+}
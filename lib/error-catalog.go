@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kinds of errors in the catalog of a service
+const (
+	ErrorKindSentinel = "sentinel" // var ErrX = errors.New(...), matched with errors.Is
+	ErrorKindType     = "type"     // Type implementing error, matched with errors.As
+)
+
+// ErrorDefinition is an error a service declares, clients match it by its stable code
+type ErrorDefinition struct {
+	Name    string `yaml:"name" json:"name"`
+	Code    string `yaml:"code" json:"code"`
+	Kind    string `yaml:"kind" json:"kind"`
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	Doc     string `yaml:"doc,omitempty" json:"doc,omitempty"`
+	// Pointer is set for error types whose Error method has a pointer receiver
+	Pointer bool `yaml:"-" json:"pointer,omitempty"`
+}
+
+// parseErrors collects the exported sentinel errors and error types of a service package.
+// The code defaults to the name in upper snake case without the Err prefix or Error suffix,
+// //polycode:error code=... overrides it.
+func parseErrors(serviceFolder string, exclude []string) ([]ErrorDefinition, error) {
+	files, err := filepath.Glob(filepath.Join(serviceFolder, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var catalog []ErrorDefinition
+	typeDocs := make(map[string]*ast.CommentGroup)
+	errorTypes := make(map[string]bool)
+	for _, file := range files {
+		rel, _ := filepath.Rel(serviceFolder, file)
+		if strings.HasSuffix(file, "_test.go") || isExcluded(rel, exclude) {
+			continue
+		}
+
+		node, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, decl := range node.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					doc := specDoc(decl, spec)
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						typeDocs[spec.Name.Name] = doc
+					case *ast.ValueSpec:
+						if decl.Tok != token.VAR || len(spec.Names) != len(spec.Values) {
+							continue
+						}
+						for i, name := range spec.Names {
+							message, ok := sentinelMessage(spec.Values[i])
+							if !ok || !name.IsExported() {
+								continue
+							}
+							definition, err := newErrorDefinition(name.Name, ErrorKindSentinel, message, doc)
+							if err != nil {
+								return nil, err
+							}
+							catalog = append(catalog, definition)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if receiver, pointer, ok := errorMethodReceiver(decl); ok && ast.IsExported(receiver) {
+					errorTypes[receiver] = pointer
+				}
+			}
+		}
+	}
+
+	for name, pointer := range errorTypes {
+		definition, err := newErrorDefinition(name, ErrorKindType, "", typeDocs[name])
+		if err != nil {
+			return nil, err
+		}
+		definition.Pointer = pointer
+		catalog = append(catalog, definition)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Name < catalog[j].Name
+	})
+	codes := make(map[string]string)
+	for _, definition := range catalog {
+		if other, ok := codes[definition.Code]; ok {
+			return nil, fmt.Errorf("errors %s and %s share the code %s, set distinct codes with //polycode:error code=...", other, definition.Name, definition.Code)
+		}
+		codes[definition.Code] = definition.Name
+	}
+	return catalog, nil
+}
+
+// specDoc returns the doc comment of a spec, falling back to the declaration for ungrouped declarations
+func specDoc(decl *ast.GenDecl, spec ast.Spec) *ast.CommentGroup {
+	var doc *ast.CommentGroup
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		doc = spec.Doc
+	case *ast.ValueSpec:
+		doc = spec.Doc
+	}
+	if doc == nil && len(decl.Specs) == 1 {
+		doc = decl.Doc
+	}
+	return doc
+}
+
+func newErrorDefinition(name string, kind string, message string, doc *ast.CommentGroup) (ErrorDefinition, error) {
+	code := errorCode(name)
+	if args, ok := parseDirectives(doc)["error"]; ok {
+		custom := parseDirectiveArgs(args)["code"]
+		if custom == "" || custom == "true" {
+			return ErrorDefinition{}, fmt.Errorf("error %s: //polycode:error code must not be empty", name)
+		}
+		code = custom
+	}
+	return ErrorDefinition{Name: name, Code: code, Kind: kind, Message: message, Doc: extractDocComment(doc)}, nil
+}
+
+// sentinelMessage returns the message of errors.New("...") or fmt.Errorf("...") with a constant string
+func sentinelMessage(expr ast.Expr) (string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || !(pkg.Name == "errors" && sel.Sel.Name == "New" || pkg.Name == "fmt" && sel.Sel.Name == "Errorf") {
+		return "", false
+	}
+	if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		if message, err := strconv.Unquote(lit.Value); err == nil {
+			return message, true
+		}
+	}
+	return "", true
+}
+
+// errorMethodReceiver returns the type of an Error() string method
+func errorMethodReceiver(fn *ast.FuncDecl) (name string, pointer bool, ok bool) {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Name.Name != "Error" {
+		return "", false, false
+	}
+	if len(flattenFields(fn.Type.Params)) != 0 {
+		return "", false, false
+	}
+	results := flattenFields(fn.Type.Results)
+	if len(results) != 1 {
+		return "", false, false
+	}
+	if result, ok := results[0].(*ast.Ident); !ok || result.Name != "string" {
+		return "", false, false
+	}
+
+	receiver := fn.Recv.List[0].Type
+	if star, ok := receiver.(*ast.StarExpr); ok {
+		receiver, pointer = star.X, true
+	}
+	ident, ok := receiver.(*ast.Ident)
+	if !ok {
+		// Generic error types cannot be matched without type arguments
+		return "", false, false
+	}
+	return ident.Name, pointer, true
+}
+
+// errorCode derives the default code of an error, ErrOrderNotFound and OrderNotFoundError become ORDER_NOT_FOUND
+func errorCode(name string) string {
+	if trimmed := strings.TrimPrefix(name, "Err"); trimmed != name && trimmed != "" && unicode.IsUpper(rune(trimmed[0])) {
+		name = trimmed
+	} else if trimmed := strings.TrimSuffix(name, "Error"); trimmed != "" {
+		name = trimmed
+	}
+
+	var code strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		// Start a word at a lower to upper change and at the last upper of an acronym, like HTTPStatus
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+			code.WriteByte('_')
+		}
+		code.WriteRune(unicode.ToUpper(r))
+	}
+	return code.String()
+}
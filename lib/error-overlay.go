@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+const overlayPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>next-gen</title>
+<style>
+body { margin: 0; font-family: sans-serif; }
+#overlay { display: none; position: fixed; inset: 0; background: rgba(20, 20, 20, .92); color: #eee; padding: 2em; overflow: auto; }
+#overlay h1 { color: #ff6b6b; font-size: 1.2em; }
+#overlay pre { font-family: monospace; white-space: pre-wrap; font-size: .95em; }
+#ok { padding: 2em; color: #2b8a3e; }
+</style>
+</head>
+<body>
+<div id="ok">No generation errors.</div>
+<div id="overlay"><h1>Service generation failed</h1><pre id="diagnostics"></pre></div>
+<script>
+var events = new EventSource('/events');
+events.onmessage = function (e) {
+	var state = JSON.parse(e.data);
+	document.getElementById('overlay').style.display = state.error ? 'block' : 'none';
+	document.getElementById('ok').style.display = state.error ? 'none' : 'block';
+	document.getElementById('diagnostics').textContent = state.error;
+};
+</script>
+</body>
+</html>
+`
+
+type overlayState struct {
+	Error string `json:"error"`
+}
+
+// ErrorOverlay keeps the last generation error and pushes it to browsers over server-sent events
+type ErrorOverlay struct {
+	mu      sync.Mutex
+	state   overlayState
+	clients map[chan overlayState]struct{}
+}
+
+func NewErrorOverlay() *ErrorOverlay {
+	return &ErrorOverlay{
+		clients: make(map[chan overlayState]struct{}),
+	}
+}
+
+// Report records the result of a generation run, a nil error clears the overlay
+func (o *ErrorOverlay) Report(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.state = overlayState{}
+	if err != nil {
+		o.state.Error = err.Error()
+	}
+
+	// A slow client has its unread state replaced, it always gets the latest one. Reports hold the lock,
+	// once drained the buffer has room for the send.
+	for client := range o.clients {
+		select {
+		case <-client:
+		default:
+		}
+		client <- o.state
+	}
+}
+
+func (o *ErrorOverlay) subscribe() (chan overlayState, overlayState) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	client := make(chan overlayState, 1)
+	o.clients[client] = struct{}{}
+	return client, o.state
+}
+
+func (o *ErrorOverlay) unsubscribe(client chan overlayState) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.clients, client)
+}
+
+func (o *ErrorOverlay) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client, state := o.subscribe()
+	defer o.unsubscribe(client)
+
+	for {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return
+		}
+		if _, err = fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case state = <-client:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Handler returns the overlay page and event stream handlers
+func (o *ErrorOverlay) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(overlayPage))
+	})
+	mux.HandleFunc("GET /events", o.serveEvents)
+	return mux
+}
+
+// ListenAndServe serves the overlay page on / and the event stream on /events
+func (o *ErrorOverlay) ListenAndServe(addr string) error {
+	slog.Info("Serving error overlay", "url", "http://"+addr)
+	return http.ListenAndServe(addr, o.Handler())
+}
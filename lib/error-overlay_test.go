@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorOverlayReportsLatestState(t *testing.T) {
+	o := NewErrorOverlay()
+	client, state := o.subscribe()
+	defer o.unsubscribe(client)
+	if state.Error != "" {
+		t.Errorf("got initial error %q, want none", state.Error)
+	}
+
+	// The client reads nothing while several runs report, it gets the last state
+	o.Report(errors.New("first"))
+	o.Report(errors.New("second"))
+	if got := (<-client).Error; got != "second" {
+		t.Errorf("got %q, want second", got)
+	}
+	o.Report(errors.New("third"))
+	o.Report(nil)
+	if got := (<-client).Error; got != "" {
+		t.Errorf("got %q, want the overlay cleared", got)
+	}
+}
+
+func TestErrorOverlayEvents(t *testing.T) {
+	o := NewErrorOverlay()
+	o.Report(errors.New("orders: undefined: Order"))
+	server := httptest.NewServer(o.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("got content type %q, want text/event-stream", got)
+	}
+
+	events := bufio.NewScanner(res.Body)
+	next := func() string {
+		for events.Scan() {
+			if data, ok := strings.CutPrefix(events.Text(), "data: "); ok {
+				return data
+			}
+		}
+		t.Fatalf("event stream ended: %v", events.Err())
+		return ""
+	}
+	if got, want := next(), `{"error":"orders: undefined: Order"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	o.Report(nil)
+	if got, want := next(), `{"error":""}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,365 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"golang.org/x/tools/go/packages"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// eventsPackage is the folder of the output holding the publish helpers of the events of the app
+const eventsPackage = "events"
+
+// Directives declaring an event on a struct type and subscribing a service function to one
+const (
+	eventDirective     = "event"
+	subscribeDirective = "subscribe"
+)
+
+// reservedEventNames are declared by the events package, events cannot be named after them
+var reservedEventNames = []string{"Publisher", "Names"}
+
+// EventType is a struct type declared as an event with //polycode:event <Name>, the name defaults to
+// the name of the type. Services publish it through the helpers of .polycode/events and subscribe to
+// it with //polycode:subscribe.
+type EventType struct {
+	Name       string // Name the event is published with, like OrderCreated
+	Type       string // Go type of the payload qualified by package name, like models.OrderCreated
+	TypeName   string // Name of the type in its package
+	ImportPath string // Import path of the package declaring the type
+}
+
+// key returns the import path qualified name of the type of the event, like the keys of extractStructs
+func (e EventType) key() string {
+	return e.ImportPath + "." + e.TypeName
+}
+
+// EventDefinition is an event a service publishes or subscribes to, the payload struct is listed in
+// the Types of the service
+type EventDefinition struct {
+	Name    string `yaml:"name" json:"name"`
+	Type    string `yaml:"type" json:"type"`
+	Handler string `yaml:"handler,omitempty" json:"handler,omitempty"` // Function subscribed to the event
+}
+
+// ServiceEvents are the events of a service definition
+type ServiceEvents struct {
+	// Publishes lists the events the service publishes through the helpers of .polycode/events
+	Publishes []EventDefinition `yaml:"publishes,omitempty" json:"publishes,omitempty"`
+	// Subscribes lists the events delivered to the service through ExecuteEvent
+	Subscribes []EventDefinition `yaml:"subscribes,omitempty" json:"subscribes,omitempty"`
+}
+
+// findEvents returns the event types declared in the packages of the app keyed by import path and type
+// name, like example.com/app/models.OrderCreated. Events are imported by the events package the services
+// import, they cannot be declared in a service package.
+func findEvents(pkgs []*packages.Package, moduleName string, servicePackages []string) (map[string]EventType, error) {
+	events := make(map[string]EventType)
+	names := make(map[string]string)
+	var err error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if err != nil || pkg.Types == nil || !provides(moduleName, pkg.PkgPath) {
+			return
+		}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					doc := typeSpec.Doc
+					if doc == nil && len(gen.Specs) == 1 {
+						doc = gen.Doc
+					}
+					args, ok := parseDirectives(doc)[eventDirective]
+					if !ok {
+						continue
+					}
+
+					position := pkg.Fset.Position(typeSpec.Pos())
+					event := EventType{Name: args, Type: pkg.Types.Name() + "." + typeSpec.Name.Name, TypeName: typeSpec.Name.Name, ImportPath: pkg.PkgPath}
+					if event.Name == "" {
+						event.Name = typeSpec.Name.Name
+					}
+					switch _, isStruct := typeSpec.Type.(*ast.StructType); {
+					case !token.IsIdentifier(event.Name) || !token.IsExported(event.Name):
+						err = fmt.Errorf("%s: //polycode:event %s: event names must be exported Go identifiers, like OrderCreated", position, event.Name)
+					case slices.Contains(reservedEventNames, event.Name):
+						err = fmt.Errorf("%s: //polycode:event %s: the name is taken by the events package", position, event.Name)
+					case !isStruct || typeSpec.TypeParams != nil || !typeSpec.Name.IsExported():
+						err = fmt.Errorf("%s: //polycode:event must be declared on an exported struct type that is not generic", position)
+					case slices.Contains(servicePackages, pkg.PkgPath):
+						err = fmt.Errorf("%s: event %s is declared in a service package, the services importing .polycode/events would import it in a cycle, move it to a package like models", position, event.Name)
+					case pkg.Name == "main":
+						err = fmt.Errorf("%s: event %s is declared in a main package, which cannot be imported", position, event.Name)
+					case names[event.Name] != "":
+						err = fmt.Errorf("%s: event %s is declared by both %s and %s", position, event.Name, names[event.Name], event.Type)
+					}
+					if err != nil {
+						return
+					}
+					names[event.Name] = event.Type
+					events[event.key()] = event
+				}
+			}
+		}
+	})
+	return events, err
+}
+
+// checkSubscriber checks the signature of a function subscribed to an event with //polycode:subscribe,
+// the event is the type of its input
+func checkSubscriber(fn *ast.FuncDecl, contextType string, sdk sdkResolver) error {
+	if contextType != "Service" {
+		return fmt.Errorf("function %s: event subscribers take a polycode.ServiceContext", fn.Name.Name)
+	}
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams == nil && len(params) == 2 && len(results) == 1 && isErrorType(results[0]) {
+		if _, variadic := params[1].(*ast.Ellipsis); !variadic {
+			if _, isStream, _ := streamElement(params[1], sdk); !isStream {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("function %s: event subscribers must have the signature func(ctx polycode.ServiceContext, event T) error", fn.Name.Name)
+}
+
+// resolveSubscriptions sets the event each subscriber of a service handles, from the type of its input.
+// events are keyed by the names the service writes types with, see localTypes.
+func resolveSubscriptions(methods []MethodInfo, events map[string]EventType) error {
+	subscribed := make(map[string]string)
+	for i, method := range methods {
+		if !method.IsSubscriber {
+			continue
+		}
+		event, ok := events[method.InputType]
+		if !ok {
+			return fmt.Errorf("function %s: //polycode:subscribe takes an event, %s is not declared with //polycode:event", method.OriginalName, method.InputType)
+		}
+		if other, ok := subscribed[event.Name]; ok {
+			return fmt.Errorf("functions %s and %s both subscribe to event %s", other, method.OriginalName, event.Name)
+		}
+		subscribed[event.Name] = method.OriginalName
+		methods[i].Event = event.Name
+	}
+	return nil
+}
+
+// publishedEvents returns the events a service publishes, found from its calls of the Publish helpers
+// of the events package
+func publishedEvents(serviceFolder string, exclude []string, eventsImport string, events map[string]EventType) ([]EventType, error) {
+	byName := make(map[string]EventType)
+	for _, event := range events {
+		byName[event.Name] = event
+	}
+
+	entries, err := os.ReadDir(serviceFolder)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || isExcluded(name, exclude) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(serviceFolder, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		local := ""
+		for _, spec := range file.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path == eventsImport {
+				local = eventsPackage
+				if spec.Name != nil {
+					local = spec.Name.Name
+				}
+			}
+		}
+		if local == "" {
+			continue
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if selector, ok := node.(*ast.SelectorExpr); ok {
+				if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == local {
+					if name, ok := strings.CutPrefix(selector.Sel.Name, "Publish"); ok {
+						if _, ok := byName[name]; ok {
+							found[name] = true
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	var published []EventType
+	for name := range found {
+		published = append(published, byName[name])
+	}
+	sort.Slice(published, func(i, j int) bool {
+		return published[i].Name < published[j].Name
+	})
+	return published, nil
+}
+
+// serviceEvents describes the events a service publishes and subscribes to, nil when there are none
+func serviceEvents(published []EventType, subscribers []MethodInfo, events map[string]EventType, structs map[string][]Field, types map[string][]Field) *ServiceEvents {
+	if len(published) == 0 && len(subscribers) == 0 {
+		return nil
+	}
+	result := &ServiceEvents{}
+	for _, event := range published {
+		result.Publishes = append(result.Publishes, EventDefinition{Name: event.Name, Type: event.Type})
+		collectNestedTypes([]Field{{Type: event.Type, ref: event.key()}}, structs, types)
+	}
+	for _, subscriber := range subscribers {
+		event := events[subscriber.InputType]
+		result.Subscribes = append(result.Subscribes, EventDefinition{Name: event.Name, Type: event.Type, Handler: subscriber.ExposedName})
+		collectNestedTypes([]Field{{Type: event.Type, ref: event.key()}}, structs, types)
+	}
+	return result
+}
+
+const eventsTemplate = `// Code generated by next-gen. DO NOT EDIT.
+
+// Package events publishes the events declared with //polycode:event. Services import it to publish
+// them, and subscribe to them with //polycode:subscribe.
+package events
+
+import (
+	"context"
+	"fmt"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+// Publisher is implemented by the contexts of runtimes delivering events to their subscribers
+type Publisher interface {
+	PublishEvent(name string, payload any) error
+}
+
+// Names of the events
+const (
+{{- range .Events}}
+	{{.Name}} = {{printf "%q" .Name}} // {{.Type}}
+{{- end}}
+)
+
+// Names lists the events of the app sorted by name
+var Names = []string{ {{- range $i, $e := .Events}}{{if $i}}, {{end}}{{.Name}}{{end -}} }
+{{range .Events}}
+// Publish{{.Name}} publishes the {{.Name}} event to the services subscribed to it
+func Publish{{.Name}}(ctx context.Context, event {{.GoType}}) error {
+	return publish(ctx, {{.Name}}, event)
+}
+{{end}}
+func publish(ctx context.Context, name string, payload any) error {
+	publisher, ok := ctx.(Publisher)
+	if !ok {
+		return fmt.Errorf("cannot publish event %s: the context does not implement events.Publisher", name)
+	}
+	return publisher.PublishEvent(name, payload)
+}
+`
+
+// writeEvents writes the publish helpers of the events into .polycode/events, removing them when the
+// app declares no events
+func writeEvents(output outputFS, outputPath string, events map[string]EventType) error {
+	folder := filepath.Join(outputPath, eventsPackage)
+	path := filepath.Join(folder, eventsPackage+".go")
+	if len(events) == 0 {
+		if !output.Exists(path) {
+			return nil
+		}
+		return removeGeneratedFile(output, outputPath, filepath.Join(eventsPackage, eventsPackage+".go"))
+	}
+
+	type templateEvent struct {
+		EventType
+		GoType string
+	}
+	var list []templateEvent
+	for _, event := range events {
+		list = append(list, templateEvent{EventType: event})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	// Packages of the same name are told apart by a numbered alias, in the order of their import paths
+	var paths []string
+	for _, event := range list {
+		if !slices.Contains(paths, event.ImportPath) {
+			paths = append(paths, event.ImportPath)
+		}
+	}
+	sort.Strings(paths)
+	aliases := make(map[string]string)
+	taken := map[string]bool{"context": true, "fmt": true}
+	var imports []string
+	for _, path := range paths {
+		name := packageNameOf(events, path)
+		alias := name
+		for i := 2; taken[alias]; i++ {
+			alias = name + strconv.Itoa(i)
+		}
+		taken[alias] = true
+		aliases[path] = alias
+		spec := strconv.Quote(path)
+		if alias != name {
+			spec = alias + " " + spec
+		}
+		imports = append(imports, spec)
+	}
+	for i := range list {
+		list[i].GoType = aliases[list[i].ImportPath] + "." + list[i].TypeName
+	}
+
+	tmpl, err := template.New(eventsPackage).Parse(stampVersion(eventsTemplate))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, map[string]any{"Events": list, "Imports": imports}); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", eventsPackage, err)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if existing, err := output.ReadFile(path); err == nil && bytes.Equal(existing, code) {
+		return nil
+	}
+	if err = output.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	return output.WriteFile(path, code, 0644)
+}
+
+// packageNameOf returns the name of the package an event type is declared in
+func packageNameOf(events map[string]EventType, importPath string) string {
+	for _, event := range events {
+		if event.ImportPath == importPath {
+			name, _, _ := strings.Cut(event.Type, ".")
+			return name
+		}
+	}
+	return ""
+}
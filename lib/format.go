@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"golang.org/x/tools/imports"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+const (
+	FormatGoImports = "goimports"
+	FormatGofmt     = "gofmt"
+	FormatNone      = "none"
+	FormatCustom    = "custom"
+)
+
+// formatGenerated runs the configured formatter over the generated folder. Go files written by the run
+// were formatted before being written with formatSource, the in-process formatters only rewrite the
+// files that are not, like those of a previous run with another formatter.
+func formatGenerated(output outputFS, folder string, opts Options) error {
+	switch opts.Format {
+	case FormatNone:
+		return nil
+	case FormatCustom:
+		return runFormatCommand(output, folder, opts.FormatCommand)
+	case FormatGofmt, FormatGoImports, "":
+		return formatFiles(output, folder, opts)
+	default:
+		return fmt.Errorf("unknown formatter %q", opts.Format)
+	}
+}
+
+// formatSource formats a generated file with the in-process formatter of the options before it is
+// written. goimports removes unused imports and adds missing ones resolved as if the file was in its
+// folder, nothing is installed or downloaded so it works in offline and vendored builds. Files other
+// than Go files and formatters run on the folder leave the source as is.
+func formatSource(path string, src []byte, opts Options) ([]byte, error) {
+	if !IsGoFile(path) {
+		return src, nil
+	}
+	switch opts.Format {
+	case FormatGofmt:
+		formatted, err := format.Source(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format %s: %w", path, err)
+		}
+		return formatted, nil
+	case FormatGoImports, "":
+		formatted, err := imports.Process(path, src, nil)
+		if err != nil {
+			return nil, fmt.Errorf("goimports failed on %s: %w", path, err)
+		}
+		return formatted, nil
+	}
+	return src, nil
+}
+
+// formatFiles formats the Go files of the folder in-process, rewriting only those that change
+func formatFiles(output outputFS, folder string, opts Options) error {
+	files, err := output.Files(folder)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		if !IsGoFile(path) {
+			continue
+		}
+
+		src, err := output.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := formatSource(path, src, opts)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(src, formatted) {
+			continue
+		}
+		if err = output.WriteFile(path, formatted, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runFormatCommand runs a user supplied formatter command on the folder
+func runFormatCommand(output outputFS, folder string, command string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return fmt.Errorf("custom formatter selected but no format command configured")
+	}
+	if _, dryRun := output.(*overlayFS); dryRun {
+		slog.Warn("The custom formatter only runs on files on disk, dry run output is not formatted", "command", command)
+		return nil
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], folder)...)
+	combined, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("formatter %s failed: %s", args[0], strings.TrimSpace(string(combined)))
+	}
+	return nil
+}
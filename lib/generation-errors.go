@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// positionError attaches the position of the declaration being parsed to an error
+type positionError struct {
+	pos token.Position
+	err error
+}
+
+func (e *positionError) Error() string {
+	return e.pos.String() + ": " + e.err.Error()
+}
+
+func (e *positionError) Unwrap() error {
+	return e.err
+}
+
+// ServiceError is the failure to generate a single service, File and Line locate the cause when known
+type ServiceError struct {
+	Service string
+	File    string // Relative to the app root
+	Line    int
+	Err     error
+}
+
+// newServiceError wraps the failure of a service, locating it from parser errors, positioned parse
+// errors or compiler style file:line: messages
+func newServiceError(appPath string, serviceName string, err error) *ServiceError {
+	serviceErr := &ServiceError{Service: serviceName, Err: err}
+
+	var pos token.Position
+	var list scanner.ErrorList
+	var positioned *positionError
+	if errors.As(err, &list) && len(list) > 0 {
+		pos = list[0].Pos
+	} else if errors.As(err, &positioned) {
+		pos = positioned.pos
+	} else if match := diagnosticLine.FindStringSubmatch(strings.SplitN(err.Error(), "\n", 2)[0]); match != nil {
+		pos.Filename = match[1]
+		pos.Line, _ = strconv.Atoi(match[2])
+	}
+
+	if pos.Filename != "" {
+		serviceErr.File = pos.Filename
+		if rel, err := filepath.Rel(appPath, pos.Filename); err == nil && filepath.IsAbs(pos.Filename) {
+			serviceErr.File = filepath.ToSlash(rel)
+		}
+		serviceErr.Line = pos.Line
+	}
+	return serviceErr
+}
+
+func (e *ServiceError) Error() string {
+	// Failures of generated code shared by the services belong to none of them
+	if e.Service == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("service %s: %v", e.Service, e.Err)
+}
+
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// Location returns file:line of the cause, or - when it is unknown
+func (e *ServiceError) Location() string {
+	if e.File == "" {
+		return "-"
+	}
+	if e.Line > 0 {
+		return e.File + ":" + strconv.Itoa(e.Line)
+	}
+	return e.File
+}
+
+// GenerationError reports every service that failed in a run, the other services are still generated
+type GenerationError struct {
+	Services []*ServiceError
+}
+
+func (e *GenerationError) Error() string {
+	messages := make([]string, len(e.Services))
+	for i, err := range e.Services {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d service(s) failed:\n%s", len(e.Services), strings.Join(messages, "\n"))
+}
+
+func (e *GenerationError) Unwrap() []error {
+	errs := make([]error, len(e.Services))
+	for i, err := range e.Services {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Summary renders the failures as a table of service, location and the first line of the error
+func (e *GenerationError) Summary() string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tLOCATION\tERROR")
+	for _, err := range e.Services {
+		message := strings.SplitN(causeMessage(err.Err), "\n", 2)[0]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", err.Service, err.Location(), message)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// causeMessage returns the message of an error without the position shown in the location column
+func causeMessage(err error) string {
+	var list scanner.ErrorList
+	var positioned *positionError
+	if errors.As(err, &list) && len(list) > 0 {
+		return list[0].Msg
+	}
+	if errors.As(err, &positioned) {
+		return positioned.err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Generation profiles available without configuration, selected with -profile
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// Profile overrides the top level settings of next-gen.yaml for a kind of build, like local
+// development or production images. Fields that are not set keep the top level setting.
+type Profile struct {
+	// Production exposes the @definition method in the wrappers
+	Production *bool `yaml:"production"`
+	// Metrics instruments the wrappers with OpenTelemetry
+	Metrics *bool `yaml:"metrics"`
+	// DebugDispatch logs the calls dispatched by the wrappers at debug level
+	DebugDispatch *bool `yaml:"debugDispatch"`
+	// Mocks generates the mocks target
+	Mocks *bool `yaml:"mocks"`
+}
+
+// builtinProfiles are the defaults of the dev, staging and prod profiles, the profiles of
+// next-gen.yaml with the same name override them field by field
+var builtinProfiles = map[string]Profile{
+	ProfileDev:     {Production: boolValue(false), DebugDispatch: boolValue(true), Mocks: boolValue(true)},
+	ProfileStaging: {Production: boolValue(true)},
+	ProfileProd:    {Production: boolValue(true), DebugDispatch: boolValue(false), Mocks: boolValue(false)},
+}
+
+func boolValue(b bool) *bool {
+	return &b
+}
+
+// ApplyProfile applies a built-in or configured profile onto opts, it goes after Apply and before flags
+func (c Config) ApplyProfile(name string, opts *Options) error {
+	profile, builtin := builtinProfiles[name]
+	configured, ok := c.Profiles[name]
+	if !builtin && !ok {
+		return fmt.Errorf("unknown profile %q, expected %s", name, strings.Join(c.profileNames(), ", "))
+	}
+
+	for _, field := range []struct{ value, override **bool }{
+		{&profile.Production, &configured.Production},
+		{&profile.Metrics, &configured.Metrics},
+		{&profile.DebugDispatch, &configured.DebugDispatch},
+		{&profile.Mocks, &configured.Mocks},
+	} {
+		if *field.override != nil {
+			*field.value = *field.override
+		}
+	}
+
+	if profile.Production != nil {
+		opts.Production = *profile.Production
+	}
+	if profile.Metrics != nil {
+		opts.Metrics = *profile.Metrics
+	}
+	if profile.DebugDispatch != nil {
+		opts.DebugDispatch = *profile.DebugDispatch
+	}
+	if profile.Mocks != nil {
+		if *profile.Mocks {
+			opts.Targets = appendTarget(opts.Targets, TargetMocks)
+		} else {
+			opts.Targets = slices.DeleteFunc(slices.Clone(opts.Targets), func(target string) bool { return target == TargetMocks })
+		}
+	}
+	return nil
+}
+
+// profileNames returns the built-in and configured profiles sorted by name
+func (c Config) profileNames() []string {
+	var names []string
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	for name := range c.Profiles {
+		if _, ok := builtinProfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ReportJSON writes .polycode/report.json after each run
+const ReportJSON = "json"
+
+// reportName is the machine-readable report of the last run in the output folder
+const reportName = "report.json"
+
+// GenerationReport is the content of .polycode/report.json, the outcome of the last run for the
+// services of a module, so CI and dashboards can track generation health over time
+type GenerationReport struct {
+	GeneratorVersion string            `json:"generatorVersion"`
+	Module           string            `json:"module"`
+	StartedAt        time.Time         `json:"startedAt"`
+	Duration         time.Duration     `json:"duration"`
+	Success          bool              `json:"success"`
+	Services         []ReportedService `json:"services"`
+}
+
+// ReportedService is a service of the report with the hashes of its files and its error
+type ReportedService struct {
+	ServiceReport
+	Files []ReportedFile `json:"files,omitempty"`
+	Error *ReportedError `json:"error,omitempty"`
+}
+
+// ReportedError is the failure of a service, located in its sources when known
+type ReportedError struct {
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"` // Relative to the app root
+	Line    int    `json:"line,omitempty"`
+}
+
+// ReportedFile is a file of a service relative to the output folder with the sha256 of its content
+type ReportedFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeGenerationReport writes the report of the services of a module into its output folder. Files
+// are hashed as left on disk, after formatting. Dry runs write nothing, the report changes every run.
+func writeGenerationReport(output outputFS, outputPath string, moduleName string, services []ServiceReport, failures []*ServiceError, start time.Time) error {
+	if _, dryRun := output.(*overlayFS); dryRun {
+		return nil
+	}
+	report := GenerationReport{
+		GeneratorVersion: VersionString(),
+		Module:           moduleName,
+		StartedAt:        start.UTC(),
+		Duration:         time.Since(start),
+		Success:          len(failures) == 0,
+		Services:         []ReportedService{},
+	}
+
+	errs := make(map[string]*ReportedError)
+	for _, failure := range failures {
+		errs[failure.Service] = &ReportedError{Message: causeMessage(failure.Err), File: failure.File, Line: failure.Line}
+	}
+	for _, service := range services {
+		reported := ReportedService{ServiceReport: service, Error: errs[service.Service]}
+		for _, file := range service.Files {
+			data, err := output.ReadFile(filepath.Join(outputPath, filepath.FromSlash(file)))
+			if err != nil {
+				return fmt.Errorf("failed to hash %s: %w", file, err)
+			}
+			sum := sha256.Sum256(data)
+			reported.Files = append(reported.Files, ReportedFile{Path: file, SHA256: hex.EncodeToString(sum[:])})
+		}
+		report.Services = append(report.Services, reported)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", reportName, err)
+	}
+	if err = output.MkdirAll(outputPath, 0755); err != nil {
+		return err
+	}
+	return output.WriteFile(filepath.Join(outputPath, reportName), append(data, '\n'), 0644)
+}
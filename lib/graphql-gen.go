@@ -0,0 +1,638 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// graphqlFolder holds the GraphQL schema of the app and its resolvers under the output folder
+const graphqlFolder = "graphql"
+
+// Operations a method is exposed as, chosen with //polycode:method graphql=query, mutation or skip
+const (
+	GraphQLQuery    = "query"
+	GraphQLMutation = "mutation"
+	GraphQLSkip     = "skip"
+)
+
+// graphqlQueryPrefixes are the first words of the names of methods exposed as queries by default,
+// methods reading rather than changing state
+var graphqlQueryPrefixes = []string{"Get", "List", "Find", "Search", "Query", "Count", "Fetch", "Lookup", "Check", "Is", "Has"}
+
+// graphqlName matches the names GraphQL accepts for types, fields and enum values
+var graphqlName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+const graphqlResolversTemplate = `// Code generated by next-gen. DO NOT EDIT.
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	{{.SDKImport}}
+	wrapper "{{.WrapperPackage}}"
+)
+
+// Schema is the GraphQL SDL of the services, the fields of Query and Mutation are resolved by Resolvers
+//
+//go:embed schema.graphql
+var Schema string
+
+// Resolver resolves a field of Query or Mutation from its arguments. The output is made of the maps,
+// slices and values encoding/json decodes to, so default field resolvers read it by field name.
+type Resolver func(ctx context.Context, args map[string]any) (any, error)
+
+// Resolvers holds the resolvers of the fields of Query and Mutation by field name
+type Resolvers struct {
+	Query    map[string]Resolver
+	Mutation map[string]Resolver
+}
+
+// Options provides what the polycode runtime would, the contexts the methods are called with
+type Options struct {
+	// ServiceContext returns the context of service methods
+	ServiceContext func(ctx context.Context) (polycode.ServiceContext, error)
+	// WorkflowContext returns the context of workflow methods
+	WorkflowContext func(ctx context.Context) (polycode.WorkflowContext, error)
+	// Roles returns the roles of the caller, checked against the //polycode:auth policy of the method.
+	// Methods with a policy are forbidden when it is nil.
+	Roles func(ctx context.Context) []string
+}
+
+// Error is a failed call, its extensions carry the code of the error and the rejected fields of invalid inputs
+type Error struct {
+	Message string
+	Code    string
+	Details any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions returns the extensions of the GraphQL error
+func (e *Error) Extensions() map[string]any {
+	extensions := map[string]any{"code": e.Code}
+	if e.Details != nil {
+		extensions["details"] = e.Details
+	}
+	return extensions
+}
+
+// NewResolvers returns the resolvers calling the methods through the wrappers of the services
+func NewResolvers(opts Options) Resolvers {
+	{{- range .Services}}
+	{{.Var}} := wrapper.New{{.StructName}}()
+	{{- end}}
+	return Resolvers{
+		Query: map[string]Resolver{
+			{{- range .Queries}}
+			{{- if .Service}}
+			"{{.Field}}": resolve({{.Service}}, "{{.Method}}", {{.Void}}, opts),
+			{{- else}}
+			"{{.Field}}": func(context.Context, map[string]any) (any, error) { return true, nil },
+			{{- end}}
+			{{- end}}
+		},
+		Mutation: map[string]Resolver{
+			{{- range .Mutations}}
+			"{{.Field}}": resolve({{.Service}}, "{{.Method}}", {{.Void}}, opts),
+			{{- end}}
+		},
+	}
+}
+
+// service is the part of the wrappers the resolvers call
+type service interface {
+	GetAuthPolicy(method string) (*wrapper.AuthPolicy, error)
+	GetInputType(method string) (any, error)
+	IsWorkflow(method string) bool
+	ExecuteService(ctx polycode.ServiceContext, method string, input any) (any, error)
+	ExecuteWorkflow(ctx polycode.WorkflowContext, method string, input any) (any, error)
+}
+
+// resolve returns the resolver of a method, decoding the input argument as its input. Methods
+// without output resolve to true.
+func resolve(service service, method string, void bool, opts Options) Resolver {
+	return func(ctx context.Context, args map[string]any) (any, error) {
+		policy, err := service.GetAuthPolicy(method)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil && (opts.Roles == nil || !policy.Allows(opts.Roles(ctx))) {
+			return nil, &Error{Message: "caller is not allowed to call " + method, Code: "FORBIDDEN"}
+		}
+
+		input, err := service.GetInputType(method)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := args["input"]; ok && input != nil {
+			data, err := json.Marshal(value)
+			if err == nil {
+				err = json.Unmarshal(data, input)
+			}
+			if err != nil {
+				return nil, &Error{Message: "invalid input: " + err.Error(), Code: "BAD_USER_INPUT"}
+			}
+		}
+
+		var output any
+		if service.IsWorkflow(method) {
+			if opts.WorkflowContext == nil {
+				return nil, fmt.Errorf("no workflow context configured")
+			}
+			workflowCtx, err := opts.WorkflowContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			output, err = service.ExecuteWorkflow(workflowCtx, method, input)
+			if err != nil {
+				return nil, serviceError(service, err)
+			}
+		} else {
+			if opts.ServiceContext == nil {
+				return nil, fmt.Errorf("no service context configured")
+			}
+			serviceCtx, err := opts.ServiceContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			output, err = service.ExecuteService(serviceCtx, method, input)
+			if err != nil {
+				return nil, serviceError(service, err)
+			}
+		}
+		if void {
+			return true, nil
+		}
+
+		data, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if err = json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+// serviceError maps a method error to a GraphQL error: rejected inputs carry their fields and errors of
+// the service error catalog their code, anything else is left as is
+func serviceError(service service, err error) error {
+	var validation wrapper.ValidationErrors
+	if errors.As(err, &validation) {
+		return &Error{Message: err.Error(), Code: "BAD_USER_INPUT", Details: validation}
+	}
+	if catalog, ok := service.(interface{ GetErrorCode(err error) string }); ok {
+		if code := catalog.GetErrorCode(err); code != "" {
+			return &Error{Message: err.Error(), Code: code}
+		}
+	}
+	return err
+}
+`
+
+// graphqlField is a field of Query or Mutation resolved by a method
+type graphqlField struct {
+	Field   string // Name of the field, like billingCharge
+	Service string // Variable holding the wrapper of the service in NewResolvers
+	Method  string
+	Void    bool // The method has no output, the field resolves to true
+}
+
+// graphqlOperation returns the operation a method is exposed as, empty when it is not exposed
+func graphqlOperation(def ServiceDefinition, method MethodDefinition) (string, error) {
+	if option, ok := method.Options["graphql"]; ok {
+		switch option {
+		case GraphQLQuery, GraphQLMutation:
+			if method.InputStream || method.OutputStream {
+				return "", fmt.Errorf("method %s.%s: streaming methods cannot be exposed over GraphQL", def.Name, method.Name)
+			}
+			return option, nil
+		case GraphQLSkip:
+			return "", nil
+		}
+		return "", fmt.Errorf("method %s.%s: //polycode:method graphql=%s must be query, mutation or skip", def.Name, method.Name, option)
+	}
+	if method.InputStream || method.OutputStream {
+		return "", nil
+	}
+	if !method.IsWorkflow {
+		for _, prefix := range graphqlQueryPrefixes {
+			rest, ok := strings.CutPrefix(method.Name, prefix)
+			if ok && (rest == "" || !unicode.IsLower([]rune(rest)[0])) {
+				return GraphQLQuery, nil
+			}
+		}
+	}
+	return GraphQLMutation, nil
+}
+
+// graphqlSchema builds the GraphQL SDL of the services, struct types become object types when returned
+// and input types when taken, suffixed with Input
+type graphqlSchema struct {
+	types   map[string][]Field // Struct types by Go type name
+	names   map[string]string  // GraphQL names of the struct and enum types by Go type name
+	objects map[string]bool    // Struct types used in outputs
+	inputs  map[string]bool    // Struct types used in inputs
+	enums   map[string][]any   // Values of the enum types by Go type name
+	// usedEnums and scalars are the enum types and custom scalars, JSON and Time, used by the schema
+	usedEnums map[string]bool
+	scalars   map[string]bool
+}
+
+func newGraphQLSchema(defs []ServiceDefinition) *graphqlSchema {
+	s := &graphqlSchema{
+		types:     collectSchemaTypes(defs),
+		names:     make(map[string]string),
+		objects:   make(map[string]bool),
+		inputs:    make(map[string]bool),
+		enums:     make(map[string][]any),
+		usedEnums: make(map[string]bool),
+		scalars:   make(map[string]bool),
+	}
+
+	// Types are named after the Go type, qualified with their package when two packages declare the same name
+	var goTypes []string
+	for name := range s.types {
+		goTypes = append(goTypes, name)
+	}
+	var collect func(schema *TypeSchema)
+	collect = func(schema *TypeSchema) {
+		if schema == nil {
+			return
+		}
+		if schema.Type != "" && graphqlEnum(schema) {
+			if _, ok := s.enums[schema.Type]; !ok {
+				s.enums[schema.Type] = schema.Enum
+				goTypes = append(goTypes, schema.Type)
+			}
+		}
+		collect(schema.Elem)
+		for _, field := range schema.Fields {
+			collect(field.Schema)
+		}
+	}
+	for _, fields := range s.types {
+		for _, field := range fields {
+			collect(field.Schema)
+		}
+	}
+	for _, def := range defs {
+		for _, method := range def.Methods {
+			collect(method.InputTypeSchema)
+			collect(method.OutputTypeSchema)
+		}
+	}
+
+	count := make(map[string]int)
+	for _, goType := range goTypes {
+		count[unqualifiedName(goType)]++
+	}
+	for _, goType := range goTypes {
+		name := unqualifiedName(goType)
+		if count[name] > 1 {
+			name = constName(goType)
+		}
+		s.names[goType] = name
+	}
+	return s
+}
+
+// unqualifiedName returns the name of a Go type without its package
+func unqualifiedName(goType string) string {
+	return goType[strings.LastIndex(goType, ".")+1:]
+}
+
+// graphqlEnum reports whether the values of a named type can be the values of a GraphQL enum
+func graphqlEnum(schema *TypeSchema) bool {
+	if schema.Kind != SchemaKindString || len(schema.Enum) == 0 {
+		return false
+	}
+	for _, value := range schema.Enum {
+		name, ok := value.(string)
+		if !ok || !graphqlName.MatchString(name) || name == "true" || name == "false" || name == "null" {
+			return false
+		}
+	}
+	return true
+}
+
+// typeRef returns the GraphQL type of a field type without its non-null marker
+func (s *graphqlSchema) typeRef(schema *TypeSchema, input bool) string {
+	switch schema.Kind {
+	case SchemaKindString:
+		if _, ok := s.enums[schema.Type]; ok && schema.Type != "" {
+			s.usedEnums[schema.Type] = true
+			return s.names[schema.Type]
+		}
+		return "String"
+	case SchemaKindInteger:
+		return "Int"
+	case SchemaKindNumber:
+		return "Float"
+	case SchemaKindBoolean:
+		return "Boolean"
+	case SchemaKindBytes:
+		return "String"
+	case SchemaKindTime:
+		s.scalars["Time"] = true
+		return "Time"
+	case SchemaKindArray:
+		return "[" + s.nonNull(s.typeRef(schema.Elem, input), !schema.Elem.Optional) + "]"
+	case SchemaKindStruct:
+		if _, ok := s.types[schema.Type]; ok && schema.Type != "" {
+			return s.structRef(schema.Type, input)
+		}
+	}
+	// Maps, anonymous structs and recursive collections have no GraphQL counterpart
+	s.scalars["JSON"] = true
+	return "JSON"
+}
+
+// goTypeRef returns the GraphQL type of a Go type expression for the inputs and outputs described by
+// their type alone
+func (s *graphqlSchema) goTypeRef(goType string, input bool) string {
+	goType = strings.TrimPrefix(goType, "*")
+	if _, ok := s.types[goType]; ok {
+		return s.structRef(goType, input)
+	}
+	switch {
+	case goType == "string", goType == "[]byte":
+		return "String"
+	case goType == "bool":
+		return "Boolean"
+	case integerTypes[goType]:
+		return "Int"
+	case floatTypes[goType]:
+		return "Float"
+	case goType == "time.Time":
+		s.scalars["Time"] = true
+		return "Time"
+	case strings.HasPrefix(goType, "[]"):
+		elem := strings.TrimPrefix(goType, "[]")
+		return "[" + s.nonNull(s.goTypeRef(elem, input), !strings.HasPrefix(elem, "*")) + "]"
+	}
+	s.scalars["JSON"] = true
+	return "JSON"
+}
+
+// structRef returns the GraphQL name of a struct type and records it among the object or input types
+func (s *graphqlSchema) structRef(goType string, input bool) string {
+	if input {
+		if !s.inputs[goType] {
+			s.inputs[goType] = true
+			for _, field := range s.types[goType] {
+				s.fieldRef(field, true)
+			}
+		}
+		return s.names[goType] + "Input"
+	}
+	if !s.objects[goType] {
+		s.objects[goType] = true
+		for _, field := range s.types[goType] {
+			s.fieldRef(field, false)
+		}
+	}
+	return s.names[goType]
+}
+
+// fieldRef returns the GraphQL type of a struct field, non-null unless it may be absent on the wire
+func (s *graphqlSchema) fieldRef(field Field, input bool) string {
+	var ref string
+	if field.Schema != nil {
+		ref = s.typeRef(field.Schema, input)
+	} else {
+		ref = s.goTypeRef(field.Type, input)
+	}
+	return s.nonNull(ref, !field.Optional && !strings.HasPrefix(field.Type, "*"))
+}
+
+func (s *graphqlSchema) nonNull(ref string, required bool) string {
+	if required {
+		return ref + "!"
+	}
+	return ref
+}
+
+// writeGraphQLDescription writes a GraphQL description block at the indentation
+func writeGraphQLDescription(b *strings.Builder, indent string, description string) {
+	if description == "" {
+		return
+	}
+	description = strings.ReplaceAll(description, `"""`, `\"""`)
+	if !strings.Contains(description, "\n") {
+		fmt.Fprintf(b, "%s\"\"\"%s\"\"\"\n", indent, description)
+		return
+	}
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(description, "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(b, "%s%s\n", indent, line)
+	}
+	fmt.Fprintf(b, "%s\"\"\"\n", indent)
+}
+
+// writeFields writes the fields of an object or input type by their wire names
+func (s *graphqlSchema) writeFields(b *strings.Builder, fields []Field, input bool) {
+	for _, field := range fields {
+		name, _, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		if !graphqlName.MatchString(name) || strings.HasPrefix(name, "__") {
+			fmt.Fprintf(b, "  # %s is not a valid GraphQL name\n", name)
+			continue
+		}
+		writeGraphQLDescription(b, "  ", field.Doc)
+		fmt.Fprintf(b, "  %s: %s\n", name, s.fieldRef(field, input))
+	}
+}
+
+// buildGraphQL returns the SDL of the methods of the services and the fields of Query and Mutation
+// resolving them
+func buildGraphQL(defs []ServiceDefinition) (string, []graphqlField, []graphqlField, error) {
+	s := newGraphQLSchema(defs)
+	var queries, mutations []graphqlField
+	var queryFields, mutationFields strings.Builder
+	declared := make(map[string]string)
+	for _, def := range defs {
+		for _, method := range def.Methods {
+			operation, err := graphqlOperation(def, method)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			if operation == "" {
+				continue
+			}
+
+			serviceField := constName(def.Name)
+			field := graphqlField{
+				Field:   strings.ToLower(serviceField[:1]) + serviceField[1:] + constName(method.Name),
+				Service: "service" + serviceField,
+				Method:  method.Name,
+				Void:    method.OutputType == "",
+			}
+			if first, ok := declared[field.Field]; ok {
+				return "", nil, nil, fmt.Errorf("GraphQL fields of %s and %s.%s are both %s, rename one of them", first, def.Name, method.Name, field.Field)
+			}
+			declared[field.Field] = def.Name + "." + method.Name
+
+			var args string
+			switch {
+			case method.InputTypeSchema != nil:
+				args = "(input: " + s.nonNull(s.typeRef(method.InputTypeSchema, true), !method.InputTypeSchema.Optional) + ")"
+			case method.InputType != "":
+				args = "(input: " + s.nonNull(s.goTypeRef(method.InputType, true), !strings.HasPrefix(method.InputType, "*")) + ")"
+			}
+			var result string
+			switch {
+			case method.OutputType == "":
+				result = "Boolean!"
+			case method.OutputTypeSchema != nil:
+				result = s.typeRef(method.OutputTypeSchema, false)
+			default:
+				result = s.nonNull(s.goTypeRef(method.OutputType, false), !strings.HasPrefix(method.OutputType, "*") && !strings.HasPrefix(method.OutputType, "["))
+			}
+
+			b := &mutationFields
+			if operation == GraphQLQuery {
+				b = &queryFields
+				queries = append(queries, field)
+			} else {
+				mutations = append(mutations, field)
+			}
+			description := method.Description
+			if method.Doc != "" {
+				description = method.Doc
+			}
+			writeGraphQLDescription(b, "  ", description)
+			fmt.Fprintf(b, "  %s%s: %s", field.Field, args, result)
+			if method.Deprecated != nil {
+				reason := method.Deprecated.Message
+				if reason == "" && method.Deprecated.Use != "" {
+					reason = "use " + method.Deprecated.Use
+				}
+				if reason == "" {
+					b.WriteString(" @deprecated")
+				} else {
+					fmt.Fprintf(b, " @deprecated(reason: %q)", reason)
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(queries) == 0 {
+		// GraphQL schemas must have a query type
+		writeGraphQLDescription(&queryFields, "  ", "Always true, the services expose no queries")
+		queryFields.WriteString("  ok: Boolean!\n")
+		queries = append(queries, graphqlField{Field: "ok", Void: true})
+	}
+
+	var sdl strings.Builder
+	sdl.WriteString("# " + generatedHeader() + ". DO NOT EDIT.\n")
+	sdl.WriteString("\ntype Query {\n" + queryFields.String() + "}\n")
+	if mutationFields.Len() > 0 {
+		sdl.WriteString("\ntype Mutation {\n" + mutationFields.String() + "}\n")
+	}
+
+	// Types are written once every reference was resolved, the fields of the types record more of them
+	for _, kind := range []struct {
+		keyword string
+		suffix  string
+		used    map[string]bool
+		input   bool
+	}{
+		{"type", "", s.objects, false},
+		{"input", "Input", s.inputs, true},
+	} {
+		goTypes := slices.SortedFunc(maps.Keys(kind.used), func(a, b string) int { return strings.Compare(s.names[a], s.names[b]) })
+		for _, goType := range goTypes {
+			fmt.Fprintf(&sdl, "\n%s %s%s {\n", kind.keyword, s.names[goType], kind.suffix)
+			s.writeFields(&sdl, s.types[goType], kind.input)
+			sdl.WriteString("}\n")
+		}
+	}
+	for _, goType := range slices.Sorted(maps.Keys(s.usedEnums)) {
+		fmt.Fprintf(&sdl, "\nenum %s {\n", s.names[goType])
+		for _, value := range s.enums[goType] {
+			fmt.Fprintf(&sdl, "  %s\n", value)
+		}
+		sdl.WriteString("}\n")
+	}
+	for _, scalar := range slices.Sorted(maps.Keys(s.scalars)) {
+		fmt.Fprintf(&sdl, "\nscalar %s\n", scalar)
+	}
+	return sdl.String(), queries, mutations, nil
+}
+
+// writeGraphQL writes the GraphQL schema of the services as .polycode/graphql/schema.graphql and, when
+// the wrappers are generated, resolvers routing its fields into ExecuteService and ExecuteWorkflow
+func writeGraphQL(output outputFS, outputPath string, moduleName string, defs []ServiceDefinition, opts Options) error {
+	sdl, queries, mutations, err := buildGraphQL(defs)
+	if err != nil {
+		return err
+	}
+	folder := filepath.Join(outputPath, graphqlFolder)
+	if err = output.MkdirAll(folder, 0755); err != nil {
+		return fmt.Errorf("failed to create graphql folder: %w", err)
+	}
+	files := map[string][]byte{"schema.graphql": []byte(sdl)}
+
+	if slices.Contains(opts.Targets, TargetGo) {
+		type serviceVar struct{ Var, StructName string }
+		var services []serviceVar
+		for _, def := range defs {
+			services = append(services, serviceVar{Var: "service" + constName(def.Name), StructName: toPascalCase(serviceFileName(def.Name))})
+		}
+		tmpl, err := template.New("graphql").Parse(stampVersion(graphqlResolversTemplate))
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, map[string]any{
+			"SDKImport":      sdkImportSpec(opts.SDKImport),
+			"WrapperPackage": moduleName + "/" + filepath.ToSlash(filepath.Clean(opts.OutputDir)),
+			"Services":       services,
+			"Queries":        queries,
+			"Mutations":      mutations,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate GraphQL resolvers: %w", err)
+		}
+		if files["resolvers.go"], err = format.Source(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	keep := make(map[string]bool)
+	for name, data := range files {
+		keep[name] = true
+		path := filepath.Join(folder, name)
+		if existing, err := output.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			continue
+		}
+		if err = output.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return removeUnlisted(output, folder, "*", keep)
+}
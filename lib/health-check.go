@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// healthCheckName is the function a service reports its health with instead of exposing it as a method
+const healthCheckName = "HealthCheck"
+
+// healthStatusName is the name of the type a health check returns, declared by the service package or
+// a package it imports
+const healthStatusName = "HealthStatus"
+
+// HealthCheck is the optional health probe of a service, wired into the Health method of the wrapper:
+//
+//	func HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error)
+type HealthCheck struct {
+	StatusType      string // Go type of the status as written in the wrapper, like service.HealthStatus
+	IsStatusPointer bool   // The status is returned by pointer
+	IsMethod        bool   // Declared on the ServiceReceiver rather than as a function
+}
+
+// HealthCheckDefinition tells orchestrators the service can be probed and what the probe returns
+type HealthCheckDefinition struct {
+	StatusType   string  `yaml:"statusType" json:"statusType"`
+	StatusSchema []Field `yaml:"statusSchema,omitempty" json:"statusSchema,omitempty"`
+}
+
+// HealthCallee returns the expression the wrapper calls the health check through
+func (s ServiceInfo) HealthCallee() string {
+	if s.HealthCheck.IsMethod {
+		return "t.receiver." + healthCheckName
+	}
+	return "service." + healthCheckName
+}
+
+// parseHealthCheck checks the signature of a health check and returns its status type
+func parseHealthCheck(fn *ast.FuncDecl, resolve contextResolver, localPkg string) (*HealthCheck, error) {
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams == nil && len(params) == 1 && resolve(params[0]) == "Service" && len(results) == 2 && isErrorType(results[1]) && isHealthStatus(results[0]) {
+		statusType, isPointer, _ := extractType(results[0], localPkg)
+		return &HealthCheck{StatusType: statusType, IsStatusPointer: isPointer, IsMethod: fn.Recv != nil}, nil
+	}
+	return nil, fmt.Errorf("function %s: health checks must have the signature func %s(ctx polycode.ServiceContext) (%s, error)", fn.Name.Name, healthCheckName, healthStatusName)
+}
+
+// isHealthStatus reports whether a type expression is a HealthStatus type or a pointer to one
+func isHealthStatus(expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name == healthStatusName
+	case *ast.SelectorExpr:
+		return t.Sel.Name == healthStatusName
+	}
+	return false
+}
+
+// healthCheckDefinition describes the health check of a service, nil when it declares none
+func healthCheckDefinition(check *HealthCheck, structs map[string][]Field) *HealthCheckDefinition {
+	if check == nil {
+		return nil
+	}
+	statusType := check.StatusType
+	if check.IsStatusPointer {
+		statusType = "*" + statusType
+	}
+	return &HealthCheckDefinition{StatusType: statusType, StatusSchema: structs[check.StatusType]}
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    *HealthCheck
+		wantErr string
+	}{
+		{name: "none", src: "func Create(ctx polycode.ServiceContext) error { return nil }"},
+		{
+			name: "local status",
+			src:  "type HealthStatus struct{ Ready bool }\n\nfunc HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error) { return HealthStatus{}, nil }",
+			want: &HealthCheck{StatusType: "service.HealthStatus"},
+		},
+		{
+			name: "imported pointer status",
+			src:  "func HealthCheck(ctx polycode.ServiceContext) (*models.HealthStatus, error) { return nil, nil }",
+			want: &HealthCheck{StatusType: "models.HealthStatus", IsStatusPointer: true},
+		},
+		{
+			name: "method of the receiver",
+			src: "type HealthStatus struct{ Ready bool }\n\ntype Orders struct{}\n\n" +
+				"func (o *Orders) Create(ctx polycode.ServiceContext) error { return nil }\n\n" +
+				"func (o *Orders) HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error) { return HealthStatus{}, nil }",
+			want: &HealthCheck{StatusType: "service.HealthStatus", IsMethod: true},
+		},
+		{
+			name:    "workflow context",
+			src:     "type HealthStatus struct{}\n\nfunc HealthCheck(ctx polycode.WorkflowContext) (HealthStatus, error) { return HealthStatus{}, nil }",
+			wantErr: "health checks must have the signature func HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error)",
+		},
+		{
+			name:    "other status type",
+			src:     "func HealthCheck(ctx polycode.ServiceContext) (string, error) { return \"\", nil }",
+			wantErr: "health checks must have the signature",
+		},
+		{
+			name: "function and method",
+			src: "type HealthStatus struct{}\n\ntype Orders struct{}\n\n" +
+				"func (o *Orders) HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error) { return HealthStatus{}, nil }\n\n" +
+				"func HealthCheck(ctx polycode.ServiceContext) (HealthStatus, error) { return HealthStatus{}, nil }",
+			wantErr: "declared both as a function and as a method of Orders",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseSource(t, tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed.Health, tt.want) {
+				t.Errorf("got %+v, want %+v", parsed.Health, tt.want)
+			}
+			// The health check is not exposed as a method
+			for _, method := range parsed.Methods {
+				if method.OriginalName == healthCheckName {
+					t.Errorf("health check exposed as a method")
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheckDefinition(t *testing.T) {
+	status := []Field{{Name: "ready", Type: "bool"}}
+	structs := map[string][]Field{"service.HealthStatus": status}
+	tests := []struct {
+		name  string
+		check *HealthCheck
+		want  *HealthCheckDefinition
+	}{
+		{name: "none"},
+		{name: "value", check: &HealthCheck{StatusType: "service.HealthStatus"}, want: &HealthCheckDefinition{StatusType: "service.HealthStatus", StatusSchema: status}},
+		{name: "pointer", check: &HealthCheck{StatusType: "service.HealthStatus", IsStatusPointer: true}, want: &HealthCheckDefinition{StatusType: "*service.HealthStatus", StatusSchema: status}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := healthCheckDefinition(tt.check, structs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapperHealth(t *testing.T) {
+	parsed, err := parseSource(t, "type Orders struct{}\n\n"+
+		"func (o *Orders) Create(ctx polycode.ServiceContext) error { return nil }\n\n"+
+		"func (o *Orders) HealthCheck(ctx polycode.ServiceContext) (*models.HealthStatus, error) { return nil, nil }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := newServiceInfo("example.com/app", "orders", "services/orders", parsed.Methods, parsed.Imports, DefaultOptions())
+	info.Receiver, info.HealthCheck = parsed.Receiver, parsed.Health
+	code, err := generateServiceCode(info, wrapperTemplate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "return t.receiver.HealthCheck(ctx)"; !strings.Contains(code, want) {
+		t.Errorf("wrapper does not contain %q:\n%s", want, code)
+	}
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+)
+
+// Hook stages that can be configured under hooks in next-gen.yaml
+const (
+	HookPreGenerate     = "pre-generate"
+	HookPostGenerate    = "post-generate"
+	HookPostWatchChange = "post-watch-change"
+)
+
+// Hooks lists shell commands run around generation, each stage accepts a single command or a list
+type Hooks struct {
+	PreGenerate     StringList `yaml:"pre-generate"`
+	PostGenerate    StringList `yaml:"post-generate"`
+	PostWatchChange StringList `yaml:"post-watch-change"`
+}
+
+// RunHooks runs the commands of a stage in the app folder one after the other, stopping at the first failure.
+// The variables in env are added to the environment as NEXTGEN_<key> along with NEXTGEN_APP_PATH.
+func RunHooks(stage string, commands []string, appPath string, env map[string]string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	environ := append(os.Environ(), "NEXTGEN_HOOK="+stage, "NEXTGEN_APP_PATH="+appPath)
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		environ = append(environ, "NEXTGEN_"+key+"="+env[key])
+	}
+
+	for _, command := range commands {
+		slog.Info("Running hook", "stage", stage, "command", command)
+		cmd := shellCommand(command)
+		cmd.Dir = appPath
+		cmd.Env = environ
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", stage, command, err)
+		}
+	}
+	return nil
+}
+
+// shellCommand runs a command line through the platform shell so hooks can use pipes and variables
+func shellCommand(command string) *exec.Cmd {
+	args := shellArgs(command)
+	return exec.Command(args[0], args[1:]...)
+}
+
+// shellArgs returns the arguments running a command line through the platform shell
+func shellArgs(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are sh command lines")
+	}
+	appPath := t.TempDir()
+	env := map[string]string{"CHANGED_FILES": "services/orders/a.go\nservices/orders/b.go", "SERVICE": "orders"}
+	// The commands run in the app folder, one after the other
+	commands := []string{
+		`printf %s "$NEXTGEN_HOOK" > hook.txt`,
+		`printf %s "$NEXTGEN_SERVICE" > service.txt`,
+		`printf %s "$NEXTGEN_CHANGED_FILES" > changed.txt`,
+		`printf %s "$NEXTGEN_APP_PATH" > app.txt`,
+	}
+	if err := RunHooks(HookPostWatchChange, commands, appPath, env); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hook.txt":    HookPostWatchChange,
+		"service.txt": "orders",
+		"changed.txt": "services/orders/a.go\nservices/orders/b.go",
+		"app.txt":     appPath,
+	}
+	for file, value := range want {
+		data, err := os.ReadFile(filepath.Join(appPath, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != value {
+			t.Errorf("%s: got %q, want %q", file, data, value)
+		}
+	}
+}
+
+func TestRunHooksStopsAtFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks are sh command lines")
+	}
+	appPath := t.TempDir()
+	err := RunHooks(HookPostGenerate, []string{"exit 3", "touch ran.txt"}, appPath, nil)
+	if want := `post-generate hook "exit 3" failed`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want an error containing %q", err, want)
+	}
+	if _, err = os.Stat(filepath.Join(appPath, "ran.txt")); !os.IsNotExist(err) {
+		t.Errorf("the command after the failure ran: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const httpTemplate = `// Code generated by next-gen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	{{.Info.SDKImport}}
+	wrapper "{{.Info.ModuleName}}/{{.Info.OutputDir}}"
+)
+
+// ServiceName is the registered name of the {{.Info.ServiceName}} service
+const ServiceName = "{{.Info.ServiceName}}"
+
+// Pattern is the net/http route serving the methods of the service
+const Pattern = "POST /services/{{.Info.ServiceName}}/{method}"
+
+// Options provides what the polycode runtime would, the contexts the methods are called with
+type Options struct {
+	// ServiceContext returns the context of service methods
+	ServiceContext func(r *http.Request) (polycode.ServiceContext, error)
+	// WorkflowContext returns the context of workflow methods
+	WorkflowContext func(r *http.Request) (polycode.WorkflowContext, error)
+	// Roles returns the roles of the caller, checked against the //polycode:auth policy of the method.
+	// Methods with a policy are forbidden when it is nil.
+	Roles func(r *http.Request) []string
+}
+
+// ErrorResponse is the JSON body of a failed call
+type ErrorResponse struct {
+	Error   string ` + "`json:\"error\"`" + `
+	Code    string ` + "`json:\"code,omitempty\"`" + `
+	Details any    ` + "`json:\"details,omitempty\"`" + `
+}
+
+// Register routes POST /services/{{.Info.ServiceName}}/{method} of mux to the service
+func Register(mux *http.ServeMux, opts Options) {
+	mux.Handle(Pattern, NewHandler(opts))
+}
+
+// NewHandler returns a handler calling the method named by the last path segment, the JSON request body
+// is decoded as its input and its output is encoded as the JSON response
+func NewHandler(opts Options) http.Handler {
+	service := wrapper.New{{.Info.ServiceStructName}}()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "use POST to call a method"})
+			return
+		}
+		method := r.PathValue("method")
+		if method == "" {
+			method = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		}
+		if service.IsStreaming(method) {
+			writeError(w, http.StatusNotImplemented, ErrorResponse{Error: "streaming methods are not served over HTTP"})
+			return
+		}
+
+		policy, err := service.GetAuthPolicy(method)
+		if err != nil {
+			writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if policy != nil && (opts.Roles == nil || !policy.Allows(opts.Roles(r))) {
+			writeError(w, http.StatusForbidden, ErrorResponse{Error: "caller is not allowed to call " + method})
+			return
+		}
+
+		input, err := service.GetInputType(method)
+		if err != nil {
+			writeError(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if input != nil {
+			// An empty body leaves the input at its zero value
+			if err = json.NewDecoder(r.Body).Decode(input); err != nil && !errors.Is(err, io.EOF) {
+				writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid input: " + err.Error()})
+				return
+			}
+		}
+
+		var output any
+		if service.IsWorkflow(method) {
+			if opts.WorkflowContext == nil {
+				writeError(w, http.StatusInternalServerError, ErrorResponse{Error: "no workflow context configured"})
+				return
+			}
+			ctx, err := opts.WorkflowContext(r)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+				return
+			}
+			output, err = service.ExecuteWorkflow(ctx, method, input)
+			if err != nil {
+				writeServiceError(w, service, err)
+				return
+			}
+		} else {
+			if opts.ServiceContext == nil {
+				writeError(w, http.StatusInternalServerError, ErrorResponse{Error: "no service context configured"})
+				return
+			}
+			ctx, err := opts.ServiceContext(r)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+				return
+			}
+			output, err = service.ExecuteService(ctx, method, input)
+			if err != nil {
+				writeServiceError(w, service, err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(output)
+	})
+}
+
+// writeServiceError maps a method error to a status: rejected inputs are bad requests, errors of the
+// service error catalog are unprocessable with their code and anything else is an internal error
+func writeServiceError(w http.ResponseWriter, service *wrapper.{{.Info.ServiceStructName}}, err error) {
+	var validation wrapper.ValidationErrors
+	if errors.As(err, &validation) {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error(), Details: validation})
+		return
+	}
+	{{- if .Info.Errors}}
+	if code := service.GetErrorCode(err); code != "" {
+		writeError(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error(), Code: code})
+		return
+	}
+	{{- end}}
+	writeError(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+}
+
+func writeError(w http.ResponseWriter, status int, response ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+`
+
+// TargetHTTP generates net/http handlers serving services without the polycode runtime
+const TargetHTTP = "http"
+
+// httpPackageName returns the Go package name of the HTTP handler of a service
+func httpPackageName(serviceName string) string {
+	return strings.ToLower(strings.ReplaceAll(serviceFileName(serviceName), "-", "")) + "http"
+}
+
+// httpTarget generates .polycode/http/<service>http packages
+type httpTarget struct{}
+
+func (httpTarget) Name() string {
+	return TargetHTTP
+}
+
+func (httpTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	tmpl, err := template.New("http").Parse(stampVersion(httpTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	packageName := httpPackageName(info.ServiceName)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"PackageName": packageName,
+		"Info":        info,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate HTTP handler: %w", err)
+	}
+
+	return map[string][]byte{"http/" + packageName + "/handler.go": buf.Bytes()}, nil
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// routesPackage is the folder of the output holding the route table of the app
+const routesPackage = "routes"
+
+// routeMethods are the HTTP methods //polycode:http accepts
+var routeMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// routeSegment matches a segment of a route path, a literal or a {name} or {name...} wildcard
+var routeSegment = regexp.MustCompile(`^([A-Za-z0-9._~!$&'()*+,;=:@%-]*|\{[A-Za-z_][A-Za-z0-9_]*(\.\.\.)?\})$`)
+
+// HTTPRoute is a route of an API gateway to a method, declared by //polycode:http method=POST path=/orders.
+// A method may declare several routes, the HTTP method defaults to POST.
+type HTTPRoute struct {
+	Method string
+	Path   string
+}
+
+// RouteDefinition is a route of the definition, the gateway forwards its requests to Handler
+type RouteDefinition struct {
+	Method  string `yaml:"method" json:"method"`
+	Path    string `yaml:"path" json:"path"`
+	Handler string `yaml:"handler" json:"handler"` // Name the method is invoked with
+}
+
+// parseHTTPRoute reads the arguments of a //polycode:http directive
+func parseHTTPRoute(args string) (HTTPRoute, error) {
+	route := HTTPRoute{Method: "POST"}
+	for key, value := range parseDirectiveArgs(args) {
+		switch key {
+		case "method":
+			route.Method = strings.ToUpper(value)
+			if !slices.Contains(routeMethods, route.Method) {
+				return route, fmt.Errorf("//polycode:http method %q must be one of %s", value, strings.Join(routeMethods, ", "))
+			}
+		case "path":
+			route.Path = value
+		default:
+			return route, fmt.Errorf("//polycode:http: unknown option %q, expected method or path", key)
+		}
+	}
+	if route.Path == "" || route.Path == "true" {
+		return route, fmt.Errorf("//polycode:http requires a path, like path=/orders")
+	}
+	if !strings.HasPrefix(route.Path, "/") {
+		return route, fmt.Errorf("//polycode:http path %q must start with /", route.Path)
+	}
+	segments := strings.Split(route.Path[1:], "/")
+	for i, segment := range segments {
+		if !routeSegment.MatchString(segment) {
+			return route, fmt.Errorf("//polycode:http path %q: segment %q must be a literal or a {name} wildcard", route.Path, segment)
+		}
+		if strings.HasSuffix(segment, "...}") && i != len(segments)-1 {
+			return route, fmt.Errorf("//polycode:http path %q: %s must be the last segment", route.Path, segment)
+		}
+	}
+	return route, nil
+}
+
+// parseHTTPRoutes reads the //polycode:http directives of a method
+func parseHTTPRoutes(directives []string) ([]HTTPRoute, error) {
+	var routes []HTTPRoute
+	for _, args := range directives {
+		route, err := parseHTTPRoute(args)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(routes, route) {
+			return nil, fmt.Errorf("//polycode:http %s %s is declared twice", route.Method, route.Path)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// serviceRoutes returns the routes of the methods of a service sorted by path and method, two methods
+// may not declare the same route
+func serviceRoutes(methods []MethodInfo) ([]RouteDefinition, error) {
+	var routes []RouteDefinition
+	declared := make(map[HTTPRoute]string)
+	for _, method := range methods {
+		for _, route := range method.Routes {
+			if other, ok := declared[route]; ok {
+				return nil, fmt.Errorf("methods %s and %s both declare //polycode:http %s %s", other, method.ExposedName, route.Method, route.Path)
+			}
+			declared[route] = method.ExposedName
+			routes = append(routes, RouteDefinition{Method: route.Method, Path: route.Path, Handler: method.ExposedName})
+		}
+	}
+	sortRoutes(routes, func(route RouteDefinition) (string, string) { return route.Path, route.Method })
+	return routes, nil
+}
+
+// sortRoutes orders routes by path then method
+func sortRoutes[R any](routes []R, key func(R) (path string, method string)) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		pathI, methodI := key(routes[i])
+		pathJ, methodJ := key(routes[j])
+		if pathI != pathJ {
+			return pathI < pathJ
+		}
+		return methodI < methodJ
+	})
+}
+
+const routesTemplate = `// Code generated by next-gen. DO NOT EDIT.
+
+// Package routes is the route table of the app, the HTTP routes declared with //polycode:http and
+// the service methods they call, to configure API gateways from.
+package routes
+
+// Route maps an HTTP route to a service method
+type Route struct {
+	Method  string // HTTP method, like POST
+	Path    string // Path pattern, like /orders/{id}
+	Service string // Registered name of the service
+	Handler string // Name the method is invoked with
+}
+
+// Pattern returns the route as a net/http pattern, like POST /orders/{id}
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+// Table lists the routes of the services sorted by path and method
+var Table = []Route{
+	{{- range .}}
+	{Method: {{printf "%q" .Method}}, Path: {{printf "%q" .Path}}, Service: {{printf "%q" .Service}}, Handler: {{printf "%q" .Handler}}},
+	{{- end}}
+}
+`
+
+// writeRouteTable writes the routes of the services into .polycode/routes, two services may not
+// declare the same route
+func writeRouteTable(output outputFS, outputPath string, defs []ServiceDefinition) error {
+	type tableRoute struct {
+		RouteDefinition
+		Service string
+	}
+	var routes []tableRoute
+	declared := make(map[HTTPRoute]string)
+	for _, def := range defs {
+		for _, route := range def.Routes {
+			key := HTTPRoute{Method: route.Method, Path: route.Path}
+			if other, ok := declared[key]; ok {
+				return fmt.Errorf("%s and %s.%s both declare //polycode:http %s %s", other, def.Name, route.Handler, route.Method, route.Path)
+			}
+			declared[key] = def.Name + "." + route.Handler
+			routes = append(routes, tableRoute{RouteDefinition: route, Service: def.Name})
+		}
+	}
+	sortRoutes(routes, func(route tableRoute) (string, string) { return route.Path, route.Method })
+
+	tmpl, err := template.New("routes").Parse(stampVersion(routesTemplate))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, routes); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", routesPackage, err)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	folder := filepath.Join(outputPath, routesPackage)
+	path := filepath.Join(folder, routesPackage+".go")
+	if existing, err := output.ReadFile(path); err == nil && bytes.Equal(existing, code) {
+		return nil
+	}
+	if err = output.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	return output.WriteFile(path, code, 0644)
+}
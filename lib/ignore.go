@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// builtinIgnores are always skipped by the watcher, the output folder is added by LoadIgnoreMatcher
+var builtinIgnores = []string{"vendor/", "node_modules/"}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// IgnoreMatcher matches paths below a root against gitignore-style patterns.
+// Hidden files and folders are always ignored.
+type IgnoreMatcher struct {
+	root     string
+	patterns []ignorePattern
+}
+
+// NewIgnoreMatcher compiles gitignore-style patterns relative to root, later patterns take precedence
+func NewIgnoreMatcher(root string, patterns []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{root: filepath.Clean(root)}
+	for _, pattern := range patterns {
+		m.add(pattern)
+	}
+	return m
+}
+
+// LoadIgnoreMatcher builds the watcher ignore rules of an app from the built-in exclusions,
+// the output folder, the app's .gitignore and extra patterns
+func LoadIgnoreMatcher(appPath string, outputDir string, extra []string) (*IgnoreMatcher, error) {
+	patterns := append([]string{}, builtinIgnores...)
+	patterns = append(patterns, "/"+filepath.ToSlash(filepath.Clean(outputDir))+"/")
+
+	file, err := os.Open(filepath.Join(appPath, ".gitignore"))
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			patterns = append(patterns, scanner.Text())
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return NewIgnoreMatcher(appPath, append(patterns, extra...)), nil
+}
+
+func (m *IgnoreMatcher) add(line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the root
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return
+	}
+	p.re = re
+	m.patterns = append(m.patterns, p)
+}
+
+// globToRegexp translates a gitignore glob with *, ?, [...] and ** into a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether path, or one of its parent folders below the root, is ignored
+func (m *IgnoreMatcher) Match(path string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == "." || isOutside(rel) {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		if m.matchOne(strings.Join(parts[:i+1], "/"), i < len(parts)-1 || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOne applies the patterns to a single path, the last matching pattern decides
+func (m *IgnoreMatcher) matchOne(rel string, isDir bool) bool {
+	if strings.HasPrefix(rel[strings.LastIndex(rel, "/")+1:], ".") {
+		return true
+	}
+
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n/build/\n!keep.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := LoadIgnoreMatcher(root, ".polycode", []string{"tmp/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{rel: filepath.Join("services", "orders", "orders.go"), want: false},
+		{rel: ".polycode", isDir: true, want: true},
+		{rel: filepath.Join(".polycode", "orders.go"), want: true},
+		{rel: filepath.Join("services", ".hidden.go"), want: true},
+		{rel: filepath.Join("vendor", "example.com", "lib.go"), want: true},
+		{rel: filepath.Join("web", "node_modules"), isDir: true, want: true},
+		{rel: filepath.Join("services", "debug.log"), want: true},
+		{rel: filepath.Join("services", "keep.log"), want: false},
+		{rel: "build", isDir: true, want: true},
+		{rel: filepath.Join("services", "build"), isDir: true, want: false},
+		{rel: "build", want: false},
+		{rel: filepath.Join("tmp", "scratch.go"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := matcher.Match(filepath.Join(root, tt.rel), tt.isDir); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if matcher.Match(root, true) || matcher.Match(filepath.Join(filepath.Dir(root), "other.log"), false) {
+		t.Error("matched the root or a path outside of it")
+	}
+}
@@ -0,0 +1,323 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultInvokeURL is where next-gen invoke calls the running app when neither -url nor dev.url is set
+const DefaultInvokeURL = "http://localhost:8080"
+
+// InvokeResult is the response of the running app to a method call
+type InvokeResult struct {
+	Status int
+	Body   []byte
+}
+
+// FindMethod looks up a method in the generated definitions of the app, the method name is matched
+// case-insensitively like the wrappers do
+func FindMethod(appPath string, opts Options, serviceName string, methodName string) (ServiceDefinition, MethodDefinition, error) {
+	outputPaths, err := OutputFolders(appPath, opts)
+	if err != nil {
+		return ServiceDefinition{}, MethodDefinition{}, err
+	}
+
+	var services []string
+	for _, outputPath := range outputPaths {
+		defs, err := LoadServiceDefinitions(outputPath)
+		if err != nil {
+			return ServiceDefinition{}, MethodDefinition{}, err
+		}
+		for _, def := range defs {
+			services = append(services, def.Name)
+			if def.Name != serviceName {
+				continue
+			}
+
+			var methods []string
+			for _, method := range def.Methods {
+				if strings.EqualFold(method.Name, methodName) {
+					return def, method, nil
+				}
+				methods = append(methods, method.Name)
+			}
+			return def, MethodDefinition{}, fmt.Errorf("service %s has no method %q, expected one of %s", serviceName, methodName, strings.Join(methods, ", "))
+		}
+	}
+	if len(services) == 0 {
+		return ServiceDefinition{}, MethodDefinition{}, fmt.Errorf("no service definitions found, run next-gen first")
+	}
+	sort.Strings(services)
+	return ServiceDefinition{}, MethodDefinition{}, fmt.Errorf("unknown service %q, expected one of %s", serviceName, strings.Join(services, ", "))
+}
+
+// ValidateInput checks a JSON input against the schema of a method: its type, the fields of structs
+// required by their validate tag, unknown fields and the types of nested values. Every problem is reported.
+// Other absent fields decode to their zero value and are accepted.
+func ValidateInput(def ServiceDefinition, method MethodDefinition, data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		if method.InputType != "" {
+			return fmt.Errorf("method %s takes an input of type %s, pass it with -data", method.Name, method.InputType)
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("input is not valid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("input holds more than one JSON value")
+	}
+	if method.InputType == "" {
+		return fmt.Errorf("method %s takes no input", method.Name)
+	}
+
+	v := inputValidator{types: def.Types, named: make(map[string]*TypeSchema)}
+	if len(method.InputSchema) > 0 {
+		v.checkFields("input", value, method.InputSchema)
+	} else if method.InputTypeSchema != nil {
+		v.checkSchema("input", value, method.InputTypeSchema)
+	} else {
+		v.checkGoType("input", value, method.InputType)
+	}
+	return errors.Join(v.problems...)
+}
+
+// inputValidator collects the problems of a JSON value against field schemas
+type inputValidator struct {
+	types map[string][]Field
+	// named holds the schemas of the named types met so far, recursive uses are checked against them
+	named    map[string]*TypeSchema
+	problems []error
+}
+
+func (v *inputValidator) fail(path string, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+// checkFields checks an object against the fields of a struct
+func (v *inputValidator) checkFields(path string, value any, fields []Field) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		v.fail(path, "expected an object, got %s", jsonKind(value))
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, field := range fields {
+		name, _, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		known[name] = true
+
+		fieldValue, present := object[name]
+		switch {
+		case !present && requiredByTag(field):
+			v.fail(path+"."+name, "required field of type %s is missing", field.Type)
+		case !present:
+		case field.Schema != nil:
+			v.checkSchema(path+"."+name, fieldValue, field.Schema)
+		default:
+			v.checkGoType(path+"."+name, fieldValue, field.Type)
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			v.fail(path+"."+name, "unknown field")
+		}
+	}
+}
+
+// checkSchema checks a value against the schema of a field type
+func (v *inputValidator) checkSchema(path string, value any, schema *TypeSchema) {
+	if schema.Kind == SchemaKindRef {
+		named, ok := v.named[schema.Type]
+		if !ok || value == nil && schema.Optional {
+			return
+		}
+		schema = named
+	}
+	if schema.Type != "" {
+		v.named[schema.Type] = schema
+	}
+
+	if value == nil {
+		if !schema.Optional && schema.Kind != SchemaKindAny && schema.Kind != SchemaKindArray && schema.Kind != SchemaKindMap && schema.Kind != SchemaKindBytes {
+			v.fail(path, "expected %s, got null", schemaKindName(schema))
+		}
+		return
+	}
+
+	switch schema.Kind {
+	case SchemaKindString, SchemaKindBytes:
+		if _, ok := value.(string); !ok {
+			v.fail(path, "expected %s, got %s", schemaKindName(schema), jsonKind(value))
+		} else {
+			v.checkEnum(path, value, schema)
+		}
+	case SchemaKindTime:
+		s, ok := value.(string)
+		if !ok {
+			v.fail(path, "expected an RFC 3339 time, got %s", jsonKind(value))
+		} else if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			v.fail(path, "expected an RFC 3339 time like 2006-01-02T15:04:05Z, got %q", s)
+		}
+	case SchemaKindInteger:
+		if n, ok := value.(json.Number); !ok {
+			v.fail(path, "expected an integer, got %s", jsonKind(value))
+		} else if _, err := n.Int64(); err != nil {
+			v.fail(path, "expected an integer, got %s", n)
+		} else {
+			v.checkEnum(path, value, schema)
+		}
+	case SchemaKindNumber:
+		if _, ok := value.(json.Number); !ok {
+			v.fail(path, "expected a number, got %s", jsonKind(value))
+		} else {
+			v.checkEnum(path, value, schema)
+		}
+	case SchemaKindBoolean:
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "expected a boolean, got %s", jsonKind(value))
+		}
+	case SchemaKindArray:
+		items, ok := value.([]any)
+		if !ok {
+			v.fail(path, "expected an array, got %s", jsonKind(value))
+			return
+		}
+		for i, item := range items {
+			v.checkSchema(fmt.Sprintf("%s[%d]", path, i), item, schema.Elem)
+		}
+	case SchemaKindMap:
+		entries, ok := value.(map[string]any)
+		if !ok {
+			v.fail(path, "expected an object, got %s", jsonKind(value))
+			return
+		}
+		for key, entry := range entries {
+			v.checkSchema(path+"."+key, entry, schema.Elem)
+		}
+	case SchemaKindStruct:
+		fields := schema.Fields
+		if schema.Type != "" {
+			known, ok := v.types[schema.Type]
+			if !ok {
+				// Types outside the definition, like structs of other modules, are not checked
+				return
+			}
+			fields = known
+		}
+		v.checkFields(path, value, fields)
+	}
+}
+
+// checkEnum checks a string or number against the constants of its named type
+func (v *inputValidator) checkEnum(path string, value any, schema *TypeSchema) {
+	if len(schema.Enum) == 0 {
+		return
+	}
+	allowed := make([]string, len(schema.Enum))
+	for i, enum := range schema.Enum {
+		allowed[i] = fmt.Sprint(enum)
+		if allowed[i] == fmt.Sprint(value) {
+			return
+		}
+	}
+	v.fail(path, "%v is not a value of %s, expected one of %s", value, schema.Type, strings.Join(allowed, ", "))
+}
+
+// checkGoType checks a value against a Go type written in a definition without a schema, like the
+// primitive input of a method, types it cannot tell are accepted
+func (v *inputValidator) checkGoType(path string, value any, goType string) {
+	if fields, ok := v.types[strings.TrimPrefix(goType, "*")]; ok {
+		v.checkFields(path, value, fields)
+		return
+	}
+
+	var kind string
+	switch {
+	case goType == "string":
+		kind = SchemaKindString
+	case goType == "bool":
+		kind = SchemaKindBoolean
+	case integerTypes[goType]:
+		kind = SchemaKindInteger
+	case floatTypes[goType]:
+		kind = SchemaKindNumber
+	case goType == "time.Time":
+		kind = SchemaKindTime
+	default:
+		return
+	}
+	v.checkSchema(path, value, &TypeSchema{Kind: kind})
+}
+
+// schemaKindName describes the expected kind of a value in problems
+func schemaKindName(schema *TypeSchema) string {
+	switch schema.Kind {
+	case SchemaKindBytes:
+		return "a base64 string"
+	case SchemaKindInteger:
+		return "an integer"
+	case SchemaKindArray:
+		return "an array"
+	case SchemaKindStruct, SchemaKindMap:
+		return "an object"
+	}
+	return "a " + schema.Kind
+}
+
+// jsonKind names the kind of a decoded JSON value
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Invoke calls a method of the running app through the HTTP handlers generated with -http, at
+// baseURL/services/{service}/{method}
+func Invoke(baseURL string, service string, method string, data []byte, timeout time.Duration) (InvokeResult, error) {
+	target := fmt.Sprintf("%s/services/%s/%s", strings.TrimSuffix(baseURL, "/"), service, method)
+	client := &http.Client{Timeout: timeout}
+	res, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return InvokeResult{}, fmt.Errorf("failed to call %s, is the app running with the generated HTTP handlers? %w", target, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return InvokeResult{}, fmt.Errorf("failed to read the response of %s: %w", target, err)
+	}
+	return InvokeResult{Status: res.StatusCode, Body: body}, nil
+}
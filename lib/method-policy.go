@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Backoffs of //polycode:retry, the delay between attempts stays the same or doubles after each one
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+)
+
+// RetryPolicy is the retry policy declared by //polycode:retry max=5 backoff=exponential delay=1s
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     string
+	Delay       time.Duration // Delay before the first retry, 0 leaves it to the runtime
+}
+
+// RetryDefinition is a RetryPolicy in a service definition, the delay written like 1s
+type RetryDefinition struct {
+	MaxAttempts int    `yaml:"maxAttempts" json:"maxAttempts"`
+	Backoff     string `yaml:"backoff" json:"backoff"`
+	Delay       string `yaml:"delay,omitempty" json:"delay,omitempty"`
+}
+
+// parseTimeout reads the argument of a //polycode:timeout directive like 30s
+func parseTimeout(args string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(args)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("//polycode:timeout must be a positive duration like 30s, got %q", args)
+	}
+	return timeout, nil
+}
+
+// parseRetryPolicy reads the arguments of a //polycode:retry directive, max is required and the
+// backoff defaults to exponential
+func parseRetryPolicy(args string) (*RetryPolicy, error) {
+	policy := &RetryPolicy{Backoff: BackoffExponential}
+	for key, value := range parseDirectiveArgs(args) {
+		switch key {
+		case "max":
+			attempts, err := strconv.Atoi(value)
+			if err != nil || attempts <= 0 {
+				return nil, fmt.Errorf("//polycode:retry: max must be a positive number of attempts, got %q", value)
+			}
+			policy.MaxAttempts = attempts
+		case "backoff":
+			if value != BackoffFixed && value != BackoffExponential {
+				return nil, fmt.Errorf("//polycode:retry: unknown backoff %q, expected %s or %s", value, BackoffFixed, BackoffExponential)
+			}
+			policy.Backoff = value
+		case "delay":
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return nil, fmt.Errorf("//polycode:retry: delay must be a positive duration like 1s, got %q", value)
+			}
+			policy.Delay = delay
+		default:
+			return nil, fmt.Errorf("//polycode:retry: unknown option %q, expected max=<attempts>, backoff=<%s|%s> or delay=<duration>", key, BackoffFixed, BackoffExponential)
+		}
+	}
+	if policy.MaxAttempts == 0 {
+		return nil, fmt.Errorf("//polycode:retry requires the number of attempts, like max=5")
+	}
+	return policy, nil
+}
+
+// timeoutDefinition returns the timeout of a method for its service definition, empty when it declares none
+func timeoutDefinition(timeout time.Duration) string {
+	if timeout == 0 {
+		return ""
+	}
+	return timeout.String()
+}
+
+// retryDefinition returns the retry policy of a method for its service definition, nil when it declares none
+func retryDefinition(retry *RetryPolicy) *RetryDefinition {
+	if retry == nil {
+		return nil
+	}
+	def := &RetryDefinition{MaxAttempts: retry.MaxAttempts, Backoff: retry.Backoff}
+	if retry.Delay > 0 {
+		def.Delay = retry.Delay.String()
+	}
+	return def
+}
@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// Middleware is a function of the service package wrapping the calls dispatched by the wrapper,
+// declared in the package doc comment by //polycode:middleware LoggingMiddleware,AuthMiddleware.
+// Middleware taking a polycode.ServiceContext wrap ExecuteService, those taking a
+// polycode.WorkflowContext wrap ExecuteWorkflow:
+//
+//	func LoggingMiddleware(ctx polycode.ServiceContext, method string, input any, next func() (any, error)) (any, error)
+type Middleware struct {
+	Name       string
+	IsWorkflow bool
+}
+
+// ServiceMiddleware returns the middleware wrapping ExecuteService, outermost first
+func (s ServiceInfo) ServiceMiddleware() []string {
+	return s.middlewareNames(false)
+}
+
+// WorkflowMiddleware returns the middleware wrapping ExecuteWorkflow, outermost first
+func (s ServiceInfo) WorkflowMiddleware() []string {
+	return s.middlewareNames(true)
+}
+
+func (s ServiceInfo) middlewareNames(workflow bool) []string {
+	var names []string
+	for _, middleware := range s.Middleware {
+		if middleware.IsWorkflow == workflow {
+			names = append(names, middleware.Name)
+		}
+	}
+	return names
+}
+
+// findMiddleware returns the chain declared by the //polycode:middleware directive of the service
+// package, in the declared order. The directive may be in the package doc comment of any file but once.
+func findMiddleware(fset *token.FileSet, files []*ast.File, servicePackage string, contexts contextTypes) ([]Middleware, error) {
+	var declared *ast.File
+	var names []string
+	for _, file := range files {
+		args, ok := parseDirectives(file.Doc)["middleware"]
+		if !ok {
+			continue
+		}
+		if declared != nil {
+			return nil, &positionError{pos: fset.Position(file.Doc.Pos()), err: fmt.Errorf("//polycode:middleware is declared in both %s and %s, declare it once per package",
+				filepath.Base(fset.Position(declared.Pos()).Filename), filepath.Base(fset.Position(file.Pos()).Filename))}
+		}
+		declared = file
+
+		for _, name := range strings.Split(args, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !token.IsIdentifier(name) || !ast.IsExported(name) {
+				return nil, &positionError{pos: fset.Position(file.Doc.Pos()), err: fmt.Errorf("//polycode:middleware %q must name an exported function of the service package", name)}
+			}
+			for _, other := range names {
+				if other == name {
+					return nil, &positionError{pos: fset.Position(file.Doc.Pos()), err: fmt.Errorf("//polycode:middleware lists %s twice", name)}
+				}
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, &positionError{pos: fset.Position(file.Doc.Pos()), err: fmt.Errorf("//polycode:middleware lists no functions")}
+		}
+	}
+	if declared == nil {
+		return nil, nil
+	}
+
+	chain := make([]Middleware, 0, len(names))
+	for _, name := range names {
+		var found *ast.FuncDecl
+		var resolve contextResolver
+		for _, file := range files {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+					found, resolve = fn, contexts.resolver(file, servicePackage)
+				}
+			}
+		}
+		if found == nil {
+			return nil, &positionError{pos: fset.Position(declared.Doc.Pos()), err: fmt.Errorf("//polycode:middleware %s is not a function of the service package", name)}
+		}
+
+		kind := middlewareKind(found, resolve)
+		if kind == "" {
+			return nil, &positionError{pos: fset.Position(found.Pos()), err: fmt.Errorf("function %s: middleware must have the signature func %s(ctx polycode.ServiceContext, method string, input any, next func() (any, error)) (any, error), or take a polycode.WorkflowContext", name, name)}
+		}
+		chain = append(chain, Middleware{Name: name, IsWorkflow: kind == "Workflow"})
+	}
+	return chain, nil
+}
+
+// middlewareKind returns the context kind of a function with the middleware signature, empty for other functions
+func middlewareKind(fn *ast.FuncDecl, resolve contextResolver) string {
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams != nil || len(params) != 4 || !isIdent(params[1], "string") || !isAnyType(params[2]) || !isCallResult(results) {
+		return ""
+	}
+	next, ok := params[3].(*ast.FuncType)
+	if !ok || len(flattenFields(next.Params)) != 0 || !isCallResult(flattenFields(next.Results)) {
+		return ""
+	}
+	return resolve(params[0])
+}
+
+// isCallResult reports whether results are (any, error), those of a dispatched call
+func isCallResult(results []ast.Expr) bool {
+	return len(results) == 2 && isAnyType(results[0]) && isErrorType(results[1])
+}
+
+// isAnyType reports whether a type expression is any or interface{}
+func isAnyType(expr ast.Expr) bool {
+	if iface, ok := expr.(*ast.InterfaceType); ok {
+		return iface.Methods == nil || len(iface.Methods.List) == 0
+	}
+	return isIdent(expr, "any")
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const middlewareSource = `package orders
+
+import "github.com/cloudimpl/next-coder-sdk/polycode"
+
+func Create(ctx polycode.ServiceContext) error { return nil }
+
+func Logging(ctx polycode.ServiceContext, method string, input any, next func() (any, error)) (any, error) {
+	return next()
+}
+
+func Auth(ctx polycode.ServiceContext, method string, input any, next func() (any, error)) (any, error) {
+	return next()
+}
+
+func Replay(ctx polycode.WorkflowContext, method string, input any, next func() (any, error)) (any, error) {
+	return next()
+}
+
+func Helper(ctx polycode.ServiceContext, method string) error { return nil }
+`
+
+func TestFindMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    []Middleware
+		wantErr string
+	}{
+		{name: "none"},
+		{
+			name: "declared order",
+			doc:  "//polycode:middleware Logging, Auth,Replay\n",
+			want: []Middleware{{Name: "Logging"}, {Name: "Auth"}, {Name: "Replay", IsWorkflow: true}},
+		},
+		{name: "unknown function", doc: "//polycode:middleware Missing\n", wantErr: "Missing is not a function of the service package"},
+		{name: "wrong signature", doc: "//polycode:middleware Helper\n", wantErr: "function Helper: middleware must have the signature"},
+		{name: "listed twice", doc: "//polycode:middleware Auth,Auth\n", wantErr: "lists Auth twice"},
+		{name: "unexported", doc: "//polycode:middleware logging\n", wantErr: `"logging" must name an exported function`},
+		{name: "empty", doc: "//polycode:middleware ,\n", wantErr: "lists no functions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseFiles(t, map[string]string{"orders.go": tt.doc + middlewareSource})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed.Middleware, tt.want) {
+				t.Errorf("got %+v, want %+v", parsed.Middleware, tt.want)
+			}
+			// Middleware are not exposed as methods
+			if len(parsed.Methods) != 2 || parsed.Methods[0].OriginalName != "Create" || parsed.Methods[1].OriginalName != "Helper" {
+				t.Errorf("got methods %+v, want Create and Helper", parsed.Methods)
+			}
+		})
+	}
+}
+
+func TestFindMiddlewareDeclaredTwice(t *testing.T) {
+	_, err := parseFiles(t, map[string]string{
+		"orders.go": "//polycode:middleware Logging\n" + middlewareSource,
+		"doc.go":    "//polycode:middleware Auth\npackage orders\n",
+	})
+	if want := "//polycode:middleware is declared in both"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want one containing %q", err, want)
+	}
+}
+
+func TestWrapperMiddlewareChain(t *testing.T) {
+	parsed, err := parseFiles(t, map[string]string{"orders.go": "//polycode:middleware Logging,Auth,Replay\n" + middlewareSource})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := newServiceInfo("example.com/app", "orders", "services/orders", parsed.Methods, parsed.Imports, DefaultOptions())
+	info.Middleware = parsed.Middleware
+	code, err := generateServiceCode(info, wrapperTemplate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first declared middleware is the outermost, the dispatch is innermost
+	tests := []struct {
+		function string
+		want     []string
+	}{
+		{function: "ExecuteService", want: []string{"service.Logging(ctx, method, input,", "service.Auth(ctx, method, input,", "t.executeService(ctx, method, input)"}},
+		{function: "ExecuteWorkflow", want: []string{"service.Replay(ctx, method, input,", "t.executeWorkflow(ctx, method, input)"}},
+	}
+	for _, tt := range tests {
+		start := strings.Index(code, ") "+tt.function+"(")
+		if start < 0 {
+			t.Fatalf("%s not found in the wrapper", tt.function)
+		}
+		body := code[start:]
+		body = body[:strings.Index(body, "\n}\n")]
+		last := 0
+		for _, call := range tt.want {
+			i := strings.Index(body, call)
+			if i < last {
+				t.Errorf("%s: %q not found after the previous call in\n%s", tt.function, call, body)
+				break
+			}
+			last = i
+		}
+		if tt.function == "ExecuteService" && strings.Contains(body, "service.Replay(") {
+			t.Errorf("ExecuteService calls the workflow middleware:\n%s", body)
+		}
+	}
+}
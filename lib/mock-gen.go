@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const mockTemplate = `// Code generated by next-gen. DO NOT EDIT.
+package {{.PackageName}}
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	{{range .Info.Imports}}{{.}}
+	{{end}}
+)
+
+// ServiceName is the registered name of the mocked {{.Info.ServiceName}} service
+const ServiceName = "{{.Info.ServiceName}}"
+
+// Call records an invocation received by the mock
+type Call struct {
+	Method string
+	Input  any
+}
+
+// Mock stands in for the {{.Info.ServiceName}} service in unit tests. Set the func of each method the
+// code under test calls and pass the mock as the invoker of the generated client, methods without a
+// func fail with an error.
+type Mock struct {
+{{- range .Info.Methods}}{{if not .IsStreaming}}
+	{{.OriginalName}}Func func({{template "params" .}}) {{template "results" .}}
+{{- end}}{{end}}
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns the invocations received so far, in order
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount returns how many times a method was invoked
+func (m *Mock) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, call := range m.calls {
+		if strings.EqualFold(call.Method, method) {
+			count++
+		}
+	}
+	return count
+}
+
+// Invoke implements the Invoker of the generated {{.Info.ServiceName}} client
+func (m *Mock) Invoke(serviceName string, method string, input any, output any) error {
+	if serviceName != ServiceName {
+		return fmt.Errorf("{{.PackageName}}: unexpected call to service %s", serviceName)
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: method, Input: input})
+	m.mu.Unlock()
+
+	switch strings.ToLower(method) {
+{{- range .Info.Methods}}{{if not .IsStreaming}}
+	case "{{lower .ExposedName}}":
+		if m.{{.OriginalName}}Func == nil {
+			return fmt.Errorf("{{$.PackageName}}: {{.OriginalName}} is not mocked")
+		}
+{{- if .IsMultiInput}}
+		args, ok := input.(map[string]any)
+		if !ok {
+			return fmt.Errorf("{{$.PackageName}}: unexpected input %T for {{.OriginalName}}", input)
+		}
+{{- range .Params}}
+		{{.JSONName}}, _ := args["{{.JSONName}}"].({{.Type}})
+{{- end}}
+{{- else if .HasInput}}
+		in, ok := input.({{if .IsInputPointer}}*{{end}}{{.InputType}})
+		if !ok {
+			return fmt.Errorf("{{$.PackageName}}: unexpected input %T for {{.OriginalName}}", input)
+		}
+{{- end}}
+{{- if .HasOutput}}
+		result, err := m.{{.OriginalName}}Func({{template "args" .}})
+		if err != nil {
+			return err
+		}
+		if out, ok := output.(*{{.OutputType}}); ok{{if .IsOutputPointer}} && result != nil{{end}} {
+			*out = {{if .IsOutputPointer}}*{{end}}result
+		}
+		return nil
+{{- else}}
+		return m.{{.OriginalName}}Func({{template "args" .}})
+{{- end}}
+{{- end}}{{end}}
+	default:
+		return fmt.Errorf("{{.PackageName}}: unknown method %s", method)
+	}
+}
+{{- define "params"}}{{if .IsMultiInput}}{{range $i, $p := .Params}}{{if $i}}, {{end}}{{.JSONName}} {{if .IsVariadic}}...{{slice .Type 2}}{{else}}{{.Type}}{{end}}{{end}}{{else if .HasInput}}input {{if .IsInputPointer}}*{{end}}{{.InputType}}{{end}}{{end}}
+{{- define "results"}}{{if .HasOutput}}({{if .IsOutputPointer}}*{{end}}{{.OutputType}}, error){{else}}error{{end}}{{end}}
+{{- define "args"}}{{if .IsMultiInput}}{{range $i, $p := .Params}}{{if $i}}, {{end}}{{.JSONName}}{{if .IsVariadic}}...{{end}}{{end}}{{else if .HasInput}}in{{end}}{{end}}`
+
+// TargetMocks generates mocks of services so callers can unit test without running them
+const TargetMocks = "mocks"
+
+// mockPackageName returns the Go package name of a service mock
+func mockPackageName(serviceName string) string {
+	return strings.ToLower(strings.ReplaceAll(serviceFileName(serviceName), "-", "")) + "mock"
+}
+
+// mockTarget generates .polycode/mocks/<service>mock packages
+type mockTarget struct{}
+
+func (mockTarget) Name() string {
+	return TargetMocks
+}
+
+func (mockTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	tmpl, err := template.New("mock").Funcs(template.FuncMap{"lower": strings.ToLower}).Parse(stampVersion(mockTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	packageName := mockPackageName(info.ServiceName)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"PackageName": packageName,
+		"Info":        info,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mock: %w", err)
+	}
+
+	return map[string][]byte{"mocks/" + packageName + "/mock.go": buf.Bytes()}, nil
+}
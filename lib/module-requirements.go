@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// moduleRequirements are the modules the packages of a module can be imported from, read from its go.mod
+type moduleRequirements struct {
+	module    string            // Module path
+	required  map[string]bool   // Module paths required by go.mod
+	replaced  map[string]string // Module paths replaced by go.mod, to their replacement
+	local     map[string]string // Replacements that are folders, to the absolute folder
+	workspace []string          // Paths of the other modules of go.work
+}
+
+// readModuleRequirements reads the requirements and replacements of the go.mod of a module
+func readModuleRequirements(module appModule) (*moduleRequirements, error) {
+	goModPath := filepath.Join(module.Dir, "go.mod")
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open go.mod file: %w", err)
+	}
+	file, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading go.mod file: %w", err)
+	}
+
+	r := &moduleRequirements{
+		module:    module.Name,
+		required:  make(map[string]bool),
+		replaced:  make(map[string]string),
+		local:     make(map[string]string),
+		workspace: module.Workspace,
+	}
+	for _, require := range file.Require {
+		r.required[require.Mod.Path] = true
+	}
+	for _, replace := range file.Replace {
+		r.replaced[replace.Old.Path] = replace.New.Path
+		if replace.New.Version == "" {
+			dir := filepath.FromSlash(replace.New.Path)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(module.Dir, dir)
+			}
+			r.local[replace.Old.Path] = dir
+		}
+	}
+	return r, nil
+}
+
+// provides reports whether an import path is the path of the module or of one of its packages
+func provides(module string, importPath string) bool {
+	return importPath == module || strings.HasPrefix(importPath, module+"/")
+}
+
+// longestProviding returns the module of a set with the longest path providing the import path
+func longestProviding[V any](modules map[string]V, importPath string) string {
+	var found string
+	for module := range modules {
+		if provides(module, importPath) && len(module) > len(found) {
+			found = module
+		}
+	}
+	return found
+}
+
+// missingRequirement is the issue with the module providing a package imported by the method signatures
+func (r *moduleRequirements) missingRequirement(importPath string) string {
+	if provides(r.module, importPath) {
+		return ""
+	}
+	// Standard library packages have no dot in their first path element
+	if first, _, _ := strings.Cut(importPath, "/"); !strings.Contains(first, ".") {
+		return ""
+	}
+	for _, module := range r.workspace {
+		if provides(module, importPath) {
+			return ""
+		}
+	}
+
+	module := longestProviding(r.required, importPath)
+	replaced := longestProviding(r.replaced, importPath)
+	if module == "" {
+		if replaced != "" {
+			return fmt.Sprintf("go.mod replaces %s with %s but does not require it, run: go mod tidy", replaced, r.replaced[replaced])
+		}
+		return "no module of go.mod provides it, run: go get " + importPath
+	}
+	if dir, ok := r.local[module]; ok && replaced == module {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+			return fmt.Sprintf("go.mod replaces %s with %s, which has no go.mod", module, r.replaced[module])
+		}
+	}
+	return ""
+}
+
+// checkSignatureImports reports the packages imported by the method signatures of a service that the
+// module cannot import, the wrappers importing them would not compile
+func (r *moduleRequirements) checkSignatureImports(imports []string) error {
+	var issues []string
+	for _, spec := range imports {
+		// Specs are an optionally aliased quoted import path
+		importPath, err := strconv.Unquote(spec[strings.Index(spec, `"`):])
+		if err != nil {
+			continue
+		}
+		if issue := r.missingRequirement(importPath); issue != "" {
+			issues = append(issues, importPath+": "+issue)
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	return fmt.Errorf("method signatures use packages the module cannot import, the generated code would not compile: %s", strings.Join(issues, "; "))
+}
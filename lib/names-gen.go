@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// namesPackage is the folder of the output holding the name constants, a package of its own so
+// callers import the names without the wrappers and their registration side effects
+const namesPackage = "names"
+
+const namesTemplate = `// Code generated by next-gen. DO NOT EDIT.
+
+// Package names declares the registered names of the services of the app and of their methods,
+// signals and queries, use them to build calls to other services instead of string literals.
+package names
+
+// Service is the registered name of a service
+type Service string
+
+// Method is the name a service method or workflow is called by
+type Method string
+
+// Signal is the name of a signal handler of a workflow
+type Signal string
+
+// Query is the name of a query handler of a workflow
+type Query string
+{{range .}}
+// Names of the {{.Name}} service
+const (
+	{{.Const}} Service = {{printf "%q" .Name}}
+	{{- range .Methods}}
+	{{.Const}} {{.Kind}} = {{printf "%q" .Name}}
+	{{- end}}
+)
+{{end}}`
+
+// nameConst is a constant of the names package
+type nameConst struct {
+	Const string
+	Kind  string
+	Name  string
+}
+
+// writeServiceNames writes the names package from the service definitions in the output folder,
+// leaving an identical file untouched. Service constants are suffixed with Service, methods are
+// prefixed with their service and signals and queries suffixed with their kind, like OrdersService,
+// OrdersCreateOrder and OrdersApproveSignal.
+func writeServiceNames(output outputFS, outputPath string) error {
+	defs, err := loadServiceDefinitions(output, outputPath)
+	if err != nil {
+		return err
+	}
+
+	type serviceNames struct {
+		Name    string
+		Const   string
+		Methods []nameConst
+	}
+	var services []serviceNames
+	declared := make(map[string]string)
+	declare := func(constName string, what string) error {
+		if first, ok := declared[constName]; ok {
+			return fmt.Errorf("name constants of %s and %s are both %s, rename one of them", first, what, constName)
+		}
+		declared[constName] = what
+		return nil
+	}
+
+	for _, def := range defs {
+		prefix := constName(def.Name)
+		service := serviceNames{Name: def.Name, Const: prefix + "Service"}
+		if err := declare(service.Const, "service "+def.Name); err != nil {
+			return err
+		}
+		for _, group := range []struct {
+			kind    string
+			suffix  string
+			methods []MethodDefinition
+		}{
+			{"Method", "", def.Methods},
+			{"Signal", "Signal", def.Signals},
+			{"Query", "Query", def.Queries},
+		} {
+			for _, method := range group.methods {
+				c := nameConst{Const: prefix + constName(method.Name) + group.suffix, Kind: group.kind, Name: method.Name}
+				if err := declare(c.Const, fmt.Sprintf("%s %s.%s", strings.ToLower(group.kind), def.Name, method.Name)); err != nil {
+					return err
+				}
+				service.Methods = append(service.Methods, c)
+			}
+		}
+		services = append(services, service)
+	}
+
+	tmpl, err := template.New("names").Parse(stampVersion(namesTemplate))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, services); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", namesPackage, err)
+	}
+	// Formatted here so the comparison below matches the file left by the formatter
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	folder := filepath.Join(outputPath, namesPackage)
+	path := filepath.Join(folder, namesPackage+".go")
+	if existing, err := output.ReadFile(path); err == nil && bytes.Equal(existing, code) {
+		return nil
+	}
+	if err = output.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	return output.WriteFile(path, code, 0644)
+}
+
+// constName turns a service or method name into an exported identifier, words separated by
+// characters that are not letters or digits are capitalized, like create-order to CreateOrder
+func constName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	if b.Len() == 0 || unicode.IsDigit([]rune(b.String())[0]) {
+		return "N" + b.String()
+	}
+	return b.String()
+}
@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ConfigFileName is the optional project configuration file at the app root
+const ConfigFileName = "next-gen.yaml"
+
+// Config is the content of next-gen.yaml, fields that are not set keep their defaults
+type Config struct {
+	Services          StringList        `yaml:"services"`
+	Output            string            `yaml:"output"`
+	Package           string            `yaml:"package"`
+	Exclude           []string          `yaml:"exclude"`
+	Production        *bool             `yaml:"production"`
+	Format            string            `yaml:"format"`
+	FormatCommand     string            `yaml:"formatCommand"`
+	Targets           []string          `yaml:"targets"`
+	DefinitionFormats StringList        `yaml:"definitionFormats"`
+	Analyzers         []string          `yaml:"analyzers"`
+	OpenAPI           bool              `yaml:"openapi"`
+	AsyncAPI          bool              `yaml:"asyncapi"`
+	GraphQL           bool              `yaml:"graphql"`
+	Routes            bool              `yaml:"routes"`
+	JSONSchema        bool              `yaml:"jsonSchema"`
+	SharedSchemas     bool              `yaml:"sharedSchemas"`
+	Dependencies      bool              `yaml:"dependencies"`
+	ErrorCodes        bool              `yaml:"errorCodes"`
+	Metrics           bool              `yaml:"metrics"`
+	DebugDispatch     bool              `yaml:"debugDispatch"`
+	Strict            bool              `yaml:"strict"`
+	NoCompileCheck    bool              `yaml:"noCompileCheck"`
+	Template          string            `yaml:"template"`
+	TemplateDir       string            `yaml:"templateDir"`
+	Workers           int               `yaml:"workers"`
+	LockTimeout       string            `yaml:"lockTimeout"`
+	SignKey           string            `yaml:"signKey"`
+	Report            string            `yaml:"report"`
+	GenTests          bool              `yaml:"genTests"`
+	Plugins           []string          `yaml:"plugins"`
+	Generators        map[string]string `yaml:"generators"`
+	Watch             WatchConfig       `yaml:"watch"`
+	Dev               DevConfig         `yaml:"dev"`
+	SDK               SDKConfig         `yaml:"sdk"`
+	Publish           PublishConfig     `yaml:"publish"`
+	Hooks             Hooks             `yaml:"hooks"`
+	// Profiles are selected with -profile, dev, staging and prod are built in
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// StringList is a YAML list that also accepts a single string
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(unmarshal func(any) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// WatchConfig holds the watch mode settings of next-gen.yaml
+type WatchConfig struct {
+	Debounce    string `yaml:"debounce"`
+	Incremental bool   `yaml:"incremental"`
+	Overlay     string `yaml:"overlay"`
+	// Poll scans for changes at this interval instead of using file system notifications
+	Poll string `yaml:"poll"`
+	// Ignore lists gitignore-style patterns the watcher skips in addition to .gitignore
+	Ignore []string `yaml:"ignore"`
+	// Run is a command run after each successful regeneration, like go test ./services/...
+	Run string `yaml:"run"`
+}
+
+// DevConfig holds the commands `next-gen dev` uses to build and run the app
+type DevConfig struct {
+	Build string `yaml:"build"`
+	Run   string `yaml:"run"`
+	// URL is where the running app serves the generated HTTP handlers, called by next-gen invoke
+	URL string `yaml:"url"`
+}
+
+// SDKConfig selects the polycode SDK the generated code depends on
+type SDKConfig struct {
+	// Import is the import path of the polycode package, for forks or vendored copies of the SDK
+	Import string `yaml:"import"`
+	// Version is the minimum SDK version go.mod must require, like v1.4.0
+	Version string `yaml:"version"`
+}
+
+// DebounceDuration parses the configured debounce delay, an empty value disables debouncing
+func (w WatchConfig) DebounceDuration() (time.Duration, error) {
+	if w.Debounce == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(w.Debounce)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watch.debounce %q: %w", w.Debounce, err)
+	}
+	return d, nil
+}
+
+// PollInterval parses the configured polling interval, an empty value uses file system notifications
+func (w WatchConfig) PollInterval() (time.Duration, error) {
+	if w.Poll == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(w.Poll)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watch.poll %q: %w", w.Poll, err)
+	}
+	return d, nil
+}
+
+// LoadConfig reads next-gen.yaml from the app root, a missing file yields an empty config
+func LoadConfig(appPath string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(filepath.Join(appPath, ConfigFileName))
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("failed to read %s: %w", ConfigFileName, err)
+	}
+
+	if err = yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %w", ConfigFileName, err)
+	}
+
+	// Paths to files are relative to the app root
+	if config.Template != "" && !filepath.IsAbs(config.Template) {
+		config.Template = filepath.Join(appPath, config.Template)
+	}
+	if config.TemplateDir != "" && !filepath.IsAbs(config.TemplateDir) {
+		config.TemplateDir = filepath.Join(appPath, config.TemplateDir)
+	}
+	if config.SignKey != "" && !filepath.IsAbs(config.SignKey) {
+		config.SignKey = filepath.Join(appPath, config.SignKey)
+	}
+	for i, plugin := range config.Plugins {
+		if !filepath.IsAbs(plugin) {
+			config.Plugins[i] = filepath.Join(appPath, plugin)
+		}
+	}
+	return config, nil
+}
+
+// Apply copies the configured values onto opts and registers the configured generators and plugins
+func (c Config) Apply(opts *Options) error {
+	if len(c.Services) > 0 {
+		opts.ServicesDirs = c.Services
+	}
+	if c.Output != "" {
+		opts.OutputDir = c.Output
+	}
+	if c.Package != "" {
+		opts.PackageName = c.Package
+	}
+	if c.Production != nil {
+		opts.Production = *c.Production
+	}
+	if c.Format != "" {
+		opts.Format = c.Format
+	}
+	if c.FormatCommand != "" {
+		opts.FormatCommand = c.FormatCommand
+	}
+	if len(c.Targets) > 0 {
+		opts.Targets = c.Targets
+	}
+	if len(c.DefinitionFormats) > 0 {
+		opts.DefinitionFormats = c.DefinitionFormats
+	}
+	opts.Exclude = append(opts.Exclude, c.Exclude...)
+	opts.Analyzers = append(opts.Analyzers, c.Analyzers...)
+	opts.OpenAPI = opts.OpenAPI || c.OpenAPI
+	opts.AsyncAPI = opts.AsyncAPI || c.AsyncAPI
+	opts.GraphQL = opts.GraphQL || c.GraphQL
+	opts.Routes = opts.Routes || c.Routes
+	opts.JSONSchema = opts.JSONSchema || c.JSONSchema
+	opts.SharedSchemas = opts.SharedSchemas || c.SharedSchemas
+	opts.Dependencies = opts.Dependencies || c.Dependencies
+	opts.ErrorCodes = opts.ErrorCodes || c.ErrorCodes
+	opts.Metrics = opts.Metrics || c.Metrics
+	opts.DebugDispatch = opts.DebugDispatch || c.DebugDispatch
+	opts.Strict = opts.Strict || c.Strict
+	opts.NoCompileCheck = opts.NoCompileCheck || c.NoCompileCheck
+	opts.GenTests = opts.GenTests || c.GenTests
+	if c.Template != "" {
+		opts.Template = c.Template
+	}
+	if c.TemplateDir != "" {
+		opts.TemplateDir = c.TemplateDir
+	}
+	if c.Workers > 0 {
+		opts.Workers = c.Workers
+	}
+	if c.Report != "" {
+		opts.Report = c.Report
+	}
+	if c.SignKey != "" {
+		opts.SignKey = c.SignKey
+	}
+	if c.LockTimeout != "" {
+		timeout, err := time.ParseDuration(c.LockTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid lockTimeout %q, expected a duration like 30s", c.LockTimeout)
+		}
+		opts.LockTimeout = timeout
+	}
+	if c.SDK.Import != "" {
+		opts.SDKImport = c.SDK.Import
+	}
+	if c.SDK.Version != "" {
+		opts.SDKVersion = c.SDK.Version
+	}
+	opts.Hooks = c.Hooks
+
+	for _, path := range c.Plugins {
+		generator, err := LoadPlugin(path)
+		if err != nil {
+			return err
+		}
+		opts.RegisterGenerator(generator)
+		opts.Targets = appendTarget(opts.Targets, generator.Name())
+	}
+	names := make([]string, 0, len(c.Generators))
+	for name := range c.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts.RegisterGenerator(NewCommandGenerator(name, c.Generators[name]))
+		opts.Targets = appendTarget(opts.Targets, name)
+	}
+	return nil
+}
+
+// appendTarget adds a target unless it is already selected
+func appendTarget(targets []string, name string) []string {
+	for _, target := range targets {
+		if target == name {
+			return targets
+		}
+	}
+	return append(targets, name)
+}
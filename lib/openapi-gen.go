@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+// schemaRef returns the component name used for a Go type in OpenAPI documents
+func schemaRef(goType string) string {
+	return strings.TrimPrefix(goType, "*")
+}
+
+// openAPISchema maps a Go type expression to an OpenAPI 3.1 (JSON Schema) schema
+func openAPISchema(goType string, components map[string]any) map[string]any {
+	return jsonSchema(goType, componentRef(components))
+}
+
+// componentRef resolves struct types to the schemas under #/components/schemas
+func componentRef(components map[string]any) refFunc {
+	return func(name string) (string, bool) {
+		_, ok := components[name]
+		return "#/components/schemas/" + name, ok
+	}
+}
+
+// buildOpenAPI builds an OpenAPI 3.1 document exposing each method as POST /services/{service}/{method}
+func buildOpenAPI(title string, defs []ServiceDefinition) yaml.MapSlice {
+	types := collectSchemaTypes(defs)
+	components := make(map[string]any)
+	for name := range types {
+		components[name] = nil
+	}
+	for name, fields := range types {
+		components[name] = structSchema(fields, componentRef(components), rootPointer("components", "schemas", name))
+	}
+
+	paths := make(map[string]any)
+	for _, def := range defs {
+		for _, method := range def.Methods {
+
+			response := map[string]any{"description": "Successful response"}
+			if method.OutputType != "" {
+				response["content"] = map[string]any{
+					"application/json": map[string]any{"schema": openAPISchema(method.OutputType, components)},
+				}
+			}
+
+			operation := map[string]any{
+				"operationId":         def.Name + "." + method.Name,
+				"tags":                []string{def.Name},
+				"responses":           map[string]any{"200": response},
+				"x-polycode-workflow": method.IsWorkflow,
+			}
+			if method.InputType != "" {
+				operation["requestBody"] = map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": openAPISchema(method.InputType, components)},
+					},
+				}
+			}
+			if method.Description != "" {
+				operation["summary"] = method.Description
+			}
+			if method.Doc != "" {
+				operation["description"] = method.Doc
+			}
+			if method.Deprecated != nil {
+				operation["deprecated"] = true
+			}
+			paths[fmt.Sprintf("/services/%s/%s", def.Name, method.Name)] = map[string]any{"post": operation}
+		}
+	}
+
+	return yaml.MapSlice{
+		{Key: "openapi", Value: "3.1.0"},
+		{Key: "info", Value: map[string]any{"title": title, "version": "1.0.0"}},
+		{Key: "paths", Value: paths},
+		{Key: "components", Value: map[string]any{"schemas": components}},
+	}
+}
+
+// writeOpenAPISpecs writes an OpenAPI document per service and a merged one for the app
+func writeOpenAPISpecs(output outputFS, outputPath string, moduleName string, defs []ServiceDefinition) error {
+	openAPIFolder := filepath.Join(outputPath, "openapi")
+	err := output.MkdirAll(openAPIFolder, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create openapi folder: %w", err)
+	}
+
+	write := func(name string, doc yaml.MapSlice) error {
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal openapi document: %w", err)
+		}
+		return output.WriteFile(filepath.Join(openAPIFolder, name), append([]byte(yamlHeader), data...), 0644)
+	}
+
+	keep := map[string]bool{"openapi.yml": true}
+	for _, def := range defs {
+		if err = write(serviceFileName(def.Name)+".yml", buildOpenAPI(def.Name, []ServiceDefinition{def})); err != nil {
+			return err
+		}
+		keep[serviceFileName(def.Name)+".yml"] = true
+	}
+	if err = write("openapi.yml", buildOpenAPI(moduleName, defs)); err != nil {
+		return err
+	}
+	return removeUnlisted(output, openAPIFolder, "*.yml", keep)
+}
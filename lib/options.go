@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"go/token"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Options controls how services are generated
+type Options struct {
+	// Production enables the @definition endpoint in the generated wrappers
+	Production bool
+	// Format selects the post-generation formatter (goimports, gofmt, none or custom)
+	Format string
+	// FormatCommand is the formatter command used when Format is custom, the generated folder is appended as the last argument
+	FormatCommand string
+	// Targets lists the runtimes wrappers are generated for (go, typescript)
+	Targets []string
+	// Generators are the custom generators selectable in Targets besides the built-in ones, added with
+	// RegisterGenerator from plugins and generator commands
+	Generators map[string]Generator
+	// Analyzers run after generation, "vet" runs go vet, anything else is run as a command with package patterns
+	Analyzers []string
+	// ServicesDirs are the folders holding the service packages, relative to the app root.
+	// Nested folders are services too, services/billing/invoices is named billing-invoices.
+	ServicesDirs []string
+	// OutputDir is the folder generated code is written to, relative to the app root
+	OutputDir string
+	// PackageName is the Go package name of the generated wrappers
+	PackageName string
+	// Exclude lists glob patterns of service folders and files that are skipped, matched against
+	// the path relative to its services root and against the base name
+	Exclude []string
+	// GenTests writes a table-driven test scaffold into each service folder that does not have one yet
+	GenTests bool
+	// Workers is the number of services generated concurrently
+	Workers int
+	// Template is the path of a text/template file replacing the built-in Go wrapper template
+	Template string
+	// TemplateDir is a folder of wrapper template overrides: wrapper.go.tmpl for every service,
+	// <service>.go.tmpl for a single one and other .tmpl files with shared {{define}} blocks.
+	// Services without an override use the built-in template.
+	TemplateDir string
+	// DefinitionFormats are the formats service definitions are written in under .polycode/definition:
+	// yaml, json or cbor
+	DefinitionFormats []string
+	// OpenAPI emits OpenAPI 3.1 documents under .polycode/openapi
+	OpenAPI bool
+	// AsyncAPI emits AsyncAPI 3.0 documents of the workflow trigger and result messages under .polycode/asyncapi
+	AsyncAPI bool
+	// Routes generates the route table of the //polycode:http routes of the services under .polycode/routes
+	Routes bool
+	// GraphQL emits a GraphQL schema of the services and resolvers calling them under .polycode/graphql
+	GraphQL bool
+	// JSONSchema emits a JSON Schema document per input/output struct under .polycode/schema
+	JSONSchema bool
+	// SharedSchemas writes the struct types used by several services once under .polycode/schema/common,
+	// the service definitions refer to them instead of each holding a copy
+	SharedSchemas bool
+	// Dependencies emits the graph of calls between services as .polycode/dependencies.yml and .dot
+	Dependencies bool
+	// ErrorCodes generates GetErrorCode in the wrappers, mapping the errors of the catalog to their codes
+	ErrorCodes bool
+	// Metrics instruments ExecuteService and ExecuteWorkflow of the wrappers with OpenTelemetry spans and
+	// call, error and duration metrics
+	Metrics bool
+	// DebugDispatch logs every call dispatched by ExecuteService and ExecuteWorkflow at debug level
+	DebugDispatch bool
+	// Strict fails the services whose workflows call non-deterministic code or whose exported functions
+	// have unsupported parameter or result types instead of warning
+	Strict bool
+	// NoCache regenerates every service even when its inputs match .polycode/cache.json
+	NoCache bool
+	// NoCompileCheck keeps generated code that does not type-check instead of restoring the previous files
+	NoCompileCheck bool
+	// Report writes a machine-readable report of each run into the output folder, json for .polycode/report.json
+	Report string
+	// LockTimeout is how long a run waits for another run writing the same output folder, zero fails at once
+	LockTimeout time.Duration
+	// SDKImport is the import path of the polycode package of the SDK the generated code depends on
+	SDKImport string
+	// SDKVersion is the minimum SDK version go.mod must require, empty when any version is compatible
+	SDKVersion string
+	// Hooks are shell commands run before and after generation
+	Hooks Hooks
+	// SignKey is the path of an Ed25519 private key in PKCS #8 PEM, definitions are written with their
+	// hash and signature when it is set
+	SignKey string
+
+	signingKey ed25519.PrivateKey // Loaded from SignKey at the start of a run
+}
+
+// DefaultOptions returns the options used by the CLI when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		Production:        true,
+		Format:            FormatGoImports,
+		Targets:           []string{TargetGo},
+		DefinitionFormats: []string{DefinitionYAML},
+		ServicesDirs:      []string{"services"},
+		OutputDir:         ".polycode",
+		PackageName:       "_polycode",
+		Workers:           runtime.NumCPU(),
+		SDKImport:         DefaultSDKImport,
+		LockTimeout:       DefaultLockTimeout,
+	}
+}
+
+// Validate checks that the output folder stays inside the app and the package name is a Go identifier
+func (o Options) Validate() error {
+	outputDir := filepath.Clean(o.OutputDir)
+	if outputDir == "." || isOutside(outputDir) {
+		return fmt.Errorf("output folder %q must be a folder inside the app", o.OutputDir)
+	}
+	if !token.IsIdentifier(o.PackageName) {
+		return fmt.Errorf("package name %q is not a valid Go identifier", o.PackageName)
+	}
+	if len(o.DefinitionFormats) == 0 {
+		return fmt.Errorf("at least one definition format is required")
+	}
+	for _, format := range o.DefinitionFormats {
+		if definitionExtension(format) == "" {
+			return fmt.Errorf("unknown definition format %q, expected yaml, json or cbor", format)
+		}
+	}
+	if o.Template != "" && o.TemplateDir != "" {
+		return fmt.Errorf("a wrapper template and a template folder cannot be combined, move the template into the folder as %s", WrapperTemplateName)
+	}
+	if o.Report != "" && o.Report != ReportJSON {
+		return fmt.Errorf("unknown report format %q, expected json", o.Report)
+	}
+	if err := validateSDK(o.SDKImport, o.SDKVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// isExcluded reports whether a path relative to the services folder matches one of the exclude patterns
+func isExcluded(rel string, patterns []string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package lib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// outputFS performs the file operations of the generation pipeline. It is the disk, except during a
+// dry run where an overlay keeps the writes and removals in memory.
+type outputFS interface {
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(path string) error
+	// Exists reports whether a file or folder is present
+	Exists(path string) bool
+	Glob(pattern string) ([]string, error)
+	// Files lists the files below a folder, recursively
+	Files(dir string) ([]string, error)
+}
+
+// diskFS writes to the disk, atomically so readers never observe partial files
+type diskFS struct{}
+
+func (diskFS) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (diskFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(path, data, perm)
+}
+
+func (diskFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (diskFS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (diskFS) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (diskFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (diskFS) Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// overlayFS reads through to the disk and keeps writes and removals in memory, keyed by cleaned path
+type overlayFS struct {
+	mu      sync.Mutex
+	written map[string][]byte
+	removed map[string]bool
+}
+
+func newOverlayFS() *overlayFS {
+	return &overlayFS{written: make(map[string][]byte), removed: make(map[string]bool)}
+}
+
+func (o *overlayFS) ReadFile(path string) ([]byte, error) {
+	path = filepath.Clean(path)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if data, ok := o.written[path]; ok {
+		return data, nil
+	}
+	if o.removed[path] {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return os.ReadFile(path)
+}
+
+func (o *overlayFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	path = filepath.Clean(path)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.written[path] = data
+	delete(o.removed, path)
+	return nil
+}
+
+func (o *overlayFS) MkdirAll(path string, perm os.FileMode) error {
+	return nil
+}
+
+func (o *overlayFS) Remove(path string) error {
+	path = filepath.Clean(path)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.written[path]; ok {
+		delete(o.written, path)
+		if fileExists(path) {
+			o.removed[path] = true
+		}
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || o.removed[path] {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		// Folders only matter through their files, report them as not empty to stop cleanups walking up
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrExist}
+	}
+	o.removed[path] = true
+	return nil
+}
+
+func (o *overlayFS) Exists(path string) bool {
+	path = filepath.Clean(path)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.written[path]; ok {
+		return true
+	}
+	for file := range o.written {
+		if file != path && isInside(file, path) {
+			return true
+		}
+	}
+	_, err := os.Stat(path)
+	return err == nil && !o.removed[path]
+}
+
+func (o *overlayFS) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.merge(matches, func(path string) bool {
+		ok, _ := filepath.Match(pattern, path)
+		return ok
+	}), nil
+}
+
+func (o *overlayFS) Files(dir string) ([]string, error) {
+	files, err := diskFS{}.Files(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.merge(files, func(path string) bool {
+		return path != dir && isInside(path, dir)
+	}), nil
+}
+
+// merge drops removed paths from the disk paths and adds the written paths matching match
+func (o *overlayFS) merge(paths []string, match func(path string) bool) []string {
+	var merged []string
+	for _, path := range paths {
+		if _, ok := o.written[path]; !ok && !o.removed[path] {
+			merged = append(merged, path)
+		}
+	}
+	for path := range o.written {
+		if match(path) {
+			merged = append(merged, path)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// changes returns the paths the overlay wrote or removed, sorted
+func (o *overlayFS) changes() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var paths []string
+	for path := range o.written {
+		paths = append(paths, path)
+	}
+	for path := range o.removed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
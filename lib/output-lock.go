@@ -0,0 +1,222 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// lockSuffix names the file a run holds beside the output folder while writing it, like .polycode.lock.
+// It is kept out of the folder so locking creates nothing in it and cleaning never sees it.
+const lockSuffix = ".lock"
+
+// DefaultLockTimeout is how long a run waits for another run writing the same output folder
+const DefaultLockTimeout = 30 * time.Second
+
+const (
+	// lockPollInterval is how often a waiting run checks the lock
+	lockPollInterval = 200 * time.Millisecond
+	// remoteLockStaleAfter is the age after which a lock held from another host is taken over, the
+	// liveness of its process cannot be checked
+	remoteLockStaleAfter = time.Hour
+	// lockWriteGrace is how long an empty or unreadable lock is considered held, it is being written
+	lockWriteGrace = 5 * time.Second
+)
+
+// lockHolder is the content of a lock file, identifying the run holding it
+type lockHolder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+func (h lockHolder) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", h.PID, h.Host, h.Started.Local().Format(time.TimeOnly))
+}
+
+// LockError is returned when another run kept the output folder locked past the lock timeout
+type LockError struct {
+	Output string
+	Path   string
+	Holder string
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("another next-gen run (%s) is writing %s, wait for it to finish or remove %s if it is not running",
+		e.Holder, e.Output, e.Path)
+}
+
+// outputLock is a held lock of an output folder
+type outputLock struct {
+	path string
+}
+
+// lockOutput locks an output folder so a single run writes it at a time. While another run holds the
+// lock it waits up to timeout, a zero timeout fails at once. Locks left by runs that are gone, like
+// killed watchers, are taken over. Dry runs write nothing to the disk and take no lock.
+func lockOutput(ctx context.Context, output outputFS, outputPath string, timeout time.Duration) (*outputLock, error) {
+	if _, ok := output.(diskFS); !ok {
+		return &outputLock{}, nil
+	}
+	outputPath = filepath.Clean(outputPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return nil, err
+	}
+
+	path := outputPath + lockSuffix
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		held, holder, err := tryLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if !held {
+			return &outputLock{path: path}, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, &LockError{Output: outputPath, Path: path, Holder: holder}
+		}
+		if !waiting {
+			slog.Info("Waiting for another next-gen run writing the output folder", "holder", holder, "timeout", timeout)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// tryLock creates the lock file, taking it over first when it is stale. held is true with a description
+// of the holder when another run holds the lock.
+func tryLock(path string) (held bool, holder string, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err == nil {
+		defer file.Close()
+		host, _ := os.Hostname()
+		data, _ := json.Marshal(lockHolder{PID: os.Getpid(), Host: host, Started: time.Now().UTC()})
+		if _, err = file.Write(data); err != nil {
+			os.Remove(path)
+			return false, "", err
+		}
+		return false, "", nil
+	}
+	if !errors.Is(err, os.ErrExist) {
+		return false, "", fmt.Errorf("failed to create lock %s: %w", path, err)
+	}
+
+	stale, holder, seen := checkLock(path)
+	if !stale {
+		return true, holder, nil
+	}
+	slog.Warn("Removing stale lock", "path", path, "holder", holder)
+	if err := takeOver(path, seen); err != nil {
+		return false, "", err
+	}
+	return tryLock(path)
+}
+
+// takeoverSeq tells apart the files stale locks are moved to by the runs of a process
+var takeoverSeq atomic.Int64
+
+// takeOver removes a stale lock, unless another run replaced it since it was seen. The lock is renamed
+// aside first, which only one run can do, and removed when it is still the one judged stale. A fresh
+// lock moved aside by a run that saw the stale one is linked back, which fails rather than replacing
+// a lock taken meanwhile.
+func takeOver(path string, seen lockState) error {
+	aside := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), takeoverSeq.Add(1))
+	if err := os.Rename(path, aside); err != nil {
+		if os.IsNotExist(err) {
+			// Released or taken over meanwhile
+			return nil
+		}
+		return fmt.Errorf("failed to remove stale lock %s: %w", path, err)
+	}
+	defer os.Remove(aside)
+
+	if current, err := readLockState(aside); err == nil && current.equal(seen) {
+		return nil
+	}
+	if err := os.Link(aside, path); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to restore lock %s: %w", path, err)
+	}
+	return nil
+}
+
+// lockState is the content and modification time of a lock file, a lock is replaced when either changes
+type lockState struct {
+	data    []byte
+	modTime time.Time
+}
+
+func (s lockState) equal(other lockState) bool {
+	return bytes.Equal(s.data, other.data) && s.modTime.Equal(other.modTime)
+}
+
+func readLockState(path string) (lockState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return lockState{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockState{}, err
+	}
+	return lockState{data: data, modTime: info.ModTime()}, nil
+}
+
+// checkLock reports whether an existing lock is stale: its process is gone, or it was taken on
+// another host longer than remoteLockStaleAfter ago. seen is the lock the decision was made on.
+func checkLock(path string) (stale bool, holder string, seen lockState) {
+	seen, err := readLockState(path)
+	if err != nil {
+		// Released meanwhile
+		return os.IsNotExist(err), "unknown", seen
+	}
+	var h lockHolder
+	if json.Unmarshal(seen.data, &h) != nil || h.PID == 0 {
+		return time.Since(seen.modTime) > lockWriteGrace, "unknown", seen
+	}
+
+	if host, _ := os.Hostname(); h.Host == host {
+		return !processAlive(h.PID), h.String(), seen
+	}
+	return time.Since(h.Started) > remoteLockStaleAfter, h.String(), seen
+}
+
+// processAlive reports whether a process of this host is running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process on Windows and fails once it exited
+		process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// unlock releases the lock
+func (l *outputLock) unlock() {
+	if l.path == "" {
+		return
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove lock", "path", l.path, "error", err)
+	}
+}
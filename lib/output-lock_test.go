@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exitedPID returns the process ID of a process of this host that already exited
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// writeLock writes a lock held by the process pid of this host
+func writeLock(t *testing.T, path string, pid int) {
+	t.Helper()
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(lockHolder{PID: pid, Host: host, Started: time.Now().UTC()})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  func(t *testing.T) int // Process holding the lock before the run, none when nil
+		held bool
+	}{
+		{name: "unlocked"},
+		{name: "held by a running process", pid: func(*testing.T) int { return os.Getpid() }, held: true},
+		{name: "left by an exited process", pid: exitedPID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), ".polycode")
+			if tt.pid != nil {
+				writeLock(t, outputPath+lockSuffix, tt.pid(t))
+			}
+
+			lock, err := lockOutput(context.Background(), diskFS{}, outputPath, 0)
+			var lockErr *LockError
+			if tt.held {
+				if !errors.As(err, &lockErr) {
+					t.Fatalf("got %v, want a LockError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lock.unlock()
+			if _, err := os.Stat(outputPath + lockSuffix); !os.IsNotExist(err) {
+				t.Errorf("the lock was left after unlocking: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockTakeoverIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".polycode"+lockSuffix)
+	writeLock(t, path, exitedPID(t))
+
+	// Every run sees the stale lock, a single one may take it over
+	var mu sync.Mutex
+	acquired := 0
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			held, _, err := tryLock(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !held {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired != 1 {
+		t.Errorf("%d runs took the lock, want 1", acquired)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) > 0 {
+		t.Errorf("locks moved aside were left: %v", matches)
+	}
+}
+
+func TestTakeOverKeepsReplacedLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".polycode"+lockSuffix)
+	writeLock(t, path, exitedPID(t))
+	_, _, seen := checkLock(path)
+
+	// Another run took the stale lock over and locked again after it was seen
+	writeLock(t, path, os.Getpid())
+	fresh, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := takeOver(path, seen); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != string(fresh) {
+		t.Errorf("got lock %q (%v), want the fresh lock %q kept", data, err, fresh)
+	}
+}
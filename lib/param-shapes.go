@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// SkippedFunction is an exported function of a service package that is not exposed because its
+// inputs or outputs cannot be carried by the generated wrapper
+type SkippedFunction struct {
+	Name   string `json:"name"`
+	File   string `json:"file"` // Relative to the app root once reported
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+func (s SkippedFunction) String() string {
+	return fmt.Sprintf("%s:%d: function %s: %s", s.File, s.Line, s.Name, s.Reason)
+}
+
+// unsupportedShape returns why the parameters or results of a service function cannot be decoded or
+// encoded by the wrapper, empty when they can. The context parameter and the error result are not
+// checked, streams are checked by their element type.
+func unsupportedShape(fn *ast.FuncDecl, sdk sdkResolver) string {
+	results := flattenFields(fn.Type.Results)
+	switch {
+	case len(results) == 0 || len(results) > 2:
+		return fmt.Sprintf("expected (output, error) or error results, got %d results", len(results))
+	case !isErrorType(results[len(results)-1]):
+		return "expected (output, error) or error results, the last result must be error"
+	}
+
+	params := flattenFields(fn.Type.Params)
+	names := paramNames(fn.Type.Params)
+	for i, param := range params {
+		if i == 0 {
+			continue
+		}
+		if elem, _, err := streamElement(param, sdk); err == nil {
+			param = elem
+		}
+		if reason := unsupportedType(param); reason != "" {
+			name := names[i]
+			if name == "" || name == "_" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			return fmt.Sprintf("parameter %s: %s", name, reason)
+		}
+	}
+
+	for i, result := range results {
+		if i == len(results)-1 && isErrorType(result) {
+			continue
+		}
+		if elem, _, err := streamElement(result, sdk); err == nil {
+			result = elem
+		}
+		if reason := unsupportedType(result); reason != "" {
+			return "result: " + reason
+		}
+	}
+	return ""
+}
+
+// unsupportedType returns why values of a type expression cannot be carried as JSON, types it cannot
+// tell without type checking, like named types of other packages, are accepted. Streams are unwrapped
+// before, a channel left is nested in another type.
+func unsupportedType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return "anonymous struct types cannot be referred to by the generated package, declare a named type"
+	case *ast.FuncType:
+		return "functions cannot be encoded as JSON"
+	case *ast.ChanType:
+		return "channels are only supported as the stream input or output itself"
+	case *ast.Ident:
+		if t.Name == "complex64" || t.Name == "complex128" {
+			return "complex numbers cannot be encoded as JSON"
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "unsafe" {
+			return "unsafe pointers cannot be encoded as JSON"
+		}
+	case *ast.StarExpr:
+		return unsupportedType(t.X)
+	case *ast.ArrayType:
+		return unsupportedType(t.Elt)
+	case *ast.Ellipsis:
+		return unsupportedType(t.Elt)
+	case *ast.MapType:
+		if reason := unsupportedMapKey(t.Key); reason != "" {
+			return reason
+		}
+		return unsupportedType(t.Value)
+	case *ast.IndexExpr:
+		return unsupportedType(t.Index)
+	case *ast.IndexListExpr:
+		for _, index := range t.Indices {
+			if reason := unsupportedType(index); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+// unsupportedMapKey returns why a map key type cannot be a JSON object key, JSON keys are strings,
+// integers or types implementing encoding.TextMarshaler
+func unsupportedMapKey(key ast.Expr) string {
+	switch t := key.(type) {
+	case *ast.Ident:
+		if t.Name == "bool" || strings.HasPrefix(t.Name, "float") || strings.HasPrefix(t.Name, "complex") {
+			return fmt.Sprintf("map keys of type %s cannot be encoded as JSON object keys", t.Name)
+		}
+		return ""
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		return ""
+	}
+	return "map keys must be strings, integers or named types implementing encoding.TextMarshaler"
+}
+
+// unsupportedShapesError is the error of a service with skipped functions in strict mode, located at
+// the first one
+func unsupportedShapesError(skipped []SkippedFunction) error {
+	lines := make([]string, len(skipped))
+	for i, s := range skipped {
+		lines[i] = s.String()
+	}
+	err := fmt.Errorf("%d exported function(s) have unsupported parameter or result types:\n%s", len(skipped), strings.Join(lines, "\n"))
+	return &positionError{pos: token.Position{Filename: skipped[0].File, Line: skipped[0].Line}, err: err}
+}
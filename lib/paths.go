@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// NormalizeAppPath returns the app path absolute and cleaned, so paths built from it and paths reported
+// by the file system compare equal whatever form the user passed, like app/, .\app or a relative path
+func NormalizeAppPath(appPath string) (string, error) {
+	if appPath == "" {
+		appPath = "."
+	}
+	abs, err := filepath.Abs(appPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid app path %q: %w", appPath, err)
+	}
+	return abs, nil
+}
+
+// isOutside reports whether a path returned by filepath.Rel leaves its base folder, unlike a prefix
+// check on ".." it keeps folders named like ..data inside
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) || filepath.VolumeName(rel) != ""
+}
+
+// isInside reports whether path is dir or below it, both cleaned
+func isInside(path string, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeAppPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := filepath.Join(cwd, "app")
+	sep := string(filepath.Separator)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "", want: cwd},
+		{path: ".", want: cwd},
+		{path: "app", want: app},
+		{path: "app" + sep, want: app},
+		{path: "." + sep + "app" + sep + sep, want: app},
+		{path: filepath.Join("app", "services", ".."), want: app},
+		{path: app + sep, want: app},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := NormalizeAppPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOutside(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{rel: ".", want: false},
+		{rel: "services", want: false},
+		{rel: filepath.Join("services", "orders"), want: false},
+		{rel: "..data", want: false},
+		{rel: "..", want: true},
+		{rel: filepath.Join("..", "other"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := isOutside(tt.rel); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "app")
+	sep := string(filepath.Separator)
+	tests := []struct {
+		name string
+		path string
+		dir  string
+		want bool
+	}{
+		{name: "same folder", path: root, dir: root, want: true},
+		{name: "below", path: filepath.Join(root, "services", "orders.go"), dir: root, want: true},
+		{name: "dir with trailing separator", path: filepath.Join(root, "services"), dir: root + sep, want: true},
+		{name: "sibling with the same prefix", path: root + "2", dir: root, want: false},
+		{name: "parent", path: filepath.Dir(root), dir: root, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isInside(tt.path, tt.dir); got != tt.want {
+				t.Errorf("isInside(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"plugin"
+	"strings"
+)
+
+// LoadPlugin opens a Go plugin exporting a `Generator` variable implementing Generator, register it with
+// Options.RegisterGenerator
+func LoadPlugin(path string) (Generator, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+
+	symbol, err := p.Lookup("Generator")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export Generator: %w", path, err)
+	}
+
+	var generator Generator
+	switch s := symbol.(type) {
+	case Generator:
+		generator = s
+	case *Generator:
+		generator = *s
+	case func() Generator:
+		generator = s()
+	default:
+		return nil, fmt.Errorf("plugin %s: Generator has type %T, expected lib.Generator", path, symbol)
+	}
+	return generator, nil
+}
+
+// commandGenerator runs an external command for each service. The command receives
+// {"service": ServiceInfo, "definition": ServiceDefinition} as JSON on stdin and must
+// print {"files": {"relative/path": "content"}} on stdout.
+type commandGenerator struct {
+	name    string
+	command string
+}
+
+// NewCommandGenerator returns a generator delegating to an external command
+func NewCommandGenerator(name string, command string) Generator {
+	return commandGenerator{name: name, command: command}
+}
+
+func (g commandGenerator) Name() string {
+	return g.name
+}
+
+func (g commandGenerator) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	args := strings.Fields(g.command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("generator %s has no command", g.name)
+	}
+
+	input, err := json.Marshal(map[string]any{"service": info, "definition": def})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("generator %s failed: %w: %s", g.name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output struct {
+		Files map[string]string `json:"files"`
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("generator %s returned invalid output: %w", g.name, err)
+	}
+
+	files := make(map[string][]byte, len(output.Files))
+	for name, content := range output.Files {
+		files[name] = []byte(content)
+	}
+	return files, nil
+}
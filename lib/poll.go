@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileState is what the poller compares between scans
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+// FileSnapshot records the state of the watched files, used by polling watch mode where file system
+// notifications are unavailable, like on NFS or Docker volume mounts
+type FileSnapshot map[string]fileState
+
+// TakeSnapshot scans the roots recursively plus the given files, skipping ignored paths
+func TakeSnapshot(roots []string, files []string, ignore *IgnoreMatcher) FileSnapshot {
+	snapshot := make(FileSnapshot)
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Vanished while scanning, the next scan will notice
+				return nil
+			}
+			if ignore.Match(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size(), isDir: d.IsDir()}
+			}
+			return nil
+		})
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			snapshot[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return snapshot
+}
+
+// Diff returns the files created or modified since the previous snapshot and the paths removed since,
+// folders only count as changed when they are created or removed
+func (s FileSnapshot) Diff(previous FileSnapshot) (changed []string, removed []string) {
+	for path, state := range s {
+		old, ok := previous[path]
+		if !ok || !state.isDir && (!old.modTime.Equal(state.modTime) || old.size != state.size) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := s[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFileSnapshotDiff(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, data string) string {
+		t.Helper()
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	orders := write(filepath.Join("services", "orders", "orders.go"), "package orders")
+	billing := write(filepath.Join("services", "billing", "billing.go"), "package billing")
+	write(filepath.Join(".polycode", "orders.go"), "package polycode")
+	goMod := write("go.mod", "module example.com/app")
+	ignore := NewIgnoreMatcher(root, []string{"/.polycode/"})
+
+	before := TakeSnapshot([]string{filepath.Join(root, "services")}, []string{goMod}, ignore)
+	if _, ok := before[goMod]; !ok {
+		t.Error("the snapshot misses the watched file outside of the roots")
+	}
+
+	write(filepath.Join("services", "orders", "orders.go"), "package orders // changed")
+	// Coarse file system clocks may not tell the write apart, the size changed too
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(orders, later, later); err != nil {
+		t.Fatal(err)
+	}
+	shipping := write(filepath.Join("services", "shipping", "shipping.go"), "package shipping")
+	write(filepath.Join(".polycode", "billing.go"), "package polycode")
+	if err := os.Remove(billing); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Dir(billing)); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, removed := TakeSnapshot([]string{filepath.Join(root, "services")}, []string{goMod}, ignore).Diff(before)
+	wantChanged := []string{orders, filepath.Dir(shipping), shipping}
+	slices.Sort(wantChanged)
+	if !slices.Equal(changed, wantChanged) {
+		t.Errorf("got changed %v, want %v", changed, wantChanged)
+	}
+	wantRemoved := []string{filepath.Dir(billing), billing}
+	slices.Sort(wantRemoved)
+	if !slices.Equal(removed, wantRemoved) {
+		t.Errorf("got removed %v, want %v", removed, wantRemoved)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package lib
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup does nothing on Windows, there is no process group to signal
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup signals the command itself on Windows, which can only kill it
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package lib
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup makes the command the leader of a new process group
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends a signal to the process group led by a started command
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
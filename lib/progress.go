@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Outcomes of a service in a generation run
+const (
+	ServiceGenerated = "generated"
+	ServiceUnchanged = "unchanged"
+	ServiceEmpty     = "empty"
+	ServiceFailed    = "failed"
+	// ServiceRestored services were generated, then restored to their previous files along with the
+	// rest of the module because the generated code does not compile
+	ServiceRestored = "restored"
+)
+
+// ServiceReport is the outcome of generating a single service
+type ServiceReport struct {
+	Service   string        `json:"service"`
+	Module    string        `json:"module,omitempty"` // Set in workspaces of several modules
+	Status    string        `json:"status"`
+	Methods   int           `json:"methods"`   // Methods taking a polycode.ServiceContext
+	Workflows int           `json:"workflows"` // Methods taking a polycode.WorkflowContext
+	Files     []string      `json:"files,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	// Warnings are the problems that did not fail the service, Skipped its functions that are not exposed
+	Warnings []string          `json:"warnings,omitempty"`
+	Skipped  []SkippedFunction `json:"skipped,omitempty"`
+}
+
+// countMethods fills the method and workflow counts of the report
+func (r *ServiceReport) countMethods(methods []MethodInfo) {
+	r.Methods, r.Workflows = 0, 0
+	for _, method := range methods {
+		if method.IsWorkflow {
+			r.Workflows++
+		} else {
+			r.Methods++
+		}
+	}
+}
+
+// Written returns the number of files written for the service, unchanged services write none
+func (r *ServiceReport) Written() int {
+	if r.Status != ServiceGenerated {
+		return 0
+	}
+	return len(r.Files)
+}
+
+// Report is the outcome of a generation run, services are listed in the order they were selected
+type Report struct {
+	Services []ServiceReport `json:"services"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// count returns the number of services with the given status
+func (r *Report) count(status string) int {
+	n := 0
+	for _, service := range r.Services {
+		if service.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Generated returns the names of the services whose outputs the run wrote, generated or restored
+func (r *Report) Generated() []string {
+	var names []string
+	for _, service := range r.Services {
+		if service.Status == ServiceGenerated || service.Status == ServiceRestored {
+			names = append(names, service.Service)
+		}
+	}
+	return names
+}
+
+// Summary renders the run as a table of services with their method counts, files written and
+// durations, followed by the totals
+func (r *Report) Summary() string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tSTATUS\tMETHODS\tWORKFLOWS\tFILES\tDURATION")
+	var methods, workflows, files int
+	for _, service := range r.Services {
+		name := service.Service
+		if service.Module != "" {
+			name = service.Module + ":" + name
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", name, service.Status, service.Methods, service.Workflows, service.Written(), service.Duration.Round(time.Millisecond))
+		methods += service.Methods
+		workflows += service.Workflows
+		files += service.Written()
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%s\n", methods, workflows, files, r.Duration.Round(time.Millisecond))
+	w.Flush()
+	fmt.Fprintf(&buf, "%d service(s): %d generated, %d unchanged, %d failed",
+		len(r.Services), r.count(ServiceGenerated), r.count(ServiceUnchanged), r.count(ServiceFailed))
+	if restored := r.count(ServiceRestored); restored > 0 {
+		fmt.Fprintf(&buf, ", %d restored", restored)
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
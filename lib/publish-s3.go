@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3HashMeta is the object metadata holding the SHA-256 of a published file
+const s3HashMeta = "X-Amz-Meta-Sha256"
+
+// s3Registry uploads files as objects of a bucket, signed with AWS Signature Version 4. The
+// credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type s3Registry struct {
+	bucket    string
+	prefix    string
+	region    string
+	endpoint  string // Path-style endpoint of S3 compatible stores, empty for AWS
+	accessKey string
+	secretKey string
+	token     string
+	client    *http.Client
+}
+
+// newS3Registry reads the bucket and prefix of an s3://bucket/prefix location and the credentials
+// from the environment
+func newS3Registry(cfg PublishConfig) (*s3Registry, error) {
+	location, ok := strings.CutPrefix(cfg.S3, "s3://")
+	bucket, prefix, _ := strings.Cut(location, "/")
+	if !ok || bucket == "" {
+		return nil, fmt.Errorf("invalid S3 location %q, expected s3://bucket/prefix", cfg.S3)
+	}
+
+	r := &s3Registry{
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		region:    cfg.Region,
+		endpoint:  strings.TrimSuffix(cfg.Endpoint, "/"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: time.Minute},
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if r.region == "" {
+			r.region = os.Getenv(env)
+		}
+	}
+	if r.region == "" {
+		return nil, fmt.Errorf("no S3 region configured, set publish.region or AWS_REGION")
+	}
+	if r.accessKey == "" || r.secretKey == "" {
+		return nil, fmt.Errorf("no S3 credentials, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return r, nil
+}
+
+// Put uploads an object unless the bucket holds it with the same hash in its metadata
+func (r *s3Registry) Put(ctx context.Context, key string, data []byte, hash string) (bool, error) {
+	objectURL := r.objectURL(path.Join(r.prefix, key))
+
+	head, err := r.do(ctx, http.MethodHead, objectURL, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	head.Body.Close()
+	if head.StatusCode == http.StatusOK && head.Header.Get(s3HashMeta) == hash {
+		return false, nil
+	}
+	if head.StatusCode != http.StatusOK && head.StatusCode != http.StatusNotFound {
+		return false, fmt.Errorf("S3 answered %s to HEAD %s", head.Status, objectURL)
+	}
+
+	res, err := r.do(ctx, http.MethodPut, objectURL, data, map[string]string{"Content-Type": contentType(key), s3HashMeta: hash})
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return false, fmt.Errorf("S3 answered %s to PUT %s: %s", res.Status, objectURL, strings.TrimSpace(string(body)))
+	}
+	return true, nil
+}
+
+func (r *s3Registry) String() string {
+	return "s3://" + path.Join(r.bucket, r.prefix)
+}
+
+// objectURL returns the URL of an object, virtual-hosted on AWS and path-style on custom endpoints
+func (r *s3Registry) objectURL(key string) string {
+	escaped := s3EscapePath(key)
+	if r.endpoint != "" {
+		return r.endpoint + "/" + r.bucket + "/" + escaped
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", r.bucket, r.region, escaped)
+}
+
+// do sends a signed request
+func (r *s3Registry) do(ctx context.Context, method string, objectURL string, data []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if r.token != "" {
+		req.Header.Set("X-Amz-Security-Token", r.token)
+	}
+	r.sign(req, data, time.Now().UTC())
+	return r.client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 Authorization header of a request, signing the host and
+// every header set on the request
+func (r *s3Registry) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256Hex(payload)
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + r.region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+r.secretKey), now.Format("20060102"))
+	for _, part := range []string{r.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", r.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery sorts and escapes query parameters as Signature Version 4 expects
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3EscapePath escapes each segment of an object key
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Escape percent-encodes everything but the unreserved characters of RFC 3986
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,237 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultPublishTokenEnv is the environment variable holding the bearer token of an HTTP registry
+const DefaultPublishTokenEnv = "NEXT_GEN_PUBLISH_TOKEN"
+
+// publishedFolders are the folders of the output published to a registry, the service definitions
+// and the schemas generated from them
+var publishedFolders = []string{"definition", "openapi", "asyncapi", "schema"}
+
+// PublishConfig holds the registry next-gen publish uploads the definitions and schemas to, either an
+// HTTP endpoint or an S3 bucket
+type PublishConfig struct {
+	// URL is the HTTP registry, files are uploaded with PUT <url>/<prefix>/<file>
+	URL string `yaml:"url"`
+	// TokenEnv names the environment variable holding the bearer token of the HTTP registry
+	TokenEnv string `yaml:"tokenEnv"`
+	// S3 is the bucket and optional key prefix files are uploaded to, like s3://bucket/definitions
+	S3 string `yaml:"s3"`
+	// Region of the bucket, AWS_REGION when empty
+	Region string `yaml:"region"`
+	// Endpoint of an S3 compatible store like MinIO, addressed path-style
+	Endpoint string `yaml:"endpoint"`
+	// Prefix is prepended to the keys of the files, like the name of the app
+	Prefix string `yaml:"prefix"`
+}
+
+// Statuses of a published file
+const (
+	PublishUploaded  = "uploaded"
+	PublishUnchanged = "unchanged"
+	PublishSkipped   = "skipped" // Dry run
+)
+
+// PublishedFile is the outcome of publishing a single file
+type PublishedFile struct {
+	Key    string `json:"key"`
+	Hash   string `json:"sha256"`
+	Status string `json:"status"`
+}
+
+// PublishReport lists the published files
+type PublishReport struct {
+	Registry string          `json:"registry"`
+	Files    []PublishedFile `json:"files"`
+}
+
+// Summary renders the published files as a table followed by the counts of each status
+func (r *PublishReport) Summary() string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSHA256\tSTATUS")
+	counts := make(map[string]int)
+	for _, file := range r.Files {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", file.Key, file.Hash[:12], file.Status)
+		counts[file.Status]++
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "%d file(s) to %s: %d uploaded, %d unchanged", len(r.Files), r.Registry, counts[PublishUploaded], counts[PublishUnchanged])
+	if counts[PublishSkipped] > 0 {
+		fmt.Fprintf(&buf, ", %d skipped by the dry run", counts[PublishSkipped])
+	}
+	return buf.String() + "\n"
+}
+
+// registry stores published files. Put uploads a file unless the registry already holds the content
+// of that hash under the key, and reports whether it uploaded.
+type registry interface {
+	Put(ctx context.Context, key string, data []byte, hash string) (bool, error)
+	String() string
+}
+
+// newRegistry returns the registry of the config, exactly one of URL and S3 must be set
+func newRegistry(cfg PublishConfig) (registry, error) {
+	switch {
+	case cfg.URL != "" && cfg.S3 != "":
+		return nil, fmt.Errorf("publish to either an HTTP registry or an S3 bucket, not both")
+	case cfg.URL != "":
+		tokenEnv := cfg.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = DefaultPublishTokenEnv
+		}
+		return &httpRegistry{url: strings.TrimSuffix(cfg.URL, "/"), token: os.Getenv(tokenEnv), client: &http.Client{Timeout: time.Minute}}, nil
+	case cfg.S3 != "":
+		return newS3Registry(cfg)
+	}
+	return nil, fmt.Errorf("no registry configured, set publish.url or publish.s3 in %s or use -url or -s3", ConfigFileName)
+}
+
+// Publish uploads the definitions and schemas generated into the output folders of the app. Each file is
+// keyed by its path in the output folder under cfg.Prefix, and by module path in workspaces of several
+// modules. Files the registry already holds with the same SHA-256 are not uploaded again, so publishing
+// on every merge only uploads what changed.
+func Publish(ctx context.Context, appPath string, opts Options, cfg PublishConfig, dryRun bool) (*PublishReport, error) {
+	reg, err := newRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := resolveModules(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PublishReport{Registry: reg.String(), Files: []PublishedFile{}}
+	for _, module := range modules {
+		outputPath := filepath.Join(module.Dir, opts.OutputDir)
+		files, err := publishedFiles(outputPath)
+		if err != nil {
+			return report, err
+		}
+
+		prefix := cfg.Prefix
+		if len(modules) > 1 {
+			prefix = path.Join(prefix, module.Name)
+		}
+		for _, rel := range files {
+			data, err := os.ReadFile(filepath.Join(outputPath, filepath.FromSlash(rel)))
+			if err != nil {
+				return report, err
+			}
+			sum := sha256.Sum256(data)
+			file := PublishedFile{Key: path.Join(prefix, rel), Hash: hex.EncodeToString(sum[:]), Status: PublishSkipped}
+			if !dryRun {
+				uploaded, err := reg.Put(ctx, file.Key, data, file.Hash)
+				if err != nil {
+					return report, fmt.Errorf("failed to publish %s: %w", file.Key, err)
+				}
+				file.Status = PublishUnchanged
+				if uploaded {
+					file.Status = PublishUploaded
+				}
+			}
+			report.Files = append(report.Files, file)
+		}
+	}
+	if len(report.Files) == 0 {
+		return report, fmt.Errorf("no service definitions found, run next-gen first")
+	}
+	return report, nil
+}
+
+// publishedFiles lists the files of the published folders of an output folder, as sorted slash
+// separated paths relative to it
+func publishedFiles(outputPath string) ([]string, error) {
+	var files []string
+	for _, folder := range publishedFolders {
+		err := filepath.WalkDir(filepath.Join(outputPath, folder), func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(outputPath, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// contentType returns the media type a published file is uploaded with
+func contentType(key string) string {
+	switch path.Ext(key) {
+	case ".yml", ".yaml":
+		return "application/yaml"
+	case ".json":
+		return "application/json"
+	case ".cbor":
+		return "application/cbor"
+	}
+	return "application/octet-stream"
+}
+
+// httpRegistry uploads files with PUT <url>/<key>. The SHA-256 of the content is sent as the ETag the
+// registry must not already hold with If-None-Match and as the Idempotency-Key, registries answer 304
+// or 412 when they hold it.
+type httpRegistry struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (r *httpRegistry) Put(ctx context.Context, key string, data []byte, hash string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, r.url+"/"+key, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType(key))
+	req.Header.Set("If-None-Match", `"`+hash+`"`)
+	req.Header.Set("Idempotency-Key", "sha256:"+hash)
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotModified || res.StatusCode == http.StatusPreconditionFailed:
+		return false, nil
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return true, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return false, fmt.Errorf("registry answered %s: %s", res.Status, strings.TrimSpace(string(body)))
+}
+
+func (r *httpRegistry) String() string {
+	return r.url
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// RunQueue serializes generation runs so that a single run executes at a time. Triggers arriving
+// while a run executes are merged into the pending runs, one per key with the paths seen, and a
+// pending full run, keyed by "", absorbs the runs of single services and their paths.
+type RunQueue struct {
+	mu      sync.Mutex
+	running bool
+	pending map[string][]string
+	order   []string
+	idle    *sync.Cond
+	run     func(paths []string)
+}
+
+// NewRunQueue returns a queue calling run for every merged trigger, one call at a time
+func NewRunQueue(run func(paths []string)) *RunQueue {
+	q := &RunQueue{pending: make(map[string][]string), run: run}
+	q.idle = sync.NewCond(&q.mu)
+	return q
+}
+
+// Trigger queues a run for the paths of the key, an empty key being a full run, and returns without waiting for it
+func (q *RunQueue) Trigger(key string, paths []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, full := q.pending[""]; full && key != "" {
+		slog.Debug("Merged into the pending full generation", "paths", paths)
+		key = ""
+	} else if key == "" {
+		// The full run takes over the paths of the runs it replaces
+		var absorbed []string
+		for _, pendingKey := range q.order {
+			absorbed = append(absorbed, q.pending[pendingKey]...)
+		}
+		paths = append(absorbed, paths...)
+		q.pending, q.order = make(map[string][]string), nil
+	}
+	if _, ok := q.pending[key]; !ok {
+		q.order = append(q.order, key)
+	} else {
+		slog.Debug("Merged into a pending generation", "key", key, "paths", paths)
+	}
+	for _, path := range paths {
+		q.pending[key] = appendPath(q.pending[key], path)
+	}
+
+	if !q.running {
+		q.running = true
+		go q.drain()
+	}
+}
+
+// drain runs the pending triggers in arrival order until none are left
+func (q *RunQueue) drain() {
+	q.mu.Lock()
+	for len(q.order) > 0 {
+		key := q.order[0]
+		paths := q.pending[key]
+		q.order = q.order[1:]
+		delete(q.pending, key)
+		q.mu.Unlock()
+
+		q.run(paths)
+
+		q.mu.Lock()
+	}
+	q.running = false
+	q.idle.Broadcast()
+	q.mu.Unlock()
+}
+
+// Wait blocks until the running and pending runs are done
+func (q *RunQueue) Wait() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.running {
+		q.idle.Wait()
+	}
+}
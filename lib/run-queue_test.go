@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		triggers [][2]string // key and path of each trigger queued while a first run executes
+		want     []string    // paths of the runs after the first one, comma separated, in order
+	}{
+		{name: "arrival order", triggers: [][2]string{{"orders", "a.go"}, {"billing", "b.go"}}, want: []string{"a.go", "b.go"}},
+		{name: "same key merged into a batch", triggers: [][2]string{{"orders", "a.go"}, {"billing", "b.go"}, {"orders", "c.go"}, {"orders", "a.go"}}, want: []string{"a.go,c.go", "b.go"}},
+		{name: "full run absorbs pending services", triggers: [][2]string{{"orders", "a.go"}, {"", "go.mod"}, {"billing", "b.go"}}, want: []string{"a.go,go.mod,b.go"}},
+		{name: "full runs merged", triggers: [][2]string{{"", "go.mod"}, {"", "go.sum"}}, want: []string{"go.mod,go.sum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ran []string
+			started := make(chan struct{})
+			release := make(chan struct{})
+			q := NewRunQueue(func(paths []string) {
+				if paths[0] == "first" {
+					close(started)
+					<-release
+					return
+				}
+				mu.Lock()
+				ran = append(ran, strings.Join(paths, ","))
+				mu.Unlock()
+			})
+
+			q.Trigger("orders", []string{"first"})
+			<-started
+			for _, trigger := range tt.triggers {
+				q.Trigger(trigger[0], []string{trigger[1]})
+			}
+			close(release)
+			q.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !slices.Equal(ran, tt.want) {
+				t.Errorf("ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunQueueRunsOneAtATime(t *testing.T) {
+	var mu sync.Mutex
+	running := 0
+	started := make(chan int)
+	proceed := make(chan struct{})
+	q := NewRunQueue(func([]string) {
+		mu.Lock()
+		running++
+		n := running
+		mu.Unlock()
+
+		started <- n
+		<-proceed
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"orders", "billing", "shipping", "", "orders"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Trigger(key, []string{key + ".go"})
+		}()
+	}
+	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		q.Wait()
+		close(done)
+	}()
+
+	// Every run is held until the test lets it finish, another starting meanwhile would count two
+	for {
+		select {
+		case n := <-started:
+			if n != 1 {
+				t.Errorf("%d runs executed at the same time, want 1", n)
+			}
+			proceed <- struct{}{}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// refFunc resolves a struct type name to a JSON Schema $ref, ok is false for unknown types
+type refFunc func(name string) (ref string, ok bool)
+
+// pointerEscaper escapes the reference tokens of JSON pointers
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// schemaPointer locates a schema in its document, so recursive uses of a named type can refer back to
+// the schema the type is expanded at
+type schemaPointer struct {
+	uri   string            // URI reference of the schema, like #/components/schemas/models.Node
+	named map[string]string // URI references of the enclosing expansions of named types, by type name
+}
+
+// rootPointer returns the pointer of a schema at the tokens of its document
+func rootPointer(tokens ...string) schemaPointer {
+	return schemaPointer{uri: "#"}.child(tokens...)
+}
+
+// child returns the pointer of a subschema
+func (p schemaPointer) child(tokens ...string) schemaPointer {
+	uri := p.uri
+	for _, token := range tokens {
+		uri += "/" + url.PathEscape(pointerEscaper.Replace(token))
+	}
+	return schemaPointer{uri: uri, named: p.named}
+}
+
+// expanding returns the pointer recording the schema as the expansion of a named type
+func (p schemaPointer) expanding(name string) schemaPointer {
+	named := maps.Clone(p.named)
+	if named == nil {
+		named = make(map[string]string)
+	}
+	named[name] = p.uri
+	return schemaPointer{uri: p.uri, named: named}
+}
+
+// integerTypes are the Go integer types, named exactly so interface{} is not taken for one
+var integerTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"byte": true, "rune": true,
+}
+
+// floatTypes are the Go floating point types
+var floatTypes = map[string]bool{
+	"float32": true, "float64": true,
+}
+
+// jsonSchema maps a Go type expression to a JSON Schema
+func jsonSchema(goType string, ref refFunc) map[string]any {
+	goType = strings.TrimPrefix(goType, "*")
+	switch {
+	case goType == "any", goType == "interface{}":
+		return map[string]any{}
+	case goType == "[]byte":
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case strings.HasPrefix(goType, "["):
+		elem, _ := elemTypeName(goType)
+		return map[string]any{"type": "array", "items": jsonSchema(elem, ref)}
+	case strings.HasPrefix(goType, "map["):
+		_, value, _ := strings.Cut(goType, "]")
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(value, ref)}
+	case goType == "string":
+		return map[string]any{"type": "string"}
+	case goType == "bool":
+		return map[string]any{"type": "boolean"}
+	case integerTypes[goType]:
+		return map[string]any{"type": "integer"}
+	case floatTypes[goType]:
+		return map[string]any{"type": "number"}
+	case goType == "time.Time":
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	if target, ok := ref(goType); ok {
+		return map[string]any{"$ref": target}
+	}
+	return map[string]any{"type": "object"}
+}
+
+// typeJSONSchema maps the schema of a field type to a JSON Schema written at the pointer, nullability of
+// pointers is left to the caller
+func typeJSONSchema(schema *TypeSchema, ref refFunc, at schemaPointer) map[string]any {
+	if schema.Type != "" && (schema.Kind == SchemaKindArray || schema.Kind == SchemaKindMap) {
+		at = at.expanding(schema.Type)
+	}
+	switch schema.Kind {
+	case SchemaKindString:
+		return withEnum(map[string]any{"type": "string"}, schema)
+	case SchemaKindInteger:
+		return withEnum(map[string]any{"type": "integer"}, schema)
+	case SchemaKindNumber:
+		return withEnum(map[string]any{"type": "number"}, schema)
+	case SchemaKindBoolean:
+		return map[string]any{"type": "boolean"}
+	case SchemaKindBytes:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case SchemaKindTime:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case SchemaKindArray:
+		return map[string]any{"type": "array", "items": typeJSONSchema(schema.Elem, ref, at.child("items"))}
+	case SchemaKindMap:
+		return map[string]any{"type": "object", "additionalProperties": typeJSONSchema(schema.Elem, ref, at.child("additionalProperties"))}
+	case SchemaKindStruct:
+		if schema.Type == "" {
+			return structSchema(schema.Fields, ref, at)
+		}
+		if target, ok := ref(schema.Type); ok {
+			return map[string]any{"$ref": target}
+		}
+		return map[string]any{"type": "object"}
+	case SchemaKindRef:
+		if target, ok := at.named[schema.Type]; ok {
+			return map[string]any{"$ref": target}
+		}
+	}
+	return map[string]any{}
+}
+
+// withEnum adds the enum values of a named type to its JSON Schema
+func withEnum(jsonSchema map[string]any, schema *TypeSchema) map[string]any {
+	if len(schema.Enum) > 0 {
+		jsonSchema["enum"] = schema.Enum
+	}
+	return jsonSchema
+}
+
+// jsonFieldName returns the wire name of a field from its json tag, skip is true for `json:"-"`
+func jsonFieldName(field Field) (name string, omitempty bool, skip bool) {
+	tag, ok := reflect.StructTag(field.Tag).Lookup("json")
+	if !ok {
+		return field.Name, false, false
+	}
+
+	// Like encoding/json, `json:"-,"` names the field -
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(","+options+",", ",omitempty,"), false
+}
+
+// structSchema builds an object schema written at the pointer from the fields of a struct honoring json
+// and validate tags, fields are required unless they are pointers or tagged omitempty, or when their
+// validate tag says so
+func structSchema(fields []Field, ref refFunc, at schemaPointer) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for _, field := range fields {
+		name, omitempty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		var schema map[string]any
+		if field.Schema != nil {
+			fieldAt := at.child("properties", name)
+			if strings.HasPrefix(field.Type, "*") {
+				// Wrapped below to allow null
+				fieldAt = fieldAt.child("anyOf", "0")
+			}
+			schema = typeJSONSchema(field.Schema, ref, fieldAt)
+		} else {
+			schema = jsonSchema(field.Type, ref)
+		}
+		// Constraints of the validate tag apply to the value, a nil pointer is rejected by required
+		validateRequired := applySchemaConstraints(schema, field)
+		if strings.HasPrefix(field.Type, "*") {
+			schema = map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+		}
+		if validateRequired || !omitempty && !strings.HasPrefix(field.Type, "*") {
+			required = append(required, name)
+		}
+		if field.Doc != "" {
+			schema["description"] = field.Doc
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectSchemaTypes returns every struct type used by the definitions, keyed by type name
+func collectSchemaTypes(defs []ServiceDefinition) map[string][]Field {
+	types := make(map[string][]Field)
+	for _, def := range defs {
+		for name, fields := range def.Types {
+			types[schemaRef(name)] = fields
+		}
+		for _, method := range def.Methods {
+			if method.InputSchema != nil {
+				types[schemaRef(method.InputType)] = method.InputSchema
+			}
+			if method.OutputSchema != nil {
+				types[schemaRef(method.OutputType)] = method.OutputSchema
+			}
+		}
+	}
+	return types
+}
+
+// writeJSONSchemas writes a draft 2020-12 JSON Schema file per struct type under .polycode/schema
+func writeJSONSchemas(output outputFS, outputPath string, defs []ServiceDefinition) error {
+	schemaFolder := filepath.Join(outputPath, "schema")
+	err := output.MkdirAll(schemaFolder, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create schema folder: %w", err)
+	}
+
+	types := collectSchemaTypes(defs)
+	ref := func(name string) (string, bool) {
+		_, ok := types[name]
+		return name + ".json", ok
+	}
+
+	keep := make(map[string]bool, len(types))
+	for name, fields := range types {
+		keep[name+".json"] = true
+		schema := structSchema(fields, ref, rootPointer())
+		schema["$schema"] = jsonSchemaDraft
+		schema["$comment"] = generatedHeader() + ". DO NOT EDIT."
+		schema["$id"] = name + ".json"
+		schema["title"] = name
+
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal schema for %s: %w", name, err)
+		}
+
+		err = output.WriteFile(filepath.Join(schemaFolder, name+".json"), append(data, '\n'), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return removeUnlisted(output, schemaFolder, "*.json", keep)
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	ref := func(name string) (string, bool) {
+		return name + ".json", name == "models.Order"
+	}
+	tests := []struct {
+		goType string
+		want   map[string]any
+	}{
+		{goType: "string", want: map[string]any{"type": "string"}},
+		{goType: "*string", want: map[string]any{"type": "string"}},
+		{goType: "bool", want: map[string]any{"type": "boolean"}},
+		{goType: "int", want: map[string]any{"type": "integer"}},
+		{goType: "uint64", want: map[string]any{"type": "integer"}},
+		{goType: "rune", want: map[string]any{"type": "integer"}},
+		{goType: "float32", want: map[string]any{"type": "number"}},
+		{goType: "any", want: map[string]any{}},
+		{goType: "interface{}", want: map[string]any{}},
+		{goType: "[]byte", want: map[string]any{"type": "string", "contentEncoding": "base64"}},
+		{goType: "time.Time", want: map[string]any{"type": "string", "format": "date-time"}},
+		{goType: "[]int", want: map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}},
+		{goType: "[3]string", want: map[string]any{"type": "array", "items": map[string]any{"type": "string"}}},
+		{goType: "[]interface{}", want: map[string]any{"type": "array", "items": map[string]any{}}},
+		{goType: "map[string]float64", want: map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number"}}},
+		{goType: "map[string]interface{}", want: map[string]any{"type": "object", "additionalProperties": map[string]any{}}},
+		{goType: "models.Order", want: map[string]any{"$ref": "models.Order.json"}},
+		{goType: "[]*models.Order", want: map[string]any{"type": "array", "items": map[string]any{"$ref": "models.Order.json"}}},
+		{goType: "models.Unknown", want: map[string]any{"type": "object"}},
+		{goType: "integer", want: map[string]any{"type": "object"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goType, func(t *testing.T) {
+			if got := jsonSchema(tt.goType, ref); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoTypeSchema(t *testing.T) {
+	structs := map[string][]Field{"models.Order": {{Name: "ID", Type: "string"}}}
+	tests := []struct {
+		goType string
+		want   *TypeSchema
+	}{
+		{goType: "string", want: &TypeSchema{Kind: SchemaKindString}},
+		{goType: "*int64", want: &TypeSchema{Kind: SchemaKindInteger, Optional: true}},
+		{goType: "float64", want: &TypeSchema{Kind: SchemaKindNumber}},
+		{goType: "interface{}", want: &TypeSchema{Kind: SchemaKindAny}},
+		{goType: "[]byte", want: &TypeSchema{Kind: SchemaKindBytes}},
+		{goType: "time.Time", want: &TypeSchema{Kind: SchemaKindTime, Type: "time.Time"}},
+		{goType: "[]string", want: &TypeSchema{Kind: SchemaKindArray, Elem: &TypeSchema{Kind: SchemaKindString}}},
+		{goType: "map[string]*models.Order", want: &TypeSchema{
+			Kind: SchemaKindMap,
+			Key:  &TypeSchema{Kind: SchemaKindString},
+			Elem: &TypeSchema{Kind: SchemaKindStruct, Type: "models.Order", Optional: true},
+		}},
+		{goType: "models.Unknown", want: &TypeSchema{Kind: SchemaKindAny, Type: "models.Unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goType, func(t *testing.T) {
+			if got := goTypeSchema(tt.goType, structs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructSchemaRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		field Field
+		want  []string
+	}{
+		{name: "value", field: Field{Name: "ID", Type: "string"}, want: []string{"ID"}},
+		{name: "renamed", field: Field{Name: "ID", Type: "string", Tag: `json:"id"`}, want: []string{"id"}},
+		{name: "pointer", field: Field{Name: "Note", Type: "*string"}},
+		{name: "omitempty", field: Field{Name: "Note", Type: "string", Tag: `json:"note,omitempty"`}},
+		{name: "required pointer", field: Field{Name: "Note", Type: "*string", Tag: `json:"note" validate:"required"`}, want: []string{"note"}},
+		{name: "required omitempty", field: Field{Name: "Note", Type: "string", Tag: `json:"note,omitempty" validate:"required,min=1"`}, want: []string{"note"}},
+	}
+	noRef := func(string) (string, bool) { return "", false }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := structSchema([]Field{tt.field}, noRef, rootPointer())
+			got, _ := schema["required"].([]string)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got required %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructSchemaSkipsIgnoredFields(t *testing.T) {
+	fields := []Field{
+		{Name: "ID", Type: "string", Tag: `json:"id"`},
+		{Name: "Secret", Type: "string", Tag: `json:"-"`},
+		{Name: "Dash", Type: "string", Tag: `json:"-,"`},
+	}
+	schema := structSchema(fields, func(string) (string, bool) { return "", false }, rootPointer())
+	properties := schema["properties"].(map[string]any)
+	if _, ok := properties["Secret"]; ok {
+		t.Error("got the field tagged json:\"-\"")
+	}
+	for _, name := range []string{"id", "-"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("missing property %q", name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SDKModule is the module of the polycode SDK the generated wrappers depend on by default
+const SDKModule = "github.com/cloudimpl/next-coder-sdk"
+
+// DefaultSDKImport is the import path of the polycode package of the SDK
+const DefaultSDKImport = SDKModule + "/polycode"
+
+// DefaultSDKVersion is the SDK version fetched when bootstrapping an app without a minimum version, pinned
+// so bootstraps are reproducible. The generated code does not assume its APIs: those newer releases added
+// are looked up in the SDK the app requires, see SDKFeatures.
+const DefaultSDKVersion = "v1.4.0"
+
+// sdkImportSpec returns the import spec of the polycode package, aliased to polycode when the last
+// element of its path differs, since the generated code refers to it as polycode
+func sdkImportSpec(importPath string) string {
+	if path.Base(importPath) == "polycode" {
+		return strconv.Quote(importPath)
+	}
+	return "polycode " + strconv.Quote(importPath)
+}
+
+// validateSDK checks the SDK import path and the minimum version of the options
+func validateSDK(importPath string, version string) error {
+	if err := module.CheckImportPath(importPath); err != nil {
+		return fmt.Errorf("invalid SDK import path: %w", err)
+	}
+	if version != "" && !semver.IsValid(version) {
+		return fmt.Errorf("invalid SDK version %q, expected a semantic version like v1.4.0", version)
+	}
+	return nil
+}
+
+// ModuleRequirement is the requirement of the app's go.mod on the module providing a package the
+// generated code imports, like the polycode package of the SDK
+type ModuleRequirement struct {
+	Import  string // Import path of the package
+	Module  string // Required module providing the package, empty when go.mod requires none
+	Version string // Version go.mod requires
+	Minimum string // Minimum compatible version, empty when any version is
+	Default string // Version fetched when there is no minimum, latest when empty
+	Local   bool   // The module is replaced by a local folder, its version is not checked
+}
+
+// Satisfied reports whether go.mod requires the SDK at a compatible version
+func (r ModuleRequirement) Satisfied() bool {
+	if r.Module == "" {
+		return false
+	}
+	return r.Local || r.Minimum == "" || semver.Compare(r.Version, r.Minimum) >= 0
+}
+
+// GetCommand returns the go get command adding or upgrading the requirement
+func (r ModuleRequirement) GetCommand() string {
+	version := r.Minimum
+	if version == "" {
+		version = r.Default
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return "go get " + r.Import + "@" + version
+}
+
+// CheckSDKRequirement reads which module of the app's go.mod provides the polycode package of
+// opts.SDKImport and whether its version is at least opts.SDKVersion, DefaultSDKVersion is fetched when
+// there is no minimum
+func CheckSDKRequirement(appPath string, opts Options) (ModuleRequirement, error) {
+	req, err := CheckModuleRequirement(appPath, opts.SDKImport, opts.SDKVersion)
+	req.Default = DefaultSDKVersion
+	return req, err
+}
+
+// CheckModuleRequirement reads which module of the app's go.mod provides the package importPath and
+// whether its version is at least minimum, any version is compatible when minimum is empty
+func CheckModuleRequirement(appPath string, importPath string, minimum string) (ModuleRequirement, error) {
+	req := ModuleRequirement{Import: importPath, Minimum: minimum}
+	goModPath := filepath.Join(appPath, "go.mod")
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return req, fmt.Errorf("failed to open go.mod file: %w", err)
+	}
+	file, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return req, fmt.Errorf("error reading go.mod file: %w", err)
+	}
+
+	// The module providing a package is the required module with the longest matching path
+	for _, require := range file.Require {
+		path := require.Mod.Path
+		if (req.Import == path || strings.HasPrefix(req.Import, path+"/")) && len(path) > len(req.Module) {
+			req.Module, req.Version = path, require.Mod.Version
+		}
+	}
+	for _, replace := range file.Replace {
+		if replace.Old.Path == req.Module && replace.New.Version == "" {
+			req.Local = true
+		}
+	}
+	return req, nil
+}
+
+// BootstrapRequirement adds or upgrades a requirement of the app by running go get
+func BootstrapRequirement(appPath string, req ModuleRequirement) error {
+	args := strings.Fields(req.GetCommand())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = appPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", req.GetCommand(), err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"strings"
+)
+
+// SDKFeatures are the APIs of the polycode package that older SDK releases lack, looked up in the SDK
+// version the app requires. The wrappers only use those it provides, and services using the others fail
+// with the SDK upgrade they need instead of generating code that does not compile.
+type SDKFeatures struct {
+	Stream         bool // polycode.Stream[T], the streaming parameters and results of service functions
+	LifecycleAware bool // polycode.LifecycleAware, implemented by wrappers with OnStart and OnStop hooks
+	Policy         bool // polycode.Policy and polycode.RetryPolicy, returned by GetMethodPolicy
+}
+
+// findSDKFeatures looks up the optional APIs in the polycode package of the loaded packages, found by
+// name like the context types
+func findSDKFeatures(pkgs []*packages.Package) SDKFeatures {
+	var features SDKFeatures
+	visitTypes(pkgs, func(pkg *types.Package) {
+		if pkg.Name() != "polycode" {
+			return
+		}
+		declares := func(name string) bool {
+			_, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			return ok
+		}
+		features.Stream = features.Stream || declares("Stream")
+		features.LifecycleAware = features.LifecycleAware || declares("LifecycleAware")
+		features.Policy = features.Policy || declares("Policy") && declares("RetryPolicy")
+	})
+	return features
+}
+
+// check fails for a service using APIs the SDK lacks, naming what uses them
+func (f SDKFeatures) check(parsed parsedService, sdkImport string) error {
+	var missing []string
+	if !f.Stream && len(parsed.Streams) > 0 {
+		missing = append(missing, fmt.Sprintf("polycode.Stream, used by %s (use a channel instead)", strings.Join(parsed.Streams, ", ")))
+	}
+	if !f.LifecycleAware && len(parsed.Lifecycle) > 0 {
+		missing = append(missing, fmt.Sprintf("polycode.LifecycleAware, needed by %s", strings.Join(parsed.Lifecycle, ", ")))
+	}
+	if !f.Policy {
+		var declared []string
+		for _, method := range parsed.Methods {
+			if method.Timeout > 0 || method.Retry != nil {
+				declared = append(declared, method.OriginalName)
+			}
+		}
+		if len(declared) > 0 {
+			missing = append(missing, fmt.Sprintf("polycode.Policy, needed by the //polycode:timeout and //polycode:retry directives of %s", strings.Join(declared, ", ")))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the SDK version required by go.mod lacks %s, upgrade it with go get %s@latest", strings.Join(missing, " and "), sdkImport)
+	}
+	return nil
+}
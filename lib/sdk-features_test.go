@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"go/token"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sdkPackage returns an app package importing a polycode package that declares the named types
+func sdkPackage(names ...string) []*packages.Package {
+	sdk := types.NewPackage(DefaultSDKImport, "polycode")
+	for _, name := range names {
+		sdk.Scope().Insert(types.NewTypeName(token.NoPos, sdk, name, types.Typ[types.Int]))
+	}
+	app := types.NewPackage("example.com/app/services/orders", "orders")
+	app.SetImports([]*types.Package{sdk})
+	return []*packages.Package{{PkgPath: app.Path(), Types: app}}
+}
+
+func TestFindSDKFeatures(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  SDKFeatures
+	}{
+		{name: "no optional APIs", types: []string{"ServiceContext", "WorkflowContext"}},
+		{name: "all", types: []string{"Stream", "LifecycleAware", "Policy", "RetryPolicy"}, want: SDKFeatures{Stream: true, LifecycleAware: true, Policy: true}},
+		{name: "policy without retry policy", types: []string{"Policy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findSDKFeatures(sdkPackage(tt.types...)); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSDKFeaturesCheck(t *testing.T) {
+	all := SDKFeatures{Stream: true, LifecycleAware: true, Policy: true}
+	tests := []struct {
+		name     string
+		features SDKFeatures
+		parsed   parsedService
+		wantErr  string
+	}{
+		{name: "nothing used", parsed: parsedService{Methods: []MethodInfo{{OriginalName: "Create"}}}},
+		{name: "all provided", features: all, parsed: parsedService{Streams: []string{"Watch"}, Lifecycle: []string{"OnStart"}, Methods: []MethodInfo{{OriginalName: "Create", Timeout: time.Second}}}},
+		{name: "stream", parsed: parsedService{Streams: []string{"Watch"}}, wantErr: "lacks polycode.Stream, used by Watch (use a channel instead)"},
+		{name: "lifecycle", parsed: parsedService{Lifecycle: []string{"OnStart", "OnStop"}}, wantErr: "lacks polycode.LifecycleAware, needed by OnStart, OnStop"},
+		{name: "timeout", parsed: parsedService{Methods: []MethodInfo{{OriginalName: "Create", Timeout: time.Second}, {OriginalName: "Get"}}}, wantErr: "lacks polycode.Policy, needed by the //polycode:timeout and //polycode:retry directives of Create,"},
+		{name: "retry", parsed: parsedService{Methods: []MethodInfo{{OriginalName: "Create", Retry: &RetryPolicy{MaxAttempts: 3}}}}, wantErr: "directives of Create, upgrade it with go get " + DefaultSDKImport + "@latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.features.check(tt.parsed, DefaultSDKImport)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDirStreams(t *testing.T) {
+	parsed, err := parseSource(t, "func Watch(ctx polycode.ServiceContext, in polycode.Stream[int]) (<-chan string, error) { return nil, nil }\n\n"+
+		"func Feed(ctx polycode.ServiceContext, in <-chan int) (polycode.Stream[string], error) { return nil, nil }\n\n"+
+		"func Pipe(ctx polycode.ServiceContext, in <-chan int) (<-chan int, error) { return nil, nil }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(parsed.Streams, ","), "Watch,Feed"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestWrapperMethodPolicy(t *testing.T) {
+	parsed, err := parseSource(t, "//polycode:timeout 2s\nfunc Create(ctx polycode.ServiceContext) error { return nil }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []bool{true, false} {
+		info := newServiceInfo("example.com/app", "orders", "services/orders", parsed.Methods, parsed.Imports, DefaultOptions())
+		info.SDK.Policy = policy
+		code, err := generateServiceCode(info, wrapperTemplate, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(code, "GetMethodPolicy"); got != policy {
+			t.Errorf("with Policy %v the wrapper declares GetMethodPolicy: %v", policy, got)
+		}
+
+		_, err = activityTarget{}.Generate(info, ServiceDefinition{})
+		if got := err == nil; got != policy {
+			t.Errorf("with Policy %v the activities target fails with %v", policy, err)
+		}
+	}
+}
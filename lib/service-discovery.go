@@ -0,0 +1,185 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNoServicesFolder is returned by discoverServices when none of the services roots exist
+var errNoServicesFolder = errors.New("no services folder found")
+
+// serviceEntry is a service package found below one of the services roots
+type serviceEntry struct {
+	Name      string // Registered name, derived from the folder with nested folders joined with "-" unless declared
+	Dir       string // Folder relative to the app root, slash separated
+	Namespace string // Declared with //polycode:service
+	Version   string // Declared with //polycode:service
+}
+
+// serviceNameForDir derives a service name from a folder relative to its root, billing/invoices becomes billing-invoices
+func serviceNameForDir(rel string) string {
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+}
+
+// discoverServices finds the service packages below the services roots, so domains can group services
+// in nested folders. A folder right below a root holding Go files is a service, a nested folder only
+// when it is a service package, the others like helper packages of a service are walked as groups.
+func discoverServices(appPath string, opts Options) ([]serviceEntry, error) {
+	var entries []serviceEntry
+	owners := make(map[string]string)
+	found := false
+
+	for _, root := range opts.ServicesDirs {
+		rootPath := filepath.Join(appPath, root)
+		if _, err := os.Stat(rootPath); os.IsNotExist(err) {
+			continue
+		}
+		found = true
+
+		err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(rootPath, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata" || d.Name() == "vendor" || isExcluded(rel, opts.Exclude) {
+				return filepath.SkipDir
+			}
+
+			ok, err := hasGoFiles(path)
+			if err != nil || !ok {
+				return err
+			}
+			if strings.ContainsRune(rel, filepath.Separator) {
+				if ok, err = isServicePackage(path, opts.SDKImport); err != nil || !ok {
+					return err
+				}
+			}
+
+			entry := serviceEntry{Dir: filepath.ToSlash(filepath.Join(root, rel))}
+			id, err := readServiceIdentity(path, serviceNameForDir(rel))
+			if err != nil {
+				return fmt.Errorf("service %s: %w", entry.Dir, err)
+			}
+			entry.Name, entry.Namespace, entry.Version = id.String(), id.Namespace, id.Version
+			// Names differing by slashes only would share their generated files
+			if owner, ok := owners[serviceFileName(entry.Name)]; ok {
+				return fmt.Errorf("service name collision: %s and %s are both named %q", owner, entry.Dir, entry.Name)
+			}
+			owners[serviceFileName(entry.Name)] = entry.Dir
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, errNoServicesFolder
+	}
+	return entries, nil
+}
+
+// hasGoFiles reports whether a folder directly contains Go files other than tests
+func hasGoFiles(dir string) (bool, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if !file.IsDir() && IsGoFile(file.Name()) && !strings.HasSuffix(file.Name(), "_test.go") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isServicePackage reports whether the package in a folder declares //polycode:service or has an
+// exported function or method taking a polycode context. Contexts are recognized by the SDK import,
+// a package using aliases of them declares //polycode:service to be found in a nested folder.
+func isServicePackage(dir string, sdkImport string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(fset, file, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := parseDirectives(node.Doc)["service"]; ok {
+			return true, nil
+		}
+		imports := importPaths(node)
+		for _, decl := range node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !fn.Name.IsExported() || fn.Type.Params == nil || len(fn.Type.Params.List) == 0 {
+				continue
+			}
+			switch sdkTypeName(fn.Type.Params.List[0].Type, imports, sdkImport) {
+			case "ServiceContext", "WorkflowContext":
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ServiceForPath returns the service owning a path below one of the services roots
+func ServiceForPath(appPath string, opts Options, path string) (string, bool) {
+	appPath, err := NormalizeAppPath(appPath)
+	if err != nil {
+		return "", false
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", false
+	}
+	for _, root := range opts.ServicesDirs {
+		rel, err := filepath.Rel(filepath.Join(appPath, root), path)
+		if err != nil || rel == "." || isOutside(rel) {
+			continue
+		}
+
+		// A folder names its own service, a file belongs to the service of its folder
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			rel = filepath.Dir(rel)
+		}
+		// A helper package nested in a service belongs to the closest service above it
+		for strings.ContainsRune(rel, filepath.Separator) {
+			if ok, err := isServicePackage(filepath.Join(appPath, root, rel), opts.SDKImport); err != nil || ok {
+				break
+			}
+			rel = filepath.Dir(rel)
+		}
+		if rel == "." {
+			// A file directly inside a services root does not belong to a service
+			return "", false
+		}
+		id, err := readServiceIdentity(filepath.Join(appPath, root, rel), serviceNameForDir(rel))
+		if err != nil {
+			// Regenerating the whole app reports the error
+			return "", false
+		}
+		return id.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverServicesNested(t *testing.T) {
+	appPath := t.TempDir()
+	service := "package %s\n\nimport sdk \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc Ping(ctx sdk.ServiceContext) error { return nil }\n"
+	files := map[string]string{
+		"services/orders/orders.go":               fmt.Sprintf(service, "orders"),
+		"services/orders/internal/db/db.go":       "package db\n\nfunc Open() error { return nil }\n",
+		"services/orders/util/util.go":            "package util\n\nimport \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc helper(ctx polycode.ServiceContext) {}\n",
+		"services/billing/invoices/invoices.go":   fmt.Sprintf(service, "invoices"),
+		"services/billing/payments/payments.go":   "// Package payments declares its service without context functions yet\n//\n//polycode:service name=payments\npackage payments\n",
+		"services/billing/invoices/pdf/render.go": "package pdf\n\nfunc Render() {}\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(appPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := discoverServices(appPath, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		dirs = append(dirs, entry.Dir)
+	}
+	want := []string{"services/billing/invoices", "services/billing/payments", "services/orders"}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("got services %v, want %v", dirs, want)
+	}
+
+	// A file of a helper package belongs to the service above it
+	name, ok := ServiceForPath(appPath, DefaultOptions(), filepath.Join(appPath, "services", "orders", "internal", "db", "db.go"))
+	if !ok || name != "orders" {
+		t.Errorf("got service %q, %v for the helper package, want orders", name, ok)
+	}
+}
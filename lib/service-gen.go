@@ -2,186 +2,38 @@ package lib
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"golang.org/x/tools/go/packages"
+	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
-	"text/template"
-	"unicode"
-)
-
-type MethodInfo struct {
-	OriginalName      string
-	Name              string
-	Description       string
-	InputType         string
-	IsInputPointer    bool
-	IsInputPrimitive  bool
-	OutputType        string
-	IsOutputPointer   bool
-	IsOutputPrimitive bool
-	IsWorkflow        bool
-	IsService         bool
-}
-
-type ServiceInfo struct {
-	ModuleName        string
-	ServiceName       string
-	ServiceStructName string
-	Methods           []MethodInfo
-	IsProduction      bool // New flag to determine if we are in production mode
-	Imports           []string
-}
-
-const wrapperTemplate = `package _polycode
-
-import (
-	"errors"
-	"github.com/cloudimpl/next-coder-sdk/polycode"
-	"strings"
-    service "{{.ModuleName}}/services/{{.ServiceName}}"
-	{{range .Imports}}"{{.}}"
-	{{end}}
+	"sync/atomic"
+	"time"
 )
 
-func init() {
-	polycode.RegisterService(&{{.ServiceStructName}}{})
-}
-
-type {{.ServiceStructName}} struct {
-}
-
-func (t *{{.ServiceStructName}}) GetName() string {
-	return "{{.ServiceName}}"
-}
-
-func (t *{{.ServiceStructName}}) GetDescription(method string) (string, error) {
-	method = strings.ToLower(method)
-	switch method {
-	{{range .Methods}}case "{{.Name}}":
-		{
-			return "{{.Description}}", nil
-		}
-	{{end}}default:
-		{
-			return "", errors.New("method not found")
-		}
-	}
-}
-
-func (t *{{.ServiceStructName}}) GetInputType(method string) (any, error) {
-	method = strings.ToLower(method)
-	switch method {
-	{{range .Methods}}case "{{.Name}}":
-		{
-			return &{{.InputType}}{}, nil
-		}
-	{{end}}default:
-		{
-			return nil, errors.New("method not found")
-		}
-	}
-}
-
-func (t *{{.ServiceStructName}}) GetOutputType(method string) (any, error) {
-	switch strings.ToLower(method) {
-	{{range .Methods}}
-	case "{{.Name}}":
-		{{if .IsOutputPrimitive}}
-		var v {{.OutputType}}
-		return &v, nil
-		{{else}}
-		return &{{.OutputType}}{}, nil
-		{{end}}
-	{{end}}
-	default:
-		return nil, fmt.Errorf("method %q not found", method)
-	}
-}
-
-// ExecuteService handles methods with polycode.ServiceContext as the first parameter
-func (t *{{.ServiceStructName}}) ExecuteService(ctx polycode.ServiceContext, method string, input any) (any, error) {
-	method = strings.ToLower(method)
-
-	{{if .IsProduction}}
-	// Handle @definition case
-	if method == "@definition" {
-		return []string{
-			{{range .Methods}}"{{.OriginalName}}",
-			{{end}}
-		}, nil
-	}
-	{{end}}
-
-	switch method {
-	{{range .Methods}}{{if .IsService}}case "{{.Name}}":
-		{
-			// Pass the input correctly as a pointer or value based on the method signature
-			{{if .IsInputPointer}}
-			return service.{{.OriginalName}}(ctx, input.(*{{.InputType}}))
-			{{else}}
-			return service.{{.OriginalName}}(ctx, *(input.(*{{.InputType}})))
-			{{end}}
-		}
-		{{end}}{{end}}default:
-		{
-			return nil, errors.New("method not found")
-		}
-	}
-}
-
-// ExecuteWorkflow handles methods with polycode.WorkflowContext as the first parameter
-func (t *{{.ServiceStructName}}) ExecuteWorkflow(ctx polycode.WorkflowContext, method string, input any) (any, error) {
-	method = strings.ToLower(method)
-
-	switch method {
-	{{range .Methods}}{{if .IsWorkflow}}case "{{.Name}}":
-		{
-			// Pass the input correctly as a pointer or value based on the method signature
-			{{if .IsInputPointer}}
-			return service.{{.OriginalName}}(ctx, input.(*{{.InputType}}))
-			{{else}}
-			return service.{{.OriginalName}}(ctx, *(input.(*{{.InputType}})))
-			{{end}}
+// checkInterfaceTypes rejects interface inputs, which cannot be decoded, and marks interface outputs.
+// Interface outputs are allowed but get no schema since their dynamic type is unknown.
+func checkInterfaceTypes(methods []MethodInfo, interfaces map[string]bool) error {
+	for i, method := range methods {
+		if interfaces[method.InputType] {
+			return fmt.Errorf("function %s: input %s is an interface and cannot be decoded, use a concrete type", method.OriginalName, method.InputType)
 		}
-		{{end}}{{end}}default:
-		{
-			return nil, errors.New("method not found")
-		}
-	}
-}
-
-// IsWorkflow checks whether the method is a workflow (i.e., its first parameter is polycode.WorkflowContext)
-func (t *{{.ServiceStructName}}) IsWorkflow(method string)bool {
-	method = strings.ToLower(method)
-	switch method {
-	{{range .Methods}}{{if .IsWorkflow}}case "{{.Name}}":
-		{
-			return true
+		for _, param := range method.Params {
+			if interfaces[strings.TrimPrefix(param.Type, "*")] {
+				return fmt.Errorf("function %s: parameter %s is an interface and cannot be decoded, use a concrete type", method.OriginalName, param.JSONName)
+			}
 		}
-		{{end}}{{end}}
-	}
-	return false
-}
-`
-
-// extractDescriptionFromComments extracts the @description value from []*ast.Comment.
-func extractDescriptionFromComments(comments []*ast.Comment) string {
-	for _, c := range comments {
-		line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
-		line = strings.TrimSpace(strings.TrimPrefix(line, "/*")) // handle block comment
-		line = strings.TrimSpace(strings.TrimSuffix(line, "*/"))
-
-		if strings.HasPrefix(line, "@description") {
-			return strings.TrimSpace(strings.TrimPrefix(line, "@description"))
+		if interfaces[method.OutputType] {
+			slog.Warn("Output is an interface, its schema is unknown", "method", method.OriginalName, "type", method.OutputType)
+			methods[i].IsOutputInterface = true
 		}
 	}
-	return ""
+	return nil
 }
 
 // GetModuleName reads the go.mod file and extracts the module name
@@ -215,237 +67,647 @@ func getModuleName(filePath string) (string, error) {
 	return "", fmt.Errorf("module name not found in go.mod")
 }
 
-func generateService(appPath string, servicePath string, moduleName string, serviceName string, prod bool) error {
-	methods, imports, err := parseDir(servicePath)
+// moduleContext is what the services of a module are generated from, shared by the workers
+type moduleContext struct {
+	output       outputFS
+	appPath      string
+	moduleName   string
+	structs      map[string][]Field
+	interfaces   map[string]bool
+	typeParams   map[string][]string
+	events       map[string]EventType
+	contexts     contextTypes
+	sdk          SDKFeatures
+	requirements *moduleRequirements
+	cache        *buildCache
+	opts         Options
+}
+
+// generateService writes the outputs of a service and reports their paths relative to the output folder.
+// When the cache shows the inputs did not change since the previous files were written, nothing is written
+// and the service is reported unchanged.
+func generateService(mod *moduleContext, entry serviceEntry, previous []string) (ServiceReport, error) {
+	output, appPath, moduleName, opts := mod.output, mod.appPath, mod.moduleName, mod.opts
+	serviceName, serviceDir := entry.Name, entry.Dir
+	report := ServiceReport{Service: serviceName, Status: ServiceGenerated}
+	servicePath := filepath.Join(appPath, serviceDir)
+	wrapperPackage := moduleName + "/" + filepath.ToSlash(filepath.Clean(opts.OutputDir))
+	parsed, err := parseDir(servicePath, servicePackagePath(moduleName, serviceDir), wrapperPackage, opts.Exclude, mod.contexts, opts.SDKImport)
 	if err != nil {
-		fmt.Printf("Error parsing directory: %v\n", err)
-		return err
+		slog.Error("Error parsing directory", "error", err)
+		return report, err
+	}
+
+	methods, imports, skipped := parsed.Methods, parsed.Imports, parsed.Skipped
+	for i := range skipped {
+		if rel, err := filepath.Rel(appPath, skipped[i].File); err == nil {
+			skipped[i].File = filepath.ToSlash(rel)
+		}
+	}
+	if len(skipped) > 0 && opts.Strict {
+		return report, unsupportedShapesError(skipped)
+	}
+	for _, s := range skipped {
+		slog.Warn("Exported function is not exposed", "service", serviceName, "position", fmt.Sprintf("%s:%d", s.File, s.Line), "function", s.Name, "reason", s.Reason)
+	}
+	report.Skipped = skipped
+	if err = mod.sdk.check(parsed, opts.SDKImport); err != nil {
+		return report, err
 	}
 
 	if methods == nil {
-		fmt.Printf("No methods found in the directory\n")
-		return nil
+		slog.Warn("No methods found in the directory", "service", serviceName, "path", servicePath)
+		report.Warnings = append(report.Warnings, "no methods found in "+serviceDir)
+		report.Status = ServiceEmpty
+		return report, nil
 	}
 
-	generatedCode, err := generateServiceCode(moduleName, serviceName, methods, imports, prod)
+	// Types are looked up by the names the service writes them with, packages of the same name are
+	// told apart by the import of the service. The wrapper refers to the types of the service package
+	// through its service import and definitions by the package name.
+	packageName, err := servicePackageName(servicePath)
 	if err != nil {
-		fmt.Printf("Error generating code: %v\n", err)
-		return err
+		return report, err
+	}
+	localImports := append(slices.Clip(imports), fmt.Sprintf("%s %q", packageName, servicePackagePath(moduleName, serviceDir)))
+	structs, interfaces, events := localTypes(mod.structs, localImports), localTypes(mod.interfaces, localImports), localTypes(mod.events, localImports)
+	typeParams := localTypes(mod.typeParams, localImports)
+	if err = instantiateStructs(methods, structs, typeParams); err != nil {
+		return report, err
+	}
+
+	if err = checkInterfaceTypes(methods, interfaces); err != nil {
+		return report, err
+	}
+	if err = mod.requirements.checkSignatureImports(imports); err != nil {
+		return report, err
+	}
+	for _, method := range methods {
+		if method.IsOutputInterface {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("function %s: output %s is an interface, its schema is unknown", method.OriginalName, method.OutputType))
+		}
 	}
 
-	err = os.MkdirAll(appPath+"/.polycode", 0755)
+	issues, err := checkDeterminism(appPath, servicePath, servicePackagePath(moduleName, serviceDir), opts.Exclude, mod.contexts)
 	if err != nil {
-		fmt.Printf("Error creating directory: %v\n", err)
-		return err
+		return report, err
+	}
+	if len(issues) > 0 && opts.Strict {
+		return report, determinismError(issues)
+	}
+	for _, issue := range issues {
+		slog.Warn("Non-deterministic code in workflow", "service", serviceName, "position", issue.Position.String(), "function", issue.Function, "call", issue.Construct, "reason", issue.Reason)
+		report.Warnings = append(report.Warnings, issue.String())
 	}
 
-	err = os.WriteFile(appPath+"/.polycode/"+serviceName+".go", []byte(generatedCode), 0644)
+	catalog, err := parseErrors(servicePath, opts.Exclude)
 	if err != nil {
-		fmt.Printf("Error writing file: %v\n", err)
-		return err
+		return report, err
+	}
+	if err = resolveSubscriptions(methods, events); err != nil {
+		return report, err
+	}
+	published, err := publishedEvents(servicePath, opts.Exclude, wrapperPackage+"/"+eventsPackage, events)
+	if err != nil {
+		return report, err
 	}
 
-	return nil
-}
+	serviceInfo := newServiceInfo(moduleName, serviceName, serviceDir, methods, imports, opts)
+	report.countMethods(serviceInfo.Methods)
+	serviceInfo.Lifecycle = parsed.Lifecycle
+	serviceInfo.Receiver = parsed.Receiver
+	serviceInfo.Middleware = parsed.Middleware
+	serviceInfo.HealthCheck = parsed.Health
+	serviceInfo.SDK = mod.sdk
+	if opts.ErrorCodes {
+		serviceInfo.Errors = catalog
+	}
+	checks := make(map[string][]ValidationCheck)
+	for i, method := range serviceInfo.Methods {
+		if method.HasInput && !method.IsInputPrimitive && !method.IsMultiInput && !method.IsStreaming() {
+			// Collection inputs are checked element by element
+			checked := method.InputType
+			if method.InputCollection != "" {
+				checked = strings.TrimPrefix(method.InputElemType, "*")
+			}
+			if _, ok := checks[checked]; !ok {
+				checks[checked] = validationChecks(checked, structs[checked])
+			}
+			serviceInfo.Methods[i].Validations = checks[checked]
+		}
+	}
+	defInfo := definitionInfo(serviceInfo, packageName)
+	if err = instantiateStructs(slices.Concat(defInfo.Methods, defInfo.Signals, defInfo.Queries), structs, typeParams); err != nil {
+		return report, err
+	}
+	def := buildServiceDefinition(serviceName, defInfo, structs)
+	def.Namespace, def.Version = entry.Namespace, entry.Version
+	if def.Routes, err = serviceRoutes(serviceInfo.Methods); err != nil {
+		return report, err
+	}
+	def.Errors = catalog
+	def.Events = serviceEvents(published, serviceInfo.Subscribers, events, structs, def.Types)
 
-func GenerateServices(appPath string, prod bool) error {
-	moduleName, err := getModuleName(appPath + "/go.mod")
+	hash, err := serviceHash(serviceInfo, def, opts)
 	if err != nil {
-		fmt.Printf("Error getting module name: %v\n", err)
-		return err
+		return report, err
+	}
+	outputPath := filepath.Join(appPath, opts.OutputDir)
+	if !opts.NoCache && mod.cache.unchanged(serviceName, hash) && outputsExist(output, outputPath, previous) {
+		report.Status, report.Files = ServiceUnchanged, previous
+		return report, nil
 	}
 
-	polycodeFolder := filepath.Join(appPath, ".polycode")
-	servicesFolder := filepath.Join(appPath, "services")
+	for _, targetName := range opts.Targets {
+		target, err := resolveGenerator(targetName, opts)
+		if err != nil {
+			return report, err
+		}
 
-	if _, err = os.Stat(servicesFolder); os.IsNotExist(err) {
-		println("No services folder found")
-	} else {
-		entries, err := os.ReadDir(servicesFolder)
+		targetFiles, err := target.Generate(serviceInfo, def)
 		if err != nil {
-			fmt.Printf("Error reading directory: %v\n", err)
-			return err
+			slog.Error("Error generating code", "service", serviceName, "target", targetName, "error", err)
+			return report, err
 		}
 
-		for i, entry := range entries {
-			fmt.Printf("Processing entry [%d/%d]", i+1, len(entries))
-			if entry.IsDir() {
-				servicePath := filepath.Join(servicesFolder, entry.Name())
-				println("Generating code for path: ", servicePath)
-				serviceName := entry.Name()
-				err = generateService(appPath, servicePath, moduleName, serviceName, prod)
-				if err != nil {
-					fmt.Printf("Error generating service: %v\n", err)
-					return err
-				}
-				println("Generated code for path: ", servicePath)
-			}
+		files, err := writeTargetFiles(output, filepath.Join(appPath, opts.OutputDir), targetName, targetFiles, opts)
+		report.Files = append(report.Files, files...)
+		if err != nil {
+			return report, err
 		}
+	}
 
-		println("Finished generating code for services")
+	definitions, err := writeServiceDefinition(output, filepath.Join(appPath, opts.OutputDir), def, opts.DefinitionFormats, opts.signingKey)
+	if err != nil {
+		slog.Error("Error writing service definition", "error", err)
+		return report, err
 	}
+	report.Files = append(report.Files, definitions...)
 
-	if _, err = os.Stat(polycodeFolder); !os.IsNotExist(err) {
-		println("Cleaning up imports")
-		err = runGoImports(polycodeFolder)
-		if err != nil {
-			fmt.Printf("Error cleaning up imports: %v\n", err)
-			return err
+	if opts.GenTests {
+		if err = writeTestScaffold(output, servicePath, serviceInfo); err != nil {
+			slog.Error("Error writing test scaffold", "error", err)
+			return report, err
 		}
-		println("Imports cleaned")
 	}
 
-	return nil
+	mod.cache.set(serviceName, hash)
+	return report, nil
 }
 
-// Modified validateFunctionParams to check for polycode.ServiceContext or polycode.WorkflowContext
-func validateFunctionParams(fn *ast.FuncDecl) (string, error) {
-	// Check if there are at least two parameters (ctx and input)
-	if fn.Type.Params == nil || len(fn.Type.Params.List) < 2 {
-		return "", fmt.Errorf("function %s does not have enough parameters", fn.Name.Name)
-	}
-
-	// Validate the first parameter type
-	firstParam := fn.Type.Params.List[0].Type
-	if starExpr, ok := firstParam.(*ast.SelectorExpr); ok {
-		if starExpr.X.(*ast.Ident).Name == "polycode" {
-			// Check if the first parameter is either ServiceContext or WorkflowContext
-			if starExpr.Sel.Name == "ServiceContext" {
-				return "Service", nil
-			} else if starExpr.Sel.Name == "WorkflowContext" {
-				return "Workflow", nil
-			} else {
-				return "", fmt.Errorf("function %s: first parameter must be polycode.ServiceContext or polycode.WorkflowContext", fn.Name.Name)
-			}
+// GenerateServices generates every service of the app with the default options, production wrappers
+// when prod is set. Build tools embedding the generator use pkg/generator instead.
+func GenerateServices(appPath string, prod bool) error {
+	opts := DefaultOptions()
+	opts.Production = prod
+	_, err := Generate(context.Background(), appPath, nil, opts)
+	return err
+}
+
+// Generate generates the named services, or every service when services is nil, and reports per service
+// its outcome, method counts, files written and duration. The report is returned along with a
+// *GenerationError when some services failed. Services not started when ctx is done are skipped and its
+// error is returned. It backs the CLI and pkg/generator, which embedders use instead.
+func Generate(ctx context.Context, appPath string, services []string, opts Options) (*Report, error) {
+	return generateServices(ctx, diskFS{}, appPath, services, opts)
+}
+
+// generateServices generates the given services, or all services when only is nil, and reports the
+// outcome of each service
+func generateServices(ctx context.Context, output outputFS, appPath string, only []string, opts Options) (*Report, error) {
+	appPath, err := NormalizeAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkWrapperTemplates(opts); err != nil {
+		return nil, err
+	}
+	if opts.SignKey != "" {
+		if opts.signingKey, err = loadSigningKey(opts.SignKey); err != nil {
+			return nil, err
 		}
 	}
-	return "", fmt.Errorf("function %s: first parameter must be polycode.ServiceContext or polycode.WorkflowContext", fn.Name.Name)
-}
 
-func extractType(expr ast.Expr) (typeStr string, isPointer bool, isPrimitive bool) {
-	switch t := expr.(type) {
+	modules, err := resolveModules(appPath)
+	if err != nil {
+		slog.Error("Error resolving modules", "error", err)
+		return nil, err
+	}
 
-	case *ast.StarExpr:
-		innerType, _, primitive := extractType(t.X)
-		return innerType, true, primitive
+	// NEXTGEN_SERVICE lists the services requested before the run, it is empty when all of them are,
+	// and the services generated after it
+	hookEnv := map[string]string{"SERVICE": strings.Join(only, ","), "OUTPUT_DIR": opts.OutputDir}
+	if err = RunHooks(HookPreGenerate, opts.Hooks.PreGenerate, appPath, hookEnv); err != nil {
+		slog.Error("Error running hook", "error", err)
+		return nil, err
+	}
 
-	case *ast.SelectorExpr:
-		// Handles pkg.Type
-		if pkgIdent, ok := t.X.(*ast.Ident); ok {
-			typeName := fmt.Sprintf("%s.%s", pkgIdent.Name, t.Sel.Name)
-			return typeName, false, false
-		}
+	entries, err := discoverServices(appPath, opts)
+	discovered := err == nil
+	if errors.Is(err, errNoServicesFolder) {
+		slog.Warn("No services folder found", "roots", opts.ServicesDirs)
+	} else if err != nil {
+		slog.Error("Error discovering services", "error", err)
+		return nil, err
+	}
 
-		return t.Sel.Name, false, false
+	// Each module of a workspace is generated on its own, into the output folder inside the module
+	grouped, err := groupByModule(appPath, modules, entries)
+	if err != nil {
+		slog.Error("Error discovering services", "error", err)
+		return nil, err
+	}
 
-	case *ast.Ident:
-		// Handles builtin and local types
-		return t.Name, false, primitiveTypes[t.Name]
+	start := time.Now()
+	report := &Report{}
+	var failures []*ServiceError
+	var outputDirs []string
+	for _, module := range modules {
+		moduleEntries := grouped[module.Dir]
+		if len(modules) > 1 {
+			// Skip modules without selected services, unless their outputs of removed services need cleaning
+			if only != nil && !containsService(moduleEntries, only) || len(moduleEntries) == 0 && !output.Exists(filepath.Join(module.Dir, opts.OutputDir)) {
+				continue
+			}
+			slog.Info("Generating module", "module", module.Name, "dir", module.Dir)
+		}
 
-	case *ast.ArrayType:
-		elemType, _, _ := extractType(t.Elt)
-		return "[]" + elemType, false, false
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Watchers and manual runs writing the same output folder take turns
+		lock, err := lockOutput(ctx, output, filepath.Join(module.Dir, opts.OutputDir), opts.LockTimeout)
+		if err != nil {
+			slog.Error("Error locking output folder", "error", err)
+			return nil, err
+		}
+		moduleStart := time.Now()
+		moduleReports, moduleFailures, err := generateModule(ctx, output, module, moduleEntries, discovered, only, opts)
+		if err == nil && opts.Report == ReportJSON {
+			if err = writeGenerationReport(output, filepath.Join(module.Dir, opts.OutputDir), module.Name, moduleReports, moduleFailures, moduleStart); err != nil {
+				slog.Error("Error writing generation report", "error", err)
+			}
+		}
+		lock.unlock()
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range moduleReports {
+			if len(modules) > 1 {
+				service.Module = module.Name
+			}
+			report.Services = append(report.Services, service)
+		}
+		failures = append(failures, moduleFailures...)
+		if rel, err := filepath.Rel(appPath, filepath.Join(module.Dir, opts.OutputDir)); err == nil {
+			outputDirs = append(outputDirs, rel)
+		}
+	}
 
-	case *ast.MapType:
-		keyType, _, _ := extractType(t.Key)
-		valType, _, _ := extractType(t.Value)
-		return fmt.Sprintf("map[%s]%s", keyType, valType), false, false
+	report.Duration = time.Since(start)
+	if len(failures) > 0 {
+		return report, &GenerationError{Services: failures}
+	}
 
-	case *ast.InterfaceType:
-		return "interface{}", false, false
+	if len(opts.Analyzers) > 0 {
+		slog.Debug("Running static analysis", "analyzers", opts.Analyzers)
+		diags, err := runAnalyzers(appPath, opts.Analyzers, analysisPatterns(outputDirs, opts))
+		if err != nil {
+			slog.Error("Error running static analysis", "error", err)
+			return nil, err
+		}
+		if len(diags) > 0 {
+			return report, &DiagnosticsError{Diagnostics: diags}
+		}
+		slog.Info("Static analysis passed")
+	}
 
-	default:
-		return fmt.Sprintf("%T", t), false, false
+	hookEnv["SERVICE"] = strings.Join(report.Generated(), ",")
+	if err = RunHooks(HookPostGenerate, opts.Hooks.PostGenerate, appPath, hookEnv); err != nil {
+		slog.Error("Error running hook", "error", err)
+		return nil, err
 	}
-}
 
-var primitiveTypes = map[string]bool{
-	"string": true, "bool": true, "int": true, "int8": true, "int16": true,
-	"int32": true, "int64": true, "uint": true, "uint8": true, "uint16": true,
-	"uint32": true, "uint64": true, "float32": true, "float64": true,
-	"byte": true, "rune": true, "any": true, "interface{}": true,
+	report.Duration = time.Since(start)
+	return report, nil
 }
 
-// Updated parseDir function to mark methods as workflow or service
-func parseDir(serviceFolder string) ([]MethodInfo, []string, error) {
-	fset := token.NewFileSet()
+// generateModule generates the services of a module into its output folder, entries are relative to the module.
+// The reports and failures of single services are returned, other errors abort the run.
+func generateModule(ctx context.Context, output outputFS, module appModule, entries []serviceEntry, discovered bool, only []string, opts Options) ([]ServiceReport, []*ServiceError, error) {
+	appPath, moduleName := module.Dir, module.Name
+	polycodeFolder := filepath.Join(appPath, opts.OutputDir)
+	var reports []ServiceReport
+	var failures []*ServiceError
+
+	// Generated code that does not compile is rolled back, the app keeps building with the previous files.
+	// The journal only sees the writes of this module.
+	var journal *journalFS
+	if !opts.NoCompileCheck {
+		journal = newJournalFS(output, polycodeFolder)
+		output = journal
+	}
 
-	var methods []MethodInfo
-	var imports []string
+	// Without a services folder there is nothing to generate, but previous outputs are still formatted
+	if discovered {
+		pkgs, err := loadAppPackages(ctx, appPath)
+		if err != nil {
+			slog.Error("Error extracting structs", "error", err)
+			return nil, nil, err
+		}
+		structs, interfaces, typeParams := extractStructs(pkgs)
+		contexts := findContextTypes(pkgs)
+		var servicePackages []string
+		for _, entry := range entries {
+			servicePackages = append(servicePackages, servicePackagePath(moduleName, entry.Dir))
+		}
+		events, err := findEvents(pkgs, moduleName, servicePackages)
+		if err != nil {
+			slog.Error("Error finding events", "error", err)
+			return nil, nil, err
+		}
+		// Services import the publish helpers, they are written before the wrappers are type-checked
+		if slices.Contains(opts.Targets, TargetGo) {
+			if err = writeEvents(output, polycodeFolder, events); err != nil {
+				slog.Error("Error writing event helpers", "error", err)
+				return nil, nil, err
+			}
+		}
+		requirements, err := readModuleRequirements(module)
+		if err != nil {
+			slog.Error("Error reading module requirements", "error", err)
+			return nil, nil, err
+		}
 
-	err := filepath.Walk(serviceFolder, func(path string, info os.FileInfo, err error) error {
+		record, err := loadGeneratedFiles(output, polycodeFolder)
 		if err != nil {
-			return err
+			slog.Error("Error loading generated files", "error", err)
+			return nil, nil, err
+		}
+		cache := loadBuildCache(output, polycodeFolder)
+
+		services := make(map[string]bool)
+		serviceEntries := make(map[string]serviceEntry)
+		var selected []string
+		for _, entry := range entries {
+			services[entry.Name] = true
+			serviceEntries[entry.Name] = entry
+
+			if only == nil || slices.Contains(only, entry.Name) {
+				selected = append(selected, entry.Name)
+			}
 		}
-		// Only process Go files that are not test files
-		if strings.HasSuffix(info.Name(), ".go") && !strings.HasSuffix(info.Name(), "_test.go") {
-			node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+
+		slog.Info("Generating services", "count", len(selected), "workers", opts.Workers)
+		// Reports are stored at the position of their service, workers never share a slot
+		reports = make([]ServiceReport, len(selected))
+		positions := make(map[string]int, len(selected))
+		for i, name := range selected {
+			positions[name] = i
+		}
+		mod := &moduleContext{
+			output:       output,
+			appPath:      appPath,
+			moduleName:   moduleName,
+			structs:      structs,
+			interfaces:   interfaces,
+			typeParams:   typeParams,
+			events:       events,
+			contexts:     contexts,
+			sdk:          findSDKFeatures(pkgs),
+			requirements: requirements,
+			cache:        cache,
+			opts:         opts,
+		}
+		var done atomic.Int32
+		results := generateParallel(ctx, selected, opts.Workers, func(serviceName string) ([]string, error) {
+			slog.Debug("Generating service", "service", serviceName, "dir", serviceEntries[serviceName].Dir)
+			start := time.Now()
+			report, err := generateService(mod, serviceEntries[serviceName], record.Services[serviceName])
+			report.Duration = time.Since(start)
+			progress := fmt.Sprintf("%d/%d", done.Add(1), len(selected))
+			if err != nil {
+				report.Status, report.Files = ServiceFailed, nil
+				reports[positions[serviceName]] = report
+				return nil, newServiceError(appPath, serviceName, err)
+			}
+			reports[positions[serviceName]] = report
+
+			switch report.Status {
+			case ServiceGenerated:
+				slog.Info("Generated service", "progress", progress, "service", serviceName, "methods", report.Methods, "workflows", report.Workflows,
+					"files", len(report.Files), "duration", report.Duration)
+			case ServiceUnchanged:
+				slog.Info("Service unchanged, skipped", "progress", progress, "service", serviceName)
+			}
+			return report.Files, nil
+		})
+		// Canceled runs leave the outputs of finished services in place, the next run records them again
+		if err = ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		// Failed services keep their previous outputs, the others are still written, recorded and
+		// post-processed before the failures are reported together
+		for _, result := range results {
+			if result.err != nil {
+				slog.Error("Error generating service", "service", result.name, "error", result.err)
+				failures = append(failures, result.err.(*ServiceError))
+				continue
+			}
+			if err = record.update(output, polycodeFolder, result.name, result.files); err != nil {
+				slog.Error("Error removing stale files", "error", err)
+				return nil, nil, err
+			}
+		}
+
+		// Services that were deleted or excluded since the last run leave their outputs behind
+		if err = record.removeStale(output, polycodeFolder, services); err != nil {
+			slog.Error("Error removing stale files", "error", err)
+			return nil, nil, err
+		}
+		if output.Exists(polycodeFolder) {
+			if err = record.save(output, polycodeFolder); err != nil {
+				slog.Error("Error saving generated files", "error", err)
+				return nil, nil, err
+			}
+			if err = cache.save(output, polycodeFolder, services); err != nil {
+				slog.Error("Error saving build cache", "error", err)
+				return nil, nil, err
+			}
+		}
+
+		// Shared types span services, they are split out once every definition is written
+		if err = shareTypes(output, polycodeFolder, opts.DefinitionFormats, opts.SharedSchemas, opts.signingKey); err != nil {
+			slog.Error("Error writing shared schemas", "error", err)
+			return nil, nil, err
+		}
+
+		if len(failures) == 0 {
+			slog.Info("Finished generating code for services")
+		} else {
+			slog.Warn("Finished generating code with failures", "failed", len(failures), "services", len(selected))
+		}
+
+		if opts.OpenAPI || opts.AsyncAPI || opts.JSONSchema || opts.GraphQL || opts.Routes {
+			defs, err := loadServiceDefinitions(output, polycodeFolder)
 			if err != nil {
-				return err
+				slog.Error("Error loading service definitions", "error", err)
+				return nil, nil, err
+			}
+
+			if opts.OpenAPI {
+				err = writeOpenAPISpecs(output, polycodeFolder, moduleName, defs)
+				if err != nil {
+					slog.Error("Error writing OpenAPI specs", "error", err)
+					return nil, nil, err
+				}
+				slog.Info("OpenAPI specs generated")
+			}
+
+			if opts.AsyncAPI {
+				err = writeAsyncAPISpecs(output, polycodeFolder, moduleName, defs)
+				if err != nil {
+					slog.Error("Error writing AsyncAPI specs", "error", err)
+					return nil, nil, err
+				}
+				slog.Info("AsyncAPI specs generated")
 			}
 
-			// Collect all imports from this file
-			for _, imp := range node.Imports {
-				importPath := strings.Trim(imp.Path.Value, "\"")
-				imports = append(imports, importPath)
+			if opts.JSONSchema {
+				err = writeJSONSchemas(output, polycodeFolder, defs)
+				if err != nil {
+					slog.Error("Error writing JSON schemas", "error", err)
+					return nil, nil, err
+				}
+				slog.Info("JSON schemas generated")
+			}
+
+			if opts.GraphQL {
+				err = writeGraphQL(output, polycodeFolder, moduleName, defs, opts)
+				if err != nil {
+					slog.Error("Error writing GraphQL schema", "error", err)
+					return nil, nil, err
+				}
+				slog.Info("GraphQL schema generated")
 			}
 
-			for _, decl := range node.Decls {
-				if fn, isFn := decl.(*ast.FuncDecl); isFn && fn.Recv == nil {
-					OriginalName := fn.Name.Name
-
-					// check if function name starts with simple letter
-					if unicode.IsLower(rune(OriginalName[0])) {
-						continue
-					}
-
-					// Validate the function's parameters
-					contextType, err := validateFunctionParams(fn)
-					if err != nil {
-						return err
-					}
-
-					// Extract the function name and input/output parameters
-					methodName := strings.ToLower(fn.Name.Name) // Normalize to lowercase
-					var description string
-
-					if fn.Doc == nil || len(fn.Doc.List) == 0 {
-						description = ""
-					} else {
-						description = extractDescriptionFromComments(fn.Doc.List)
-					}
-					inputType, isInputPointer, isInputPrimitive := extractType(fn.Type.Params.List[1].Type)
-					outputType, isOutputPointer, isOutputPrimitive := extractType(fn.Type.Results.List[0].Type)
-
-					// Append the method and its corresponding input type to methods
-					if inputType != "" && outputType != "" {
-						methods = append(methods, MethodInfo{
-							OriginalName:      OriginalName,
-							Name:              methodName,
-							Description:       description,
-							InputType:         inputType,
-							IsInputPointer:    isInputPointer,
-							IsInputPrimitive:  isInputPrimitive,
-							OutputType:        outputType,
-							IsOutputPointer:   isOutputPointer,
-							IsOutputPrimitive: isOutputPrimitive,
-							IsWorkflow:        contextType == "Workflow",
-							IsService:         contextType == "Service",
-						})
-					}
+			if opts.Routes {
+				err = writeRouteTable(output, polycodeFolder, defs)
+				if err != nil {
+					slog.Error("Error writing route table", "error", err)
+					return nil, nil, err
 				}
+				slog.Info("Route table generated")
 			}
 		}
-		return nil
-	})
 
-	if err != nil {
-		return nil, nil, err
+		if slices.Contains(opts.Targets, TargetGo) {
+			if err = writeSupportFiles(output, polycodeFolder, opts); err != nil {
+				slog.Error("Error writing wrapper support files", "error", err)
+				return nil, nil, err
+			}
+			if err = writeServiceNames(output, polycodeFolder); err != nil {
+				slog.Error("Error writing name constants", "error", err)
+				return nil, nil, err
+			}
+		}
+
+		if err = writeAppManifest(output, polycodeFolder, moduleName); err != nil {
+			slog.Error("Error writing app manifest", "error", err)
+			return nil, nil, err
+		}
+
+		if opts.Dependencies {
+			graph, err := buildDependencyGraph(appPath, moduleName, entries, opts)
+			if err != nil {
+				slog.Error("Error analyzing service dependencies", "error", err)
+				return nil, nil, err
+			}
+			if err = writeDependencyGraph(output, polycodeFolder, graph); err != nil {
+				slog.Error("Error writing dependency graph", "error", err)
+				return nil, nil, err
+			}
+			slog.Info("Dependency graph generated")
+		}
+	}
+
+	if output.Exists(polycodeFolder) {
+		slog.Debug("Formatting generated code", "formatter", opts.Format)
+		err := formatGenerated(output, polycodeFolder, opts)
+		if err != nil {
+			slog.Error("Error formatting generated code", "error", err)
+			return nil, nil, err
+		}
+		slog.Info("Generated code formatted")
+	}
+
+	if journal != nil && journal.changedGoFiles() {
+		slog.Debug("Type-checking generated code")
+		compileFailures, err := checkGenerated(ctx, output, module, polycodeFolder, entries, reports)
+		if err != nil {
+			slog.Error("Error type-checking generated code", "error", err)
+			return nil, nil, err
+		}
+		if len(compileFailures) > 0 {
+			if err = journal.rollback(); err != nil {
+				slog.Error("Error restoring previous files", "error", err)
+				return nil, nil, err
+			}
+			slog.Error("Generated code does not compile, previous files restored", "errors", len(compileFailures))
+			rollbackReports(reports, compileFailures)
+			failures = mergeFailures(failures, compileFailures)
+		}
+	}
+
+	return reports, failures, nil
+}
+
+// writeTargetFiles formats and writes the files a target generated below the output folder and returns
+// their names. Targets may be external commands, every name is checked to stay inside the output folder
+// before anything is written.
+func writeTargetFiles(output outputFS, outputPath string, targetName string, files map[string][]byte, opts Options) ([]string, error) {
+	names := slices.Sorted(maps.Keys(files))
+	for _, name := range names {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("target %s generated %q, file names must be relative paths inside the output folder", targetName, name)
+		}
+	}
+
+	var written []string
+	for _, name := range names {
+		filePath := filepath.Join(outputPath, filepath.FromSlash(name))
+		err := output.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			slog.Error("Error creating directory", "error", err)
+			return written, err
+		}
+
+		content, err := formatSource(filePath, files[name], opts)
+		if err != nil {
+			slog.Error("Error formatting generated code", "error", err)
+			return written, err
+		}
+		err = output.WriteFile(filePath, content, 0644)
+		if err != nil {
+			slog.Error("Error writing file", "error", err)
+			return written, err
+		}
+		written = append(written, filepath.ToSlash(filepath.Clean(filepath.FromSlash(name))))
 	}
+	return written, nil
+}
 
-	// Remove duplicate imports
-	imports = unique(imports)
-	return methods, imports, nil
+// servicePackagePath returns the import path of the service package in serviceDir
+func servicePackagePath(moduleName string, serviceDir string) string {
+	if serviceDir == "." {
+		// The service is the root package of its module
+		return moduleName
+	}
+	return moduleName + "/" + filepath.ToSlash(serviceDir)
 }
 
 // Helper function to remove duplicate import paths
@@ -476,46 +738,28 @@ func toPascalCase(input string) string {
 	return strings.Join(words, "")
 }
 
-// GenerateService the wrapper code based on the extracted information
-func generateServiceCode(moduleName string, serviceName string, methods []MethodInfo, imports []string, isProd bool) (string, error) {
-	serviceStructName := toPascalCase(serviceName)
-
-	serviceInfo := ServiceInfo{
-		ModuleName:        moduleName,
-		ServiceName:       serviceName,
-		ServiceStructName: serviceStructName,
-		Methods:           methods,
-		IsProduction:      isProd,
-		Imports:           imports,
+// CheckFileCompilable type-checks the package containing fileName, so errors spanning several files
+// of the package are caught too. Nothing is written to disk, which keeps the check portable.
+func CheckFileCompilable(fileName string) error {
+	cfg := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes,
+		Dir:   filepath.Dir(fileName),
+		Tests: strings.HasSuffix(fileName, "_test.go"),
 	}
 
-	// Use template to generate the code
-	var buf bytes.Buffer
-	tmpl, err := template.New("wrapper").Parse(wrapperTemplate)
+	pkgs, err := packages.Load(cfg, ".")
 	if err != nil {
-		return "", err
+		return fmt.Errorf("compilation error: %w", err)
 	}
 
-	err = tmpl.Execute(&buf, serviceInfo)
-	if err != nil {
-		return "", err
+	var errs []string
+	for _, pkg := range pkgs {
+		for _, pkgErr := range pkg.Errors {
+			errs = append(errs, pkgErr.Error())
+		}
 	}
-
-	return buf.String(), nil
-}
-
-// RunGoImports runs goimports on the generated file to remove unnecessary imports
-func runGoImports(filePath string) error {
-	cmd := exec.Command("goimports", "-w", filePath)
-	return cmd.Run()
-}
-
-func CheckFileCompilable(fileName string) error {
-	// Execute the `go build` command for the file
-	cmd := exec.Command("go", "build", "-o", "/dev/null", fileName)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("compilation error: %s", strings.TrimSpace(string(output)))
+	if len(errs) > 0 {
+		return fmt.Errorf("compilation error: %s", strings.Join(unique(errs), "\n"))
 	}
 	return nil
 }
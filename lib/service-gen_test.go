@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGenerateServices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the post-generate hook is a sh command line")
+	}
+	dir := t.TempDir()
+	// The app requires a stub of the SDK through a local replace, no module is downloaded
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", "generate"))); err != nil {
+		t.Fatal(err)
+	}
+	appPath := filepath.Join(dir, "app")
+	opts := DefaultOptions()
+	opts.Hooks.PostGenerate = StringList{`printf %s "$NEXTGEN_SERVICE" > ` + filepath.Join(dir, "generated.txt")}
+
+	report, err := Generate(context.Background(), appPath, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]string)
+	for _, service := range report.Services {
+		statuses[service.Service] = service.Status
+	}
+	want := map[string]string{"orders": ServiceGenerated, "billing": ServiceGenerated}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Fatalf("got %v, want %v", statuses, want)
+	}
+	for _, file := range []string{"orders.go", "billing.go"} {
+		if _, err := os.Stat(filepath.Join(appPath, opts.OutputDir, file)); err != nil {
+			t.Error(err)
+		}
+	}
+	// The post-generate hook is given the services generated, all of them on a full run
+	if got, want := readHookOutput(t, dir), "billing,orders"; got != want {
+		t.Errorf("got NEXTGEN_SERVICE %q, want %q", got, want)
+	}
+
+	// A path with a trailing separator names the same app, nothing changed since the first run
+	report, err = Generate(context.Background(), appPath+string(filepath.Separator), nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range report.Services {
+		if service.Status != ServiceUnchanged {
+			t.Errorf("%s: got status %s on the second run, want %s", service.Service, service.Status, ServiceUnchanged)
+		}
+	}
+	if got := readHookOutput(t, dir); got != "" {
+		t.Errorf("got NEXTGEN_SERVICE %q on the second run, want none", got)
+	}
+}
+
+func TestGenerateServicesStrict(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", "generate"))); err != nil {
+		t.Fatal(err)
+	}
+	appPath := filepath.Join(dir, "app")
+	src := "package orders\n\nimport \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc Cancel(ctx polycode.ServiceContext) (int, int, error) { return 0, 0, nil }\n"
+	if err := os.WriteFile(filepath.Join(appPath, "services", "orders", "cancel.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Strict = true
+
+	_, err := Generate(context.Background(), appPath, nil, opts)
+	want := "services/orders/cancel.go:5: function Cancel: expected (output, error) or error results, got 3 results"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want one containing %q", err, want)
+	}
+}
+
+// readHookOutput returns the NEXTGEN_SERVICE written by the post-generate hook of TestGenerateServices
+func readHookOutput(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "generated.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteTargetFiles(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), ".polycode")
+	tests := []struct {
+		name  string
+		files []string
+		want  []string // names written, nil when the target is rejected
+	}{
+		{name: "nested names", files: []string{"orders.ts", "client/orders/index.ts"}, want: []string{"client/orders/index.ts", "orders.ts"}},
+		{name: "parent folder", files: []string{"orders.ts", "../../x.ts"}},
+		{name: "parent folder after cleaning", files: []string{"client/../../x.ts"}},
+		{name: "absolute path", files: []string{"/tmp/x.ts"}},
+		{name: "empty name", files: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string][]byte)
+			for _, name := range tt.files {
+				files[name] = []byte("export {}\n")
+			}
+			output := newOverlayFS()
+			written, err := writeTargetFiles(output, outputPath, "ts", files, DefaultOptions())
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("wrote %v, want the target rejected", written)
+				}
+				if changes := output.changes(); len(changes) > 0 {
+					t.Errorf("wrote %v before rejecting the target", changes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(written, tt.want) {
+				t.Errorf("got %v, want %v", written, tt.want)
+			}
+		})
+	}
+}
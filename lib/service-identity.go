@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// servicePart matches the name and namespace given with //polycode:service
+var servicePart = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// serviceVersion matches versions given with //polycode:service, like v2 or v2beta1
+var serviceVersion = regexp.MustCompile(`^v[0-9][a-z0-9.]*$`)
+
+// serviceIdentity is the name a service is registered with, declared in the package doc comment by
+// //polycode:service name=orders version=v2 namespace=billing. The registered name joins the set parts
+// with slashes like billing/orders/v2, so two versions of a service can run side by side.
+type serviceIdentity struct {
+	Name      string
+	Namespace string
+	Version   string
+}
+
+// String returns the registered name
+func (id serviceIdentity) String() string {
+	var parts []string
+	for _, part := range []string{id.Namespace, id.Name, id.Version} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// readServiceIdentity reads the //polycode:service directive of the package in a service folder,
+// the name defaults to the one derived from the folder. The directive may be in any file but once.
+func readServiceIdentity(dir string, defaultName string) (serviceIdentity, error) {
+	id := serviceIdentity{Name: defaultName}
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return id, err
+	}
+
+	var declared string
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(fset, file, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return id, err
+		}
+		args, ok := parseDirectives(node.Doc)["service"]
+		if !ok {
+			continue
+		}
+		if declared != "" {
+			return id, fmt.Errorf("//polycode:service is declared in both %s and %s, declare it once per package", filepath.Base(declared), filepath.Base(file))
+		}
+		declared = file
+
+		for key, value := range parseDirectiveArgs(args) {
+			switch key {
+			case "name", "namespace":
+				if !servicePart.MatchString(value) {
+					return id, fmt.Errorf("%s: //polycode:service %s %q must be lowercase letters, digits and dashes", filepath.Base(file), key, value)
+				}
+				if key == "name" {
+					id.Name = value
+				} else {
+					id.Namespace = value
+				}
+			case "version":
+				if !serviceVersion.MatchString(value) {
+					return id, fmt.Errorf("%s: //polycode:service version %q must look like v2 or v2beta1", filepath.Base(file), value)
+				}
+				id.Version = value
+			default:
+				return id, fmt.Errorf("%s: unknown //polycode:service argument %q, expected name, namespace or version", filepath.Base(file), key)
+			}
+		}
+	}
+	return id, nil
+}
+
+// serviceFileName returns the name of the files generated for a service, its registered name with
+// the slashes of namespaces and versions replaced, like billing-orders-v2
+func serviceFileName(serviceName string) string {
+	return strings.ReplaceAll(serviceName, "/", "-")
+}
@@ -0,0 +1,192 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MethodInfo is a service method as seen by the wrapper template, part of the template contract
+type MethodInfo struct {
+	OriginalName      string            // Go function name, or the instantiation name of a generic function
+	GenericName       string            // Generic function instantiated by //polycode:instantiate, empty otherwise
+	TypeArgs          string            // Type argument list of the instantiation, like [models.User]
+	Name              string            // Lowercase name the method is dispatched by
+	Description       string            // @description line of the doc comment
+	Doc               string            // Go doc comment without directives and @description lines
+	HasInput          bool              // False for func(ctx) shaped methods
+	InputType         string            // Go type of the input as written in the wrapper, like models.CreateOrderRequest
+	IsInputPointer    bool              // Input is passed by pointer
+	IsInputPrimitive  bool              // Input is not a struct, like string or []int
+	InputCollection   string            // Kind of slice, array and map inputs: slice, array or map, empty for other inputs
+	InputKeyType      string            // Key type of map inputs
+	InputElemType     string            // Element type of collection inputs, like models.Order or *models.Order
+	HasOutput         bool              // False for methods returning only an error
+	OutputType        string            // Go type of the output as written in the wrapper
+	IsOutputPointer   bool              // Output is returned by pointer
+	IsOutputPrimitive bool              // Output is not a struct
+	IsOutputInterface bool              // Output is an interface type, its dynamic type is unknown until the method returns
+	IsMultiInput      bool              // Several or variadic business parameters, bundled into the generated InputType struct
+	Params            []ParamInfo       // Business parameters of a multi-input method
+	IsInputStream     bool              // Input is a channel or polycode.Stream, InputType is its element type
+	IsOutputStream    bool              // Output is a channel or polycode.Stream, OutputType is its element type
+	IsWorkflow        bool              // Takes a polycode.WorkflowContext
+	IsService         bool              // Takes a polycode.ServiceContext
+	ConcurrencyLimit  int               // Maximum concurrent executions, 0 means unlimited
+	ExposedName       string            // Name the method is invoked with, set by //polycode:method name=...
+	Options           map[string]string // Key/value options of the //polycode:method directive
+	Auth              *AuthPolicy       // Roles declared by //polycode:auth, nil when every caller is allowed
+	Timeout           time.Duration     // Declared by //polycode:timeout, 0 leaves it to the runtime
+	Retry             *RetryPolicy      // Declared by //polycode:retry, nil leaves it to the runtime
+	Routes            []HTTPRoute       // Declared by //polycode:http, the routes of API gateways to the method
+	IsMethod          bool              // Declared on the ServiceReceiver rather than as a function
+	Deprecated        *Deprecation      // Set by a "Deprecated:" doc paragraph or //polycode:deprecated
+	IsSignal          bool              // Handles a signal sent to running workflows, listed in ServiceInfo.Signals
+	IsQuery           bool              // Answers a query on running workflows, listed in ServiceInfo.Queries
+	IsSubscriber      bool              // Subscribed to an event with //polycode:subscribe, listed in ServiceInfo.Subscribers
+	Event             string            // Name of the event a subscriber handles
+	Validations       []ValidationCheck // Checks generated from the validate tags of the input struct
+}
+
+// ParamInfo is a business parameter of a multi-input method and the field holding it in the input struct
+type ParamInfo struct {
+	Name       string // Exported field name
+	JSONName   string // Parameter name, used as the JSON key
+	Type       string // Field type, variadic parameters are slices
+	IsVariadic bool
+}
+
+// Func returns the expression calling the service function, instantiating generic functions
+func (m MethodInfo) Func() string {
+	if m.GenericName != "" {
+		return m.GenericName + m.TypeArgs
+	}
+	return m.OriginalName
+}
+
+// Callee returns the expression the wrapper calls the method through, the service package or the receiver
+func (m MethodInfo) Callee() string {
+	if m.IsMethod {
+		return "t.receiver." + m.Func()
+	}
+	return "service." + m.Func()
+}
+
+// IsInputElemPointer reports whether the elements of a collection input are pointers
+func (m MethodInfo) IsInputElemPointer() bool {
+	return strings.HasPrefix(m.InputElemType, "*")
+}
+
+// IsStreaming reports whether the method consumes or produces a stream
+func (m MethodInfo) IsStreaming() bool {
+	return m.IsInputStream || m.IsOutputStream
+}
+
+// ServiceInfo is the data the Go wrapper template is executed with, its fields and those of MethodInfo
+// are the template contract versioned by TemplateContract
+type ServiceInfo struct {
+	ModuleName        string            // Module path of the app
+	ServiceName       string            // Name the service is registered with, like orders or billing-invoices
+	ServiceStructName string            // Name of the generated wrapper struct
+	Methods           []MethodInfo      // Exposed methods sorted by name
+	Signals           []MethodInfo      // Workflow signal handlers sorted by name
+	Queries           []MethodInfo      // Workflow query handlers sorted by name
+	Subscribers       []MethodInfo      // Event subscribers sorted by event
+	IsProduction      bool              // New flag to determine if we are in production mode
+	Imports           []string          // Import specs (optionally aliased) needed by the method input/output types, the service package as service
+	ServicePackage    string            // Import path of the service package
+	ServiceDir        string            // Service folder relative to the app root
+	OutputDir         string            // Output folder relative to the app root
+	PackageName       string            // Go package name of the wrappers
+	Lifecycle         []string          // Lifecycle hooks declared by the service, like OnStart
+	Errors            []ErrorDefinition // Error catalog mapped by GetErrorCode, only set with Options.ErrorCodes
+	Receiver          *ServiceReceiver  // Struct the methods are declared on, nil for services made of functions
+	SDKImport         string            // Import spec of the polycode package, aliased when its path ends differently
+	SDK               SDKFeatures       // Optional APIs the polycode package provides
+	Metrics           bool              // Instrument ExecuteService and ExecuteWorkflow with OpenTelemetry, set by Options.Metrics
+	DebugDispatch     bool              // Log the calls of ExecuteService and ExecuteWorkflow at debug level, set by Options.DebugDispatch
+	Middleware        []Middleware      // Chain declared by //polycode:middleware, outermost first
+	HealthCheck       *HealthCheck      // Health probe declared by the service, nil when there is none
+}
+
+// lifecycleHooks are the function names called by the runtime instead of being exposed as methods
+var lifecycleHooks = map[string]bool{"OnStart": true, "OnStop": true}
+
+// HasLifecycle reports whether the service declares the lifecycle hook
+func (s ServiceInfo) HasLifecycle(name string) bool {
+	return slices.Contains(s.Lifecycle, name)
+}
+
+// LifecycleCallee returns the expression the wrapper calls a lifecycle hook through
+func (s ServiceInfo) LifecycleCallee(name string) string {
+	if s.Receiver != nil && slices.Contains(s.Receiver.Lifecycle, name) {
+		return "t.receiver." + name
+	}
+	return "service." + name
+}
+
+// HasConcurrencyLimits reports whether any method declares a //polycode:concurrency limit
+func (s ServiceInfo) HasConcurrencyLimits() bool {
+	for _, method := range s.Methods {
+		if method.ConcurrencyLimit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DependencyImports returns the imports of the method types other than the service package, which the
+// wrapper always imports as service
+func (s ServiceInfo) DependencyImports() []string {
+	local := fmt.Sprintf("service %q", s.ServicePackage)
+	return slices.DeleteFunc(slices.Clone(s.Imports), func(spec string) bool {
+		return spec == local
+	})
+}
+
+func newServiceInfo(moduleName string, serviceName string, serviceDir string, methods []MethodInfo, imports []string, opts Options) ServiceInfo {
+	structName := toPascalCase(serviceFileName(serviceName))
+
+	// Input structs of multi-input methods share the wrapper package, prefix them with the service
+	var named, signals, queries, subscribers []MethodInfo
+	for _, method := range methods {
+		switch {
+		case method.IsSignal:
+			signals = append(signals, method)
+		case method.IsQuery:
+			queries = append(queries, method)
+		case method.IsSubscriber:
+			subscribers = append(subscribers, method)
+		default:
+			if method.IsMultiInput {
+				method.InputType = structName + method.OriginalName + "Input"
+			}
+			named = append(named, method)
+		}
+	}
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Event < subscribers[j].Event
+	})
+
+	return ServiceInfo{
+		ModuleName:        moduleName,
+		ServiceName:       serviceName,
+		ServiceStructName: structName,
+		Methods:           named,
+		Signals:           signals,
+		Queries:           queries,
+		Subscribers:       subscribers,
+		IsProduction:      opts.Production,
+		Imports:           imports,
+		ServicePackage:    servicePackagePath(moduleName, serviceDir),
+		ServiceDir:        filepath.ToSlash(serviceDir),
+		OutputDir:         filepath.ToSlash(filepath.Clean(opts.OutputDir)),
+		PackageName:       opts.PackageName,
+		SDKImport:         sdkImportSpec(opts.SDKImport),
+		Metrics:           opts.Metrics,
+		DebugDispatch:     opts.DebugDispatch,
+	}
+}
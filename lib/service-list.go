@@ -0,0 +1,208 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats of next-gen list
+const (
+	ListFormatTable = "table"
+	ListFormatJSON  = "json"
+)
+
+// ServiceListing is a discovered service with the methods parsed from its package
+type ServiceListing struct {
+	Name    string          `json:"name"`
+	Module  string          `json:"module,omitempty"` // Set in workspaces of several modules
+	Dir     string          `json:"dir"`              // Relative to the app root
+	Methods []MethodListing `json:"methods"`
+	Signals []MethodListing `json:"signals,omitempty"`
+	Queries []MethodListing `json:"queries,omitempty"`
+	// Subscribers are the functions events are delivered to, their input is the event
+	Subscribers []MethodListing   `json:"subscribers,omitempty"`
+	Skipped     []SkippedFunction `json:"skipped,omitempty"` // Exported functions not exposed, with the reason
+	Error       string            `json:"error,omitempty"`   // Set when the service package cannot be parsed
+}
+
+// MethodListing is a method of a listed service with its input and output types as written in Go
+type MethodListing struct {
+	Name         string `json:"name"`
+	IsWorkflow   bool   `json:"isWorkflow"`
+	InputType    string `json:"inputType,omitempty"`
+	OutputType   string `json:"outputType,omitempty"`
+	InputStream  bool   `json:"inputStream,omitempty"`
+	OutputStream bool   `json:"outputStream,omitempty"`
+	Deprecated   bool   `json:"deprecated,omitempty"`
+}
+
+// ListServices parses every service of the app without generating anything. Services that cannot be
+// parsed are listed with their error.
+func ListServices(appPath string, opts Options) ([]ServiceListing, error) {
+	appPath, err := NormalizeAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	modules, err := resolveModules(appPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := discoverServices(appPath, opts)
+	if errors.Is(err, errNoServicesFolder) {
+		return []ServiceListing{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	grouped, err := groupByModule(appPath, modules, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	listings := []ServiceListing{}
+	for _, module := range modules {
+		wrapperPackage := module.Name + "/" + filepath.ToSlash(filepath.Clean(opts.OutputDir))
+		// Aliases of the polycode contexts need type information, without it only the SDK types are recognized
+		var contexts contextTypes
+		if len(grouped[module.Dir]) > 0 {
+			pkgs, err := loadAppPackages(context.Background(), module.Dir)
+			if err != nil {
+				slog.Warn("Failed to load packages, context aliases are not recognized", "module", module.Name, "error", err)
+			}
+			contexts = findContextTypes(pkgs)
+		}
+		for _, entry := range grouped[module.Dir] {
+			listing := ServiceListing{Name: entry.Name, Dir: entry.Dir, Methods: []MethodListing{}}
+			if len(modules) > 1 {
+				listing.Module = module.Name
+			}
+			if rel, err := filepath.Rel(appPath, filepath.Join(module.Dir, entry.Dir)); err == nil {
+				listing.Dir = filepath.ToSlash(rel)
+			}
+
+			parsed, err := parseDir(filepath.Join(module.Dir, entry.Dir), servicePackagePath(module.Name, entry.Dir), wrapperPackage, opts.Exclude, contexts, opts.SDKImport)
+			if err != nil {
+				listing.Error = err.Error()
+				listings = append(listings, listing)
+				continue
+			}
+
+			for _, function := range parsed.Skipped {
+				if rel, err := filepath.Rel(appPath, function.File); err == nil {
+					function.File = filepath.ToSlash(rel)
+				}
+				listing.Skipped = append(listing.Skipped, function)
+			}
+			info := newServiceInfo(module.Name, entry.Name, entry.Dir, parsed.Methods, parsed.Imports, opts)
+			listing.Methods = append(listing.Methods, methodListings(info.Methods, opts.PackageName)...)
+			listing.Signals = methodListings(info.Signals, opts.PackageName)
+			listing.Queries = methodListings(info.Queries, opts.PackageName)
+			listing.Subscribers = methodListings(info.Subscribers, opts.PackageName)
+			listings = append(listings, listing)
+		}
+	}
+	return listings, nil
+}
+
+// methodListings lists methods with the input structs of multi-input methods qualified by the wrapper package
+func methodListings(methods []MethodInfo, packageName string) []MethodListing {
+	var listings []MethodListing
+	for _, method := range methods {
+		inputType := method.InputType
+		if method.IsMultiInput {
+			inputType = packageName + "." + inputType
+		}
+		listings = append(listings, MethodListing{
+			Name:         method.ExposedName,
+			IsWorkflow:   method.IsWorkflow,
+			InputType:    inputType,
+			OutputType:   method.OutputType,
+			InputStream:  method.IsInputStream,
+			OutputStream: method.IsOutputStream,
+			Deprecated:   method.Deprecated != nil,
+		})
+	}
+	return listings
+}
+
+// FormatServiceListings renders the listings as a table of methods or as indented JSON
+func FormatServiceListings(listings []ServiceListing, format string) (string, error) {
+	switch format {
+	case ListFormatJSON:
+		data, err := json.MarshalIndent(listings, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case ListFormatTable, "":
+	default:
+		return "", fmt.Errorf("unknown list format %q, expected table or json", format)
+	}
+
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tMETHOD\tKIND\tINPUT\tOUTPUT")
+	for _, listing := range listings {
+		name := listing.Name
+		if listing.Module != "" {
+			name = listing.Module + ":" + name
+		}
+		if listing.Error != "" {
+			fmt.Fprintf(w, "%s\t-\terror\t%s\t\n", name, strings.SplitN(listing.Error, "\n", 2)[0])
+			continue
+		}
+		if len(listing.Methods)+len(listing.Signals)+len(listing.Queries)+len(listing.Subscribers)+len(listing.Skipped) == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\n", name)
+		}
+		for _, method := range listing.Methods {
+			kind := "service"
+			if method.IsWorkflow {
+				kind = "workflow"
+			}
+			writeListingRow(w, name, method, kind)
+		}
+		for _, signal := range listing.Signals {
+			writeListingRow(w, name, signal, "signal")
+		}
+		for _, query := range listing.Queries {
+			writeListingRow(w, name, query, "query")
+		}
+		for _, subscriber := range listing.Subscribers {
+			writeListingRow(w, name, subscriber, "subscriber")
+		}
+		for _, function := range listing.Skipped {
+			fmt.Fprintf(w, "%s\t%s\tskipped\t%s\t\n", name, function.Name, function.Reason)
+		}
+	}
+	w.Flush()
+	return buf.String(), nil
+}
+
+// writeListingRow writes a method row, streams are marked with their element type like stream<Event>
+func writeListingRow(w *tabwriter.Writer, service string, method MethodListing, kind string) {
+	if method.Deprecated {
+		kind += " (deprecated)"
+	}
+	input, output := method.InputType, method.OutputType
+	if method.InputStream {
+		input = "stream<" + input + ">"
+	}
+	if method.OutputStream {
+		output = "stream<" + output + ">"
+	}
+	if input == "" {
+		input = "-"
+	}
+	if output == "" {
+		output = "-"
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", service, method.Name, kind, input, output)
+}
@@ -0,0 +1,832 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// extractDescriptionFromComments extracts the @description value from []*ast.Comment.
+func extractDescriptionFromComments(comments []*ast.Comment) string {
+	for _, c := range comments {
+		line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		line = strings.TrimSpace(strings.TrimPrefix(line, "/*")) // handle block comment
+		line = strings.TrimSpace(strings.TrimSuffix(line, "*/"))
+
+		if strings.HasPrefix(line, "@description") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "@description"))
+		}
+	}
+	return ""
+}
+
+// extractDocComment returns the text of a doc comment without directives and @description lines
+func extractDocComment(doc *ast.CommentGroup) string {
+	var lines []string
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "@description") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// validateFunctionParams checks that the first parameter is a polycode.ServiceContext or polycode.WorkflowContext,
+// or an alias of them, and returns whether the function is a Service or a Workflow, or why it is not exposed
+func validateFunctionParams(fn *ast.FuncDecl, resolve contextResolver) (contextType string, reason string) {
+	// Check if there is at least the context parameter
+	if fn.Type.Params == nil || len(fn.Type.Params.List) < 1 {
+		return "", "the first parameter must be polycode.ServiceContext or polycode.WorkflowContext, the function has none"
+	}
+
+	// Validate the first parameter type
+	if contextType := resolve(fn.Type.Params.List[0].Type); contextType != "" {
+		return contextType, ""
+	}
+	return "", "first parameter must be polycode.ServiceContext or polycode.WorkflowContext, got " + types.ExprString(fn.Type.Params.List[0].Type)
+}
+
+// validateLifecycleHook checks that a lifecycle hook has the func(ctx polycode.ServiceContext) error signature
+func validateLifecycleHook(fn *ast.FuncDecl, resolve contextResolver) error {
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams == nil && len(params) == 1 && len(results) == 1 && isErrorType(results[0]) && resolve(params[0]) == "Service" {
+		return nil
+	}
+	return fmt.Errorf("function %s: lifecycle hooks must have the signature func %s(ctx polycode.ServiceContext) error", fn.Name.Name, fn.Name.Name)
+}
+
+// flattenFields returns the type of every parameter or result, expanding grouped names like (a, b T)
+func flattenFields(list *ast.FieldList) []ast.Expr {
+	if list == nil {
+		return nil
+	}
+
+	var exprs []ast.Expr
+	for _, field := range list.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+	return exprs
+}
+
+// paramNames returns the parameter names in the order of flattenFields, unnamed parameters have an empty name
+func paramNames(list *ast.FieldList) []string {
+	var names []string
+	for _, field := range list.List {
+		if len(field.Names) == 0 {
+			names = append(names, "")
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// isErrorType checks whether the expression is the builtin error type
+func isErrorType(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "error"
+}
+
+// extractType renders a type expression, qualifying types declared in the service package with localPkg
+func extractType(expr ast.Expr, localPkg string) (typeStr string, isPointer bool, isPrimitive bool) {
+	switch t := expr.(type) {
+
+	case *ast.StarExpr:
+		innerType, _, primitive := extractType(t.X, localPkg)
+		return innerType, true, primitive
+
+	case *ast.SelectorExpr:
+		// Handles pkg.Type
+		if pkgIdent, ok := t.X.(*ast.Ident); ok {
+			typeName := fmt.Sprintf("%s.%s", pkgIdent.Name, t.Sel.Name)
+			return typeName, false, false
+		}
+
+		return t.Sel.Name, false, false
+
+	case *ast.Ident:
+		// Handles builtin types, anything else is declared in the service package
+		if isLocalType(t) {
+			return localPkg + "." + t.Name, false, false
+		}
+		return t.Name, false, primitiveTypes[t.Name]
+
+	case *ast.ArrayType:
+		// Pointer elements and array lengths are kept, the wrapper declares values of this exact type
+		elemType := typeArgString(t.Elt, localPkg)
+		if t.Len != nil {
+			length := types.ExprString(t.Len)
+			if ident, ok := t.Len.(*ast.Ident); ok {
+				// A constant of the service package
+				length = localPkg + "." + ident.Name
+			}
+			return "[" + length + "]" + elemType, false, false
+		}
+		return "[]" + elemType, false, false
+
+	case *ast.Ellipsis:
+		// Variadic parameters are received as slices
+		elemType, isPointer, _ := extractType(t.Elt, localPkg)
+		if isPointer {
+			elemType = "*" + elemType
+		}
+		return "[]" + elemType, false, false
+
+	case *ast.MapType:
+		keyType := typeArgString(t.Key, localPkg)
+		valType := typeArgString(t.Value, localPkg)
+		return fmt.Sprintf("map[%s]%s", keyType, valType), false, false
+
+	case *ast.InterfaceType:
+		return "interface{}", false, true
+
+	case *ast.IndexExpr:
+		// Instantiated generic types like Page[models.User]
+		genericType, _, _ := extractType(t.X, localPkg)
+		return genericType + "[" + typeArgString(t.Index, localPkg) + "]", false, false
+
+	case *ast.IndexListExpr:
+		genericType, _, _ := extractType(t.X, localPkg)
+		var args []string
+		for _, index := range t.Indices {
+			args = append(args, typeArgString(index, localPkg))
+		}
+		return genericType + "[" + strings.Join(args, ", ") + "]", false, false
+
+	default:
+		return fmt.Sprintf("%T", t), false, false
+	}
+}
+
+// collectionType returns the kind, key and element types of a slice, array or map type expression or a
+// pointer to one, the kind is empty for other types
+func collectionType(expr ast.Expr, localPkg string) (kind string, keyType string, elemType string) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return "array", "", typeArgString(t.Elt, localPkg)
+		}
+		return "slice", "", typeArgString(t.Elt, localPkg)
+	case *ast.MapType:
+		return "map", typeArgString(t.Key, localPkg), typeArgString(t.Value, localPkg)
+	}
+	return "", "", ""
+}
+
+// typeArgString renders a type argument, keeping its pointer
+func typeArgString(expr ast.Expr, localPkg string) string {
+	typeStr, isPointer, _ := extractType(expr, localPkg)
+	if isPointer {
+		return "*" + typeStr
+	}
+	return typeStr
+}
+
+// genericInstance is a generic service function instantiated by a //polycode:instantiate directive
+type genericInstance struct {
+	name     string            // Method name of the instantiation
+	generic  string            // Name of the generic function
+	typeArgs string            // Type argument list, like [models.User]
+	subst    map[string]string // Type argument of each type parameter
+}
+
+// genericInstances returns the instantiations of a generic function declared with
+// //polycode:instantiate name=GetUser T=models.User, a plain function yields a single empty instance
+func genericInstances(fn *ast.FuncDecl, directives []string) ([]genericInstance, error) {
+	if fn.Type.TypeParams == nil {
+		if len(directives) > 0 {
+			return nil, fmt.Errorf("function %s: //polycode:instantiate is only valid on generic functions", fn.Name.Name)
+		}
+		return []genericInstance{{}}, nil
+	}
+
+	var typeParams []string
+	for _, field := range fn.Type.TypeParams.List {
+		for _, name := range field.Names {
+			typeParams = append(typeParams, name.Name)
+		}
+	}
+
+	if len(directives) == 0 {
+		return nil, fmt.Errorf("function %s: generic functions need a //polycode:instantiate name=<Method> %s=<type> directive per exposed instantiation",
+			fn.Name.Name, strings.Join(typeParams, "=<type> "))
+	}
+
+	var instances []genericInstance
+	for _, directive := range directives {
+		args := parseDirectiveArgs(directive)
+		instance := genericInstance{name: args["name"], generic: fn.Name.Name, subst: make(map[string]string)}
+		if !token.IsIdentifier(instance.name) || !token.IsExported(instance.name) {
+			return nil, fmt.Errorf("function %s: //polycode:instantiate needs an exported method name, got %q", fn.Name.Name, instance.name)
+		}
+		delete(args, "name")
+
+		var typeArgs []string
+		for _, param := range typeParams {
+			arg, ok := args[param]
+			if !ok || arg == "true" {
+				return nil, fmt.Errorf("function %s: //polycode:instantiate %s is missing the type argument %s=<type>", fn.Name.Name, instance.name, param)
+			}
+			instance.subst[param] = arg
+			typeArgs = append(typeArgs, arg)
+			delete(args, param)
+		}
+		for unknown := range args {
+			return nil, fmt.Errorf("function %s: //polycode:instantiate %s has no type parameter %s", fn.Name.Name, instance.name, unknown)
+		}
+		instance.typeArgs = "[" + strings.Join(typeArgs, ", ") + "]"
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// substituteTypeParams replaces type parameters in type expressions by their type arguments. The
+// expressions are printed, their identifiers replaced token by token and the result parsed again.
+func substituteTypeParams(exprs []ast.Expr, subst map[string]string) ([]ast.Expr, error) {
+	if len(subst) == 0 {
+		return exprs, nil
+	}
+
+	substituted := make([]ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, token.NewFileSet(), expr); err != nil {
+			return nil, err
+		}
+		src := buf.Bytes()
+
+		fset := token.NewFileSet()
+		file := fset.AddFile("", fset.Base(), len(src))
+		var s scanner.Scanner
+		s.Init(file, src, nil, 0)
+
+		var out strings.Builder
+		last, prev := 0, token.ILLEGAL
+		for {
+			pos, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			// Identifiers after a dot are selectors like pkg.T, not type parameters
+			if arg, ok := subst[lit]; ok && tok == token.IDENT && prev != token.PERIOD {
+				offset := file.Offset(pos)
+				out.Write(src[last:offset])
+				out.WriteString(arg)
+				last = offset + len(lit)
+			}
+			prev = tok
+		}
+		out.Write(src[last:])
+
+		parsed, err := parser.ParseExpr(out.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid type argument in %s: %w", out.String(), err)
+		}
+		substituted[i] = parsed
+	}
+	return substituted, nil
+}
+
+var primitiveTypes = map[string]bool{
+	"string": true, "bool": true, "int": true, "int8": true, "int16": true,
+	"int32": true, "int64": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "float32": true, "float64": true,
+	"byte": true, "rune": true, "any": true, "interface{}": true,
+}
+
+// parsedService is what parseDir finds in the source files of a service package
+type parsedService struct {
+	Methods    []MethodInfo
+	Imports    []string // Import specs of the types in the method signatures
+	Lifecycle  []string // Lifecycle hooks the package declares, sorted
+	Receiver   *ServiceReceiver
+	Middleware []Middleware
+	Health     *HealthCheck
+	Skipped    []SkippedFunction // Exported functions whose inputs or outputs the wrapper cannot carry
+	Streams    []string          // Functions with a polycode.Stream input or output rather than a channel
+}
+
+// Updated parseDir function to mark methods as workflow or service
+func parseDir(serviceFolder string, servicePackage string, wrapperPackage string, exclude []string, contexts contextTypes, sdkImport string) (parsedService, error) {
+	fset := token.NewFileSet()
+
+	var methods []MethodInfo
+	var imports []string
+	var lifecycle []string
+	var health *HealthCheck
+	var streams []string
+	// skipped are the exported functions whose inputs or outputs the wrapper cannot carry
+	var skipped []SkippedFunction
+	// current is the function being parsed, errors are reported at its position
+	var current *ast.FuncDecl
+	// declared keeps where each normalized method name was first declared to report collisions
+	declared := make(map[string]*ast.FuncDecl)
+
+	var files []*ast.File
+	err := filepath.Walk(serviceFolder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != serviceFolder && info.IsDir() {
+			// Sub folders are separate packages, nested services are discovered on their own
+			return filepath.SkipDir
+		}
+		if rel, _ := filepath.Rel(serviceFolder, path); rel != "." && isExcluded(rel, exclude) {
+			return nil
+		}
+		// Only process Go files that are not test files
+		if strings.HasSuffix(info.Name(), ".go") && !strings.HasSuffix(info.Name(), "_test.go") {
+			node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+			if err != nil {
+				return err
+			}
+			files = append(files, node)
+		}
+		return nil
+	})
+	if err != nil {
+		return parsedService{}, err
+	}
+
+	// Methods taking a polycode context make their struct the receiver of the service
+	receiver, err := findReceiver(fset, files, servicePackage, contexts)
+	if err != nil {
+		return parsedService{}, err
+	}
+	// Middleware declared by the package wrap the calls instead of being exposed
+	middleware, err := findMiddleware(fset, files, servicePackage, contexts)
+	if err != nil {
+		return parsedService{}, err
+	}
+
+	err = func() error {
+		for _, node := range files {
+			current = nil
+			resolve := contexts.resolver(node, servicePackage)
+			sdk := newSDKResolver(node, sdkImport)
+
+			// Collect the import specs of this file keyed by the name they are referenced with
+			fileImports := make(map[string]string)
+			// Types declared in the service package are referred to through the service import of the wrapper
+			localPkg := "service"
+			localImport := fmt.Sprintf("%s %q", localPkg, servicePackage)
+			for _, imp := range node.Imports {
+				importPath := strings.Trim(imp.Path.Value, "\"")
+				if imp.Name != nil {
+					fileImports[imp.Name.Name] = fmt.Sprintf("%s %q", imp.Name.Name, importPath)
+				} else {
+					fileImports[importPath[strings.LastIndex(importPath, "/")+1:]] = fmt.Sprintf("%q", importPath)
+				}
+			}
+
+			for _, decl := range node.Decls {
+				if fn, isFn := decl.(*ast.FuncDecl); isFn && (fn.Recv == nil || receiver != nil && receiverType(fn) == receiver.Type) {
+					current = fn
+					// check if function name starts with simple letter
+					if unicode.IsLower(rune(fn.Name.Name[0])) {
+						continue
+					}
+					if receiver != nil && fn.Recv == nil && fn.Name.Name == receiver.Constructor {
+						continue
+					}
+					if fn.Recv == nil && slices.ContainsFunc(middleware, func(m Middleware) bool { return m.Name == fn.Name.Name }) {
+						continue
+					}
+
+					if lifecycleHooks[fn.Name.Name] {
+						if err := validateLifecycleHook(fn, resolve); err != nil {
+							return err
+						}
+						if slices.Contains(lifecycle, fn.Name.Name) {
+							return fmt.Errorf("lifecycle hook %s is declared both as a function and as a method of %s", fn.Name.Name, receiver.Type)
+						}
+						lifecycle = append(lifecycle, fn.Name.Name)
+						if fn.Recv != nil {
+							receiver.Lifecycle = append(receiver.Lifecycle, fn.Name.Name)
+						}
+						continue
+					}
+					if fn.Name.Name == healthCheckName {
+						if health != nil {
+							return fmt.Errorf("health check %s is declared both as a function and as a method of %s", fn.Name.Name, receiver.Type)
+						}
+						if health, err = parseHealthCheck(fn, resolve, localPkg); err != nil {
+							return err
+						}
+						continue
+					}
+
+					// Validate the function's parameters
+					contextType, reason := validateFunctionParams(fn, resolve)
+					if reason == "" {
+						reason = unsupportedShape(fn, sdk)
+					}
+					if reason != "" {
+						position := fset.Position(fn.Pos())
+						skipped = append(skipped, SkippedFunction{Name: fn.Name.Name, File: position.Filename, Line: position.Line, Reason: reason})
+						continue
+					}
+
+					directives := parseDirectives(fn.Doc)
+					methodOptions := parseDirectiveArgs(directives["method"])
+					customName, hasCustomName := methodOptions["name"]
+					if hasCustomName {
+						if customName == "" || customName == "true" {
+							return fmt.Errorf("function %s: //polycode:method name must not be empty", fn.Name.Name)
+						}
+						delete(methodOptions, "name")
+					}
+
+					handler, handlerName, err := workflowHandler(fn, directives, contextType, sdk)
+					if err != nil {
+						return err
+					}
+					if args, ok := directives[subscribeDirective]; ok {
+						switch {
+						case handler != "":
+							return fmt.Errorf("function %s: %s handlers cannot subscribe to events", fn.Name.Name, handler)
+						case args != "":
+							return fmt.Errorf("function %s: //polycode:subscribe takes no arguments, the event is the type of the input", fn.Name.Name)
+						}
+						if err = checkSubscriber(fn, contextType, sdk); err != nil {
+							return err
+						}
+						handler, handlerName = handlerEvent, fn.Name.Name
+					}
+					if handler == handlerEvent && hasCustomName {
+						return fmt.Errorf("function %s: event subscribers are dispatched by event and take no //polycode:method name", fn.Name.Name)
+					}
+					if handler != "" && hasCustomName {
+						return fmt.Errorf("function %s: %s handlers are named by //polycode:%s name=<name>", fn.Name.Name, handler, handler)
+					}
+
+					instances, err := genericInstances(fn, parseDirectiveList(fn.Doc, "instantiate"))
+					if err != nil {
+						return err
+					}
+					if hasCustomName && fn.Type.TypeParams != nil {
+						return fmt.Errorf("function %s: generic functions are named by their //polycode:instantiate directives", fn.Name.Name)
+					}
+
+					var description string
+
+					if fn.Doc == nil || len(fn.Doc.List) == 0 {
+						description = ""
+					} else {
+						description = extractDescriptionFromComments(fn.Doc.List)
+					}
+
+					concurrencyLimit := 0
+					if value, ok := directives["concurrency"]; ok {
+						concurrencyLimit, err = strconv.Atoi(value)
+						if err != nil || concurrencyLimit <= 0 {
+							return fmt.Errorf("function %s: //polycode:concurrency must be a positive integer, got %q", fn.Name.Name, value)
+						}
+					}
+
+					var authPolicy *AuthPolicy
+					if args, ok := directives["auth"]; ok {
+						if authPolicy, err = parseAuthPolicy(args); err != nil {
+							return fmt.Errorf("function %s: %w", fn.Name.Name, err)
+						}
+					}
+
+					var timeout time.Duration
+					if args, ok := directives["timeout"]; ok {
+						if timeout, err = parseTimeout(args); err != nil {
+							return fmt.Errorf("function %s: %w", fn.Name.Name, err)
+						}
+					}
+
+					var retryPolicy *RetryPolicy
+					if args, ok := directives["retry"]; ok {
+						if retryPolicy, err = parseRetryPolicy(args); err != nil {
+							return fmt.Errorf("function %s: %w", fn.Name.Name, err)
+						}
+					}
+					if handler == handlerEvent && (timeout > 0 || retryPolicy != nil) {
+						return fmt.Errorf("function %s: the delivery of events is retried by the runtime, subscribers take no //polycode:timeout or //polycode:retry", fn.Name.Name)
+					}
+					if handler != "" && (timeout > 0 || retryPolicy != nil) {
+						return fmt.Errorf("function %s: %s handlers run inside their workflow and take no //polycode:timeout or //polycode:retry", fn.Name.Name, handler)
+					}
+
+					routes, err := parseHTTPRoutes(parseDirectiveList(fn.Doc, "http"))
+					if err != nil {
+						return fmt.Errorf("function %s: %w", fn.Name.Name, err)
+					}
+					if len(routes) > 0 && handler != "" {
+						return fmt.Errorf("function %s: %s handlers are not called through routes and take no //polycode:http", fn.Name.Name, handler)
+					}
+					if len(routes) > 0 && len(instances) > 1 {
+						return fmt.Errorf("function %s: the instantiations of a generic function cannot share //polycode:http routes", fn.Name.Name)
+					}
+
+					deprecation, err := parseDeprecation(fn.Doc)
+					if err != nil {
+						return fmt.Errorf("function %s: %w", fn.Name.Name, err)
+					}
+
+					for _, instance := range instances {
+						OriginalName := fn.Name.Name
+						exposedName := OriginalName
+						if instance.name != "" {
+							OriginalName, exposedName = instance.name, instance.name
+						} else if hasCustomName {
+							exposedName = customName
+						} else if handler != "" {
+							exposedName = handlerName
+						}
+
+						// Extract the function name and input/output parameters
+						methodName := strings.ToLower(exposedName) // Normalize to lowercase
+						// Signals and queries are dispatched apart from methods, their names may overlap
+						key := strings.TrimSpace(handler + " " + methodName)
+						if first, ok := declared[key]; ok {
+							return fmt.Errorf("method name collision: %s at %s and %s at %s are both exposed as %q",
+								first.Name.Name, fset.Position(first.Pos()), OriginalName, fset.Position(fn.Pos()), key)
+						}
+						declared[key] = fn
+
+						params, err := substituteTypeParams(flattenFields(fn.Type.Params), instance.subst)
+						if err != nil {
+							return fmt.Errorf("function %s: %w", OriginalName, err)
+						}
+						results, err := substituteTypeParams(flattenFields(fn.Type.Results), instance.subst)
+						if err != nil {
+							return fmt.Errorf("function %s: %w", OriginalName, err)
+						}
+						for _, param := range params[1:] {
+							if iface, ok := param.(*ast.InterfaceType); ok && len(iface.Methods.List) > 0 {
+								return fmt.Errorf("function %s: inputs of inline interface types cannot be decoded, use a concrete type", fn.Name.Name)
+							}
+						}
+
+						for _, param := range params[1:] {
+							if err := checkReferable(param, fileImports, wrapperPackage); err != nil {
+								return fmt.Errorf("function %s: input %w", fn.Name.Name, err)
+							}
+						}
+						for _, result := range results[:len(results)-1] {
+							if err := checkReferable(result, fileImports, wrapperPackage); err != nil {
+								return fmt.Errorf("function %s: output %w", fn.Name.Name, err)
+							}
+						}
+
+						var inputType, outputType, inputCollection, inputKeyType, inputElemType string
+						var isInputPointer, isInputPrimitive, isOutputPointer, isOutputPrimitive bool
+						var isInputStream, isOutputStream, isMultiInput bool
+						var methodParams []ParamInfo
+						if _, variadic := params[len(params)-1].(*ast.Ellipsis); len(params) > 2 || len(params) == 2 && variadic {
+							// The parameters are bundled into an input struct generated in the wrapper
+							isMultiInput = true
+							names := paramNames(fn.Type.Params)
+							for i, param := range params[1:] {
+								if _, isStream, _ := streamElement(param, sdk); isStream {
+									return fmt.Errorf("function %s: streams cannot be combined with other parameters", fn.Name.Name)
+								}
+								name := names[i+1]
+								if name == "" || name == "_" {
+									name = fmt.Sprintf("arg%d", i+1)
+								}
+								typeStr, isPointer, _ := extractType(param, localPkg)
+								if isPointer {
+									typeStr = "*" + typeStr
+								}
+								_, isVariadic := param.(*ast.Ellipsis)
+								methodParams = append(methodParams, ParamInfo{
+									Name:       strings.ToUpper(name[:1]) + name[1:],
+									JSONName:   name,
+									Type:       typeStr,
+									IsVariadic: isVariadic,
+								})
+								imports = append(imports, typeImports(param, fileImports, localImport, sdk)...)
+							}
+						} else if len(params) == 2 {
+							input := params[1]
+							if isSDKStream(input, sdk) {
+								streams = append(streams, fn.Name.Name)
+							}
+							if input, isInputStream, err = streamElement(input, sdk); err != nil {
+								return fmt.Errorf("function %s: input %w", fn.Name.Name, err)
+							}
+							inputType, isInputPointer, isInputPrimitive = extractType(input, localPkg)
+							inputCollection, inputKeyType, inputElemType = collectionType(input, localPkg)
+							imports = append(imports, typeImports(input, fileImports, localImport, sdk)...)
+						}
+						if len(results) == 2 {
+							output := results[0]
+							if isSDKStream(output, sdk) {
+								streams = append(streams, fn.Name.Name)
+							}
+							if output, isOutputStream, err = streamElement(output, sdk); err != nil {
+								return fmt.Errorf("function %s: output %w", fn.Name.Name, err)
+							}
+							outputType, isOutputPointer, isOutputPrimitive = extractType(output, localPkg)
+							imports = append(imports, typeImports(output, fileImports, localImport, sdk)...)
+						}
+
+						// Append the method and its corresponding input type to methods
+						methods = append(methods, MethodInfo{
+							OriginalName:      OriginalName,
+							GenericName:       instance.generic,
+							TypeArgs:          instance.typeArgs,
+							Name:              methodName,
+							Description:       description,
+							Doc:               extractDocComment(fn.Doc),
+							HasInput:          inputType != "" || isMultiInput,
+							InputType:         inputType,
+							IsInputPointer:    isInputPointer,
+							IsInputPrimitive:  isInputPrimitive,
+							InputCollection:   inputCollection,
+							InputKeyType:      inputKeyType,
+							InputElemType:     inputElemType,
+							HasOutput:         outputType != "",
+							OutputType:        outputType,
+							IsOutputPointer:   isOutputPointer,
+							IsOutputPrimitive: isOutputPrimitive,
+							IsMultiInput:      isMultiInput,
+							Params:            methodParams,
+							IsInputStream:     isInputStream,
+							IsOutputStream:    isOutputStream,
+							IsWorkflow:        contextType == "Workflow",
+							IsService:         contextType == "Service",
+							ConcurrencyLimit:  concurrencyLimit,
+							ExposedName:       exposedName,
+							Options:           methodOptions,
+							Auth:              authPolicy,
+							Timeout:           timeout,
+							Retry:             retryPolicy,
+							Routes:            routes,
+							IsMethod:          fn.Recv != nil,
+							Deprecated:        deprecation,
+							IsSignal:          handler == handlerSignal,
+							IsQuery:           handler == handlerQuery,
+							IsSubscriber:      handler == handlerEvent,
+						})
+					}
+				}
+			}
+		}
+		return nil
+	}()
+
+	if err != nil {
+		if current != nil {
+			err = &positionError{pos: fset.Position(current.Pos()), err: err}
+		}
+		return parsedService{}, err
+	}
+
+	if len(methods) > 0 && !canImport(wrapperPackage, servicePackage) {
+		return parsedService{}, fmt.Errorf("service package %s is internal and cannot be imported by the generated package %s, move the service out of the internal folder or the output folder under its parent",
+			servicePackage, wrapperPackage)
+	}
+
+	// Remove duplicate imports and keep the output independent of file and declaration order
+	imports = unique(imports)
+	sort.Strings(imports)
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+	sort.Strings(lifecycle)
+	return parsedService{
+		Methods:    methods,
+		Imports:    imports,
+		Lifecycle:  lifecycle,
+		Receiver:   receiver,
+		Middleware: middleware,
+		Health:     health,
+		Skipped:    skipped,
+		Streams:    unique(streams),
+	}, nil
+}
+
+// streamElement returns the element type of a streaming parameter or result, a channel or polycode.Stream[T]
+// of the SDK whatever name the file imports it with, other types are returned unchanged. Send-only
+// channels cannot be read by the wrapper and are rejected.
+func streamElement(expr ast.Expr, sdk sdkResolver) (ast.Expr, bool, error) {
+	switch t := expr.(type) {
+	case *ast.ChanType:
+		if t.Dir == ast.SEND {
+			return nil, false, fmt.Errorf("must not be a send-only channel")
+		}
+		return t.Value, true, nil
+	case *ast.IndexExpr:
+		if sdk(t.X) == "Stream" {
+			return t.Index, true, nil
+		}
+	}
+	return expr, false, nil
+}
+
+// isSDKStream reports whether a streaming parameter or result is a polycode.Stream rather than a channel
+func isSDKStream(expr ast.Expr, sdk sdkResolver) bool {
+	_, isIndex := expr.(*ast.IndexExpr)
+	_, isStream, _ := streamElement(expr, sdk)
+	return isIndex && isStream
+}
+
+// typeImports returns the import specs referenced by package qualifiers in a type expression,
+// unqualified types are declared in the service package and need localImport
+func typeImports(expr ast.Expr, fileImports map[string]string, localImport string, sdk sdkResolver) []string {
+	var imports []string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if pkgIdent, ok := n.X.(*ast.Ident); ok {
+				// The wrapper imports the SDK package as polycode itself, an SDK import under another
+				// name is kept so the types qualified with it resolve
+				if spec, ok := fileImports[pkgIdent.Name]; ok && (pkgIdent.Name != "polycode" || sdk(n) == "") {
+					imports = append(imports, spec)
+				}
+			}
+			return false
+		case *ast.Ident:
+			if isLocalType(n) {
+				imports = append(imports, localImport)
+			}
+		case *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+			// Field and method names of inline types are not type references
+			return false
+		}
+		return true
+	})
+	return imports
+}
+
+// checkReferable reports types of an input or output expression the generated package cannot refer to,
+// unexported types of the service package and types of internal packages it is not allowed to import
+func checkReferable(expr ast.Expr, fileImports map[string]string, wrapperPackage string) error {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			pkgIdent, ok := n.X.(*ast.Ident)
+			if !ok {
+				return false
+			}
+			if spec, ok := fileImports[pkgIdent.Name]; ok {
+				fields := strings.Fields(spec)
+				importPath, _ := strconv.Unquote(fields[len(fields)-1])
+				if !canImport(wrapperPackage, importPath) {
+					err = fmt.Errorf("type %s.%s is declared in the internal package %s, which the generated package %s cannot import",
+						pkgIdent.Name, n.Sel.Name, importPath, wrapperPackage)
+				}
+			}
+			return false
+		case *ast.Ident:
+			if isLocalType(n) && !n.IsExported() {
+				err = fmt.Errorf("type %s is unexported, export it so the generated package can refer to it", n.Name)
+			}
+		case *ast.StructType, *ast.InterfaceType, *ast.FuncType:
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// canImport applies the internal package rule, a path with an internal element can only be imported
+// from the tree rooted at the parent of that element
+func canImport(importer string, importPath string) bool {
+	parts := strings.Split(importPath, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == "internal" {
+			root := strings.Join(parts[:i], "/")
+			return importer == root || strings.HasPrefix(importer, root+"/")
+		}
+	}
+	return true
+}
+
+// isLocalType reports whether an unqualified type name refers to a type of the service package
+func isLocalType(ident *ast.Ident) bool {
+	return types.Universe.Lookup(ident.Name) == nil
+}
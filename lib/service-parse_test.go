@@ -0,0 +1,381 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// methodShape is the part of a MethodInfo that depends on the signature of the service function
+type methodShape struct {
+	Name              string
+	ExposedName       string
+	HasInput          bool
+	InputType         string
+	IsInputPointer    bool
+	IsInputPrimitive  bool
+	InputCollection   string
+	InputKeyType      string
+	InputElemType     string
+	HasOutput         bool
+	OutputType        string
+	IsOutputPointer   bool
+	IsOutputPrimitive bool
+	IsMultiInput      bool
+	Params            []ParamInfo
+	IsInputStream     bool
+	IsOutputStream    bool
+	IsWorkflow        bool
+	IsService         bool
+}
+
+func shapeOf(m MethodInfo) methodShape {
+	return methodShape{
+		Name: m.Name, ExposedName: m.ExposedName,
+		HasInput: m.HasInput, InputType: m.InputType, IsInputPointer: m.IsInputPointer, IsInputPrimitive: m.IsInputPrimitive,
+		InputCollection: m.InputCollection, InputKeyType: m.InputKeyType, InputElemType: m.InputElemType,
+		HasOutput: m.HasOutput, OutputType: m.OutputType, IsOutputPointer: m.IsOutputPointer, IsOutputPrimitive: m.IsOutputPrimitive,
+		IsMultiInput: m.IsMultiInput, Params: m.Params,
+		IsInputStream: m.IsInputStream, IsOutputStream: m.IsOutputStream,
+		IsWorkflow: m.IsWorkflow, IsService: m.IsService,
+	}
+}
+
+const testServicePackage = "example.com/app/services/orders"
+
+// parseSource writes the source as the only file of a service folder and parses it
+func parseSource(t *testing.T, src string) (parsedService, error) {
+	t.Helper()
+	src = "package orders\n\nimport (\n\t\"example.com/app/models\"\n\t\"github.com/cloudimpl/next-coder-sdk/polycode\"\n)\n\nvar _ models.Order\n\n" + src
+	return parseFiles(t, map[string]string{"orders.go": src})
+}
+
+// parseFiles writes the files, keyed by their path in the service folder, and parses the folder
+func parseFiles(t *testing.T, files map[string]string) (parsedService, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return parseDir(dir, testServicePackage, "example.com/app/.polycode", nil, nil, DefaultSDKImport)
+}
+
+func TestParseDirShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want methodShape
+	}{
+		{
+			name: "struct input and output",
+			src:  "func Create(ctx polycode.ServiceContext, req models.Order) (models.Receipt, error) { return models.Receipt{}, nil }",
+			want: methodShape{Name: "create", ExposedName: "Create", HasInput: true, InputType: "models.Order", HasOutput: true, OutputType: "models.Receipt", IsService: true},
+		},
+		{
+			name: "context only",
+			src:  "func Ping(ctx polycode.ServiceContext) error { return nil }",
+			want: methodShape{Name: "ping", ExposedName: "Ping", IsService: true},
+		},
+		{
+			name: "primitives",
+			src:  "func Echo(ctx polycode.ServiceContext, s string) (string, error) { return s, nil }",
+			want: methodShape{Name: "echo", ExposedName: "Echo", HasInput: true, InputType: "string", IsInputPrimitive: true, HasOutput: true, OutputType: "string", IsOutputPrimitive: true, IsService: true},
+		},
+		{
+			name: "local pointer types in a workflow",
+			src:  "type Local struct{ ID string }\n\nfunc Get(ctx polycode.WorkflowContext, id *Local) (*Local, error) { return nil, nil }",
+			want: methodShape{Name: "get", ExposedName: "Get", HasInput: true, InputType: "service.Local", IsInputPointer: true, HasOutput: true, OutputType: "service.Local", IsOutputPointer: true, IsWorkflow: true},
+		},
+		{
+			name: "slice input",
+			src:  "func Sum(ctx polycode.ServiceContext, nums []int) (int, error) { return 0, nil }",
+			want: methodShape{Name: "sum", ExposedName: "Sum", HasInput: true, InputType: "[]int", InputCollection: "slice", InputElemType: "int", HasOutput: true, OutputType: "int", IsOutputPrimitive: true, IsService: true},
+		},
+		{
+			name: "map input of pointers",
+			src:  "func Index(ctx polycode.ServiceContext, m map[string]*models.Order) (int, error) { return 0, nil }",
+			want: methodShape{Name: "index", ExposedName: "Index", HasInput: true, InputType: "map[string]*models.Order", InputCollection: "map", InputKeyType: "string", InputElemType: "*models.Order", HasOutput: true, OutputType: "int", IsOutputPrimitive: true, IsService: true},
+		},
+		{
+			name: "several parameters",
+			src:  "func Buy(ctx polycode.ServiceContext, sku string, qty int) error { return nil }",
+			want: methodShape{Name: "buy", ExposedName: "Buy", HasInput: true, IsMultiInput: true, IsService: true, Params: []ParamInfo{
+				{Name: "Sku", JSONName: "sku", Type: "string"},
+				{Name: "Qty", JSONName: "qty", Type: "int"},
+			}},
+		},
+		{
+			name: "variadic parameter",
+			src:  "func Tag(ctx polycode.ServiceContext, tags ...string) error { return nil }",
+			want: methodShape{Name: "tag", ExposedName: "Tag", HasInput: true, IsMultiInput: true, IsService: true, Params: []ParamInfo{
+				{Name: "Tags", JSONName: "tags", Type: "[]string", IsVariadic: true},
+			}},
+		},
+		{
+			name: "streams",
+			src:  "func Watch(ctx polycode.ServiceContext, in polycode.Stream[int]) (polycode.Stream[string], error) { return nil, nil }",
+			want: methodShape{Name: "watch", ExposedName: "Watch", HasInput: true, InputType: "int", IsInputPrimitive: true, IsInputStream: true, HasOutput: true, OutputType: "string", IsOutputPrimitive: true, IsOutputStream: true, IsService: true},
+		},
+		{
+			name: "renamed by directive",
+			src:  "//polycode:method name=place-order\nfunc Create(ctx polycode.ServiceContext, req models.Order) error { return nil }",
+			want: methodShape{Name: "place-order", ExposedName: "place-order", HasInput: true, InputType: "models.Order", IsService: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseSource(t, tt.src+"\n\nfunc unexported(ctx polycode.ServiceContext) error { return nil }\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			methods := parsed.Methods
+			if len(methods) != 1 {
+				t.Fatalf("got %d methods, want 1", len(methods))
+			}
+			if got := shapeOf(methods[0]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirAliasedSDKStream(t *testing.T) {
+	src := "package orders\n\nimport sdk \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\n" +
+		"func Watch(ctx sdk.ServiceContext, in sdk.Stream[int]) (sdk.Stream[string], error) { return nil, nil }\n"
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "orders.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Type checking finds the context types of the SDK, whatever name they are imported with
+	contexts := contextTypes{DefaultSDKImport + ".ServiceContext": "Service"}
+	parsed, err := parseDir(dir, testServicePackage, "example.com/app/.polycode", nil, contexts, DefaultSDKImport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Methods) != 1 {
+		t.Fatalf("got %d methods, want 1", len(parsed.Methods))
+	}
+	want := methodShape{Name: "watch", ExposedName: "Watch", HasInput: true, InputType: "int", IsInputPrimitive: true, IsInputStream: true, HasOutput: true, OutputType: "string", IsOutputPrimitive: true, IsOutputStream: true, IsService: true}
+	if got := shapeOf(parsed.Methods[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if want := []string{"Watch"}; !reflect.DeepEqual(parsed.Streams, want) {
+		t.Errorf("got streams %v, want %v", parsed.Streams, want)
+	}
+}
+
+func TestParseDirDirectives(t *testing.T) {
+	src := `// Create places an order
+// @description Places an order
+//polycode:timeout 30s
+//polycode:retry max=3 backoff=exponential
+//polycode:auth roles=admin,clerk
+//polycode:method name=place idempotent
+func Create(ctx polycode.ServiceContext, req models.Order) error { return nil }
+`
+	parsed, err := parseSource(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods, imports := parsed.Methods, parsed.Imports
+	if len(methods) != 1 {
+		t.Fatalf("got %d methods, want 1", len(methods))
+	}
+	m := methods[0]
+	if m.Doc != "Create places an order" || m.Description != "Places an order" {
+		t.Errorf("got doc %q and description %q", m.Doc, m.Description)
+	}
+	if m.Timeout != 30*time.Second {
+		t.Errorf("got timeout %v, want 30s", m.Timeout)
+	}
+	if m.Retry == nil || m.Retry.MaxAttempts != 3 {
+		t.Errorf("got retry %+v, want 3 attempts", m.Retry)
+	}
+	if m.Auth == nil || !reflect.DeepEqual(m.Auth.Roles, []string{"admin", "clerk"}) {
+		t.Errorf("got auth %+v, want roles admin and clerk", m.Auth)
+	}
+	if m.ExposedName != "place" || m.Options["idempotent"] != "true" {
+		t.Errorf("got exposed name %q and options %v", m.ExposedName, m.Options)
+	}
+	// The service package is only imported when its own types are used
+	want := []string{`"example.com/app/models"`}
+	if !reflect.DeepEqual(imports, want) {
+		t.Errorf("got imports %v, want %v", imports, want)
+	}
+}
+
+func TestParseDirWorkflowHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantKind string // signal, query or empty for a workflow method
+		wantName string
+	}{
+		{
+			name:     "signal by name",
+			src:      "func OnPaymentSignal(ctx polycode.WorkflowContext, sig models.Money) error { return nil }",
+			wantKind: handlerSignal,
+			wantName: "Payment",
+		},
+		{
+			name:     "query by name",
+			src:      "func OnStatusQuery(ctx polycode.WorkflowContext) (models.Money, error) { return models.Money{}, nil }",
+			wantKind: handlerQuery,
+			wantName: "Status",
+		},
+		{
+			name:     "signal suffix without On",
+			src:      "func ProcessSignal(ctx polycode.WorkflowContext, req models.Money) (models.Money, error) { return req, nil }",
+			wantName: "ProcessSignal",
+		},
+		{
+			name:     "query suffix without On",
+			src:      "func PriceQuery(ctx polycode.WorkflowContext, req models.Money) (models.Money, error) { return req, nil }",
+			wantName: "PriceQuery",
+		},
+		{
+			name:     "On not followed by a word",
+			src:      "func OnlineSignal(ctx polycode.WorkflowContext, req models.Money) error { return nil }",
+			wantName: "OnlineSignal",
+		},
+		{
+			name:     "signal name without the handler signature",
+			src:      "func OnPaymentSignal(ctx polycode.WorkflowContext, req models.Money) (models.Money, error) { return req, nil }",
+			wantName: "OnPaymentSignal",
+		},
+		{
+			name:     "query by directive",
+			src:      "//polycode:query name=price\nfunc PriceQuery(ctx polycode.WorkflowContext, req models.Money) (models.Money, error) { return req, nil }",
+			wantKind: handlerQuery,
+			wantName: "price",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseSource(t, tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parsed.Methods) != 1 {
+				t.Fatalf("got %d methods, want 1", len(parsed.Methods))
+			}
+			m := parsed.Methods[0]
+			var kind string
+			switch {
+			case m.IsSignal:
+				kind = handlerSignal
+			case m.IsQuery:
+				kind = handlerQuery
+			}
+			if kind != tt.wantKind || m.ExposedName != tt.wantName {
+				t.Errorf("got kind %q exposed as %q, want kind %q exposed as %q", kind, m.ExposedName, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestParseDirErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "colliding names",
+			src:  "func Place(ctx polycode.ServiceContext) error { return nil }\n\n//polycode:method name=place\nfunc Create(ctx polycode.ServiceContext) error { return nil }",
+			want: `are both exposed as "place"`,
+		},
+		{
+			name: "invalid timeout",
+			src:  "//polycode:timeout soon\nfunc Create(ctx polycode.ServiceContext) error { return nil }",
+			want: "//polycode:timeout must be a positive duration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSource(t, tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirSkippedFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want SkippedFunction
+	}{
+		{
+			name: "missing context",
+			src:  "func Create(req models.Order) error { return nil }",
+			want: SkippedFunction{Name: "Create", Line: 12, Reason: "first parameter must be polycode.ServiceContext or polycode.WorkflowContext, got models.Order"},
+		},
+		{
+			name: "anonymous struct input",
+			src:  "func Create(ctx polycode.ServiceContext, req struct{ ID string }) error { return nil }",
+			want: SkippedFunction{Name: "Create", Line: 12, Reason: "parameter req: anonymous struct types cannot be referred to by the generated package, declare a named type"},
+		},
+		{
+			name: "bad map key",
+			src:  "func Create(ctx polycode.ServiceContext, totals map[float64]int) error { return nil }",
+			want: SkippedFunction{Name: "Create", Line: 12, Reason: "parameter totals: map keys of type float64 cannot be encoded as JSON object keys"},
+		},
+		{
+			name: "wrong result count",
+			src:  "func Create(ctx polycode.ServiceContext) (models.Order, models.Order, error) { return models.Order{}, models.Order{}, nil }",
+			want: SkippedFunction{Name: "Create", Line: 12, Reason: "expected (output, error) or error results, got 3 results"},
+		},
+		{
+			name: "last result not an error",
+			src:  "func Create(ctx polycode.ServiceContext) models.Order { return models.Order{} }",
+			want: SkippedFunction{Name: "Create", Line: 12, Reason: "expected (output, error) or error results, the last result must be error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseSource(t, "func Ping(ctx polycode.ServiceContext) error { return nil }\n\n"+tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if methods := parsed.Methods; len(methods) != 1 || methods[0].OriginalName != "Ping" {
+				t.Errorf("got methods %+v, want only Ping", methods)
+			}
+			if len(parsed.Skipped) != 1 {
+				t.Fatalf("got skipped %+v, want one function", parsed.Skipped)
+			}
+			got := parsed.Skipped[0]
+			if filepath.Base(got.File) != "orders.go" {
+				t.Errorf("got file %s, want orders.go", got.File)
+			}
+			got.File = ""
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirSkipsSubfoldersAndTests(t *testing.T) {
+	parsed, err := parseFiles(t, map[string]string{
+		"orders.go":                    "package orders\n\nimport \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc Ping(ctx polycode.ServiceContext) error { return nil }\n",
+		"orders_test.go":               "package orders\n\nimport \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc Tested(ctx polycode.ServiceContext) error { return nil }\n",
+		filepath.Join("sub", "sub.go"): "package sub\n\nimport \"github.com/cloudimpl/next-coder-sdk/polycode\"\n\nfunc Nested(ctx polycode.ServiceContext) error { return nil }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if methods := parsed.Methods; len(methods) != 1 || methods[0].OriginalName != "Ping" {
+		t.Errorf("got %+v, want only Ping", methods)
+	}
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// ServiceReceiver is the struct whose methods implement a receiver based service, like
+// func (o *Orders) Create(ctx polycode.ServiceContext, req CreateRequest) (CreateResponse, error)
+type ServiceReceiver struct {
+	Type        string   // Struct type declared by the service package
+	Constructor string   // New<Type> function returning *<Type>, empty to use a zero value
+	Lifecycle   []string // Lifecycle hooks declared as methods rather than functions
+}
+
+// receiverType returns the base type name of a method receiver, like Orders for (o *Orders),
+// empty for functions and generic receivers
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// takesContext reports whether the first parameter of a function is a polycode service or workflow context
+func takesContext(fn *ast.FuncDecl, resolve contextResolver) bool {
+	params := flattenFields(fn.Type.Params)
+	return len(params) > 0 && resolve(params[0]) != ""
+}
+
+// findReceiver returns the struct whose exported methods take a polycode context, nil when the
+// service is made of functions only. A service has a single receiver, built by its New<Type>
+// constructor when the package declares one.
+func findReceiver(fset *token.FileSet, files []*ast.File, servicePackage string, contexts contextTypes) (*ServiceReceiver, error) {
+	var receiver *ServiceReceiver
+	var first *ast.FuncDecl
+	for _, file := range files {
+		resolve := contexts.resolver(file, servicePackage)
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || !fn.Name.IsExported() || !takesContext(fn, resolve) {
+				continue
+			}
+
+			name := receiverType(fn)
+			switch {
+			case name == "":
+				return nil, &positionError{pos: fset.Position(fn.Pos()), err: fmt.Errorf("method %s: generic receivers cannot be services", fn.Name.Name)}
+			case !ast.IsExported(name):
+				return nil, &positionError{pos: fset.Position(fn.Pos()), err: fmt.Errorf("method %s: receiver type %s is unexported and cannot be constructed by the generated package, export it", fn.Name.Name, name)}
+			case receiver == nil:
+				receiver, first = &ServiceReceiver{Type: name}, fn
+			case receiver.Type != name:
+				return nil, &positionError{pos: fset.Position(fn.Pos()), err: fmt.Errorf("method %s: service methods are declared on both %s (%s) and %s, a service has a single receiver",
+					fn.Name.Name, receiver.Type, fset.Position(first.Pos()), name)}
+			}
+		}
+	}
+	if receiver == nil {
+		return nil, nil
+	}
+
+	constructor := "New" + receiver.Type
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != constructor {
+				continue
+			}
+
+			if !isConstructorOf(fn, receiver.Type) {
+				return nil, &positionError{pos: fset.Position(fn.Pos()), err: fmt.Errorf("function %s: receiver constructors must have the signature func %s() *%s", constructor, constructor, receiver.Type)}
+			}
+			receiver.Constructor = constructor
+		}
+	}
+	return receiver, nil
+}
+
+// isConstructorOf reports whether a function has the func() *<typeName> signature
+func isConstructorOf(fn *ast.FuncDecl, typeName string) bool {
+	results := flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams != nil || len(flattenFields(fn.Type.Params)) > 0 || len(results) != 1 {
+		return false
+	}
+	star, ok := results[0].(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	return ok && ident.Name == typeName
+}
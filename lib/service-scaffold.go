@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const serviceScaffoldTemplate = `package {{.Package}}
+
+import (
+	{{.SDKImport}}
+)
+
+// Hello{{.Struct}}Request is the input of Hello{{.Struct}}
+type Hello{{.Struct}}Request struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// Hello{{.Struct}}Response is the output of Hello{{.Struct}}
+type Hello{{.Struct}}Response struct {
+	Message string ` + "`json:\"message\"`" + `
+}
+
+// Hello{{.Struct}} greets the caller.
+// @description Sample service method of {{.Name}}
+func Hello{{.Struct}}(ctx polycode.ServiceContext, req Hello{{.Struct}}Request) (Hello{{.Struct}}Response, error) {
+	return Hello{{.Struct}}Response{Message: "Hello " + req.Name}, nil
+}
+{{if .WithWorkflow}}
+// Run{{.Struct}} is a sample workflow, workflows take polycode.WorkflowContext and can run long operations durably.
+// @description Sample workflow of {{.Name}}
+func Run{{.Struct}}(ctx polycode.WorkflowContext, req Hello{{.Struct}}Request) (Hello{{.Struct}}Response, error) {
+	return Hello{{.Struct}}Response{Message: "Workflow started for " + req.Name}, nil
+}
+{{end}}`
+
+const serviceReadmeTemplate = `# {{.Name}}
+
+TODO: describe what the {{.Name}} service does.
+
+## Methods
+
+- ` + "`Hello{{.Struct}}`" + ` sample service method
+{{- if .WithWorkflow}}
+- ` + "`Run{{.Struct}}`" + ` sample workflow
+{{- end}}
+
+Exported functions taking ` + "`polycode.ServiceContext`" + ` or ` + "`polycode.WorkflowContext`" + ` are exposed by the service.
+Run ` + "`next-gen`" + ` after changing them to regenerate the wrappers under ` + "`{{.OutputDir}}`" + `.
+`
+
+// validServiceName matches service names accepted by init, nested folders are separated with /
+var validServiceName = regexp.MustCompile(`^[a-z][a-z0-9-]*(/[a-z][a-z0-9-]*)*$`)
+
+// ScaffoldService creates a new service folder below the first services root with a sample method,
+// an optional sample workflow, their request/response structs and a README stub
+func ScaffoldService(appPath string, name string, withWorkflow bool, opts Options) (string, error) {
+	if !validServiceName.MatchString(name) {
+		return "", fmt.Errorf("invalid service name %q, use lowercase letters, digits and dashes", name)
+	}
+
+	serviceDir := filepath.Join(opts.ServicesDirs[0], filepath.FromSlash(name))
+	servicePath := filepath.Join(appPath, serviceDir)
+	if ok, err := hasGoFiles(servicePath); err == nil && ok {
+		return "", fmt.Errorf("service folder %s already contains Go files", serviceDir)
+	}
+	if err := os.MkdirAll(servicePath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create service folder: %w", err)
+	}
+
+	data := map[string]any{
+		"Name":         serviceNameForDir(name),
+		"Package":      strings.ReplaceAll(path.Base(name), "-", ""),
+		"Struct":       toPascalCase(serviceNameForDir(name)),
+		"WithWorkflow": withWorkflow,
+		"OutputDir":    filepath.ToSlash(filepath.Clean(opts.OutputDir)),
+		"SDKImport":    sdkImportSpec(opts.SDKImport),
+	}
+
+	code, err := executeScaffold(serviceScaffoldTemplate, data)
+	if err != nil {
+		return "", err
+	}
+	if code, err = format.Source(code); err != nil {
+		return "", fmt.Errorf("failed to format service scaffold: %w", err)
+	}
+	readme, err := executeScaffold(serviceReadmeTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string][]byte{
+		data["Package"].(string) + ".go": code,
+		"README.md":                      readme,
+	}
+	for file, content := range files {
+		filePath := filepath.Join(servicePath, file)
+		if _, err := os.Stat(filePath); err == nil {
+			slog.Warn("Keeping existing file", "path", filePath)
+			continue
+		}
+		slog.Info("Writing service scaffold", "path", filePath)
+		if err = writeFileAtomic(filePath, content, 0644); err != nil {
+			return "", err
+		}
+	}
+	return serviceDir, nil
+}
+
+func executeScaffold(text string, data map[string]any) ([]byte, error) {
+	tmpl, err := template.New("scaffold").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to generate service scaffold: %w", err)
+	}
+	return buf.Bytes(), nil
+}
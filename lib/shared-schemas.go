@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// sharedSchemaFolder holds the schemas of the struct types used by several services, relative to the output folder
+const sharedSchemaFolder = "schema/common"
+
+// SharedType is a file of .polycode/schema/common, the schema of a struct type used by several
+// services that their definitions refer to under sharedTypes instead of each holding a copy
+type SharedType struct {
+	Name      string               `yaml:"name" json:"name"`
+	Fields    []Field              `yaml:"fields" json:"fields"`
+	Integrity *DefinitionIntegrity `yaml:"integrity,omitempty" json:"integrity,omitempty"`
+}
+
+// sharedTypePath returns the path of the schema of a shared type relative to the output folder
+func sharedTypePath(name string, format string) string {
+	return path.Join(sharedSchemaFolder, name+definitionExtension(format))
+}
+
+// shareTypes moves the struct types used by several services out of their definitions into
+// .polycode/schema/common, leaving a reference under sharedTypes. Without enabled the types are
+// moved back into the definitions. Definitions are rewritten in place and signed with key when it is
+// set, unchanged files are left alone.
+func shareTypes(output outputFS, outputPath string, formats []string, enabled bool, key ed25519.PrivateKey) error {
+	folder := filepath.Join(outputPath, filepath.FromSlash(sharedSchemaFolder))
+	if !enabled && !output.Exists(folder) {
+		return nil
+	}
+	defs, err := loadServiceDefinitions(output, outputPath)
+	if err != nil {
+		return err
+	}
+
+	shared := make(map[string][]Field)
+	if enabled {
+		users := make(map[string]int)
+		for _, def := range defs {
+			for name := range collectSchemaTypes([]ServiceDefinition{def}) {
+				users[name]++
+			}
+		}
+		for _, def := range defs {
+			for name, fields := range collectSchemaTypes([]ServiceDefinition{def}) {
+				if users[name] > 1 {
+					shared[name] = fields
+				}
+			}
+		}
+	}
+
+	keep := make(map[string]bool)
+	if enabled {
+		// The folder exists even when nothing is shared, images copy it
+		if err = output.MkdirAll(folder, 0755); err != nil {
+			return fmt.Errorf("failed to create %s folder: %w", sharedSchemaFolder, err)
+		}
+	}
+	for name, fields := range shared {
+		sharedType := SharedType{Name: name, Fields: fields}
+		for _, format := range formats {
+			data, err := encodeSharedType(sharedType, format, key)
+			if err != nil {
+				return fmt.Errorf("failed to marshal shared type %s: %w", name, err)
+			}
+			file := filepath.Join(outputPath, filepath.FromSlash(sharedTypePath(name, format)))
+			keep[filepath.Base(file)] = true
+			if err = writeDefinitionFile(output, file, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, def := range defs {
+		def = splitSharedTypes(def, shared, formats[0])
+		for _, format := range formats {
+			data, err := encodeServiceDefinition(def, format, key)
+			if err != nil {
+				return fmt.Errorf("failed to marshal definition: %w", err)
+			}
+			file := filepath.Join(outputPath, "definition", serviceFileName(def.Name)+definitionExtension(format))
+			if err = writeDefinitionFile(output, file, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = removeUnlisted(output, folder, "*", keep); err != nil || enabled {
+		return err
+	}
+	return removeGeneratedFile(output, outputPath, sharedSchemaFolder)
+}
+
+// writeDefinitionFile writes a definition or shared schema file unless it is unchanged
+func writeDefinitionFile(output outputFS, file string, data []byte) error {
+	if existing, err := output.ReadFile(file); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return output.WriteFile(file, data, 0644)
+}
+
+// splitSharedTypes removes the shared types from a definition, its types and the schemas of the
+// inputs and outputs of its methods and of its health status, and lists them under sharedTypes
+func splitSharedTypes(def ServiceDefinition, shared map[string][]Field, format string) ServiceDefinition {
+	def.SharedTypes = nil
+	share := func(name string) bool {
+		if _, ok := shared[name]; !ok {
+			return false
+		}
+		if def.SharedTypes == nil {
+			def.SharedTypes = make(map[string]string)
+		}
+		def.SharedTypes[name] = sharedTypePath(name, format)
+		return true
+	}
+
+	types := make(map[string][]Field)
+	for name, fields := range def.Types {
+		if !share(name) {
+			types[name] = fields
+		}
+	}
+	def.Types = types
+	for _, list := range []*[]MethodDefinition{&def.Methods, &def.Signals, &def.Queries} {
+		methods := make([]MethodDefinition, len(*list))
+		for i, method := range *list {
+			if method.InputSchema != nil && share(schemaRef(method.InputType)) {
+				method.InputSchema = nil
+			}
+			if method.OutputSchema != nil && share(schemaRef(method.OutputType)) {
+				method.OutputSchema = nil
+			}
+			methods[i] = method
+		}
+		*list = methods
+	}
+	if def.HealthCheck != nil && def.HealthCheck.StatusSchema != nil && share(schemaRef(def.HealthCheck.StatusType)) {
+		check := *def.HealthCheck
+		check.StatusSchema = nil
+		def.HealthCheck = &check
+	}
+	return def
+}
+
+// resolveSharedTypes restores the shared types of a definition read from the output folder, so it
+// reads as if it held them. Shared types are read once per cache.
+func resolveSharedTypes(output outputFS, outputPath string, def *ServiceDefinition, cache map[string][]Field) error {
+	for name, file := range def.SharedTypes {
+		fields, ok := cache[file]
+		if !ok {
+			data, err := output.ReadFile(filepath.Join(outputPath, filepath.FromSlash(file)))
+			if err != nil {
+				return fmt.Errorf("failed to read shared type %s of %s: %w", name, def.Name, err)
+			}
+			var shared SharedType
+			if err = decodeDefinition(data, definitionFormat(file), &shared); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			fields = shared.Fields
+			cache[file] = fields
+		}
+
+		if def.Types == nil {
+			def.Types = make(map[string][]Field)
+		}
+		def.Types[name] = fields
+		for _, list := range [][]MethodDefinition{def.Methods, def.Signals, def.Queries} {
+			for i := range list {
+				if list[i].InputSchema == nil && list[i].InputType != "" && schemaRef(list[i].InputType) == name {
+					list[i].InputSchema = fields
+				}
+				if list[i].OutputSchema == nil && list[i].OutputType != "" && schemaRef(list[i].OutputType) == name {
+					list[i].OutputSchema = fields
+				}
+			}
+		}
+		if def.HealthCheck != nil && def.HealthCheck.StatusSchema == nil && schemaRef(def.HealthCheck.StatusType) == name {
+			def.HealthCheck.StatusSchema = fields
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SharedTypeDirs returns the folders of the app's packages imported by the services, directly or
+// through other packages of the app, outside the services roots and the output folders. They hold
+// the request and response types of shared packages like models/, watch mode watches them too since
+// editing a type changes the schemas of the services using it.
+func SharedTypeDirs(appPath string, opts Options) ([]string, error) {
+	appPath, err := NormalizeAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := resolveModules(appPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := discoverServices(appPath, opts)
+	if errors.Is(err, errNoServicesFolder) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var excluded []string
+	for _, root := range opts.ServicesDirs {
+		excluded = append(excluded, filepath.Join(appPath, root))
+	}
+	for _, module := range modules {
+		excluded = append(excluded, filepath.Join(module.Dir, opts.OutputDir))
+	}
+
+	// Packages are visited breadth first from the services, following the imports of the app's packages
+	visited := make(map[string]bool)
+	var queue []string
+	for _, entry := range entries {
+		dir := filepath.Join(appPath, filepath.FromSlash(entry.Dir))
+		visited[dir] = true
+		queue = append(queue, dir)
+	}
+
+	var dirs []string
+	fset := token.NewFileSet()
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		for _, importPath := range packageImports(fset, dir) {
+			importDir, ok := moduleDir(modules, importPath)
+			if !ok || visited[importDir] {
+				continue
+			}
+			visited[importDir] = true
+			if info, err := os.Stat(importDir); err != nil || !info.IsDir() {
+				continue
+			}
+			queue = append(queue, importDir)
+			if !underDirs(importDir, excluded) {
+				dirs = append(dirs, importDir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// packageImports returns the import paths of the non-test Go files of a folder, files that do not
+// parse are skipped
+func packageImports(fset *token.FileSet, dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	var imports []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, imp := range node.Imports {
+			if importPath, err := strconv.Unquote(imp.Path.Value); err == nil {
+				imports = append(imports, importPath)
+			}
+		}
+	}
+	return imports
+}
+
+// moduleDir returns the folder of a package of one of the modules, the module with the longest
+// matching path wins like nested modules in a workspace
+func moduleDir(modules []appModule, importPath string) (string, bool) {
+	var owner *appModule
+	for i, module := range modules {
+		if (importPath == module.Name || strings.HasPrefix(importPath, module.Name+"/")) && (owner == nil || len(module.Name) > len(owner.Name)) {
+			owner = &modules[i]
+		}
+	}
+	if owner == nil {
+		return "", false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, owner.Name), "/")
+	return filepath.Join(owner.Dir, filepath.FromSlash(rel)), true
+}
+
+// underDirs reports whether a path is one of the folders or inside one
+func underDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isInside(path, dir) {
+			return true
+		}
+	}
+	return false
+}
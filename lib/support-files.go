@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// supportFiles are the files shared by the wrappers of all services, their code is formatted with
+// the package name. Files with an enabled func are only written when it returns true.
+var supportFiles = []struct {
+	name    string
+	code    string
+	enabled func(opts Options) bool
+}{
+	{validationSupportName, validationSupport, nil},
+	{authSupportName, authSupport, nil},
+	{deprecationSupportName, deprecationSupport, nil},
+	{telemetrySupportName, telemetrySupport, func(opts Options) bool { return opts.Metrics }},
+	{dispatchLogSupportName, dispatchLogSupport, func(opts Options) bool { return opts.DebugDispatch }},
+}
+
+// writeSupportFiles writes the types and helpers used by the wrappers, leaving identical files untouched
+// and removing the files of disabled options
+func writeSupportFiles(output outputFS, outputPath string, opts Options) error {
+	if err := output.MkdirAll(outputPath, 0755); err != nil {
+		return err
+	}
+	for _, file := range supportFiles {
+		path := filepath.Join(outputPath, file.name)
+		if file.enabled != nil && !file.enabled(opts) {
+			if output.Exists(path) {
+				if err := output.Remove(path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		code := []byte(fmt.Sprintf(stampVersion(file.code), opts.PackageName))
+		if existing, err := output.ReadFile(path); err == nil && bytes.Equal(existing, code) {
+			continue
+		}
+		if err := output.WriteFile(path, code, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
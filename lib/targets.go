@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+const (
+	TargetGo         = "go"
+	TargetTypeScript = "typescript"
+)
+
+// Generator emits files from the parsed service model, built-in generators produce the
+// wrappers for each polycode runtime and custom ones can be registered or loaded as plugins
+type Generator interface {
+	// Name is the identifier used to select the generator in Options.Targets
+	Name() string
+	// Generate returns the files to write, keyed by path relative to the .polycode folder
+	Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error)
+}
+
+// builtinGenerators are the generators available to every run, by name
+var builtinGenerators = map[string]Generator{
+	TargetGo:         goTarget{},
+	TargetTypeScript: typeScriptTarget{},
+	TargetClients:    clientTarget{},
+	TargetActivities: activityTarget{},
+	TargetTSClient:   tsClientTarget{},
+	TargetMocks:      mockTarget{},
+	TargetHTTP:       httpTarget{},
+}
+
+// RegisterGenerator makes a generator available for selection in Targets for runs with these options
+// only, it replaces the generator of the same name
+func (o *Options) RegisterGenerator(generator Generator) {
+	// Options are copied by value, the copies sharing the map must not see the registration
+	o.Generators = maps.Clone(o.Generators)
+	if o.Generators == nil {
+		o.Generators = make(map[string]Generator)
+	}
+	o.Generators[generator.Name()] = generator
+}
+
+// GeneratorNames returns the names of the built-in generators and the ones registered in the options
+func (o Options) GeneratorNames() []string {
+	names := slices.Collect(maps.Keys(builtinGenerators))
+	for name := range o.Generators {
+		if _, ok := builtinGenerators[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveGenerator returns the generator selected by name, honouring the wrapper template overrides for the Go target
+func resolveGenerator(name string, opts Options) (Generator, error) {
+	if name == TargetGo && (opts.Template != "" || opts.TemplateDir != "") {
+		templates, err := loadWrapperTemplates(opts)
+		if err != nil {
+			return nil, err
+		}
+		return goTarget{templates: templates}, nil
+	}
+
+	generator, ok := opts.Generators[name]
+	if !ok {
+		generator, ok = builtinGenerators[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown generation target %q", name)
+	}
+	return generator, nil
+}
+
+// goTarget generates the Go wrapper package, using the overrides of templates before the built-in template
+type goTarget struct {
+	templates wrapperTemplates
+}
+
+func (goTarget) Name() string {
+	return TargetGo
+}
+
+func (t goTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	wrapper, source := t.templates.services[serviceFileName(info.ServiceName)], serviceFileName(info.ServiceName)+".go.tmpl"
+	if wrapper == "" {
+		wrapper, source = t.templates.wrapper, filepath.Base(t.templates.wrapperFile)
+	}
+	if wrapper == "" {
+		wrapper, source = wrapperTemplate, ""
+	}
+
+	code, err := generateServiceCode(info, wrapper, t.templates.partials)
+	if err != nil && source != "" {
+		return nil, fmt.Errorf("failed to generate go wrapper from %s (template contract %d): %w", source, TemplateContract, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to generate go wrapper: %w", err)
+	}
+	return map[string][]byte{serviceFileName(info.ServiceName) + ".go": []byte(code)}, nil
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegisterGenerator(t *testing.T) {
+	opts := DefaultOptions()
+	other := opts
+	opts.RegisterGenerator(NewCommandGenerator("docs", "gen-docs"))
+	copied := opts
+	copied.RegisterGenerator(NewCommandGenerator("lint", "gen-lint"))
+
+	tests := []struct {
+		name    string
+		opts    Options
+		target  string
+		wantErr string
+	}{
+		{name: "built-in", opts: other, target: TargetGo},
+		{name: "registered", opts: opts, target: "docs"},
+		{name: "registered with other options", opts: other, target: "docs", wantErr: `unknown generation target "docs"`},
+		{name: "registered on a copy", opts: opts, target: "lint", wantErr: `unknown generation target "lint"`},
+		{name: "inherited by a copy", opts: copied, target: "docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := resolveGenerator(tt.target, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if generator.Name() != tt.target {
+				t.Errorf("got generator %s, want %s", generator.Name(), tt.target)
+			}
+		})
+	}
+
+	if got := opts.GeneratorNames(); !slices.Contains(got, "docs") || !slices.Contains(got, TargetGo) || slices.Contains(got, "lint") {
+		t.Errorf("got names %v, want the built-in generators and docs", got)
+	}
+}
+
+func TestConfigApplyGenerators(t *testing.T) {
+	config := Config{Generators: map[string]string{"docs": "gen-docs"}}
+	first, second := DefaultOptions(), DefaultOptions()
+	if err := config.Apply(&first); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(first.Targets, "docs") {
+		t.Errorf("got targets %v, want docs selected", first.Targets)
+	}
+	// The generators of a config are not seen by options it was not applied to
+	if _, err := resolveGenerator("docs", second); err == nil {
+		t.Errorf("got the docs generator with options the config was not applied to")
+	}
+}
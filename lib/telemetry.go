@@ -0,0 +1,97 @@
+package lib
+
+// OTelModule is the OpenTelemetry module the wrappers generated with Options.Metrics depend on
+const OTelModule = "go.opentelemetry.io/otel"
+
+// telemetrySupportName holds the OpenTelemetry instrumentation shared by the wrappers of all services
+const telemetrySupportName = "telemetry.go"
+
+const telemetrySupport = `// Code generated by next-gen. DO NOT EDIT.
+package %s
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans and metrics recorded by the wrappers
+const instrumentationName = "github.com/cloudimpl/next-gen"
+
+// TelemetryContext is implemented by polycode contexts carrying the OpenTelemetry providers of the
+// runtime, the global providers are used for other contexts
+type TelemetryContext interface {
+	TracerProvider() trace.TracerProvider
+	MeterProvider() metric.MeterProvider
+}
+
+// callInstruments are the metrics recorded for each call, created once per meter provider
+type callInstruments struct {
+	calls    metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var instruments sync.Map // metric.MeterProvider to *callInstruments
+
+// instrumentsFor returns the instruments of a meter provider, creating them on first use
+func instrumentsFor(provider metric.MeterProvider) *callInstruments {
+	if cached, ok := instruments.Load(provider); ok {
+		return cached.(*callInstruments)
+	}
+
+	meter := provider.Meter(instrumentationName)
+	calls, _ := meter.Int64Counter("polycode.calls", metric.WithDescription("Calls of service and workflow methods"))
+	failures, _ := meter.Int64Counter("polycode.errors", metric.WithDescription("Calls of service and workflow methods that returned an error"))
+	duration, _ := meter.Float64Histogram("polycode.duration", metric.WithDescription("Duration of service and workflow method calls"), metric.WithUnit("s"))
+	cached, _ := instruments.LoadOrStore(provider, &callInstruments{calls: calls, errors: failures, duration: duration})
+	return cached.(*callInstruments)
+}
+
+// startCall starts the span of a method call, the returned function ends it and records the call
+// metrics with the error the method returned. The span is a child of the span of the polycode context
+// when the context carries one.
+func startCall(polycodeCtx any, service string, method string, kind string) func(err *error) {
+	ctx, ok := polycodeCtx.(context.Context)
+	if !ok {
+		ctx = context.Background()
+	}
+	tracerProvider, meterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+	if telemetry, ok := polycodeCtx.(TelemetryContext); ok {
+		tracerProvider, meterProvider = telemetry.TracerProvider(), telemetry.MeterProvider()
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("polycode.service", service),
+		attribute.String("polycode.method", method),
+		attribute.String("polycode.kind", kind),
+	}
+	_, span := tracerProvider.Tracer(instrumentationName).Start(ctx, service+"."+method, trace.WithAttributes(attrs...))
+	start := time.Now()
+
+	return func(err *error) {
+		m := instrumentsFor(meterProvider)
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+			attrs = append(attrs, attribute.Bool("error", true))
+		} else {
+			attrs = append(attrs, attribute.Bool("error", false))
+		}
+		span.End()
+
+		set := metric.WithAttributes(attrs...)
+		m.calls.Add(ctx, 1, set)
+		if *err != nil {
+			m.errors.Add(ctx, 1, set)
+		}
+		m.duration.Record(ctx, time.Since(start).Seconds(), set)
+	}
+}
+`
@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TemplateContract is the version of the data model the Go wrapper template is executed with, a
+// ServiceInfo. It is bumped when fields of ServiceInfo or MethodInfo are renamed, removed or change
+// meaning, so overrides written against another version are reported instead of failing obscurely.
+// Version 2 refers to the types of the service package through its service import, which Imports may hold.
+const TemplateContract = 2
+
+// WrapperTemplateName is the file of a template folder overriding the Go wrapper of every service.
+// <service>.go.tmpl overrides it for a single service and the other .tmpl files hold {{define}}
+// blocks shared by the overrides.
+const WrapperTemplateName = "wrapper.go.tmpl"
+
+// templateContractPattern matches the {{/* next-gen:contract N */}} declaration of an override
+var templateContractPattern = regexp.MustCompile(`next-gen:contract\s+(\d+)`)
+
+// wrapperTemplates are the wrapper template overrides loaded from Options.Template and Options.TemplateDir
+type wrapperTemplates struct {
+	wrapper     string            // Override for every service, empty for the built-in template
+	wrapperFile string            // File the override of every service was read from
+	services    map[string]string // Overrides of single services, keyed by service name
+	partials    map[string]string // Shared {{define}} blocks, keyed by file name
+}
+
+// loadWrapperTemplates reads the overrides of the Go wrapper template, an empty set uses the built-in one
+func loadWrapperTemplates(opts Options) (wrapperTemplates, error) {
+	var templates wrapperTemplates
+	if opts.Template != "" {
+		data, err := os.ReadFile(opts.Template)
+		if err != nil {
+			return templates, fmt.Errorf("failed to read wrapper template: %w", err)
+		}
+		templates.wrapper, templates.wrapperFile = string(data), opts.Template
+	}
+	if opts.TemplateDir == "" {
+		return templates, nil
+	}
+
+	if _, err := os.Stat(opts.TemplateDir); err != nil {
+		return templates, fmt.Errorf("failed to read template folder: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(opts.TemplateDir, "*.tmpl"))
+	if err != nil {
+		return templates, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return templates, fmt.Errorf("failed to read wrapper template: %w", err)
+		}
+
+		name := filepath.Base(file)
+		switch {
+		case name == WrapperTemplateName:
+			templates.wrapper, templates.wrapperFile = string(data), file
+		case strings.HasSuffix(name, ".go.tmpl"):
+			if templates.services == nil {
+				templates.services = make(map[string]string)
+			}
+			templates.services[strings.TrimSuffix(name, ".go.tmpl")] = string(data)
+		default:
+			if templates.partials == nil {
+				templates.partials = make(map[string]string)
+			}
+			templates.partials[name] = string(data)
+		}
+	}
+	return templates, nil
+}
+
+// checkWrapperTemplates loads the overrides once per run, failing on templates that do not parse and
+// warning about overrides written for another version of the template contract
+func checkWrapperTemplates(opts Options) error {
+	templates, err := loadWrapperTemplates(opts)
+	if err != nil {
+		return err
+	}
+
+	// Overrides keyed by their file
+	overrides := make(map[string]string)
+	if templates.wrapper != "" {
+		overrides[templates.wrapperFile] = templates.wrapper
+	}
+	for service, text := range templates.services {
+		overrides[filepath.Join(opts.TemplateDir, service+".go.tmpl")] = text
+	}
+
+	for name, text := range overrides {
+		if _, err := parseWrapperTemplate(text, templates.partials); err != nil {
+			return fmt.Errorf("wrapper template %s: %w", name, err)
+		}
+
+		match := templateContractPattern.FindStringSubmatch(text)
+		if match == nil {
+			slog.Warn("Wrapper template does not declare the template contract it was written for, add {{/* next-gen:contract "+strconv.Itoa(TemplateContract)+" */}}",
+				"template", name)
+			continue
+		}
+		if contract, _ := strconv.Atoi(match[1]); contract != TemplateContract {
+			slog.Warn("Wrapper template was written for another template contract, check it against the fields of ServiceInfo and MethodInfo",
+				"template", name, "contract", contract, "expected", TemplateContract, "version", Version())
+		}
+	}
+	return nil
+}
+
+// BuiltinWrapperTemplate returns the built-in Go wrapper template, declaring the current contract, as
+// the starting point of an override
+func BuiltinWrapperTemplate() string {
+	return fmt.Sprintf("{{/* next-gen:contract %d\nExecuted with a ServiceInfo per service, see the ServiceInfo and MethodInfo types of\ngithub.com/cloudimpl/next-gen/lib for the fields. */ -}}\n", TemplateContract) + wrapperTemplate
+}
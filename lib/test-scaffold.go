@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const testScaffoldTemplate = `package {{.Package}}_test
+
+import (
+	{{.Info.SDKImport}}
+	"testing"
+	_polycode "{{.Info.ModuleName}}/{{.Info.OutputDir}}"
+	{{range .Info.Imports}}{{.}}
+	{{end}}
+)
+
+// fakeServiceContext satisfies polycode.ServiceContext, override the methods your service uses
+type fakeServiceContext struct {
+	polycode.ServiceContext
+}
+
+// fakeWorkflowContext satisfies polycode.WorkflowContext, override the methods your workflow uses
+type fakeWorkflowContext struct {
+	polycode.WorkflowContext
+}
+{{range .Info.Methods}}
+func Test{{.OriginalName}}(t *testing.T) {
+	tests := []struct {
+		name    string
+		{{if .HasInput}}input   {{if .IsMultiInput}}_polycode.{{end}}{{.InputType}}
+		{{end}}wantErr bool
+	}{
+		// TODO: add test cases
+	}
+
+	wrapper := _polycode.New{{$.Info.ServiceStructName}}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := wrapper.{{if .IsWorkflow}}ExecuteWorkflow(fakeWorkflowContext{}{{else}}ExecuteService(fakeServiceContext{}{{end}}, "{{.Name}}", {{if .HasInput}}&tt.input{{else}}nil{{end}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("{{.ExposedName}} error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			{{- if .HasOutput}}
+
+			if _, ok := output.({{if .IsOutputPointer}}*{{end}}{{.OutputType}}); !ok {
+				t.Errorf("{{.ExposedName}} output = %T, want {{if .IsOutputPointer}}*{{end}}{{.OutputType}}", output)
+			}
+			{{- else}}
+
+			if output != nil {
+				t.Errorf("{{.ExposedName}} output = %v, want nil", output)
+			}
+			{{- end}}
+		})
+	}
+}
+{{end}}`
+
+// testScaffoldName is the test file written into each service folder with -gen-tests
+func testScaffoldName(serviceName string) string {
+	return serviceFileName(serviceName) + "_dispatch_test.go"
+}
+
+// writeTestScaffold writes table-driven test stubs calling the service through its generated wrapper.
+// The file belongs to the developer once written, so an existing scaffold is never overwritten.
+func writeTestScaffold(output outputFS, servicePath string, info ServiceInfo) error {
+	filePath := filepath.Join(servicePath, testScaffoldName(info.ServiceName))
+	if _, err := os.Stat(filePath); err == nil {
+		return nil
+	}
+
+	pkg, err := servicePackageName(servicePath)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("tests").Parse(testScaffoldTemplate)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"Package": pkg,
+		"Info":    info,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate test scaffold: %w", err)
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format test scaffold: %w", err)
+	}
+
+	slog.Info("Writing test scaffold", "path", filePath)
+	return output.WriteFile(filePath, code, 0644)
+}
+
+// servicePackageName returns the package clause of the Go files in a service folder
+func servicePackageName(servicePath string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(servicePath, "*.go"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		node, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return "", err
+		}
+		return node.Name.Name, nil
+	}
+	return "", fmt.Errorf("no Go files in %s", servicePath)
+}
@@ -0,0 +1,7 @@
+module example.com/app
+
+go 1.23.0
+
+require github.com/cloudimpl/next-coder-sdk v0.0.0
+
+replace github.com/cloudimpl/next-coder-sdk => ../sdk
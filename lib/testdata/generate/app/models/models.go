@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type CreateOrderRequest struct {
+	CustomerID string            `json:"customer_id"`
+	Items      []string          `json:"items,omitempty"`
+	Total      Money             `json:"total"`
+	Discount   *Money            `json:"discount,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Secret     string            `json:"-"`
+	CreatedAt  time.Time
+}
+
+type CreateOrderResponse struct {
+	OrderID string
+}
@@ -0,0 +1,22 @@
+package billing
+
+import (
+	"example.com/app/models"
+	"github.com/cloudimpl/next-coder-sdk/polycode"
+)
+
+func Charge(ctx polycode.ServiceContext, m models.Money) (models.Money, error) {
+	return m, nil
+}
+
+func Balance(ctx polycode.ServiceContext) (int64, error) {
+	return 0, nil
+}
+
+func Refund(ctx polycode.ServiceContext, m *models.Money) error {
+	return nil
+}
+
+func Ping(ctx polycode.WorkflowContext) error {
+	return nil
+}
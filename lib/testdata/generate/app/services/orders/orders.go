@@ -0,0 +1,20 @@
+package orders
+
+import (
+	"example.com/app/models"
+	"github.com/cloudimpl/next-coder-sdk/polycode"
+)
+
+// CreateOrder creates a new order.
+// @description Create a new order
+//
+//polycode:concurrency 10
+func CreateOrder(ctx polycode.ServiceContext, req models.CreateOrderRequest) (models.CreateOrderResponse, error) {
+	return models.CreateOrderResponse{OrderID: req.CustomerID}, nil
+}
+
+func FulfilOrder(ctx polycode.WorkflowContext, req *models.CreateOrderRequest) (*models.CreateOrderResponse, error) {
+	return &models.CreateOrderResponse{}, nil
+}
+
+func helper() {}
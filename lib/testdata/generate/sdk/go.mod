@@ -0,0 +1,3 @@
+module github.com/cloudimpl/next-coder-sdk
+
+go 1.23.0
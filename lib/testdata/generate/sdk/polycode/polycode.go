@@ -0,0 +1,38 @@
+package polycode
+
+import (
+	"context"
+	"time"
+)
+
+type ServiceContext interface{ context.Context }
+type WorkflowContext interface{ context.Context }
+
+type Service interface {
+	GetName() string
+}
+
+func RegisterService(s Service)                  {}
+func FromAppConfig(ctx context.Context, cfg any) {}
+
+// Stream is a sequence of values produced or consumed by a streaming method
+type Stream[T any] <-chan T
+
+// LifecycleAware services are notified when the runtime starts and stops them
+type LifecycleAware interface {
+	OnStart(ctx ServiceContext) error
+	OnStop(ctx ServiceContext) error
+}
+
+// Policy is the timeout and retry policy the runtime applies to the calls of a method
+type Policy struct {
+	Timeout time.Duration
+	Retry   *RetryPolicy
+}
+
+// RetryPolicy retries failed calls
+type RetryPolicy struct {
+	MaxAttempts  int
+	Backoff      string
+	InitialDelay time.Duration
+}
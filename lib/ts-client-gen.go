@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const tsClientTemplate = `// Code generated by next-gen. DO NOT EDIT.
+{{range .Interfaces}}
+export interface {{.Name}} {
+{{- range .Fields}}
+	{{- if .Doc}}
+	/** {{.Doc}} */
+	{{- end}}
+	{{tsProperty .Name}}{{if .Optional}}?{{end}}: {{.Type}};
+{{- end}}
+}
+{{end}}
+export type Fetch = (input: string, init?: RequestInit) => Promise<Response>;
+
+// {{.ClassName}} calls the {{.Info.ServiceName}} service over HTTP
+export class {{.ClassName}} {
+	constructor(private readonly baseUrl: string, private readonly fetchFn: Fetch = fetch) {}
+
+	private async invoke<T>(method: string, input?: unknown): Promise<T> {
+		const res = await this.fetchFn(this.baseUrl.replace(/\/$/, "") + "/services/{{.Info.ServiceName}}/" + method, {
+			method: "POST",
+			headers: { "Content-Type": "application/json" },
+			body: input === undefined ? undefined : JSON.stringify(input),
+		});
+		if (!res.ok) {
+			throw new Error("{{.Info.ServiceName}}." + method + " failed with " + res.status + ": " + (await res.text()));
+		}
+		const text = await res.text();
+		return (text ? JSON.parse(text) : undefined) as T;
+	}
+{{range .Methods}}
+	{{- if .Description}}
+	/** {{.Description}} */
+	{{- end}}
+	{{.Name}}({{if .Input}}input: {{.Input}}{{end}}): Promise<{{.Output}}> {
+		return this.invoke<{{.Output}}>("{{.Exposed}}"{{if .Input}}, input{{end}});
+	}
+{{end}}}
+`
+
+// TargetTSClient generates TypeScript HTTP clients for frontend consumers
+const TargetTSClient = "ts-client"
+
+type tsClientField struct {
+	Name     string
+	Type     string
+	Optional bool
+	Doc      string
+}
+
+type tsClientInterface struct {
+	Name   string
+	Fields []tsClientField
+}
+
+type tsClientMethod struct {
+	Name        string
+	Exposed     string
+	Description string
+	Input       string
+	Output      string
+}
+
+// tsClientTarget generates .polycode/ts-client/<service>.ts with the wire types and a typed client
+type tsClientTarget struct{}
+
+func (tsClientTarget) Name() string {
+	return TargetTSClient
+}
+
+func (tsClientTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	names := newTSNames(def)
+	interfaces := make(map[string]tsClientInterface)
+	addInterface := func(typeName string, schema []Field) {
+		if !strings.Contains(typeName, ".") || schema == nil {
+			return
+		}
+		iface := tsClientInterface{Name: names.tsType(typeName)}
+		for _, field := range schema {
+			name, omitempty, skip := jsonFieldName(field)
+			if skip {
+				continue
+			}
+			iface.Fields = append(iface.Fields, tsClientField{
+				Name:     name,
+				Type:     names.tsType(field.Type),
+				Optional: omitempty || strings.HasPrefix(field.Type, "*"),
+				Doc:      strings.ReplaceAll(field.Doc, "\n", " "),
+			})
+		}
+		interfaces[iface.Name] = iface
+	}
+	for name, fields := range def.Types {
+		addInterface(name, fields)
+	}
+
+	var methods []tsClientMethod
+	for _, method := range def.Methods {
+		if method.InputStream || method.OutputStream {
+			// Streams are not request/response calls, the HTTP client only covers unary methods
+			continue
+		}
+		addInterface(method.InputType, method.InputSchema)
+		addInterface(method.OutputType, method.OutputSchema)
+
+		m := tsClientMethod{
+			Name:        lowerFirst(method.Name),
+			Exposed:     method.Name,
+			Description: method.Description,
+			Output:      "void",
+		}
+		if method.InputType != "" {
+			m.Input = names.tsType(method.InputType)
+		}
+		if method.OutputType != "" {
+			m.Output = names.tsType(method.OutputType)
+		}
+		methods = append(methods, m)
+	}
+
+	var sorted []tsClientInterface
+	for _, iface := range interfaces {
+		sorted = append(sorted, iface)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	tmpl, err := template.New("ts-client").Funcs(template.FuncMap{"tsProperty": tsPropertyName}).Parse(stampVersion(tsClientTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"Info":       info,
+		"ClassName":  info.ServiceStructName + "Client",
+		"Interfaces": sorted,
+		"Methods":    methods,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate typescript client: %w", err)
+	}
+
+	return map[string][]byte{"ts-client/" + serviceFileName(info.ServiceName) + ".ts": buf.Bytes()}, nil
+}
+
+// lowerFirst lower-cases the first letter of a name, CreateOrder becomes createOrder
+func lowerFirst(name string) string {
+	for i, r := range name {
+		return string(unicode.ToLower(r)) + name[i+len(string(r)):]
+	}
+	return name
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTSClientTarget(t *testing.T) {
+	order := []Field{
+		{Name: "InvoiceID", Type: "string", Tag: `json:"invoice-id"`, Doc: "Invoice the order\nis billed on"},
+		{Name: "Lines", Type: "[]models.Line", Tag: `json:"lines,omitempty"`},
+		{Name: "Secret", Type: "string", Tag: `json:"-"`},
+	}
+	invoice := []Field{{Name: "Total", Type: "*int64", Tag: `json:"total"`}}
+	def := ServiceDefinition{
+		Name: "orders",
+		Types: map[string][]Field{
+			"orders.Order":  order,
+			"billing.Order": invoice,
+			"models.Line":   {{Name: "SKU", Type: "string", Tag: `json:"sku"`}},
+		},
+		Methods: []MethodDefinition{
+			{Name: "Create", Description: "Creates an order", InputType: "orders.Order", InputSchema: order, OutputType: "billing.Order", OutputSchema: invoice},
+			{Name: "Ping"},
+			{Name: "Watch", InputType: "orders.Order", InputSchema: order, InputStream: true},
+		},
+	}
+	info := ServiceInfo{ServiceName: "orders", ServiceStructName: "Orders"}
+	files, err := tsClientTarget{}.Generate(info, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(files["ts-client/orders.ts"])
+
+	for _, want := range []string{
+		"export interface BillingOrder {\n\ttotal?: number;\n}",
+		"export interface Line {\n\tsku: string;\n}",
+		"export interface OrdersOrder {\n\t/** Invoice the order is billed on */\n\t\"invoice-id\": string;\n\tlines?: Line[];\n}",
+		"export class OrdersClient {",
+		"\t/** Creates an order */\n\tcreate(input: OrdersOrder): Promise<BillingOrder> {\n\t\treturn this.invoke<BillingOrder>(\"Create\", input);",
+		"\tping(): Promise<void> {\n\t\treturn this.invoke<void>(\"Ping\");",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("the client does not contain %q:\n%s", want, code)
+		}
+	}
+	for _, unwanted := range []string{"Secret", "watch("} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("the client contains %q:\n%s", unwanted, code)
+		}
+	}
+}
+
+func TestLowerFirst(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "CreateOrder", want: "createOrder"},
+		{name: "Ärger", want: "ärger"},
+		{name: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := lowerFirst(tt.name); got != tt.want {
+			t.Errorf("lowerFirst(%q): got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
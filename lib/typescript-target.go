@@ -0,0 +1,231 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const typeScriptTemplate = `// Code generated by next-gen. DO NOT EDIT.
+import * as service from "{{.ServiceImport}}";
+{{range .Interfaces}}
+export interface {{.Name}} {
+{{- range .Fields}}
+	{{tsProperty .Name}}{{if .Optional}}?{{end}}: {{.Type}};
+{{- end}}
+}
+{{end}}
+export class {{.Info.ServiceStructName}} {
+	getName(): string {
+		return "{{.Info.ServiceName}}";
+	}
+
+	getDescription(method: string): string {
+		switch (method.toLowerCase()) {
+		{{- range .Info.Methods}}
+		case "{{.Name}}":
+			return {{printf "%q" .Description}};
+		{{- end}}
+		default:
+			throw new Error("method not found");
+		}
+	}
+
+	isWorkflow(method: string): boolean {
+		switch (method.toLowerCase()) {
+		{{- range .Info.Methods}}{{if .IsWorkflow}}
+		case "{{.Name}}":
+			return true;
+		{{- end}}{{end}}
+		default:
+			return false;
+		}
+	}
+
+	async executeService(ctx: unknown, method: string, input: unknown): Promise<unknown> {
+		switch (method.toLowerCase()) {
+		{{- range .Info.Methods}}{{if .IsService}}
+		case "{{.Name}}":
+			return service.{{.OriginalName}}(ctx{{if .HasInput}}, input as {{index $.Inputs .ExposedName}}{{end}});
+		{{- end}}{{end}}
+		default:
+			throw new Error("method not found");
+		}
+	}
+
+	async executeWorkflow(ctx: unknown, method: string, input: unknown): Promise<unknown> {
+		switch (method.toLowerCase()) {
+		{{- range .Info.Methods}}{{if .IsWorkflow}}
+		case "{{.Name}}":
+			return service.{{.OriginalName}}(ctx{{if .HasInput}}, input as {{index $.Inputs .ExposedName}}{{end}});
+		{{- end}}{{end}}
+		default:
+			throw new Error("method not found");
+		}
+	}
+}
+`
+
+type tsInterface struct {
+	Name   string
+	Fields []Field
+}
+
+// tsNames maps the qualified struct types of a definition to the names of their interfaces, the type
+// name unless types of several packages share it, like GraphQL types
+type tsNames map[string]string
+
+// newTSNames names the struct types of a definition and the method inputs and outputs
+func newTSNames(def ServiceDefinition) tsNames {
+	var goTypes []string
+	add := func(goType string) {
+		goType = strings.TrimPrefix(goType, "*")
+		if strings.Contains(goType, ".") && !isCollectionType(goType) && !slices.Contains(goTypes, goType) {
+			goTypes = append(goTypes, goType)
+		}
+	}
+	for name := range def.Types {
+		add(name)
+	}
+	for _, method := range def.Methods {
+		add(method.InputType)
+		add(method.OutputType)
+	}
+
+	count := make(map[string]int)
+	for _, goType := range goTypes {
+		count[tsInterfaceName(goType)]++
+	}
+	names := make(tsNames)
+	for _, goType := range goTypes {
+		name := tsInterfaceName(goType)
+		if count[name] > 1 {
+			name = constName(goType)
+		}
+		names[goType] = name
+	}
+	return names
+}
+
+// tsInterfaceName names the interface of a qualified type after the type, instantiations of generic
+// types are named after the type and its arguments
+func tsInterfaceName(goType string) string {
+	if strings.Contains(goType, "[") {
+		return constName(goType)
+	}
+	return unqualifiedName(goType)
+}
+
+// tsType maps a Go type expression to the closest TypeScript type
+func (n tsNames) tsType(goType string) string {
+	goType = strings.TrimPrefix(goType, "*")
+	switch {
+	case goType == "time.Time", goType == "[]byte":
+		// Encoded by encoding/json as RFC 3339 and base64 strings
+		return "string"
+	case strings.HasPrefix(goType, "["):
+		elem, _ := elemTypeName(goType)
+		return n.tsType(elem) + "[]"
+	case strings.HasPrefix(goType, "map["):
+		_, value, _ := strings.Cut(goType, "]")
+		return "Record<string, " + n.tsType(value) + ">"
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case integerTypes[goType], floatTypes[goType]:
+		return "number"
+	case strings.Contains(goType, "."):
+		// Qualified struct types are emitted as interfaces
+		if name, ok := n[goType]; ok {
+			return name
+		}
+		return tsInterfaceName(goType)
+	default:
+		return "any"
+	}
+}
+
+// tsIdentifier matches the property names TypeScript accepts without quotes
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName quotes the JSON names of fields that are not identifiers, like invoice-id
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	quoted, _ := json.Marshal(name)
+	return string(quoted)
+}
+
+// typeScriptTarget generates TypeScript service wrappers for the Node polycode runtime
+type typeScriptTarget struct{}
+
+func (typeScriptTarget) Name() string {
+	return TargetTypeScript
+}
+
+func (typeScriptTarget) Generate(info ServiceInfo, def ServiceDefinition) (map[string][]byte, error) {
+	names := newTSNames(def)
+	interfaces := make(map[string]tsInterface)
+	addInterface := func(typeName string, schema []Field) {
+		if !strings.Contains(typeName, ".") || isCollectionType(typeName) {
+			return
+		}
+		iface := tsInterface{Name: names.tsType(typeName)}
+		for _, field := range schema {
+			name, omitempty, skip := jsonFieldName(field)
+			if skip {
+				continue
+			}
+			iface.Fields = append(iface.Fields, Field{Name: name, Type: names.tsType(field.Type), Optional: omitempty || strings.HasPrefix(field.Type, "*")})
+		}
+		interfaces[iface.Name] = iface
+	}
+	for name, fields := range def.Types {
+		addInterface(name, fields)
+	}
+	// The wrappers cast the inputs to the types of the definition, qualified by package name
+	inputs := make(map[string]string)
+	for _, method := range def.Methods {
+		addInterface(method.InputType, method.InputSchema)
+		addInterface(method.OutputType, method.OutputSchema)
+		inputs[method.Name] = names.tsType(method.InputType)
+	}
+
+	var sorted []tsInterface
+	for _, iface := range interfaces {
+		sorted = append(sorted, iface)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	tmpl, err := template.New("typescript").Funcs(template.FuncMap{"tsProperty": tsPropertyName}).Parse(stampVersion(typeScriptTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	// The service is imported relative to the typescript folder inside the output folder
+	serviceImport, err := filepath.Rel(filepath.Join(info.OutputDir, "typescript"), info.ServiceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]any{
+		"Info":          info,
+		"Inputs":        inputs,
+		"Interfaces":    sorted,
+		"ServiceImport": filepath.ToSlash(serviceImport),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate typescript wrapper: %w", err)
+	}
+
+	return map[string][]byte{"typescript/" + serviceFileName(info.ServiceName) + ".ts": buf.Bytes()}, nil
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTSPropertyName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "id", want: "id"},
+		{name: "$ref", want: "$ref"},
+		{name: "_private2", want: "_private2"},
+		{name: "default", want: "default"},
+		{name: "invoice-id", want: `"invoice-id"`},
+		{name: "2fa", want: `"2fa"`},
+		{name: "a.b", want: `"a.b"`},
+		{name: `say "hi"`, want: `"say \"hi\""`},
+	}
+	for _, tt := range tests {
+		if got := tsPropertyName(tt.name); got != tt.want {
+			t.Errorf("tsPropertyName(%q): got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTSNames(t *testing.T) {
+	names := newTSNames(ServiceDefinition{
+		Types: map[string][]Field{
+			"billing.Order": nil,
+			"orders.Order":  nil,
+			"models.Money":  nil,
+		},
+		Methods: []MethodDefinition{
+			{InputType: "*models.Page[models.Money]", OutputType: "[]orders.Order"},
+		},
+	})
+	tests := []struct {
+		goType string
+		want   string
+	}{
+		{goType: "models.Money", want: "Money"},
+		{goType: "*orders.Order", want: "OrdersOrder"},
+		{goType: "billing.Order", want: "BillingOrder"},
+		{goType: "[]billing.Order", want: "BillingOrder[]"},
+		{goType: "map[string]models.Money", want: "Record<string, Money>"},
+		{goType: "models.Page[models.Money]", want: "ModelsPageModelsMoney"},
+		{goType: "time.Time", want: "string"},
+		{goType: "uint8", want: "number"},
+		{goType: "any", want: "any"},
+	}
+	for _, tt := range tests {
+		if got := names.tsType(tt.goType); got != tt.want {
+			t.Errorf("tsType(%q): got %s, want %s", tt.goType, got, tt.want)
+		}
+	}
+}
+
+func TestTypeScriptTarget(t *testing.T) {
+	order := []Field{{Name: "InvoiceID", Type: "string", Tag: `json:"invoice-id"`}, {Name: "Note", Type: "*string", Tag: `json:"note"`}}
+	invoice := []Field{{Name: "Total", Type: "int64", Tag: `json:"total,omitempty"`}}
+	def := ServiceDefinition{
+		Name:    "orders",
+		Types:   map[string][]Field{"orders.Order": order, "billing.Order": invoice},
+		Methods: []MethodDefinition{{Name: "Create", InputType: "orders.Order", InputSchema: order, OutputType: "billing.Order", OutputSchema: invoice}},
+	}
+	info := ServiceInfo{
+		ServiceName:       "orders",
+		ServiceStructName: "Orders",
+		Methods:           []MethodInfo{{Name: "create", ExposedName: "Create", OriginalName: "Create", IsService: true, InputType: "service.Order", HasInput: true}},
+		ServiceDir:        "services/orders",
+		OutputDir:         ".polycode",
+	}
+	files, err := typeScriptTarget{}.Generate(info, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(files["typescript/orders.ts"])
+	for _, want := range []string{
+		"export interface BillingOrder {\n\ttotal?: number;\n}",
+		"export interface OrdersOrder {\n\t\"invoice-id\": string;\n\tnote?: string;\n}",
+		"input as OrdersOrder",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("the wrapper does not contain %q:\n%s", want, code)
+		}
+	}
+}
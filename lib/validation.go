@@ -0,0 +1,295 @@
+package lib
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validationSupportName holds the validation error types shared by the wrappers of all services
+const validationSupportName = "validation.go"
+
+const validationSupport = `// Code generated by next-gen. DO NOT EDIT.
+package %s
+
+import (
+	"strings"
+)
+
+// ValidationError describes an input field rejected by a rule of its validate tag
+type ValidationError struct {
+	Field   string ` + "`json:\"field\"`" + `
+	Rule    string ` + "`json:\"rule\"`" + `
+	Param   string ` + "`json:\"param,omitempty\"`" + `
+	Message string ` + "`json:\"message\"`" + `
+}
+
+// ValidationErrors is returned by ExecuteService and ExecuteWorkflow when an input is rejected before dispatch
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "invalid input: " + strings.Join(messages, "; ")
+}
+`
+
+// Kinds of field types the validate rules are checked against
+const (
+	kindString     = "string"
+	kindInt        = "int"
+	kindUint       = "uint"
+	kindFloat      = "float"
+	kindBool       = "bool"
+	kindCollection = "collection" // Slices, arrays and maps, constrained by their length
+)
+
+// validationRule is a single rule of a validate tag, like min=1
+type validationRule struct {
+	Name  string
+	Param string
+}
+
+// ValidationCheck is a generated check of an input field, Cond is the Go expression that is true when it fails
+type ValidationCheck struct {
+	Field   string // JSON name of the field
+	Rule    string
+	Param   string
+	Cond    string
+	Message string
+}
+
+// parseValidateTag returns the rules of the validate tag of a field, like `validate:"required,min=1"`
+func parseValidateTag(field Field) []validationRule {
+	tag, ok := reflect.StructTag(field.Tag).Lookup("validate")
+	if !ok || tag == "" || tag == "-" {
+		return nil
+	}
+
+	var rules []validationRule
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, validationRule{Name: name, Param: param})
+		}
+	}
+	return rules
+}
+
+// validationChecks generates the checks of the validate tags of an input struct, with v as the input pointer.
+// Rules that cannot be checked for the field type are skipped with a warning.
+func validationChecks(typeName string, fields []Field) []ValidationCheck {
+	var checks []ValidationCheck
+	for _, field := range fields {
+		rules := parseValidateTag(field)
+		name, _, skip := jsonFieldName(field)
+		if len(rules) == 0 || skip {
+			continue
+		}
+
+		value := "v." + field.Name
+		isPointer := strings.HasPrefix(field.Type, "*")
+		// guard skips the value checks when the field is nil, or zero with the omitempty rule
+		guard := ""
+		if isPointer {
+			guard = value + " != nil && "
+			value = "(*" + value + ")"
+		}
+		for _, rule := range rules {
+			if rule.Name == "omitempty" && !isPointer {
+				if zero, ok := zeroCheck(value, field.kind); ok {
+					guard = "!(" + zero + ") && "
+				}
+			}
+		}
+
+		for _, rule := range rules {
+			if rule.Name == "omitempty" {
+				continue
+			}
+
+			var cond, message string
+			var err error
+			if rule.Name == "required" && isPointer {
+				cond, message = "v."+field.Name+" == nil", "is required"
+			} else if cond, message, err = ruleCheck(rule, value, field.kind); err != nil {
+				slog.Warn("Skipping validate rule", "type", typeName, "field", field.Name, "rule", rule.Name, "reason", err)
+				continue
+			} else if rule.Name != "required" {
+				cond = guard + "(" + cond + ")"
+			}
+			checks = append(checks, ValidationCheck{
+				Field:   name,
+				Rule:    rule.Name,
+				Param:   rule.Param,
+				Cond:    cond,
+				Message: name + " " + message,
+			})
+		}
+	}
+	return checks
+}
+
+// zeroCheck returns the expression testing whether a value of the kind is its zero value
+func zeroCheck(value string, kind string) (string, bool) {
+	switch kind {
+	case kindString:
+		return value + ` == ""`, true
+	case kindInt, kindUint, kindFloat:
+		return value + " == 0", true
+	case kindBool:
+		return "!" + value, true
+	case kindCollection:
+		return "len(" + value + ") == 0", true
+	}
+	return "", false
+}
+
+// ruleCheck returns the failure condition and message of a rule for a value of the kind
+func ruleCheck(rule validationRule, value string, kind string) (cond string, message string, err error) {
+	switch rule.Name {
+	case "required":
+		cond, ok := zeroCheck(value, kind)
+		if !ok {
+			return "", "", fmt.Errorf("required is only supported on pointers, strings, numbers, booleans and collections")
+		}
+		return cond, "is required", nil
+
+	case "min", "max", "len":
+		operator, adjective := "<", "at least"
+		if rule.Name == "max" {
+			operator, adjective = ">", "at most"
+		} else if rule.Name == "len" {
+			operator, adjective = "!=", "exactly"
+		}
+		switch kind {
+		case kindString, kindCollection:
+			n, err := strconv.Atoi(rule.Param)
+			if err != nil || n < 0 {
+				return "", "", fmt.Errorf("%s needs a non-negative integer, got %q", rule.Name, rule.Param)
+			}
+			length, unit := "len("+value+")", "items"
+			if kind == kindString {
+				length, unit = "len([]rune("+value+"))", "characters"
+			}
+			return fmt.Sprintf("%s %s %d", length, operator, n), fmt.Sprintf("must have %s %d %s", adjective, n, unit), nil
+		case kindInt, kindUint, kindFloat:
+			if err := checkNumber(rule.Param, kind); err != nil {
+				return "", "", fmt.Errorf("%s: %w", rule.Name, err)
+			}
+			if rule.Name == "len" {
+				return "", "", fmt.Errorf("len is not supported on numbers")
+			}
+			return fmt.Sprintf("%s %s %s", value, operator, rule.Param), fmt.Sprintf("must be %s %s", adjective, rule.Param), nil
+		}
+		return "", "", fmt.Errorf("%s is only supported on strings, numbers and collections", rule.Name)
+
+	case "oneof":
+		values := strings.Fields(rule.Param)
+		if len(values) == 0 {
+			return "", "", fmt.Errorf("oneof needs at least one value")
+		}
+		var conds []string
+		for _, v := range values {
+			switch kind {
+			case kindString:
+				conds = append(conds, fmt.Sprintf("%s != %q", value, v))
+			case kindInt, kindUint:
+				if err := checkNumber(v, kind); err != nil {
+					return "", "", fmt.Errorf("oneof: %w", err)
+				}
+				conds = append(conds, fmt.Sprintf("%s != %s", value, v))
+			default:
+				return "", "", fmt.Errorf("oneof is only supported on strings and integers")
+			}
+		}
+		return strings.Join(conds, " && "), "must be one of " + strings.Join(values, ", "), nil
+	}
+	return "", "", fmt.Errorf("unsupported rule")
+}
+
+// checkNumber checks that a rule parameter is a constant assignable to the kind
+func checkNumber(param string, kind string) error {
+	var err error
+	switch kind {
+	case kindInt:
+		_, err = strconv.ParseInt(param, 10, 64)
+	case kindUint:
+		_, err = strconv.ParseUint(param, 10, 64)
+	default:
+		_, err = strconv.ParseFloat(param, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", kind, param)
+	}
+	return nil
+}
+
+// requiredByTag reports whether the validate tag of a field requires it
+func requiredByTag(field Field) bool {
+	return slices.ContainsFunc(parseValidateTag(field), func(rule validationRule) bool {
+		return rule.Name == "required"
+	})
+}
+
+// applySchemaConstraints adds the JSON Schema keywords equivalent to the validate rules of a field,
+// returning whether the field is required by them
+func applySchemaConstraints(schema map[string]any, field Field) (required bool) {
+	for _, rule := range parseValidateTag(field) {
+		if rule.Name == "required" {
+			required = true
+			continue
+		}
+
+		switch schema["type"] {
+		case "string":
+			switch rule.Name {
+			case "min", "max", "len":
+				setBounds(schema, rule, "minLength", "maxLength")
+			case "oneof":
+				schema["enum"] = strings.Fields(rule.Param)
+			}
+		case "integer", "number":
+			switch rule.Name {
+			case "min", "max":
+				setBounds(schema, rule, "minimum", "maximum")
+			case "oneof":
+				var values []any
+				for _, v := range strings.Fields(rule.Param) {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						values = append(values, n)
+					}
+				}
+				schema["enum"] = values
+			}
+		case "array":
+			if rule.Name == "min" || rule.Name == "max" || rule.Name == "len" {
+				setBounds(schema, rule, "minItems", "maxItems")
+			}
+		case "object":
+			if rule.Name == "min" || rule.Name == "max" || rule.Name == "len" {
+				setBounds(schema, rule, "minProperties", "maxProperties")
+			}
+		}
+	}
+	return required
+}
+
+// setBounds sets the lower and/or upper bound keywords of a min, max or len rule
+func setBounds(schema map[string]any, rule validationRule, lower string, upper string) {
+	n, err := strconv.ParseFloat(rule.Param, 64)
+	if err != nil {
+		return
+	}
+	if rule.Name != "max" {
+		schema[lower] = n
+	}
+	if rule.Name != "min" {
+		schema[upper] = n
+	}
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// version and commit are set by release builds with
+// -ldflags "-X github.com/cloudimpl/next-gen/lib.version=v1.2.0 -X github.com/cloudimpl/next-gen/lib.commit=<sha>"
+var (
+	version string
+	commit  string
+)
+
+// Version returns the version of the next-gen build: the version set at link time, the module version
+// for installed releases or devel for builds from a checkout
+func Version() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
+
+// Commit returns the VCS revision of the build, suffixed with -dirty for uncommitted changes, empty when unknown
+func Commit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// VersionString combines the version with the short commit, like v1.2.0 (3f2c1ab)
+func VersionString() string {
+	revision, dirty := strings.CutSuffix(Commit(), "-dirty")
+	if revision == "" {
+		return Version()
+	}
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	if dirty {
+		revision += "-dirty"
+	}
+	return Version() + " (" + revision + ")"
+}
+
+// generatedHeader is the generated file marker stamped with the generator version, pipelines read it
+// to detect artifacts of outdated generators
+func generatedHeader() string {
+	return generatedMarker + " " + VersionString()
+}
+
+// stampVersion inserts the generator version into the generated file header of a template
+func stampVersion(source string) string {
+	return strings.Replace(source, generatedMarker, generatedHeader(), 1)
+}
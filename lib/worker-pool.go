@@ -0,0 +1,47 @@
+package lib
+
+import (
+	"context"
+	"sync"
+)
+
+// serviceResult is the outcome of generating a single service
+type serviceResult struct {
+	name  string
+	files []string
+	err   error
+}
+
+// generateParallel runs generate for every service with at most workers running at once, services
+// not started when ctx is done fail with its error. Results are returned in the order of names
+// regardless of completion order.
+func generateParallel(ctx context.Context, names []string, workers int, generate func(name string) ([]string, error)) []serviceResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]serviceResult, len(names))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			results[i] = serviceResult{name: name, err: ctx.Err()}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			files, err := generate(name)
+			results[i] = serviceResult{name: name, files: files, err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
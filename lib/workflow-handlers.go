@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"unicode"
+)
+
+// Kinds of handlers, the functions signals are delivered and queries are answered through while a
+// workflow runs, and events are delivered through to their subscribers
+const (
+	handlerSignal = "signal"
+	handlerQuery  = "query"
+	handlerEvent  = "event"
+)
+
+// workflowHandler returns whether a function handles the signals or queries of running workflows and
+// the name it is exposed with. //polycode:signal and //polycode:query declare a handler, name=<name>
+// overriding its name. Without a directive workflow functions named On<Name>Signal or On<Name>Query
+// with the signature of their handler kind are handlers, other functions stay workflow methods.
+func workflowHandler(fn *ast.FuncDecl, directives map[string]string, contextType string, sdk sdkResolver) (string, string, error) {
+	_, isSignal := directives[handlerSignal]
+	_, isQuery := directives[handlerQuery]
+
+	var kind, name string
+	switch {
+	case isSignal && isQuery:
+		return "", "", fmt.Errorf("function %s: //polycode:signal and //polycode:query cannot be combined", fn.Name.Name)
+	case isSignal || isQuery:
+		kind = handlerSignal
+		if isQuery {
+			kind = handlerQuery
+		}
+		for key, value := range parseDirectiveArgs(directives[kind]) {
+			if key != "name" || value == "" || value == "true" {
+				return "", "", fmt.Errorf("function %s: //polycode:%s: unknown option %q, expected name=<name>", fn.Name.Name, kind, key)
+			}
+			name = value
+		}
+		if contextType != "Workflow" {
+			return "", "", fmt.Errorf("function %s: %s handlers take a polycode.WorkflowContext", fn.Name.Name, kind)
+		}
+		if name == "" {
+			if _, name = handlerByName(fn.Name.Name); name == "" {
+				name = fn.Name.Name
+			}
+		}
+	case contextType == "Workflow":
+		if kind, name = handlerByName(fn.Name.Name); kind == "" || !hasHandlerSignature(fn, kind) {
+			return "", "", nil
+		}
+	default:
+		return "", "", nil
+	}
+
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if fn.Type.TypeParams != nil {
+		return "", "", fmt.Errorf("function %s: generic functions cannot be %s handlers", fn.Name.Name, kind)
+	}
+	if len(params) == 2 {
+		if _, variadic := params[1].(*ast.Ellipsis); variadic {
+			return "", "", fmt.Errorf("function %s: %s handlers take a single input, variadic parameters are not supported", fn.Name.Name, kind)
+		}
+		if _, isStream, _ := streamElement(params[1], sdk); isStream {
+			return "", "", fmt.Errorf("function %s: %s handlers cannot take a stream", fn.Name.Name, kind)
+		}
+	}
+	if len(results) == 2 {
+		if _, isStream, _ := streamElement(results[0], sdk); isStream {
+			return "", "", fmt.Errorf("function %s: %s handlers cannot return a stream", fn.Name.Name, kind)
+		}
+	}
+
+	switch {
+	case kind == handlerSignal && !hasHandlerSignature(fn, kind):
+		return "", "", fmt.Errorf("function %s: signal handlers must have the signature func(ctx polycode.WorkflowContext, signal T) error", fn.Name.Name)
+	case kind == handlerQuery && !hasHandlerSignature(fn, kind):
+		return "", "", fmt.Errorf("function %s: query handlers must have the signature func(ctx polycode.WorkflowContext[, input T]) (T, error)", fn.Name.Name)
+	}
+	return kind, name, nil
+}
+
+// hasHandlerSignature reports whether the parameter and result counts of a function fit a handler kind,
+// signals take an input and return an error, queries return an output and an error
+func hasHandlerSignature(fn *ast.FuncDecl, kind string) bool {
+	params, results := flattenFields(fn.Type.Params), flattenFields(fn.Type.Results)
+	if len(results) == 0 || !isErrorType(results[len(results)-1]) {
+		return false
+	}
+	if kind == handlerSignal {
+		return len(params) == 2 && len(results) == 1
+	}
+	return len(params) <= 2 && len(results) == 2
+}
+
+// handlerByName returns the handler kind and name of a function following the naming convention,
+// OnPaymentSignal is the signal Payment and OnStatusQuery the query Status, empty for other names.
+// The name after On must start with an upper case letter, OnlineSignal is not a handler.
+func handlerByName(funcName string) (string, string) {
+	name, ok := strings.CutPrefix(funcName, "On")
+	if !ok || name == "" || !unicode.IsUpper(rune(name[0])) {
+		return "", ""
+	}
+	if name, ok := strings.CutSuffix(name, "Signal"); ok && name != "" {
+		return handlerSignal, name
+	}
+	if name, ok := strings.CutSuffix(name, "Query"); ok && name != "" {
+		return handlerQuery, name
+	}
+	return "", ""
+}
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// appModule is a Go module of the app, its services are generated into the output folder inside it
+type appModule struct {
+	Dir  string // Module folder
+	Name string // Module path
+	// Workspace holds the paths of the other modules of go.work, their packages are imported without requirements
+	Workspace []string
+}
+
+// resolveModules returns the modules listed by the go.work of the app, or the module at the app root
+func resolveModules(appPath string) ([]appModule, error) {
+	data, err := os.ReadFile(filepath.Join(appPath, "go.work"))
+	if os.IsNotExist(err) {
+		moduleName, err := getModuleName(filepath.Join(appPath, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		return []appModule{{Dir: filepath.Clean(appPath), Name: moduleName}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	work, err := modfile.ParseWork("go.work", data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []appModule
+	for _, use := range work.Use {
+		dir := filepath.Join(appPath, filepath.FromSlash(use.Path))
+		if filepath.IsAbs(filepath.FromSlash(use.Path)) {
+			dir = filepath.Clean(use.Path)
+		}
+		moduleName, err := getModuleName(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("go.work module %s: %w", use.Path, err)
+		}
+		modules = append(modules, appModule{Dir: dir, Name: moduleName})
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("go.work does not use any module")
+	}
+
+	for i := range modules {
+		for _, other := range modules {
+			if other.Dir != modules[i].Dir {
+				modules[i].Workspace = append(modules[i].Workspace, other.Name)
+			}
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Dir < modules[j].Dir
+	})
+	return modules, nil
+}
+
+// groupByModule assigns each service to the innermost module containing its folder,
+// the folders of the returned entries are relative to their module
+func groupByModule(appPath string, modules []appModule, entries []serviceEntry) (map[string][]serviceEntry, error) {
+	grouped := make(map[string][]serviceEntry)
+	for _, entry := range entries {
+		dir := filepath.Join(appPath, filepath.FromSlash(entry.Dir))
+
+		var owner *appModule
+		var rel string
+		for i, module := range modules {
+			r, err := filepath.Rel(module.Dir, dir)
+			if err != nil || isOutside(r) {
+				continue
+			}
+			if owner == nil || len(module.Dir) > len(owner.Dir) {
+				owner, rel = &modules[i], r
+			}
+		}
+		if owner == nil {
+			return nil, fmt.Errorf("service %s in %s is not inside a module of go.work", entry.Name, entry.Dir)
+		}
+
+		entry.Dir = filepath.ToSlash(rel)
+		grouped[owner.Dir] = append(grouped[owner.Dir], entry)
+	}
+	return grouped, nil
+}
+
+// containsService reports whether one of the entries is among the named services
+func containsService(entries []serviceEntry, names []string) bool {
+	for _, entry := range entries {
+		if slices.Contains(names, entry.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputFolders returns the output folder of every module of the app
+func OutputFolders(appPath string, opts Options) ([]string, error) {
+	modules, err := resolveModules(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]string, len(modules))
+	for i, module := range modules {
+		folders[i] = filepath.Join(module.Dir, opts.OutputDir)
+	}
+	return folders, nil
+}
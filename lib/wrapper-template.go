@@ -0,0 +1,616 @@
+package lib
+
+import (
+	"bytes"
+	"text/template"
+)
+
+const wrapperTemplate = `// Code generated by next-gen in {{if .IsProduction}}production{{else}}development{{end}} mode. DO NOT EDIT.
+// Production mode answers the "@definition" method of ExecuteService with the list of methods,
+// development mode leaves it out. Switch with the -prod / -dev flags.
+package {{.PackageName}}
+
+import (
+	"errors"
+	"fmt"
+	{{.SDKImport}}
+	"strings"
+	service "{{.ServicePackage}}"
+	{{range .DependencyImports}}{{.}}
+	{{end}}
+)
+
+func init() {
+	polycode.RegisterService(New{{.ServiceStructName}}())
+}
+
+// New{{.ServiceStructName}} returns the wrapper registered with the runtime
+func New{{.ServiceStructName}}() *{{.ServiceStructName}} {
+	return &{{.ServiceStructName}}{
+		{{if .Receiver}}receiver: {{if .Receiver.Constructor}}service.{{.Receiver.Constructor}}(){{else}}&service.{{.Receiver.Type}}{}{{end}},{{end}}
+		{{if .HasConcurrencyLimits}}semaphores: map[string]chan struct{}{
+			{{range .Methods}}{{if .ConcurrencyLimit}}"{{.Name}}": make(chan struct{}, {{.ConcurrencyLimit}}),
+			{{end}}{{end}}
+		},{{end}}
+	}
+}
+
+type {{.ServiceStructName}} struct {
+	{{if .Receiver}}// receiver is the instance of service.{{.Receiver.Type}} the methods are called on
+	receiver *service.{{.Receiver.Type}}{{end}}
+	{{if .HasConcurrencyLimits}}// semaphores bounds the concurrent executions of methods with a //polycode:concurrency limit
+	semaphores map[string]chan struct{}{{end}}
+}
+{{range .Methods}}{{if .Validations}}{{if .InputCollection}}
+// validate{{.OriginalName}} checks the elements of the input of {{.OriginalName}} against the validate tags of their fields
+func (t *{{$.ServiceStructName}}) validate{{.OriginalName}}(input *{{.InputType}}) error {
+	var errs ValidationErrors
+	for key, v := range *input {
+		{{if .IsInputElemPointer}}if v == nil {
+			continue
+		}
+		{{end}}{{range .Validations}}if {{.Cond}} {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("[%v].%s", key, {{printf "%q" .Field}}), Rule: {{printf "%q" .Rule}}, Param: {{printf "%q" .Param}}, Message: {{printf "%q" .Message}}})
+		}
+		{{end -}}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+{{else}}
+// validate{{.OriginalName}} checks the input of {{.OriginalName}} against the validate tags of its fields
+func (t *{{$.ServiceStructName}}) validate{{.OriginalName}}(v *{{.InputType}}) error {
+	var errs ValidationErrors
+	{{range .Validations}}if {{.Cond}} {
+		errs = append(errs, ValidationError{Field: {{printf "%q" .Field}}, Rule: {{printf "%q" .Rule}}, Param: {{printf "%q" .Param}}, Message: {{printf "%q" .Message}}})
+	}
+	{{end}}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+{{end}}{{end}}{{end}}
+{{range .Methods}}{{if .IsMultiInput}}
+// {{.InputType}} bundles the parameters of {{.OriginalName}} into a single input
+type {{.InputType}} struct {
+	{{range .Params}}{{.Name}} {{.Type}} ` + "`json:\"{{.JSONName}}\"`" + `
+	{{end}}
+}
+{{end}}{{end}}
+{{if .Lifecycle}}
+var _ polycode.LifecycleAware = (*{{.ServiceStructName}})(nil)
+
+// OnStart is called by the runtime before the service handles its first request
+func (t *{{.ServiceStructName}}) OnStart(ctx polycode.ServiceContext) error {
+	{{if .HasLifecycle "OnStart"}}return {{.LifecycleCallee "OnStart"}}(ctx){{else}}return nil{{end}}
+}
+
+// OnStop is called by the runtime when the service shuts down
+func (t *{{.ServiceStructName}}) OnStop(ctx polycode.ServiceContext) error {
+	{{if .HasLifecycle "OnStop"}}return {{.LifecycleCallee "OnStop"}}(ctx){{else}}return nil{{end}}
+}
+{{end}}
+{{if .HealthCheck}}
+// Health probes the service with its health check, the status is a {{if .HealthCheck.IsStatusPointer}}*{{end}}{{.HealthCheck.StatusType}}
+func (t *{{.ServiceStructName}}) Health(ctx polycode.ServiceContext) (any, error) {
+	return {{.HealthCallee}}(ctx)
+}
+{{end}}
+{{if .Errors}}
+// GetErrorCode returns the code of an error of the service error catalog, empty for other errors
+func (t *{{.ServiceStructName}}) GetErrorCode(err error) string {
+	switch {
+	{{range .Errors}}case errors.{{if eq .Kind "sentinel"}}Is(err, service.{{.Name}}){{else}}As(err, new({{if .Pointer}}*{{end}}service.{{.Name}})){{end}}:
+		return "{{.Code}}"
+	{{end}}}
+	return ""
+}
+{{end}}
+func (t *{{.ServiceStructName}}) GetName() string {
+	return "{{.ServiceName}}"
+}
+
+func (t *{{.ServiceStructName}}) GetDescription(method string) (string, error) {
+	method = strings.ToLower(method)
+	switch method {
+	{{range .Methods}}case "{{.Name}}":
+		{
+			return "{{.Description}}", nil
+		}
+	{{end}}default:
+		{
+			return "", errors.New("method not found")
+		}
+	}
+}
+
+// GetMethodDescription returns the Go doc comment of a method, GetDescription returns its @description
+func (t *{{.ServiceStructName}}) GetMethodDescription(method string) (string, error) {
+	switch strings.ToLower(method) {
+	{{range .Methods}}case "{{.Name}}":
+		return {{printf "%q" .Doc}}, nil
+	{{end}}default:
+		return "", fmt.Errorf("method %q not found", method)
+	}
+}
+
+// GetMethodOptions returns the options declared with //polycode:method, nil when there are none
+func (t *{{.ServiceStructName}}) GetMethodOptions(method string) (map[string]string, error) {
+	switch strings.ToLower(method) {
+	{{range .Methods}}
+	case "{{.Name}}":
+		{{if .Options}}return map[string]string{
+			{{range $key, $value := .Options}}{{printf "%q" $key}}: {{printf "%q" $value}},
+			{{end}}
+		}, nil{{else}}return nil, nil{{end}}
+	{{end}}
+	default:
+		return nil, fmt.Errorf("method %q not found", method)
+	}
+}
+
+// GetAuthPolicy returns the roles allowed to call a method, declared with //polycode:auth, nil when
+// every caller is allowed. The runtime checks the caller against it before dispatch.
+func (t *{{.ServiceStructName}}) GetAuthPolicy(method string) (*AuthPolicy, error) {
+	switch strings.ToLower(method) {
+	{{range .Methods}}
+	case "{{.Name}}":
+		{{if .Auth}}return &AuthPolicy{Roles: []string{ {{- range $i, $role := .Auth.Roles}}{{if $i}}, {{end}}{{printf "%q" $role}}{{end -}} }}, nil{{else}}return nil, nil{{end}}
+	{{end}}
+	default:
+		return nil, fmt.Errorf("method %q not found", method)
+	}
+}
+
+{{if .SDK.Policy}}// GetMethodPolicy returns the timeout and retry policy of a method, declared with //polycode:timeout and
+// //polycode:retry, the zero policy leaving both to the runtime. The runtime applies it to every call.
+func (t *{{.ServiceStructName}}) GetMethodPolicy(method string) polycode.Policy {
+	switch strings.ToLower(method) {
+	{{- range .Methods}}{{if or .Timeout .Retry}}
+	case "{{.Name}}":
+		return polycode.Policy{
+			{{- with .Timeout}}
+			Timeout: {{.Nanoseconds}}, // {{.}}
+			{{- end}}
+			{{- with .Retry}}
+			Retry: &polycode.RetryPolicy{MaxAttempts: {{.MaxAttempts}}, Backoff: {{printf "%q" .Backoff}}{{with .Delay}}, InitialDelay: {{.Nanoseconds}}{{end}}},
+			{{- end}}
+		}
+	{{- end}}{{end}}
+	default:
+		return polycode.Policy{}
+	}
+}
+{{end}}
+func (t *{{.ServiceStructName}}) GetInputType(method string) (any, error) {
+	method = strings.ToLower(method)
+	switch method {
+	{{range .Methods}}case "{{.Name}}":
+		{
+			{{if not .HasInput}}
+			return nil, nil
+			{{else if .IsInputPrimitive}}
+			var v {{.InputType}}
+			return &v, nil
+			{{else}}
+			return &{{.InputType}}{}, nil
+			{{end}}
+		}
+	{{end}}default:
+		{
+			return nil, errors.New("method not found")
+		}
+	}
+}
+
+func (t *{{.ServiceStructName}}) GetOutputType(method string) (any, error) {
+	switch strings.ToLower(method) {
+	{{range .Methods}}
+	case "{{.Name}}":
+		{{if not .HasOutput}}
+		return nil, nil
+		{{else if or .IsOutputPrimitive .IsOutputInterface}}
+		var v {{.OutputType}}
+		return &v, nil
+		{{else}}
+		return &{{.OutputType}}{}, nil
+		{{end}}
+	{{end}}
+	default:
+		return nil, fmt.Errorf("method %q not found", method)
+	}
+}
+
+// ExecuteService handles methods with polycode.ServiceContext as the first parameter
+func (t *{{.ServiceStructName}}) ExecuteService(ctx polycode.ServiceContext, method string, input any) ({{if or .Metrics .DebugDispatch}}output any, err error{{else}}any, error{{end}}) {
+	method = strings.ToLower(method)
+
+	{{if .IsProduction}}
+	// Handle @definition case
+	if method == "@definition" {
+		return []string{
+			{{range .Methods}}"{{.ExposedName}}",
+			{{end}}
+		}, nil
+	}
+	{{end}}
+
+	{{if .Metrics}}defer startCall(ctx, t.GetName(), method, "service")(&err){{end}}
+	{{if .DebugDispatch}}defer logDispatch(t.GetName(), method, "service")(&err){{end}}
+
+	{{if .HasConcurrencyLimits}}if sem, ok := t.semaphores[method]; ok {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+	}{{end}}
+
+	{{if .ServiceMiddleware}}return {{range .ServiceMiddleware}}service.{{.}}(ctx, method, input, func() (any, error) {
+		return {{end}}t.executeService(ctx, method, input)
+	{{range .ServiceMiddleware}}})
+	{{end -}}
+}
+
+// executeService dispatches a call of ExecuteService once it went through the //polycode:middleware chain
+func (t *{{.ServiceStructName}}) executeService(ctx polycode.ServiceContext, method string, input any) (any, error) {
+	{{end}}switch method {
+	{{range .Methods}}{{if .IsService}}case "{{.Name}}":
+		{
+			{{if .IsStreaming}}
+			return nil, fmt.Errorf("method %q is streaming, use ExecuteServiceStream", method)
+			{{else if .HasOutput}}
+			{{template "deprecated" .}}
+			{{template "validate" .}}
+			// Pass the input correctly as a pointer or value based on the method signature
+			return {{.Callee}}(ctx{{template "input" .}})
+			{{else}}
+			{{template "deprecated" .}}
+			{{template "validate" .}}
+			// Pass the input correctly as a pointer or value based on the method signature
+			return nil, {{.Callee}}(ctx{{template "input" .}})
+			{{end}}
+		}
+		{{end}}{{end}}default:
+		{
+			return nil, errors.New("method not found")
+		}
+	}
+}
+
+// ExecuteWorkflow handles methods with polycode.WorkflowContext as the first parameter
+func (t *{{.ServiceStructName}}) ExecuteWorkflow(ctx polycode.WorkflowContext, method string, input any) ({{if or .Metrics .DebugDispatch}}output any, err error{{else}}any, error{{end}}) {
+	method = strings.ToLower(method)
+
+	{{if .Metrics}}defer startCall(ctx, t.GetName(), method, "workflow")(&err){{end}}
+	{{if .DebugDispatch}}defer logDispatch(t.GetName(), method, "workflow")(&err){{end}}
+
+	{{if .HasConcurrencyLimits}}if sem, ok := t.semaphores[method]; ok {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+	}{{end}}
+
+	{{if .WorkflowMiddleware}}return {{range .WorkflowMiddleware}}service.{{.}}(ctx, method, input, func() (any, error) {
+		return {{end}}t.executeWorkflow(ctx, method, input)
+	{{range .WorkflowMiddleware}}})
+	{{end -}}
+}
+
+// executeWorkflow dispatches a call of ExecuteWorkflow once it went through the //polycode:middleware chain
+func (t *{{.ServiceStructName}}) executeWorkflow(ctx polycode.WorkflowContext, method string, input any) (any, error) {
+	{{end}}switch method {
+	{{range .Methods}}{{if .IsWorkflow}}case "{{.Name}}":
+		{
+			{{if .IsStreaming}}
+			return nil, fmt.Errorf("method %q is streaming, use ExecuteWorkflowStream", method)
+			{{else if .HasOutput}}
+			{{template "deprecated" .}}
+			{{template "validate" .}}
+			// Pass the input correctly as a pointer or value based on the method signature
+			return {{.Callee}}(ctx{{template "input" .}})
+			{{else}}
+			{{template "deprecated" .}}
+			{{template "validate" .}}
+			// Pass the input correctly as a pointer or value based on the method signature
+			return nil, {{.Callee}}(ctx{{template "input" .}})
+			{{end}}
+		}
+		{{end}}{{end}}default:
+		{
+			return nil, errors.New("method not found")
+		}
+	}
+}
+
+// ExecuteServiceStream handles streaming methods with polycode.ServiceContext as the first parameter.
+// Streaming inputs are passed as a <-chan any of the input type, the elements of the method's
+// output are sent on the returned channel, which is closed when the output ends or ctx is done.
+// An input element of another type fails the stream: the input of the method ends and the error is
+// returned, or sent as the last element of a streaming output.
+func (t *{{.ServiceStructName}}) ExecuteServiceStream(ctx polycode.ServiceContext, method string, input any) (<-chan any, error) {
+	method = strings.ToLower(method)
+
+	// The slot of a concurrency limit is held until the output of the stream ends
+	release := func() {}
+	{{if .HasConcurrencyLimits}}if sem, ok := t.semaphores[method]; ok {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-sem }
+	}{{end}}
+
+	switch method {
+	{{range .Methods}}{{if and .IsService .IsStreaming}}case "{{.Name}}":{{template "stream" .}}
+	{{end}}{{end}}default:
+		release()
+		return nil, fmt.Errorf("streaming method %q not found", method)
+	}
+}
+
+// ExecuteWorkflowStream handles streaming methods with polycode.WorkflowContext as the first parameter
+func (t *{{.ServiceStructName}}) ExecuteWorkflowStream(ctx polycode.WorkflowContext, method string, input any) (<-chan any, error) {
+	method = strings.ToLower(method)
+
+	// The slot of a concurrency limit is held until the output of the stream ends
+	release := func() {}
+	{{if .HasConcurrencyLimits}}if sem, ok := t.semaphores[method]; ok {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-sem }
+	}{{end}}
+
+	switch method {
+	{{range .Methods}}{{if and .IsWorkflow .IsStreaming}}case "{{.Name}}":{{template "stream" .}}
+	{{end}}{{end}}default:
+		release()
+		return nil, fmt.Errorf("streaming method %q not found", method)
+	}
+}
+
+// IsStreaming checks whether the method consumes or produces a stream
+func (t *{{.ServiceStructName}}) IsStreaming(method string) bool {
+	switch strings.ToLower(method) {
+	{{range .Methods}}{{if .IsStreaming}}case "{{.Name}}":
+		return true
+	{{end}}{{end}}
+	}
+	return false
+}
+
+// ExecuteSignal delivers a signal to a running workflow through its handler, declared with
+// //polycode:signal or named On<Name>Signal
+func (t *{{.ServiceStructName}}) ExecuteSignal(ctx polycode.WorkflowContext, signal string, input any) error {
+	switch strings.ToLower(signal) {
+	{{range .Signals}}case "{{.Name}}":
+		{{- if .Deprecated}}
+		{{template "deprecated" .}}
+		{{- end}}
+		return {{.Callee}}(ctx{{template "input" .}})
+	{{end}}default:
+		return fmt.Errorf("signal %q not found", signal)
+	}
+}
+
+// ExecuteQuery answers a query on the state of a running workflow through its handler, declared with
+// //polycode:query or named <Name>Query
+func (t *{{.ServiceStructName}}) ExecuteQuery(ctx polycode.WorkflowContext, query string, input any) (any, error) {
+	switch strings.ToLower(query) {
+	{{range .Queries}}case "{{.Name}}":
+		{{- if .Deprecated}}
+		{{template "deprecated" .}}
+		{{- end}}
+		return {{.Callee}}(ctx{{template "input" .}})
+	{{end}}default:
+		return nil, fmt.Errorf("query %q not found", query)
+	}
+}
+
+// GetSignalInputType returns a pointer to a new value of the input of a signal
+func (t *{{.ServiceStructName}}) GetSignalInputType(signal string) (any, error) {
+	switch strings.ToLower(signal) {
+	{{range .Signals}}case "{{.Name}}":
+		{{template "newInput" .}}
+	{{end}}default:
+		return nil, fmt.Errorf("signal %q not found", signal)
+	}
+}
+
+// GetQueryInputType returns a pointer to a new value of the input of a query, nil for queries without input
+func (t *{{.ServiceStructName}}) GetQueryInputType(query string) (any, error) {
+	switch strings.ToLower(query) {
+	{{range .Queries}}case "{{.Name}}":
+		{{template "newInput" .}}
+	{{end}}default:
+		return nil, fmt.Errorf("query %q not found", query)
+	}
+}
+
+// ExecuteEvent delivers a published event to the function of the service subscribed to it with
+// //polycode:subscribe, the input is a pointer to the payload
+func (t *{{.ServiceStructName}}) ExecuteEvent(ctx polycode.ServiceContext, event string, input any) error {
+	switch event {
+	{{range .Subscribers}}case {{printf "%q" .Event}}:
+		{{- if .Deprecated}}
+		{{template "deprecated" .}}
+		{{- end}}
+		return {{.Callee}}(ctx{{template "input" .}})
+	{{end}}default:
+		return fmt.Errorf("event %q has no subscriber", event)
+	}
+}
+
+// GetEventPayloadType returns a pointer to a new value of the payload of an event the service subscribes to
+func (t *{{.ServiceStructName}}) GetEventPayloadType(event string) (any, error) {
+	switch event {
+	{{range .Subscribers}}case {{printf "%q" .Event}}:
+		{{template "newInput" .}}
+	{{end}}default:
+		return nil, fmt.Errorf("event %q has no subscriber", event)
+	}
+}
+
+// Subscriptions returns the events the service subscribes to sorted by name
+func (t *{{.ServiceStructName}}) Subscriptions() []string {
+	return []string{ {{- range $i, $s := .Subscribers}}{{if $i}}, {{end}}{{printf "%q" $s.Event}}{{end -}} }
+}
+
+// IsWorkflow checks whether the method is a workflow (i.e., its first parameter is polycode.WorkflowContext)
+func (t *{{.ServiceStructName}}) IsWorkflow(method string)bool {
+	method = strings.ToLower(method)
+	switch method {
+	{{range .Methods}}{{if .IsWorkflow}}case "{{.Name}}":
+		{
+			return true
+		}
+		{{end}}{{end}}
+	}
+	return false
+}
+{{define "stream"}}
+		{{- if .Deprecated}}
+		{{template "deprecated" .}}
+		{{- end}}
+		{{- if .IsInputStream}}
+		elements, ok := input.(<-chan any)
+		if !ok {
+			release()
+			return nil, fmt.Errorf("method %q expects a <-chan any input, got %T", method, input)
+		}
+		in := make(chan {{if .IsInputPointer}}*{{end}}{{.InputType}})
+		// failed holds the error of an input element of another type, the input ends with it
+		failed := make(chan error, 1)
+		go func() {
+			defer close(in)
+			for {
+				var element any
+				select {
+				case e, ok := <-elements:
+					if !ok {
+						return
+					}
+					element = e
+				case <-ctx.Done():
+					return
+				}
+
+				var v {{if .IsInputPointer}}*{{end}}{{.InputType}}
+				switch e := element.(type) {
+				case *{{.InputType}}:
+					v = {{if not .IsInputPointer}}*{{end}}e
+				{{- if not .IsInputPointer}}
+				case {{.InputType}}:
+					v = e
+				{{- end}}
+				default:
+					failed <- fmt.Errorf("method %q expects input elements of type {{if .IsInputPointer}}*{{end}}{{.InputType}}, got %T", method, element)
+					return
+				}
+				select {
+				case in <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		{{- end}}
+		{{if .HasOutput}}output, err := {{else}}err := {{end}}{{.Callee}}(ctx{{if .IsInputStream}}, in{{else}}{{template "input" .}}{{end}})
+		if err != nil {
+			release()
+			return nil, err
+		}
+		{{- if .IsOutputStream}}
+		results := make(chan any)
+		go func() {
+			defer release()
+			defer close(results)
+			for {
+				select {
+				case element, ok := <-output:
+					if !ok {
+						{{- if .IsInputStream}}
+						select {
+						case err := <-failed:
+							select {
+							case results <- err:
+							case <-ctx.Done():
+							}
+						default:
+						}
+						{{- end}}
+						return
+					}
+					select {
+					case results <- element:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		{{- else}}
+		release()
+		{{- if .IsInputStream}}
+		select {
+		case err := <-failed:
+			return nil, err
+		default:
+		}
+		{{- end}}
+		results := make(chan any, 1)
+		{{- if .HasOutput}}
+		results <- output
+		{{- end}}
+		close(results)
+		{{- end}}
+		return results, nil
+{{- end}}
+{{define "deprecated"}}{{if .Deprecated}}reportDeprecatedCall(t.GetName(), {{printf "%q" .ExposedName}}, {{printf "%q" .Deprecated.Since}}, {{printf "%q" .Deprecated.Use}}){{end}}{{end}}
+{{define "validate"}}{{if .Validations}}if err := t.validate{{.OriginalName}}(input.(*{{.InputType}})); err != nil {
+				return nil, err
+			}{{end}}{{end}}
+{{define "newInput"}}{{if not .HasInput}}return nil, nil{{else if .IsInputPrimitive}}var v {{.InputType}}
+		return &v, nil{{else}}return &{{.InputType}}{}, nil{{end}}{{end}}
+{{define "input"}}{{if .IsMultiInput}}{{range .Params}}, input.(*{{$.InputType}}).{{.Name}}{{if .IsVariadic}}...{{end}}{{end}}{{else if .HasInput}}, {{if .IsInputPointer}}input.(*{{.InputType}}){{else}}*(input.(*{{.InputType}})){{end}}{{end}}{{end}}`
+
+// GenerateService the wrapper code based on the extracted information
+func generateServiceCode(serviceInfo ServiceInfo, wrapper string, partials map[string]string) (string, error) {
+	// Use template to generate the code
+	var buf bytes.Buffer
+	tmpl, err := parseWrapperTemplate(wrapper, partials)
+	if err != nil {
+		return "", err
+	}
+
+	err = tmpl.Execute(&buf, serviceInfo)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// parseWrapperTemplate parses a wrapper template along with the {{define}} blocks of the partials
+func parseWrapperTemplate(wrapper string, partials map[string]string) (*template.Template, error) {
+	tmpl := template.New("wrapper")
+	for name, partial := range partials {
+		if _, err := tmpl.New(name).Parse(partial); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl.Parse(stampVersion(wrapper))
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// yamlHeader marks generated YAML files, the Go and TypeScript templates carry the equivalent comment
+var yamlHeader = "# " + generatedHeader() + ". DO NOT EDIT.\n"
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers like the watcher or the compiler never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Remove is a no-op once the rename succeeded
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,156 +1,1233 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/cloudimpl/next-gen/lib"
 	"github.com/fsnotify/fsnotify"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
-func watch(appPath string, onChange func()) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatalf("Failed to create watcher: %v", err)
-	}
-	defer watcher.Close()
+// maxWatchBackoff caps the delay between attempts to re-create a failed watcher
+const maxWatchBackoff = 30 * time.Second
+
+// errReload stops a watcher so that it is re-created with the current roots
+var errReload = errors.New("watched roots changed")
 
+// watch watches the roots recursively plus the given files and calls onChange with the changed path,
+// paths matched by ignore are neither watched nor reported. A failing watcher is re-created with
+// backoff, with poll set the file system is scanned at that interval instead of using notifications.
+// The roots are read again whenever reload receives, and at every scan when polling.
+func watch(roots func() []string, files []string, ignore *lib.IgnoreMatcher, poll time.Duration, reload <-chan struct{}, onChange func(path string)) {
 	// Handle OS signals for graceful shutdown
+	stop := make(chan struct{})
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		log.Println("Received termination signal, shutting down watcher...")
-		watcher.Close()
+		slog.Info("Received termination signal, shutting down watcher...")
+		close(stop)
 	}()
 
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
+	if poll > 0 {
+		pollChanges(roots, files, ignore, poll, stop, onChange)
+		return
+	}
 
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					info, err := os.Stat(event.Name)
-					if err == nil && info.IsDir() {
-						log.Printf("New directory detected: %s, adding to watcher", event.Name)
-						if err := watcher.Add(event.Name); err != nil {
-							log.Printf("Failed to watch new directory: %s, error: %v", event.Name, err)
-						}
-					}
-				}
+	backoff := time.Second
+	failed := false
+	for {
+		started := time.Now()
+		current := roots()
+		if failed {
+			// Changes made while no watcher was running are picked up by a full regeneration
+			onChange(current[0])
+		}
+
+		err := watchEvents(current, files, ignore, stop, reload, onChange)
+		if err == nil {
+			return
+		}
+		if failed = !errors.Is(err, errReload); !failed {
+			slog.Debug("Restarting watcher with the new roots")
+			continue
+		}
+
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+			slog.Error("The inotify watch limit was reached. Raise it with sysctl fs.inotify.max_user_watches=524288 "+
+				"(add it to /etc/sysctl.conf to persist), ignore large folders with -ignore, or use -poll 2s", "error", err)
+		} else {
+			slog.Error("Watcher failed", "error", err)
+		}
+
+		// A watcher that ran for a while failed for a new reason, start over with a short delay
+		if time.Since(started) > maxWatchBackoff {
+			backoff = time.Second
+		}
+		slog.Info("Restarting watcher", "in", backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWatchBackoff)
+	}
+}
 
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					if lib.IsGoFile(event.Name) {
-						if err := lib.CheckFileCompilable(event.Name); err == nil {
-							log.Printf("Change detected in: %s, triggering onChange", event.Name)
-							onChange()
-						} else {
-							log.Printf("File not compilable: %s, error: %v", event.Name, err)
-						}
+// watchEvents runs a single fsnotify watcher until stop is closed, which returns nil, or the watcher fails.
+// Editors saving atomically replace files through a rename, which shows up as Rename and Create events
+// rather than Write, so created and replaced files are reported like written ones.
+func watchEvents(roots []string, files []string, ignore *lib.IgnoreMatcher, stop <-chan struct{}, reload <-chan struct{}, onChange func(path string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, root := range roots {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			slog.Warn("Services folder does not exist, not watching it", "path", root)
+			continue
+		}
+		if _, err := watchTree(watcher, root, ignore); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", root, err)
+		}
+	}
+
+	// A watch on a file is lost when the file is replaced, the folders of the files are watched instead
+	for _, file := range files {
+		slog.Debug("Adding file to watcher", "path", file)
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			slog.Warn("Failed to watch file", "path", file, "error", err)
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+
+		case <-reload:
+			return errReload
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("watcher closed unexpectedly")
+			}
+
+			isFile := slices.Contains(files, event.Name)
+			if !isFile && (!underRoots(event.Name, roots) || ignore.Match(event.Name, false)) {
+				continue
+			}
+
+			switch {
+			case event.Op&fsnotify.Create != 0:
+				info, err := os.Stat(event.Name)
+				if err != nil {
+					continue
+				}
+				if !info.IsDir() {
+					// An empty file was just created, its content arrives with a Write
+					if info.Size() > 0 {
+						fileChanged(event.Name, files, onChange)
 					}
+					continue
+				}
+				if ignore.Match(event.Name, true) {
+					continue
+				}
+				slog.Debug("New directory detected, adding to watcher", "path", event.Name)
+				hasGoFiles, err := watchTree(watcher, event.Name, ignore)
+				if err != nil {
+					return fmt.Errorf("failed to watch new directory %s: %w", event.Name, err)
 				}
+				// Files of a folder moved or restored into place emit no events of their own
+				if hasGoFiles {
+					slog.Info("Change detected", "path", event.Name)
+					onChange(event.Name)
+				}
+
+			case event.Op&fsnotify.Write != 0:
+				fileChanged(event.Name, files, onChange)
 
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
+			case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				// A renamed folder stays watched under its old name, its new name arrives as a Create
+				_ = watcher.Remove(event.Name)
+				if _, err := os.Stat(event.Name); err == nil {
+					// Already replaced by an atomic save
+					fileChanged(event.Name, files, onChange)
+					continue
+				}
+				// A removed folder may be a deleted service and a removed file a deleted method,
+				// regenerate to clean up their outputs
+				if filepath.Ext(event.Name) == "" || lib.IsGoFile(event.Name) || isFile {
+					slog.Info("Removal detected", "path", event.Name)
+					onChange(event.Name)
 				}
-				log.Printf("Watcher error: %v", err)
 			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("watcher closed unexpectedly")
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Events were dropped, regenerate everything to catch up
+				slog.Warn("Watcher event queue overflowed, regenerating all services", "error", err)
+				onChange(roots[0])
+				continue
+			}
+			return err
 		}
-	}()
+	}
+}
 
-	err = filepath.Walk(appPath, func(path string, info os.FileInfo, err error) error {
+// watchTree adds a folder and its sub folders to the watcher, skipping ignored ones, and reports
+// whether they contain Go files
+func watchTree(watcher *fsnotify.Watcher, root string, ignore *lib.IgnoreMatcher) (hasGoFiles bool, err error) {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Error walking path: %s, error: %v", path, err)
+			slog.Error("Error walking path", "path", path, "error", err)
 			return err
 		}
 		if info.IsDir() {
-			log.Printf("Adding directory to watcher: %s", path)
+			if ignore.Match(path, true) {
+				slog.Debug("Ignoring directory", "path", path)
+				return filepath.SkipDir
+			}
+			slog.Debug("Adding directory to watcher", "path", path)
 			return watcher.Add(path)
 		}
+		if lib.IsGoFile(path) && !ignore.Match(path, false) {
+			hasGoFiles = true
+		}
 		return nil
 	})
-	if err != nil {
-		log.Fatalf("Failed to walk path: %v", err)
+	return hasGoFiles, err
+}
+
+// underRoots reports whether a path is one of the roots or inside one
+func underRoots(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileChanged reports a written file, Go files are only reported once they compile
+func fileChanged(path string, files []string, onChange func(path string)) {
+	if lib.IsGoFile(path) {
+		if err := lib.CheckFileCompilable(path); err == nil {
+			slog.Info("Change detected", "path", path)
+			onChange(path)
+		} else {
+			slog.Warn("File not compilable", "path", path, "error", err)
+		}
+	} else if slices.Contains(files, path) {
+		slog.Info("Change detected", "path", path)
+		onChange(path)
+	}
+}
+
+// pollChanges scans the watched files at every interval until stop is closed
+func pollChanges(roots func() []string, files []string, ignore *lib.IgnoreMatcher, interval time.Duration, stop <-chan struct{}, onChange func(path string)) {
+	slog.Info("Polling for changes", "interval", interval)
+	snapshot := lib.TakeSnapshot(roots(), files, ignore)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		next := lib.TakeSnapshot(roots(), files, ignore)
+		changed, removed := next.Diff(snapshot)
+		snapshot = next
+
+		for _, path := range changed {
+			fileChanged(path, files, onChange)
+		}
+		for _, path := range removed {
+			// Files of a removed folder are covered by the folder itself
+			if slices.Contains(removed, filepath.Dir(path)) {
+				continue
+			}
+			if filepath.Ext(path) == "" || lib.IsGoFile(path) {
+				slog.Info("Removal detected", "path", path)
+				onChange(path)
+			}
+		}
 	}
+}
 
-	<-done
+// generate runs a single generation and prints the summary table of the services unless quiet
+func generate(appPath string, opts lib.Options, quiet bool) {
+	report, err := lib.Generate(context.Background(), appPath, nil, opts)
+	if report != nil && !quiet {
+		fmt.Fprint(os.Stderr, "\n"+report.Summary()+"\n")
+	}
+	if err != nil {
+		printFailureSummary(err)
+		fatal("Error generating services", "error", err)
+	}
 }
 
-func generate(appPath string) {
-	err := lib.GenerateServices(appPath, true)
+// runDryRun prints the diff of the files a generation would write or remove
+func runDryRun(appPath string, opts lib.Options) {
+	diff, err := lib.DryRun(appPath, opts)
+	fmt.Print(diff)
 	if err != nil {
-		log.Fatalf("Error generating services: %s\n", err.Error())
+		printFailureSummary(err)
+		fatal("Error generating services", "error", err)
+	}
+	if diff == "" {
+		slog.Info("Generated files are up to date")
+	}
+}
+
+// printFailureSummary prints the table of failed services when some services could not be generated
+func printFailureSummary(err error) {
+	var genErr *lib.GenerationError
+	if errors.As(err, &genErr) {
+		fmt.Fprint(os.Stderr, "\n"+genErr.Summary()+"\n")
 	}
 }
 
-func watchAndGenerate(appPath string) {
+// watchAndGenerate regenerates on changes, when runner is set the app is rebuilt and restarted after each successful run
+// and when command is set it is run again, killing the previous invocation still running
+func watchAndGenerate(appPath string, opts lib.Options, overlayAddr string, incremental bool, debounce time.Duration, poll time.Duration, ignorePatterns []string, runner *lib.AppRunner, command *lib.AppRunner) {
 	// Ensure the directory exists
 	if _, err := os.Stat(appPath); os.IsNotExist(err) {
-		log.Fatalf("APP_PATH does not exist: %s", appPath)
+		fatal("APP_PATH does not exist", "path", appPath)
+	}
+
+	var overlay *lib.ErrorOverlay
+	if overlayAddr != "" {
+		overlay = lib.NewErrorOverlay()
+		go func() {
+			if err := overlay.ListenAndServe(overlayAddr); err != nil {
+				slog.Error("Error overlay stopped", "error", err)
+			}
+		}()
 	}
 
-	servicesPath := filepath.Join(appPath, "services")
-	log.Printf("Starting watcher on: %s", servicesPath)
+	var roots []string
+	for _, root := range opts.ServicesDirs {
+		roots = append(roots, filepath.Join(appPath, root))
+	}
+	// Editing a template override regenerates every service
+	if opts.TemplateDir != "" {
+		roots = append(roots, opts.TemplateDir)
+	}
+
+	// Packages of the app holding the types of the services, like models/, are watched too. Services
+	// may import other packages after a change, the watcher restarts when the set changes.
+	var sharedMu sync.Mutex
+	shared := sharedTypeDirs(appPath, opts)
+	reload := make(chan struct{}, 1)
+	watchRoots := func() []string {
+		sharedMu.Lock()
+		defer sharedMu.Unlock()
+		return append(slices.Clone(roots), shared...)
+	}
+	refreshShared := func() {
+		dirs := sharedTypeDirs(appPath, opts)
+		sharedMu.Lock()
+		changed := !slices.Equal(dirs, shared)
+		shared = dirs
+		sharedMu.Unlock()
+		if changed {
+			slog.Info("Shared type packages changed", "dirs", dirs)
+			select {
+			case reload <- struct{}{}:
+			default:
+			}
+		}
+	}
+	slog.Info("Starting watcher", "roots", roots, "shared", shared)
+
+	// Runs are keyed by service when regenerating incrementally, otherwise every change is a full run
+	runKey := func(path string) string {
+		key := ""
+		if incremental {
+			key, _ = lib.ServiceForPath(appPath, opts, path)
+		}
+		return key
+	}
 
-	watch(servicesPath, func() {
-		err := lib.GenerateServices(appPath, true)
+	// generate regenerates after a batch of changes, the paths of a single service when regenerating incrementally
+	generate := func(paths []string) {
+		var report *lib.Report
+		var err error
+		if serviceName := runKey(paths[0]); serviceName != "" {
+			slog.Info("Regenerating service", "service", serviceName)
+			report, err = lib.Generate(context.Background(), appPath, []string{serviceName}, opts)
+		} else {
+			report, err = lib.Generate(context.Background(), appPath, nil, opts)
+		}
 		if err != nil {
-			log.Printf("Error generating services: %v", err)
+			slog.Error("Error generating services", "error", err)
+			printFailureSummary(err)
+		} else {
+			// The hook sees the whole batch, one path per line, and the services the run wrote
+			env := map[string]string{"CHANGED_FILES": strings.Join(paths, "\n"), "SERVICE": strings.Join(report.Generated(), ",")}
+			if err = lib.RunHooks(lib.HookPostWatchChange, opts.Hooks.PostWatchChange, appPath, env); err != nil {
+				slog.Error("Error running hook", "error", err)
+			} else {
+				if runner != nil {
+					if err = runner.Restart(); err != nil {
+						slog.Error("Error restarting app", "error", err)
+					}
+				}
+				if command != nil {
+					if err := command.Restart(); err != nil {
+						slog.Error("Error running command", "error", err)
+					}
+				}
+			}
+		}
+		if overlay != nil {
+			overlay.Report(err)
+		}
+		refreshShared()
+	}
+
+	// A single generation runs at a time, changes arriving meanwhile are merged into the next runs
+	queue := lib.NewRunQueue(generate)
+	onChange := func(path string) {
+		queue.Trigger(runKey(path), []string{path})
+	}
+
+	if debounce > 0 {
+		// Coalesce events per service directory when regenerating incrementally, otherwise into a single full run
+		debouncer := lib.NewDebouncer(debounce, func(paths []string) {
+			queue.Trigger(runKey(paths[0]), paths)
+		})
+		onChange = func(path string) {
+			debouncer.Trigger(runKey(path), path)
+		}
+	}
+
+	ignore, err := lib.LoadIgnoreMatcher(appPath, opts.OutputDir, ignorePatterns)
+	if err != nil {
+		fatal("Failed to load ignore patterns", "error", err)
+	}
+
+	if runner != nil {
+		if _, err := lib.Generate(context.Background(), appPath, nil, opts); err != nil {
+			slog.Error("Error generating services", "error", err)
+			printFailureSummary(err)
+		} else if err = runner.Restart(); err != nil {
+			slog.Error("Error starting app", "error", err)
+		}
+		defer runner.Stop()
+	}
+	if command != nil {
+		defer command.Stop()
+	}
+
+	files := []string{filepath.Join(appPath, "go.mod")}
+	if _, err := os.Stat(filepath.Join(appPath, "go.work")); err == nil {
+		files = append(files, filepath.Join(appPath, "go.work"))
+	}
+	if opts.Template != "" {
+		files = append(files, opts.Template)
+	}
+	watch(watchRoots, files, ignore, poll, reload, onChange)
+	// Let a running generation finish its writes before exiting
+	queue.Wait()
+}
+
+// sharedTypeDirs returns the shared type packages watched besides the services, none when they cannot be found
+func sharedTypeDirs(appPath string, opts lib.Options) []string {
+	dirs, err := lib.SharedTypeDirs(appPath, opts)
+	if err != nil {
+		slog.Warn("Unable to find the shared type packages of the services, only the services are watched", "error", err)
+	}
+	return dirs
+}
+
+// fatal logs an error with its attributes and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// logging holds the -log-level and -log-format flags, given before a subcommand or to any command
+var logging = struct {
+	level  string
+	format string
+}{level: "info", format: lib.LogFormatText}
+
+// subcommands are the commands of next-gen besides generating, which takes no subcommand
+var subcommands = map[string]bool{
+	"docs": true, "init": true, "clean": true, "list": true, "verify": true, "invoke": true,
+	"publish": true, "package": true, "version": true, "templates": true, "dev": true,
+}
+
+// splitSubcommand finds the subcommand of the arguments after the logging flags given before it, like
+// clean for -log-format json clean -dry-run, and applies those flags. It returns the leading flags and
+// the arguments of the subcommand, the subcommand is empty for a generation.
+func splitSubcommand(args []string) (subcommand string, leading []string, rest []string) {
+	fs := flag.NewFlagSet("next-gen", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	level, format := logging.level, logging.format
+	fs.StringVar(&level, "log-level", level, "")
+	fs.StringVar(&format, "log-format", format, "")
+	if fs.Parse(args) != nil || fs.NArg() == 0 || !subcommands[fs.Arg(0)] {
+		return "", nil, args
+	}
+	logging.level, logging.format = level, format
+	return fs.Arg(0), args[:len(args)-fs.NArg()], fs.Args()[1:]
+}
+
+// addLogFlags registers the logging flags on a flag set
+func addLogFlags(fs *flag.FlagSet) {
+	fs.StringVar(&logging.level, "log-level", logging.level, "log level: debug, info, warn or error")
+	fs.StringVar(&logging.format, "log-format", logging.format, "log format: text or json")
+}
+
+// setupLogging makes the logger of the logging flags the default logger
+func setupLogging() {
+	logger, err := lib.NewLogger(os.Stderr, logging.level, logging.format)
+	if err != nil {
+		fatal("Invalid logging flags", "error", err)
+	}
+	slog.SetDefault(logger)
+}
+
+// parseFlags parses the flags of a subcommand along with the logging flags, which apply at once
+func parseFlags(fs *flag.FlagSet, args []string) {
+	addLogFlags(fs)
+	_ = fs.Parse(args)
+	setupLogging()
+}
+
+// rejectArgs exits when arguments are left after the flags of a subcommand taking none, a flag given
+// after them would be ignored otherwise
+func rejectArgs(fs *flag.FlagSet) {
+	if fs.NArg() > 0 {
+		fatal("Unexpected arguments, flags go before them", "command", fs.Name(), "args", strings.Join(fs.Args(), " "))
+	}
+}
+
+// isFlagSet reports whether a flag was passed on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
 	})
+	return set
+}
+
+// ensureSDK checks the app requires a compatible polycode SDK, and OpenTelemetry for -metrics, and
+// optionally adds or upgrades them
+func ensureSDK(appPath string, opts lib.Options, bootstrap bool) {
+	req, err := lib.CheckSDKRequirement(appPath, opts)
+	ensureRequirement(appPath, "SDK", req, err, bootstrap)
+	if opts.Metrics {
+		req, err = lib.CheckModuleRequirement(appPath, lib.OTelModule, "")
+		ensureRequirement(appPath, "OpenTelemetry", req, err, bootstrap)
+	}
+}
+
+// ensureRequirement warns when go.mod does not require a compatible version of a dependency of the
+// generated code, or runs go get with bootstrap
+func ensureRequirement(appPath string, name string, req lib.ModuleRequirement, err error, bootstrap bool) {
+	if err != nil {
+		slog.Warn("Unable to check "+name+" requirement", "error", err)
+		return
+	}
+	if req.Satisfied() {
+		return
+	}
+
+	if !bootstrap {
+		if req.Module == "" {
+			slog.Warn("go.mod does not require "+name+", the generated code will not compile. Re-run with -bootstrap-sdk or run the suggested command", "import", req.Import, "run", req.GetCommand())
+		} else {
+			slog.Warn("go.mod requires "+name+" older than the minimum version, the generated code may not compile. Re-run with -bootstrap-sdk or run the suggested command",
+				"module", req.Module, "required", req.Version, "minimum", req.Minimum, "run", req.GetCommand())
+		}
+		return
+	}
+
+	slog.Info("go.mod does not require a compatible "+name+". Running go get...", "run", req.GetCommand())
+	if err := lib.BootstrapRequirement(appPath, req); err != nil {
+		fatal("Failed to bootstrap "+name, "error", err)
+	}
+	slog.Info(name + " successfully added.")
+}
+
+// runDocs handles the `docs serve` subcommand
+func runDocs(cwd string, args []string) {
+	if len(args) == 0 || args[0] != "serve" {
+		fatal("Usage: next-gen docs serve [-f app path] [-addr host:port] [-invoke-url url]")
+	}
+
+	var appPath, addr, invokeURL, outputDir string
+	fs := flag.NewFlagSet("docs serve", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&outputDir, "output-dir", "", "folder holding the generated code (default from next-gen.yaml or .polycode)")
+	fs.StringVar(&addr, "addr", "localhost:7070", "address to serve the docs on")
+	fs.StringVar(&invokeURL, "invoke-url", "", "base url of the running app used by the try-it form")
+	parseFlags(fs, args[1:])
+	rejectArgs(fs)
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	if outputDir != "" {
+		opts.OutputDir = outputDir
+	}
+	if err = opts.Validate(); err != nil {
+		fatal("Invalid options", "error", err)
+	}
+
+	// The services of a go.work app are generated into the output folder of their module
+	outputPaths, err := lib.OutputFolders(appPath, opts)
+	if err != nil {
+		fatal("Failed to resolve output folders", "error", err)
+	}
+	if err := lib.ServeDocs(outputPaths, addr, invokeURL); err != nil {
+		fatal("Docs server failed", "error", err)
+	}
+}
+
+// runInit handles the `init <service>` subcommand, it scaffolds a service and runs a first generation
+func runInit(cwd string, args []string) {
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	var appPath string
+	var withWorkflow bool
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.BoolVar(&withWorkflow, "with-workflow", false, "add a sample workflow function")
+	parseFlags(fs, args)
+	appPath = normalizeAppPath(appPath)
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fatal("Usage: next-gen init <service> [-with-workflow] [-f app path]")
+	}
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+
+	serviceDir, err := lib.ScaffoldService(appPath, name, withWorkflow, opts)
+	if err != nil {
+		fatal("Failed to scaffold service", "error", err)
+	}
+	slog.Info("Service created", "path", serviceDir)
+
+	generate(appPath, opts, false)
+}
+
+// runInvoke handles the `invoke <service> <method>` subcommand, it validates the JSON input against the
+// generated definition and calls the method on the running app
+func runInvoke(cwd string, args []string) {
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+
+	var appPath, data, url string
+	var timeout time.Duration
+	var skipValidation bool
+	fs := flag.NewFlagSet("invoke", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&data, "data", "", "JSON input of the method, @file reads it from a file and - from stdin")
+	fs.StringVar(&url, "url", "", "base url of the running app (default from dev.url in next-gen.yaml or "+lib.DefaultInvokeURL+")")
+	fs.DurationVar(&timeout, "timeout", time.Minute, "time to wait for the response")
+	fs.BoolVar(&skipValidation, "no-validate", false, "send the input without checking it against the definition")
+	parseFlags(fs, args)
+	appPath = normalizeAppPath(appPath)
+
+	positional = append(positional, fs.Args()...)
+	if len(positional) != 2 {
+		fatal("Usage: next-gen invoke <service> <method> [-data json|@file|-] [-url url] [-f app path]")
+	}
+	service, method := positional[0], positional[1]
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	if url == "" {
+		url = config.Dev.URL
+	}
+	if url == "" {
+		url = lib.DefaultInvokeURL
+	}
+
+	input := []byte(data)
+	if name, ok := strings.CutPrefix(data, "@"); ok {
+		if input, err = os.ReadFile(name); err != nil {
+			fatal("Failed to read input", "error", err)
+		}
+	} else if data == "-" {
+		if input, err = io.ReadAll(os.Stdin); err != nil {
+			fatal("Failed to read input", "error", err)
+		}
+	}
+
+	def, methodDef, err := lib.FindMethod(appPath, opts, service, method)
+	if err != nil {
+		fatal("Failed to find method", "error", err)
+	}
+	if methodDef.InputStream || methodDef.OutputStream {
+		fatal("Streaming methods are not served over HTTP and cannot be invoked", "service", service, "method", methodDef.Name)
+	}
+	if !skipValidation {
+		if err = lib.ValidateInput(def, methodDef, input); err != nil {
+			fatal("Invalid input, use -no-validate to send it anyway", "input", methodDef.InputType, "error", err)
+		}
+	}
+
+	result, err := lib.Invoke(url, service, methodDef.Name, input, timeout)
+	if err != nil {
+		fatal("Invoke failed", "error", err)
+	}
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, result.Body, "", "  ") == nil {
+		result.Body = pretty.Bytes()
+	}
+	fmt.Println(strings.TrimSpace(string(result.Body)))
+	if result.Status < 200 || result.Status > 299 {
+		fatal("Method call failed", "status", result.Status)
+	}
+}
+
+// runPublish handles the `publish` subcommand, it uploads the generated definitions and schemas to the
+// registry of next-gen.yaml or of the flags
+func runPublish(cwd string, args []string) {
+	var appPath string
+	var dryRun bool
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	url := fs.String("url", "", "HTTP registry the files are uploaded to with PUT <url>/<file> (default publish.url from next-gen.yaml)")
+	s3 := fs.String("s3", "", "S3 location the files are uploaded to, like s3://bucket/prefix (default publish.s3 from next-gen.yaml)")
+	prefix := fs.String("prefix", "", "prefix of the uploaded keys, like the app name (default publish.prefix from next-gen.yaml)")
+	fs.BoolVar(&dryRun, "dry-run", false, "only list the files that would be published")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+
+	// A registry given on the command line replaces the configured one
+	publish := config.Publish
+	if *url != "" || *s3 != "" {
+		publish.URL, publish.S3 = *url, *s3
+	}
+	if *prefix != "" {
+		publish.Prefix = *prefix
+	}
+
+	report, err := lib.Publish(context.Background(), appPath, opts, publish, dryRun)
+	if report != nil && len(report.Files) > 0 {
+		fmt.Print(report.Summary())
+	}
+	if err != nil {
+		fatal("Failed to publish", "error", err)
+	}
+}
+
+// runPackage handles the `package` subcommand, it writes a multi-stage Dockerfile generating the wrappers,
+// building the app and copying its service definitions into the image
+func runPackage(cwd string, args []string) {
+	var appPath string
+	var pkg lib.PackageOptions
+	fs := flag.NewFlagSet("package", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&pkg.Main, "main", "", "package of the app binary, like ./cmd/app (default the app folder or its single cmd/<name> main package)")
+	fs.StringVar(&pkg.GoImage, "go-image", "", "image of the build stage (default golang:<go version of go.mod>)")
+	fs.StringVar(&pkg.BaseImage, "base-image", lib.DefaultBaseImage, "image the app binary runs in")
+	fs.BoolVar(&pkg.Force, "force", false, "overwrite a Dockerfile and .dockerignore not written by next-gen package")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+
+	result, err := lib.WritePackage(appPath, opts, pkg)
+	if err != nil {
+		fatal("Failed to write the Dockerfile", "error", err)
+	}
+	if len(result.Files) == 0 {
+		slog.Info("Dockerfile is up to date", "main", result.Main)
+		return
+	}
+	slog.Info("Dockerfile written, build the image with docker build", "files", strings.Join(result.Files, ", "), "main", result.Main)
+}
+
+// normalizeAppPath makes the app path given with -f absolute and clean, like app/ or .\app
+func normalizeAppPath(appPath string) string {
+	normalized, err := lib.NormalizeAppPath(appPath)
+	if err != nil {
+		fatal("Invalid app path", "error", err)
+	}
+	return normalized
+}
+
+// runVersion handles the `version` subcommand, it prints the version and commit stamped into generated files
+func runVersion() {
+	fmt.Println("next-gen", lib.Version())
+	if commit := lib.Commit(); commit != "" {
+		fmt.Println("commit", commit)
+	}
+}
+
+// runTemplates handles the `templates` subcommand, it writes the built-in wrapper template into a folder
+// as the starting point of -template-dir overrides
+func runTemplates(cwd string, args []string) {
+	var dir string
+	var force bool
+	fs := flag.NewFlagSet("templates", flag.ExitOnError)
+	fs.StringVar(&dir, "o", filepath.Join(cwd, "templates"), "folder the template is written to")
+	fs.BoolVar(&force, "force", false, "overwrite an existing template")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+
+	path := filepath.Join(dir, lib.WrapperTemplateName)
+	if _, err := os.Stat(path); err == nil && !force {
+		fatal("Template already exists, use -force to overwrite it", "path", path)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fatal("Failed to create template folder", "error", err)
+	}
+	if err := os.WriteFile(path, []byte(lib.BuiltinWrapperTemplate()), 0644); err != nil {
+		fatal("Failed to write template", "error", err)
+	}
+	slog.Info("Wrapper template written, generate with -template-dir to use it", "path", path, "contract", lib.TemplateContract)
+}
+
+// runClean handles the `clean` subcommand, it removes the generated files from the output folder
+func runClean(cwd string, args []string) {
+	var appPath, outputDir string
+	var dryRun bool
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&outputDir, "output-dir", "", "folder holding the generated code (default from next-gen.yaml or .polycode)")
+	fs.BoolVar(&dryRun, "dry-run", false, "only list the files that would be removed")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	if outputDir != "" {
+		opts.OutputDir = outputDir
+	}
+	// Clean removes files, an output folder outside the app is rejected like by the generating commands
+	if err = opts.Validate(); err != nil {
+		fatal("Invalid options", "error", err)
+	}
+
+	outputPaths, err := lib.OutputFolders(appPath, opts)
+	if err != nil {
+		fatal("Failed to resolve output folders", "error", err)
+	}
+
+	for _, outputPath := range outputPaths {
+		removed, kept, err := lib.CleanOutput(outputPath, dryRun)
+		if err != nil {
+			fatal("Failed to clean output folder", "path", outputPath, "error", err)
+		}
+
+		for _, file := range removed {
+			if dryRun {
+				slog.Info("Would remove", "file", file)
+			} else {
+				slog.Info("Removed", "file", file)
+			}
+		}
+		for _, file := range kept {
+			slog.Warn("Kept file not generated by next-gen", "file", file)
+		}
+		slog.Info("Clean finished", "path", outputPath, "removed", len(removed), "kept", len(kept), "dryRun", dryRun)
+	}
 }
 
-// isGoImportsAvailable checks if the `goimports` command is available
-func isGoImportsAvailable() bool {
-	_, err := exec.LookPath("goimports")
-	return err == nil
+// runList handles the `list` subcommand, it prints the services of the app with their methods and
+// input and output types without generating anything
+func runList(cwd string, args []string) {
+	var appPath, format string
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&format, "format", lib.ListFormatTable, "output format: table or json")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+
+	listings, err := lib.ListServices(appPath, opts)
+	if err != nil {
+		fatal("Failed to list services", "error", err)
+	}
+	out, err := lib.FormatServiceListings(listings, format)
+	if err != nil {
+		fatal("Failed to format services", "error", err)
+	}
+	fmt.Print(out)
 }
 
-// installGoImports installs the `goimports` tool using `go install`
-func installGoImports() error {
-	cmd := exec.Command("go", "install", "golang.org/x/tools/cmd/goimports@latest")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// runVerify handles the `verify` subcommand, it checks that the definitions of the output folder were
+// signed with the private half of a public key and not edited since
+func runVerify(cwd string, args []string) {
+	var appPath, keyPath string
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.StringVar(&appPath, "f", cwd, "app path")
+	fs.StringVar(&keyPath, "key", "", "Ed25519 public key (PKIX PEM) the definitions were signed for")
+	parseFlags(fs, args)
+	rejectArgs(fs)
+	if keyPath == "" {
+		fatal("Usage: next-gen verify -key public.pem [-f app path]")
+	}
+	appPath = normalizeAppPath(appPath)
+
+	opts := lib.DefaultOptions()
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		fatal("Failed to read verify key", "error", err)
+	}
+	key, err := lib.ParseVerifyKey(data)
+	if err != nil {
+		fatal("Invalid verify key", "error", err)
+	}
+	if err = lib.VerifyDefinitions(filepath.Join(appPath, opts.OutputDir), key); err != nil {
+		fatal("Definitions failed verification", "error", err)
+	}
+	slog.Info("Definitions verified")
 }
 
 func main() {
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("Failed to get current working directory: %v", err)
+		fatal("Failed to get current working directory", "error", err)
+	}
+
+	// Logging flags may come before the subcommand, like next-gen -log-format json clean
+	subcommand, leading, args := splitSubcommand(os.Args[1:])
+	setupLogging()
+
+	// `next-gen dev` is watch mode that also rebuilds and restarts the app
+	devServer := false
+	switch subcommand {
+	case "docs":
+		runDocs(cwd, args)
+		return
+	case "init":
+		runInit(cwd, args)
+		return
+	case "clean":
+		runClean(cwd, args)
+		return
+	case "list":
+		runList(cwd, args)
+		return
+	case "verify":
+		runVerify(cwd, args)
+		return
+	case "invoke":
+		runInvoke(cwd, args)
+		return
+	case "publish":
+		runPublish(cwd, args)
+		return
+	case "package":
+		runPackage(cwd, args)
+		return
+	case "version":
+		runVersion()
+		return
+	case "templates":
+		runTemplates(cwd, args)
+		return
+	case "dev":
+		devServer = true
+		os.Args = append(append([]string{os.Args[0]}, leading...), args...)
 	}
 
 	var appPath string
 	watch := flag.Bool("w", false, "watch for changes")
+	overlayAddr := flag.String("overlay", "", "serve a browser error overlay on this address in watch mode (e.g. localhost:7071)")
+	bootstrapSDK := flag.Bool("bootstrap-sdk", false, "run go get for the polycode SDK, and OpenTelemetry with -metrics, when go.mod does not require a compatible version")
+	opts := lib.DefaultOptions()
+	flag.StringVar(&opts.Format, "format", opts.Format, "formatter for generated code: goimports (built in, no install needed), gofmt, none or custom")
+	flag.StringVar(&opts.FormatCommand, "format-cmd", "", "formatter command used with -format custom")
+	flag.BoolVar(&opts.Production, "prod", opts.Production, "generate production wrappers exposing the @definition method")
+	dev := flag.Bool("dev", false, "generate development wrappers without the @definition method")
+	targets := flag.String("targets", strings.Join(opts.Targets, ","), "comma separated wrapper targets: "+strings.Join(opts.GeneratorNames(), ", "))
+	definitionFormats := flag.String("definition-format", strings.Join(opts.DefinitionFormats, ","), "comma separated formats of the service definitions: yaml, json, cbor")
+	analyzers := flag.String("analyze", "", "comma separated analyzers run after generation (vet or analyzer commands, e.g. vet,staticcheck)")
+	flag.StringVar(&opts.OutputDir, "output-dir", opts.OutputDir, "folder the generated code is written to, relative to the app path")
+	flag.StringVar(&opts.PackageName, "package", opts.PackageName, "Go package name of the generated wrappers")
+	flag.StringVar(&opts.SDKImport, "sdk-import", opts.SDKImport, "import path of the polycode package of the SDK the generated code depends on")
+	flag.StringVar(&opts.SDKVersion, "sdk-version", "", "minimum SDK version go.mod must require (e.g. v1.4.0), fetched by -bootstrap-sdk (default "+lib.DefaultSDKVersion+")")
+	flag.StringVar(&opts.TemplateDir, "template-dir", "", "folder of wrapper template overrides (wrapper.go.tmpl, <service>.go.tmpl), relative to the app path")
+	flag.BoolVar(&opts.OpenAPI, "openapi", false, "emit OpenAPI 3.1 specs under .polycode/openapi")
+	flag.BoolVar(&opts.AsyncAPI, "asyncapi", false, "emit AsyncAPI 3.0 specs of workflow trigger and result messages under .polycode/asyncapi")
+	flag.BoolVar(&opts.Routes, "routes", false, "generate the route table of the //polycode:http routes under .polycode/routes")
+	flag.BoolVar(&opts.GraphQL, "graphql", false, "emit a GraphQL schema of the services and resolvers calling them under .polycode/graphql")
+	incremental := flag.Bool("incremental", false, "in watch mode only regenerate the service whose files changed")
+	clients := flag.Bool("clients", false, "generate typed client packages under .polycode/clients")
+	activities := flag.Bool("activities", false, "generate typed activity stubs for calling services from workflows under .polycode/activities")
+	mocks := flag.Bool("mocks", false, "generate service mocks for unit tests under .polycode/mocks")
+	httpHandlers := flag.Bool("http", false, "generate net/http handlers serving POST /services/{service}/{method} under .polycode/http")
+	tsClient := flag.Bool("ts-client", false, "generate TypeScript HTTP clients under .polycode/ts-client")
+	debounce := flag.Duration("debounce", 0, "in watch mode wait for this quiet period before regenerating (e.g. 500ms)")
+	poll := flag.Duration("poll", 0, "in watch mode scan for changes at this interval instead of using file system notifications (e.g. 2s for NFS or Docker volumes)")
+	ignore := flag.String("ignore", "", "comma separated gitignore-style patterns the watcher skips, in addition to .gitignore")
+	flag.BoolVar(&opts.JSONSchema, "json-schema", false, "emit JSON Schema documents under .polycode/schema")
+	flag.BoolVar(&opts.SharedSchemas, "shared-schemas", false, "write struct types used by several services once under .polycode/schema/common instead of into each definition")
+	flag.BoolVar(&opts.Dependencies, "deps", false, "emit the service dependency graph as .polycode/dependencies.yml and .dot")
+	flag.BoolVar(&opts.Metrics, "metrics", false, "instrument ExecuteService and ExecuteWorkflow with OpenTelemetry spans and call, error and duration metrics")
+	flag.BoolVar(&opts.DebugDispatch, "debug-dispatch", false, "log every call dispatched by ExecuteService and ExecuteWorkflow at debug level")
+	profile := flag.String("profile", "", "generation profile: dev, staging, prod or one declared under profiles in next-gen.yaml, flags given on the command line take precedence")
+	flag.BoolVar(&opts.Strict, "strict", false, "fail services whose workflows call non-deterministic code (time.Now, rand, goroutines, network or file IO) or whose exported functions have unsupported parameter or result types instead of warning")
+	flag.BoolVar(&opts.ErrorCodes, "error-codes", false, "generate GetErrorCode in the wrappers, mapping declared service errors to their codes")
+	flag.BoolVar(&opts.GenTests, "gen-tests", false, "write table-driven test scaffolds into service folders that have none")
+	flag.BoolVar(&opts.NoCache, "no-cache", false, "regenerate every service, ignoring .polycode/cache.json")
+	flag.BoolVar(&opts.NoCompileCheck, "no-compile-check", false, "keep generated code that does not type-check instead of restoring the previous files")
+	flag.IntVar(&opts.Workers, "workers", opts.Workers, "number of services generated concurrently")
+	flag.StringVar(&opts.Report, "report", opts.Report, "write a machine-readable report of each run: json for .polycode/report.json")
+	flag.StringVar(&opts.SignKey, "sign-key", "", "Ed25519 private key (PKCS #8 PEM) the definitions are signed with")
+	flag.DurationVar(&opts.LockTimeout, "lock-timeout", opts.LockTimeout, "how long to wait for another next-gen run writing the same output folder, 0 exits at once")
+	dryRun := flag.Bool("dry-run", false, "print a diff of what would be generated without writing anything")
+	var customGenerators []string
+	plugins := flag.String("plugins", "", "comma separated Go plugins (.so) exporting a lib.Generator")
+	flag.Func("generator", "custom generator as name=command, may be repeated", func(value string) error {
+		name, command, ok := strings.Cut(value, "=")
+		if !ok || name == "" || command == "" {
+			return fmt.Errorf("expected name=command, got %q", value)
+		}
+		opts.RegisterGenerator(lib.NewCommandGenerator(name, command))
+		customGenerators = append(customGenerators, name)
+		return nil
+	})
+	buildCmd := flag.String("build-cmd", "", "command building the app in dev mode (default go build into a temporary folder)")
+	runCmd := flag.String("run-cmd", "", "command running the app in dev mode (default the binary built by the default build command)")
+	runAfter := flag.String("run", "", "in watch mode run this command after each successful regeneration, killing the previous invocation (e.g. \"go test ./services/...\")")
+	addLogFlags(flag.CommandLine)
+	quiet := flag.Bool("quiet", false, "do not print the per-service progress and the summary table, only warnings and errors are logged")
 	flag.StringVar(&appPath, "f", cwd, "app path")
 	flag.Parse()
 
-	// Check if `goimports` is installed
-	if !isGoImportsAvailable() {
-		log.Println("goimports is not installed. Installing now...")
+	// -quiet keeps warnings and errors unless a log level is asked for explicitly
+	if *quiet && !isFlagSet("log-level") {
+		logging.level = "warn"
+	}
+	setupLogging()
+	// Generating takes no arguments, a misplaced subcommand or a flag after an argument would be ignored
+	if flag.NArg() > 0 {
+		fatal("Unexpected arguments, subcommands go first and flags before arguments", "args", strings.Join(flag.Args(), " "))
+	}
+	appPath = normalizeAppPath(appPath)
 
-		// Attempt to install `goimports`
-		err := installGoImports()
-		if err != nil {
-			log.Fatalf("Failed to install goimports: %v. Please install it manually by running:\n\tgo install golang.org/x/tools/cmd/goimports@latest", err)
+	// next-gen.yaml provides the defaults, flags given on the command line take precedence: their values
+	// are set again once the config is applied. Flags like -generator act when parsed and are not kept.
+	given := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if _, ok := f.Value.(flag.Getter); ok {
+			given[f.Name] = f.Value.String()
+		}
+	})
+	config, err := lib.LoadConfig(appPath)
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err = config.Apply(&opts); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	if *profile != "" {
+		if err = config.ApplyProfile(*profile, &opts); err != nil {
+			fatal("Failed to apply profile", "error", err)
+		}
+	}
+	*targets = strings.Join(opts.Targets, ",")
+	*definitionFormats = strings.Join(opts.DefinitionFormats, ",")
+	*analyzers = strings.Join(opts.Analyzers, ",")
+	*incremental = config.Watch.Incremental
+	if config.Watch.Overlay != "" {
+		*overlayAddr = config.Watch.Overlay
+	}
+	if *debounce, err = config.Watch.DebounceDuration(); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	if *poll, err = config.Watch.PollInterval(); err != nil {
+		fatal("Failed to apply config", "file", lib.ConfigFileName, "error", err)
+	}
+	*buildCmd = config.Dev.Build
+	*runCmd = config.Dev.Run
+	*runAfter = config.Watch.Run
+	for name, value := range given {
+		if err = flag.Set(name, value); err != nil {
+			fatal("Invalid flag", "flag", name, "error", err)
+		}
+	}
+
+	if *plugins != "" {
+		for _, path := range strings.Split(*plugins, ",") {
+			generator, err := lib.LoadPlugin(path)
+			if err != nil {
+				fatal("Failed to load plugin", "error", err)
+			}
+			opts.RegisterGenerator(generator)
+			customGenerators = append(customGenerators, generator.Name())
+		}
+	}
+
+	if *analyzers != "" {
+		opts.Analyzers = strings.Split(*analyzers, ",")
+	}
+	if opts.TemplateDir != "" && !filepath.IsAbs(opts.TemplateDir) {
+		opts.TemplateDir = filepath.Join(appPath, opts.TemplateDir)
+	}
+
+	opts.Targets = strings.Split(*targets, ",")
+	opts.DefinitionFormats = strings.Split(*definitionFormats, ",")
+	if *clients && !slices.Contains(opts.Targets, lib.TargetClients) {
+		opts.Targets = append(opts.Targets, lib.TargetClients)
+	}
+	if *activities && !slices.Contains(opts.Targets, lib.TargetActivities) {
+		opts.Targets = append(opts.Targets, lib.TargetActivities)
+	}
+	if *mocks && !slices.Contains(opts.Targets, lib.TargetMocks) {
+		opts.Targets = append(opts.Targets, lib.TargetMocks)
+	}
+	if *httpHandlers && !slices.Contains(opts.Targets, lib.TargetHTTP) {
+		opts.Targets = append(opts.Targets, lib.TargetHTTP)
+	}
+	if *tsClient && !slices.Contains(opts.Targets, lib.TargetTSClient) {
+		opts.Targets = append(opts.Targets, lib.TargetTSClient)
+	}
+	for _, name := range customGenerators {
+		if !slices.Contains(opts.Targets, name) {
+			opts.Targets = append(opts.Targets, name)
 		}
+	}
 
-		log.Println("goimports successfully installed.")
+	if *dev {
+		if isFlagSet("prod") && opts.Production {
+			fatal("-prod and -dev cannot be used together")
+		}
+		opts.Production = false
+	}
+
+	if *dryRun {
+		if *watch || devServer {
+			fatal("-dry-run cannot be used in watch or dev mode")
+		}
+		runDryRun(appPath, opts)
+		return
 	}
 
-	if *watch {
-		watchAndGenerate(appPath)
+	ensureSDK(appPath, opts, *bootstrapSDK)
+
+	// The command of watch.run in next-gen.yaml is left alone by a single run
+	if isFlagSet("run") && !*watch && !devServer {
+		fatal("-run requires watch or dev mode")
+	}
+	var command *lib.AppRunner
+	if *runAfter != "" && (*watch || devServer) {
+		if command, err = lib.NewCommandRunner(appPath, *runAfter); err != nil {
+			fatal("Invalid -run command", "error", err)
+		}
+	}
+
+	var runner *lib.AppRunner
+	if devServer {
+		if *buildCmd == "" && *runCmd == "" {
+			*buildCmd, *runCmd = lib.DefaultDevCommands(appPath)
+		}
+		if runner, err = lib.NewAppRunner(appPath, *buildCmd, *runCmd); err != nil {
+			fatal("Invalid dev commands", "error", err)
+		}
+	}
+
+	if *watch || devServer {
+		ignorePatterns := config.Watch.Ignore
+		if *ignore != "" {
+			ignorePatterns = append(ignorePatterns, strings.Split(*ignore, ",")...)
+		}
+		watchAndGenerate(appPath, opts, *overlayAddr, *incremental, *debounce, *poll, ignorePatterns, runner, command)
 	} else {
-		generate(appPath)
+		generate(appPath, opts, *quiet)
 	}
 }
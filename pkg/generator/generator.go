@@ -0,0 +1,350 @@
+// Package generator embeds next-gen in other build tools. It generates the polycode wrappers,
+// definitions and the other outputs the next-gen CLI writes, without shelling out to it.
+//
+//	gen, err := generator.New("path/to/app", generator.WithOutputDir("gen/polycode"), generator.WithProduction(false))
+//	if err != nil {
+//		return err
+//	}
+//	return gen.GenerateAll(ctx)
+//
+// Settings of next-gen.yaml in the app folder apply first, then the profile selected with WithProfile,
+// options given to New override them.
+package generator
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudimpl/next-gen/lib"
+	"slices"
+	"time"
+)
+
+// GenerationError lists the services that failed in a run, the other services are still generated.
+// Match it with errors.As.
+type GenerationError = lib.GenerationError
+
+// ServiceError is the failure of a single service, with the file and line of the cause when known
+type ServiceError = lib.ServiceError
+
+// LockError is returned when another run kept the output folder locked past the lock timeout
+type LockError = lib.LockError
+
+// Report is the outcome of a run, per service its status, method counts, files written and duration
+type Report = lib.Report
+
+// ServiceReport is the outcome of a single service in a Report
+type ServiceReport = lib.ServiceReport
+
+// GenerationReport is the content of .polycode/report.json written with WithReport
+type GenerationReport = lib.GenerationReport
+
+// ServiceListing is a service of the app with its methods, as listed by ListServices
+type ServiceListing = lib.ServiceListing
+
+// MethodListing is a method of a listed service
+type MethodListing = lib.MethodListing
+
+// CustomGenerator writes the files of a custom target, registered with WithGenerator. Its Generate
+// method is given the ServiceInfo and ServiceDefinition of each service.
+type CustomGenerator = lib.Generator
+
+// ServiceInfo is the parsed service a CustomGenerator generates from
+type ServiceInfo = lib.ServiceInfo
+
+// ServiceDefinition is the definition of a service, as written under .polycode/definition
+type ServiceDefinition = lib.ServiceDefinition
+
+// Formatters of the generated code
+const (
+	FormatGoImports = lib.FormatGoImports
+	FormatGofmt     = lib.FormatGofmt
+	FormatNone      = lib.FormatNone
+)
+
+// Option configures a Generator
+type Option func(*settings)
+
+// settings collects the options, they are applied once the config is loaded
+type settings struct {
+	skipConfig bool
+	profile    string
+	apply      []func(*lib.Options) error
+	targets    []string // Set by WithTargets, nil keeps the configured targets
+	generators []string // Names of the generators registered by WithGenerator
+}
+
+// Generator generates the services of an app
+type Generator struct {
+	appPath string
+	opts    lib.Options
+}
+
+// New returns a generator of the app at appPath, configured by its next-gen.yaml and then by options
+func New(appPath string, options ...Option) (*Generator, error) {
+	appPath, err := lib.NormalizeAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var s settings
+	for _, option := range options {
+		option(&s)
+	}
+
+	opts := lib.DefaultOptions()
+	var config lib.Config
+	if !s.skipConfig {
+		if config, err = lib.LoadConfig(appPath); err != nil {
+			return nil, err
+		}
+		if err = config.Apply(&opts); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", lib.ConfigFileName, err)
+		}
+	}
+	if s.profile != "" {
+		if err = config.ApplyProfile(s.profile, &opts); err != nil {
+			return nil, err
+		}
+	}
+	for _, apply := range s.apply {
+		if err := apply(&opts); err != nil {
+			return nil, err
+		}
+	}
+	// Targets are checked once every generator is registered, whatever the order of the options
+	if s.targets != nil {
+		opts.Targets = s.targets
+	}
+	for _, name := range s.generators {
+		if !slices.Contains(opts.Targets, name) {
+			opts.Targets = append(opts.Targets, name)
+		}
+	}
+	known := opts.GeneratorNames()
+	for _, target := range opts.Targets {
+		if !slices.Contains(known, target) {
+			return nil, fmt.Errorf("unknown target %q, expected one of %v", target, known)
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return &Generator{appPath: appPath, opts: opts}, nil
+}
+
+// option wraps a change of the options
+func option(apply func(opts *lib.Options) error) Option {
+	return func(s *settings) {
+		s.apply = append(s.apply, apply)
+	}
+}
+
+// WithoutConfig ignores the next-gen.yaml of the app, only the defaults and options apply
+func WithoutConfig() Option {
+	return func(s *settings) {
+		s.skipConfig = true
+	}
+}
+
+// WithOutputDir sets the folder the generated code is written to, relative to the app, .polycode by default
+func WithOutputDir(dir string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.OutputDir = dir
+		return nil
+	})
+}
+
+// WithPackageName sets the Go package name of the generated wrappers, _polycode by default
+func WithPackageName(name string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.PackageName = name
+		return nil
+	})
+}
+
+// WithProduction selects production wrappers, which expose the @definition method, the default
+func WithProduction(production bool) Option {
+	return option(func(opts *lib.Options) error {
+		opts.Production = production
+		return nil
+	})
+}
+
+// WithDefinitionFormats sets the formats of the service definitions: yaml, json or cbor
+func WithDefinitionFormats(formats ...string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.DefinitionFormats = formats
+		return nil
+	})
+}
+
+// WithSDKImport sets the import path of the polycode package of the SDK the generated code depends on,
+// for forks or vendored copies of the SDK
+func WithSDKImport(importPath string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.SDKImport = importPath
+		return nil
+	})
+}
+
+// WithMetrics instruments the wrappers with OpenTelemetry spans and call, error and duration metrics,
+// the app must require go.opentelemetry.io/otel
+func WithMetrics(metrics bool) Option {
+	return option(func(opts *lib.Options) error {
+		opts.Metrics = metrics
+		return nil
+	})
+}
+
+// WithDebugDispatch logs every call dispatched by the wrappers at debug level with log/slog
+func WithDebugDispatch(debug bool) Option {
+	return option(func(opts *lib.Options) error {
+		opts.DebugDispatch = debug
+		return nil
+	})
+}
+
+// WithProfile selects a generation profile, dev, staging, prod or one declared under profiles in
+// next-gen.yaml. It applies after the config and before the other options.
+func WithProfile(name string) Option {
+	return func(s *settings) {
+		s.profile = name
+	}
+}
+
+// WithStrict fails the services whose workflows call non-deterministic code like time.Now or whose
+// exported functions cannot be exposed, like functions taking anonymous structs, instead of logging warnings
+func WithStrict(strict bool) Option {
+	return option(func(opts *lib.Options) error {
+		opts.Strict = strict
+		return nil
+	})
+}
+
+// WithTemplate replaces the built-in Go wrapper template by the text/template file at path
+func WithTemplate(path string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.Template = path
+		return nil
+	})
+}
+
+// WithTemplateDir uses the wrapper template overrides of a folder, wrapper.go.tmpl for every service and
+// <service>.go.tmpl for a single one, services without an override use the built-in template
+func WithTemplateDir(dir string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.TemplateDir = dir
+		return nil
+	})
+}
+
+// WithTargets sets the targets wrappers are generated for, like go, clients, activities or typescript.
+// The generators registered with WithGenerator are generated as well.
+func WithTargets(targets ...string) Option {
+	return func(s *settings) {
+		s.targets = targets
+	}
+}
+
+// WithGenerator registers a custom generator with this generator only and selects it as a target
+func WithGenerator(generator CustomGenerator) Option {
+	return func(s *settings) {
+		s.apply = append(s.apply, func(opts *lib.Options) error {
+			opts.RegisterGenerator(generator)
+			return nil
+		})
+		s.generators = append(s.generators, generator.Name())
+	}
+}
+
+// WithServicesDirs sets the folders holding the service packages, relative to the app
+func WithServicesDirs(dirs ...string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.ServicesDirs = dirs
+		return nil
+	})
+}
+
+// WithFormat selects the formatter of the generated code: FormatGoImports, FormatGofmt or FormatNone
+func WithFormat(format string) Option {
+	return option(func(opts *lib.Options) error {
+		if format != FormatGoImports && format != FormatGofmt && format != FormatNone {
+			return fmt.Errorf("unknown formatter %q", format)
+		}
+		opts.Format = format
+		return nil
+	})
+}
+
+// WithWorkers sets how many services are generated concurrently
+func WithWorkers(workers int) Option {
+	return option(func(opts *lib.Options) error {
+		if workers < 1 {
+			return fmt.Errorf("workers must be at least 1, got %d", workers)
+		}
+		opts.Workers = workers
+		return nil
+	})
+}
+
+// WithoutCache regenerates every service, even those whose inputs did not change since the last run
+func WithoutCache() Option {
+	return option(func(opts *lib.Options) error {
+		opts.NoCache = true
+		return nil
+	})
+}
+
+// WithLockTimeout sets how long a run waits for another run writing the same output folder, like a
+// watcher of the CLI. A zero timeout fails at once with a *LockError.
+func WithLockTimeout(timeout time.Duration) Option {
+	return option(func(opts *lib.Options) error {
+		if timeout < 0 {
+			return fmt.Errorf("lock timeout must not be negative, got %s", timeout)
+		}
+		opts.LockTimeout = timeout
+		return nil
+	})
+}
+
+// WithReport writes .polycode/report.json after each run, listing per service its outcome, warnings,
+// skipped functions and the sha256 of each file
+func WithReport() Option {
+	return option(func(opts *lib.Options) error {
+		opts.Report = lib.ReportJSON
+		return nil
+	})
+}
+
+// WithSignKey signs the definitions with the Ed25519 private key of a PKCS #8 PEM file, embedding their
+// hash and signature for next-gen verify. A relative path is resolved against the working directory.
+func WithSignKey(path string) Option {
+	return option(func(opts *lib.Options) error {
+		opts.SignKey = path
+		return nil
+	})
+}
+
+// GenerateAll generates every service of the app. Services not started when ctx is done are skipped
+// and its error is returned.
+func (g *Generator) GenerateAll(ctx context.Context) error {
+	_, err := lib.Generate(ctx, g.appPath, nil, g.opts)
+	return err
+}
+
+// GenerateAllReport generates every service like GenerateAll and reports the outcome of each of them,
+// the report is returned along with a *GenerationError when some services failed
+func (g *Generator) GenerateAllReport(ctx context.Context) (*Report, error) {
+	return lib.Generate(ctx, g.appPath, nil, g.opts)
+}
+
+// ListServices parses the services of the app and returns their methods without generating anything
+func (g *Generator) ListServices() ([]ServiceListing, error) {
+	return lib.ListServices(g.appPath, g.opts)
+}
+
+// GenerateService regenerates a single service, name is the service name like billing-invoices
+func (g *Generator) GenerateService(ctx context.Context, name string) error {
+	_, err := lib.Generate(ctx, g.appPath, []string{name}, g.opts)
+	return err
+}